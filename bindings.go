@@ -1,9 +1,10 @@
 package reindexer
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,7 +20,7 @@ const (
 	modeDelete = bindings.ModeDelete
 )
 
-func (db *Reindexer) modifyItem(namespace string, ns *reindexerNamespace, item interface{}, json []byte, mode int, precepts ...string) (count int, err error) {
+func (db *Reindexer) modifyItem(ctx context.Context, namespace string, ns *reindexerNamespace, item interface{}, json []byte, mode int, precepts ...string) (count int, err error) {
 
 	if ns == nil {
 		ns, err = db.getNS(namespace)
@@ -39,37 +40,44 @@ func (db *Reindexer) modifyItem(namespace string, ns *reindexerNamespace, item i
 			return
 		}
 
-		out, err := db.binding.ModifyItem(ns.nsHash, ns.name, format, ser.Bytes(), mode, precepts, stateToken, 0)
-
-		if err != nil {
-			rerr, ok := err.(bindings.Error)
-			if ok && rerr.Code() == bindings.ErrStateInvalidated {
-				db.Query(ns.name).Limit(0).Exec().Close()
-				err = rerr
-				continue
-			}
-			return 0, err
+		count, err = db.modifyPackedItem(ctx, ns, format, ser.Bytes(), stateToken, mode, precepts)
+		if isStateInvalidated(err) {
+			db.Query(ns.name).Limit(0).ExecCtx(ctx).Close()
+			continue
 		}
+		return count, err
+	}
+	return 0, err
+}
 
-		defer out.Free()
+// modifyPackedItem sends already packed item to reindexer, and drops it from objects cache
+func (db *Reindexer) modifyPackedItem(ctx context.Context, ns *reindexerNamespace, format int, data []byte, stateToken int, mode int, precepts []string) (count int, err error) {
 
-		rdSer := newSerializer(out.GetBuf())
-		rawQueryParams := rdSer.readRawQueryParams(func(nsid int) {
-			ns.cjsonState.ReadPayloadType(&rdSer.Serializer)
-		})
+	out, err := db.binding.ModifyItem(ctx, ns.nsHash, ns.name, format, data, mode, precepts, stateToken, 0)
+	if err != nil {
+		return 0, err
+	}
 
-		if rawQueryParams.count == 0 {
-			return 0, err
-		}
+	defer out.Free()
 
-		resultp := rdSer.readRawtItemParams()
+	rdSer := newSerializer(out.GetBuf())
+	rawQueryParams := rdSer.readRawQueryParams(func(nsid int) {
+		ns.cjsonState.ReadPayloadType(&rdSer.Serializer)
+	})
 
-		ns.cacheLock.Lock()
-		delete(ns.cacheItems, resultp.id)
-		ns.cacheLock.Unlock()
-		return rawQueryParams.count, err
+	if rawQueryParams.count == 0 {
+		return 0, nil
 	}
-	return 0, err
+
+	resultp := rdSer.readRawtItemParams()
+
+	ns.cacheItems.remove(resultp.id)
+	return rawQueryParams.count, nil
+}
+
+func isStateInvalidated(err error) bool {
+	rerr, ok := err.(bindings.Error)
+	return ok && rerr.Code() == bindings.ErrStateInvalidated
 }
 
 func packItem(ns *reindexerNamespace, item interface{}, json []byte, ser *cjson.Serializer) (format int, stateToken int, err error) {
@@ -83,10 +91,17 @@ func packItem(ns *reindexerNamespace, item interface{}, json []byte, ser *cjson.
 		if t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
-		if ns.rtype.Name() != t.Name() {
+		if ns.rtype.Name() != t.Name() || (ns.rtype == dynamicItemType) != (t == dynamicItemType) {
 			panic(ErrWrongType)
 		}
 
+		if ns.jsonItems {
+			// Binding does not support CJSON, so item is packed to JSON. Field names are the same as in CJSON
+			format = bindings.FormatJson
+			err = marshalItem(item, ser)
+			return
+		}
+
 		format = bindings.FormatCJson
 
 		enc := ns.cjsonState.NewEncoder()
@@ -102,6 +117,15 @@ func packItem(ns *reindexerNamespace, item interface{}, json []byte, ser *cjson.
 	return
 }
 
+func marshalItem(item interface{}, ser *cjson.Serializer) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	ser.Write(data)
+	return nil
+}
+
 func (db *Reindexer) getNS(namespace string) (*reindexerNamespace, error) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
@@ -113,12 +137,20 @@ func (db *Reindexer) getNS(namespace string) (*reindexerNamespace, error) {
 }
 
 func (db *Reindexer) PutMeta(namespace, key string, data []byte) error {
-	return db.binding.PutMeta(namespace, key, string(data))
+	return db.PutMetaCtx(context.Background(), namespace, key, data)
+}
+
+func (db *Reindexer) PutMetaCtx(ctx context.Context, namespace, key string, data []byte) error {
+	return db.binding.PutMeta(ctx, namespace, key, string(data))
 }
 
 func (db *Reindexer) GetMeta(namespace, key string) ([]byte, error) {
+	return db.GetMetaCtx(context.Background(), namespace, key)
+}
+
+func (db *Reindexer) GetMetaCtx(ctx context.Context, namespace, key string) ([]byte, error) {
 
-	out, err := db.binding.GetMeta(namespace, key)
+	out, err := db.binding.GetMeta(ctx, namespace, key)
 	if err != nil {
 		return nil, err
 	}
@@ -128,53 +160,74 @@ func (db *Reindexer) GetMeta(namespace, key string) ([]byte, error) {
 	return ret, nil
 }
 
-func unpackItem(ns *nsArrayEntry, params *rawResultItemParams, allowUnsafe bool, nonCacheableData bool) (item interface{}, err error) {
-	useCache := (ns.deepCopyIface || allowUnsafe) && !nonCacheableData && ns.cacheItems != nil
+// EnumMeta returns list of meta keys of namespace
+func (db *Reindexer) EnumMeta(namespace string) ([]string, error) {
+	return db.EnumMetaCtx(context.Background(), namespace)
+}
+
+func (db *Reindexer) EnumMetaCtx(ctx context.Context, namespace string) ([]string, error) {
+	return db.binding.EnumMeta(ctx, namespace)
+}
+
+// ListNamespaces returns definitions of all namespaces of database, including namespaces, which are not opened by this client
+func (db *Reindexer) ListNamespaces() ([]bindings.NamespaceDef, error) {
+	return db.ListNamespacesCtx(context.Background())
+}
+
+func (db *Reindexer) ListNamespacesCtx(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	return db.binding.EnumNamespaces(ctx)
+}
+
+// CreateDatabase creates new database on server. Supported by cproto and builtinserver bindings, and by builtin binding with storage,
+// which creates database in the parent directory of its storage
+func (db *Reindexer) CreateDatabase(dbName string) error {
+	return db.CreateDatabaseCtx(context.Background(), dbName)
+}
+
+func (db *Reindexer) CreateDatabaseCtx(ctx context.Context, dbName string) error {
+	return db.binding.CreateDatabase(ctx, dbName)
+}
+
+// DropDatabase drops database with all its namespaces on server. Supported by the same bindings as CreateDatabase
+func (db *Reindexer) DropDatabase(dbName string) error {
+	return db.DropDatabaseCtx(context.Background(), dbName)
+}
+
+func (db *Reindexer) DropDatabaseCtx(ctx context.Context, dbName string) error {
+	return db.binding.DropDatabase(ctx, dbName)
+}
+
+// unpackItem decodes item of result. If dest is not nil, it's pointer to item of namespace type, and item, which is not cached, is decoded into it
+func unpackItem(ns *nsArrayEntry, params *rawResultItemParams, allowUnsafe bool, nonCacheableData bool, dest interface{}) (item interface{}, err error) {
+	useCache := (ns.deepCopyIface || allowUnsafe) && !nonCacheableData
 	needCopy := ns.deepCopyIface && !allowUnsafe
 
 	if useCache {
-		ns.cacheLock.RLock()
-		if citem, ok := ns.cacheItems[params.id]; ok && citem.version == params.version {
-			item = citem.item
-		}
-		ns.cacheLock.RUnlock()
+		item = ns.cacheItems.get(params.id, params.version)
 	}
 
 	if item == nil {
-		if useCache {
-			ns.cacheLock.Lock()
-			if citem, ok := ns.cacheItems[params.id]; ok && citem.version == params.version {
-				item = citem.item
-			} else {
-				item = reflect.New(ns.rtype).Interface()
-				dec := ns.localCjsonState.NewDecoder()
-				if params.cptr != 0 {
-					err = dec.DecodeCPtr(params.cptr, item)
-				} else if params.data != nil {
-					err = dec.Decode(params.data, item)
-				} else {
-					panic(fmt.Errorf("Internal error while decoding item id %d from ns %s: cptr and data are both null", params.id, ns.name))
-				}
-				if err != nil {
-					ns.cacheLock.Unlock()
-					return
-				}
-				ns.cacheItems[params.id] = cacheItem{item: item, version: params.version}
-			}
-			ns.cacheLock.Unlock()
+		if dest != nil && !useCache {
+			item = dest
 		} else {
 			item = reflect.New(ns.rtype).Interface()
-			dec := ns.localCjsonState.NewDecoder()
-			if params.cptr != 0 {
-				err = dec.DecodeCPtr(params.cptr, item)
-			} else if params.data != nil {
-				err = dec.Decode(params.data, item)
-			} else {
-				panic(fmt.Errorf("Internal error while decoding item id %d from ns %s: cptr and data are both null", params.id, ns.name))
-			}
-			if err != nil {
-				return
-			}
+		}
+		dec := ns.localCjsonState.NewDecoder()
+		if params.isJSON {
+			err = json.Unmarshal(params.data, item)
+		} else if params.cptr != 0 {
+			err = dec.DecodeCPtr(params.cptr, item)
+		} else if params.data != nil {
+			err = dec.Decode(params.data, item)
+		} else {
+			panic(fmt.Errorf("Internal error while decoding item id %d from ns %s: cptr and data are both null", params.id, ns.name))
+		}
+		if err != nil {
+			return
+		}
+		if useCache {
+			ns.cacheItems.put(params.id, params.version, item)
+		} else {
 			// Reset needCopy, because item already separate
 			needCopy = false
 		}
@@ -188,65 +241,27 @@ func unpackItem(ns *nsArrayEntry, params *rawResultItemParams, allowUnsafe bool,
 		}
 	}
 
-	return
+	return ns.userItem(item), nil
 }
 
-func (db *Reindexer) rawResultToJson(rawResult []byte, jsonName string, totalName string, initJson []byte, initOffsets []int) (json []byte, offsets []int, explain []byte, err error) {
-
-	ser := newSerializer(rawResult)
-	rawQueryParams := ser.readRawQueryParams()
-	explain = rawQueryParams.explainResults
-
-	jsonReserveLen := len(rawResult) + len(totalName) + len(jsonName) + 20
-	if cap(initJson) < jsonReserveLen {
-		initJson = make([]byte, 0, jsonReserveLen)
-	} else {
-		initJson = initJson[:0]
-	}
-	jsonBuf := cjson.NewSerializer(initJson)
+func (db *Reindexer) prepareQuery(ctx context.Context, q *Query, asJson bool) (result bindings.RawBuffer, err error) {
 
-	if cap(initOffsets) < rawQueryParams.count {
-		offsets = make([]int, 0, rawQueryParams.count)
+	if ns, err := db.getNS(q.Namespace); err == nil {
+		q.nsArray = append(q.nsArray, nsArrayEntry{ns, ns.cjsonState.Copy()})
 	} else {
-		offsets = initOffsets[:0]
+		return nil, err
 	}
 
-	jsonBuf.WriteString("{\"")
-
-	if len(totalName) != 0 && rawQueryParams.totalcount != 0 {
-		jsonBuf.WriteString(totalName)
-		jsonBuf.WriteString("\":")
-		jsonBuf.WriteString(strconv.Itoa(rawQueryParams.totalcount))
-		jsonBuf.WriteString(",\"")
+	q.putAggregations()
+	for _, sq := range q.joinQueries {
+		sq.putAggregations()
 	}
-
-	jsonBuf.WriteString(jsonName)
-	jsonBuf.WriteString("\":[")
-
-	for i := 0; i < rawQueryParams.count; i++ {
-		item := ser.readRawtItemParams()
-		if i != 0 {
-			jsonBuf.WriteString(",")
-		}
-		offsets = append(offsets, len(jsonBuf.Bytes()))
-		jsonBuf.Write(item.data)
-
-		if (rawQueryParams.flags&bindings.ResultsWithJoined) != 0 && ser.GetVarUInt() != 0 {
-			panic("Sorry, not implemented: Can't return join query results as json")
+	for _, mq := range q.mergedQueries {
+		mq.putAggregations()
+		for _, sq := range mq.joinQueries {
+			sq.putAggregations()
 		}
 	}
-	jsonBuf.WriteString("]}")
-
-	return jsonBuf.Bytes(), offsets, explain, nil
-}
-
-func (db *Reindexer) prepareQuery(q *Query, asJson bool) (result bindings.RawBuffer, err error) {
-
-	if ns, err := db.getNS(q.Namespace); err == nil {
-		q.nsArray = append(q.nsArray, nsArrayEntry{ns, ns.cjsonState.Copy()})
-	} else {
-		return nil, err
-	}
 
 	ser := q.ser
 	for _, sq := range q.mergedQueries {
@@ -296,12 +311,7 @@ func (db *Reindexer) prepareQuery(q *Query, asJson bool) (result bindings.RawBuf
 	for _, ns := range q.nsArray {
 		q.ptVersions = append(q.ptVersions, ns.localCjsonState.Version^ns.localCjsonState.StateToken)
 	}
-	fetchCount := q.fetchCount
-	if asJson {
-		// json iterator not support fetch queries
-		fetchCount = -1
-	}
-	result, err = db.binding.SelectQuery(ser.Bytes(), asJson, q.ptVersions, fetchCount)
+	result, err = db.binding.SelectQuery(ctx, ser.Bytes(), asJson, q.ptVersions, q.fetchCount)
 
 	if err == nil && result.GetBuf() == nil {
 		panic(fmt.Errorf("result.Buffer is nil"))
@@ -310,30 +320,28 @@ func (db *Reindexer) prepareQuery(q *Query, asJson bool) (result bindings.RawBuf
 }
 
 // Execute query
-func (db *Reindexer) execQuery(q *Query) *Iterator {
-	result, err := db.prepareQuery(q, false)
+func (db *Reindexer) execQuery(ctx context.Context, q *Query) *Iterator {
+	result, err := db.prepareQuery(ctx, q, false)
 	if err != nil {
 		return errIterator(err)
 	}
-	iter := newIterator(q, result, q.nsArray, q.joinToFields, q.joinHandlers, q.context)
+	iter := newIterator(ctx, q, result, q.nsArray, q.joinToFields, q.joinHandlers, q.context)
 	return iter
 }
 
-func (db *Reindexer) execJSONQuery(q *Query, jsonRoot string) *JSONIterator {
-	result, err := db.prepareQuery(q, true)
+func (db *Reindexer) execJSONQuery(ctx context.Context, q *Query, jsonRoot string) *JSONIterator {
+	result, err := db.prepareQuery(ctx, q, true)
 	if err != nil {
 		return errJSONIterator(err)
 	}
-	defer result.Free()
-	var explain []byte
-	q.json, q.jsonOffsets, explain, err = db.rawResultToJson(result.GetBuf(), jsonRoot, q.totalName, q.json, q.jsonOffsets)
-	if err != nil {
-		return errJSONIterator(err)
+	nsNames := make([]string, 0, len(q.nsArray))
+	for _, ns := range q.nsArray {
+		nsNames = append(nsNames, ns.name)
 	}
-	return newJSONIterator(q, q.json, q.jsonOffsets, explain)
+	return newJSONIterator(ctx, q, result, jsonRoot, q.totalName, nsNames)
 }
 
-func (db *Reindexer) prepareSQL(namespace, query string, asJson bool) (result bindings.RawBuffer, nsArray []nsArrayEntry, err error) {
+func (db *Reindexer) prepareSQL(ctx context.Context, namespace, query string, asJson bool) (result bindings.RawBuffer, nsArray []nsArrayEntry, err error) {
 	nsArray = make([]nsArrayEntry, 0, 3)
 	var ns *reindexerNamespace
 
@@ -348,68 +356,80 @@ func (db *Reindexer) prepareSQL(namespace, query string, asJson bool) (result bi
 		ptVersions = append(ptVersions, ns.localCjsonState.Version^ns.localCjsonState.StateToken)
 	}
 
-	result, err = db.binding.Select(query, asJson, ptVersions, defaultFetchCount)
+	result, err = db.binding.Select(ctx, query, asJson, ptVersions, defaultFetchCount)
 	return
 }
 
-func (db *Reindexer) execSQL(namespace, query string) *Iterator {
-	result, nsArray, err := db.prepareSQL(namespace, query, false)
+func (db *Reindexer) execSQL(ctx context.Context, namespace, query string) *Iterator {
+	result, nsArray, err := db.prepareSQL(ctx, namespace, query, false)
 	if err != nil {
 		return errIterator(err)
 	}
-	iter := newIterator(nil, result, nsArray, nil, nil, nil)
+	iter := newIterator(ctx, nil, result, nsArray, nil, nil, nil)
 	return iter
 }
 
-func (db *Reindexer) execSQLAsJSON(namespace string, query string) *JSONIterator {
-	result, _, err := db.prepareSQL(namespace, query, true)
+func (db *Reindexer) execSQLAsJSON(ctx context.Context, namespace string, query string, joined []string) *JSONIterator {
+	result, _, err := db.prepareSQL(ctx, namespace, query, true)
 	if err != nil {
 		return errJSONIterator(err)
 	}
-	defer result.Free()
-	json, jsonOffsets, explain, err := db.rawResultToJson(result.GetBuf(), namespace, "total", nil, nil)
+	return newJSONIterator(ctx, nil, result, namespace, "total", append([]string{namespace}, joined...))
+}
+
+// Execute query
+func (db *Reindexer) deleteQuery(ctx context.Context, q *Query) (int, error) {
+
+	ns, err := db.getNS(q.Namespace)
 	if err != nil {
-		return errJSONIterator(err)
+		return 0, err
 	}
-	return newJSONIterator(nil, json, jsonOffsets, explain)
+
+	result, err := db.binding.DeleteQuery(ctx, ns.nsHash, q.ser.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	defer result.Free()
+
+	return db.dropCachedResults(ns, result, "delete"), nil
 }
 
-// Execute query
-func (db *Reindexer) deleteQuery(q *Query) (int, error) {
+// Execute update query
+func (db *Reindexer) updateQuery(ctx context.Context, q *Query) (int, error) {
 
 	ns, err := db.getNS(q.Namespace)
 	if err != nil {
 		return 0, err
 	}
 
-	result, err := db.binding.DeleteQuery(ns.nsHash, q.ser.Bytes())
+	result, err := db.binding.UpdateQuery(ctx, ns.nsHash, q.ser.Bytes())
 	if err != nil {
 		return 0, err
 	}
 	defer result.Free()
 
+	return db.dropCachedResults(ns, result, "update"), nil
+}
+
+// dropCachedResults drops items of delete or update query result from objects cache, and returns count of items
+func (db *Reindexer) dropCachedResults(ns *reindexerNamespace, result bindings.RawBuffer, kind string) int {
 	ser := newSerializer(result.GetBuf())
 	// skip total count
 	rawQueryParams := ser.readRawQueryParams()
 
-	ns.cacheLock.Lock()
 	for i := 0; i < rawQueryParams.count; i++ {
 		params := ser.readRawtItemParams()
 		if (rawQueryParams.flags&bindings.ResultsWithJoined) != 0 && ser.GetVarUInt() != 0 {
-			panic("Internal error: joined items in delete query result")
+			panic("Internal error: joined items in " + kind + " query result")
 		}
 		// Update cache
-		if _, ok := ns.cacheItems[params.id]; ok {
-			delete(ns.cacheItems, params.id)
-		}
-
+		ns.cacheItems.remove(params.id)
 	}
-	ns.cacheLock.Unlock()
 	if !ser.Eof() {
-		panic("Internal error: data after end of delete query result")
+		panic("Internal error: data after end of " + kind + " query result")
 	}
 
-	return rawQueryParams.count, err
+	return rawQueryParams.count
 }
 
 func (db *Reindexer) resetCaches() {
@@ -420,11 +440,7 @@ func (db *Reindexer) resetCaches() {
 	}
 	db.lock.RUnlock()
 	for _, ns := range nsArray {
-		ns.cacheLock.Lock()
-		if ns.cacheItems != nil {
-			ns.cacheItems = make(map[int]cacheItem)
-		}
-		ns.cacheLock.Unlock()
+		ns.cacheItems.reset()
 		ns.cjsonState.Reset()
 		db.Query(ns.name).Limit(0).Exec().Close()
 	}
@@ -5,12 +5,17 @@ package builtin
 // #include <stdlib.h>
 import "C"
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"unicode"
 	"unsafe"
 
 	"github.com/restream/reindexer/bindings"
@@ -31,9 +36,14 @@ var logMtx sync.Mutex
 
 var bufPool sync.Pool
 
+var updatesHandlers = make(map[uintptr]bindings.UpdatesHandler)
+var updatesMtx sync.RWMutex
+
 type Builtin struct {
 	cgoLimiter chan struct{}
 	rx         C.uintptr_t
+	// storagePath is path to storage of database from DSN. Other databases are kept in the same parent directory
+	storagePath string
 }
 
 type RawCBuffer struct {
@@ -115,6 +125,14 @@ func buf2go(buf C.reindexer_resbuffer) []byte {
 	return (*[1 << 30]byte)(unsafe.Pointer(uintptr(buf.data)))[:length:length]
 }
 
+// ptVersions2c returns pointer to versions of payload types. Versions can be empty for JSON results
+func ptVersions2c(ptVersions []int32) *C.int32_t {
+	if len(ptVersions) == 0 {
+		return nil
+	}
+	return (*C.int32_t)(unsafe.Pointer(&ptVersions[0]))
+}
+
 func bool2cint(v bool) C.int {
 	if v {
 		return 1
@@ -150,6 +168,7 @@ func (binding *Builtin) Init(u *url.URL, options ...interface{}) error {
 		binding.cgoLimiter = make(chan struct{}, cgoLimit)
 	}
 	if len(u.Path) != 0 && u.Path != "/" {
+		binding.storagePath = u.Path
 		err := binding.EnableStorage(u.Path)
 		if err != nil {
 			return err
@@ -163,13 +182,30 @@ func (binding *Builtin) Clone() bindings.RawBinding {
 	return &Builtin{}
 }
 
-func (binding *Builtin) Ping() error {
+func (binding *Builtin) awaitLimiter(ctx context.Context) (withLimiter bool, err error) {
+	if err = ctx.Err(); err != nil || binding.cgoLimiter == nil {
+		return
+	}
+	select {
+	case binding.cgoLimiter <- struct{}{}:
+		withLimiter = true
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+func (binding *Builtin) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_ping(binding.rx))
 }
 
-func (binding *Builtin) ModifyItem(nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
-	if binding.cgoLimiter != nil {
-		binding.cgoLimiter <- struct{}{}
+func (binding *Builtin) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
 		defer func() { <-binding.cgoLimiter }()
 	}
 
@@ -190,7 +226,10 @@ func (binding *Builtin) ModifyItem(nsHash int, namespace string, format int, dat
 	return ret2go(C.reindexer_modify_item_packed(binding.rx, buf2c(packedArgs), buf2c(data)))
 }
 
-func (binding *Builtin) OpenNamespace(namespace string, enableStorage, dropOnFormatError bool, cacheMode uint8) error {
+func (binding *Builtin) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFormatError bool, cacheMode uint8) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	var storageOptions bindings.StorageOptions
 	storageOptions.Enabled(enableStorage).DropOnFileFormatError(dropOnFormatError)
 	opts := C.StorageOpts{
@@ -198,11 +237,17 @@ func (binding *Builtin) OpenNamespace(namespace string, enableStorage, dropOnFor
 	}
 	return err2go(C.reindexer_open_namespace(binding.rx, str2c(namespace), opts, C.uint8_t(cacheMode)))
 }
-func (binding *Builtin) CloseNamespace(namespace string) error {
+func (binding *Builtin) CloseNamespace(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_close_namespace(binding.rx, str2c(namespace)))
 }
 
-func (binding *Builtin) DropNamespace(namespace string) error {
+func (binding *Builtin) DropNamespace(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_drop_namespace(binding.rx, str2c(namespace)))
 }
 
@@ -214,7 +259,10 @@ func (binding *Builtin) EnableStorage(path string) error {
 	return err2go(C.reindexer_enable_storage(binding.rx, str2c(path)))
 }
 
-func (binding *Builtin) AddIndex(namespace string, indexDef bindings.IndexDef) error {
+func (binding *Builtin) AddIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	bIndexDef, err := json.Marshal(indexDef)
 	if err != nil {
 		return err
@@ -226,7 +274,10 @@ func (binding *Builtin) AddIndex(namespace string, indexDef bindings.IndexDef) e
 	return err
 }
 
-func (binding *Builtin) UpdateIndex(namespace string, indexDef bindings.IndexDef) error {
+func (binding *Builtin) UpdateIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	bIndexDef, err := json.Marshal(indexDef)
 	if err != nil {
 		return err
@@ -238,46 +289,182 @@ func (binding *Builtin) UpdateIndex(namespace string, indexDef bindings.IndexDef
 	return err
 }
 
-func (binding *Builtin) DropIndex(namespace, index string) error {
+func (binding *Builtin) DropIndex(ctx context.Context, namespace, index string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_drop_index(binding.rx, str2c(namespace), str2c(index)))
 }
 
-func (binding *Builtin) PutMeta(namespace, key, data string) error {
+func (binding *Builtin) PutMeta(ctx context.Context, namespace, key, data string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_put_meta(binding.rx, str2c(namespace), str2c(key), str2c(data)))
 }
 
-func (binding *Builtin) GetMeta(namespace, key string) (bindings.RawBuffer, error) {
+func (binding *Builtin) GetMeta(ctx context.Context, namespace, key string) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return ret2go(C.reindexer_get_meta(binding.rx, str2c(namespace), str2c(key)))
 }
 
-func (binding *Builtin) Select(query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
-	if binding.cgoLimiter != nil {
-		binding.cgoLimiter <- struct{}{}
+func (binding *Builtin) EnumMeta(ctx context.Context, namespace string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	buf, err := ret2go(C.reindexer_enum_meta(binding.rx, str2c(namespace)))
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	keys := []string{}
+	ser := cjson.NewSerializer(buf.GetBuf())
+	for !ser.Eof() {
+		keys = append(keys, ser.GetVString())
+	}
+	return keys, nil
+}
+
+func (binding *Builtin) EnumNamespaces(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	buf, err := ret2go(C.reindexer_enum_namespaces(binding.rx))
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	var namespaces struct {
+		Items []bindings.NamespaceDef `json:"items"`
+	}
+	if err = json.Unmarshal(buf.GetBuf(), &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces.Items, nil
+}
+
+func (binding *Builtin) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
 		defer func() { <-binding.cgoLimiter }()
 	}
-	return ret2go(C.reindexer_select(binding.rx, str2c(query), bool2cint(withItems), (*C.int32_t)(unsafe.Pointer(&ptVersions[0])), C.int(len(ptVersions))))
+	return ret2go(C.reindexer_select(binding.rx, str2c(query), bool2cint(withItems), ptVersions2c(ptVersions), C.int(len(ptVersions))))
 }
 
-func (binding *Builtin) SelectQuery(data []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
-	if binding.cgoLimiter != nil {
-		binding.cgoLimiter <- struct{}{}
+func (binding *Builtin) SelectQuery(ctx context.Context, data []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
 		defer func() { <-binding.cgoLimiter }()
 	}
-	return ret2go(C.reindexer_select_query(binding.rx, buf2c(data), bool2cint(withItems), (*C.int32_t)(unsafe.Pointer(&ptVersions[0])), C.int(len(ptVersions))))
+	return ret2go(C.reindexer_select_query(binding.rx, buf2c(data), bool2cint(withItems), ptVersions2c(ptVersions), C.int(len(ptVersions))))
 }
 
-func (binding *Builtin) DeleteQuery(nsHash int, data []byte) (bindings.RawBuffer, error) {
-	if binding.cgoLimiter != nil {
-		binding.cgoLimiter <- struct{}{}
+func (binding *Builtin) DeleteQuery(ctx context.Context, nsHash int, data []byte) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
 		defer func() { <-binding.cgoLimiter }()
 	}
 	return ret2go(C.reindexer_delete_query(binding.rx, buf2c(data)))
 }
 
-func (binding *Builtin) Commit(namespace string) error {
+func (binding *Builtin) UpdateQuery(ctx context.Context, nsHash int, data []byte) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
+		defer func() { <-binding.cgoLimiter }()
+	}
+	return ret2go(C.reindexer_update_query(binding.rx, buf2c(data)))
+}
+
+func (binding *Builtin) Commit(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return err2go(C.reindexer_commit(binding.rx, str2c(namespace)))
 }
 
+func (binding *Builtin) BeginTx(ctx context.Context, namespace string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	ret := C.reindexer_start_transaction(binding.rx, str2c(namespace))
+	if err := err2go(ret.err); err != nil {
+		return 0, err
+	}
+	return int(ret.tx_id), nil
+}
+
+func (binding *Builtin) CommitTx(ctx context.Context, txID int) (bindings.RawBuffer, error) {
+	if withLimiter, err := binding.awaitLimiter(ctx); err != nil {
+		return nil, err
+	} else if withLimiter {
+		defer func() { <-binding.cgoLimiter }()
+	}
+	return ret2go(C.reindexer_commit_transaction(binding.rx, C.int(txID)))
+}
+
+func (binding *Builtin) RollbackTx(ctx context.Context, txID int) error {
+	return err2go(C.reindexer_rollback_transaction(binding.rx, C.int(txID)))
+}
+
+// CreateDatabase creates database dbName, if it's not exists yet. Databases are kept in the parent directory of storage
+// of binding, as databases of reindexer server, e.g. for builtin:///var/lib/reindexer/db new database is created in
+// /var/lib/reindexer/dbName, and can be opened by builtin:///var/lib/reindexer/dbName
+func (binding *Builtin) CreateDatabase(ctx context.Context, dbName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := binding.databasePath(dbName)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return bindings.NewError(err.Error(), bindings.ErrLogic)
+	}
+	return nil
+}
+
+// DropDatabase removes storage of database dbName. Database of binding itself can't be dropped
+func (binding *Builtin) DropDatabase(ctx context.Context, dbName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := binding.databasePath(dbName)
+	if err != nil {
+		return err
+	}
+	if path == filepath.Clean(binding.storagePath) {
+		return bindings.NewError("Can't drop database, which is used by binding", bindings.ErrParams)
+	}
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		return bindings.NewError(fmt.Sprintf("Database %s not found", dbName), bindings.ErrParams)
+	}
+	if err = os.RemoveAll(path); err != nil {
+		return bindings.NewError(err.Error(), bindings.ErrLogic)
+	}
+	return nil
+}
+
+// databasePath returns path to storage of database dbName
+func (binding *Builtin) databasePath(dbName string) (string, error) {
+	if binding.storagePath == "" {
+		return "", bindings.NewError("Databases can't be created or dropped by builtin binding without storage", bindings.ErrLogic)
+	}
+	if dbName == "" || strings.IndexFunc(dbName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	}) != -1 {
+		return "", bindings.NewError("Database name contains invalid character. Only alphas, digits,'_','-, are allowed", bindings.ErrParams)
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(binding.storagePath)), dbName), nil
+}
+
 // CGoLogger logger function for C
 //export CGoLogger
 func CGoLogger(level int, msg string) {
@@ -302,7 +489,41 @@ func (binding *Builtin) DisableLogger() {
 	logger = nil
 }
 
+// CGoUpdates updates function for C
+//export CGoUpdates
+func CGoUpdates(rx uintptr, nsName string, mode int, data string) {
+	updatesMtx.RLock()
+	handler := updatesHandlers[rx]
+	updatesMtx.RUnlock()
+	if handler != nil {
+		handler(string([]byte(nsName)), mode, []byte(data))
+	}
+}
+
+func (binding *Builtin) SubscribeUpdates(ctx context.Context, handler bindings.UpdatesHandler) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	updatesMtx.Lock()
+	updatesHandlers[uintptr(binding.rx)] = handler
+	updatesMtx.Unlock()
+	return err2go(C.reindexer_subscribe_go_updates(binding.rx, 1))
+}
+
+func (binding *Builtin) UnsubscribeUpdates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	updatesMtx.Lock()
+	delete(updatesHandlers, uintptr(binding.rx))
+	updatesMtx.Unlock()
+	return err2go(C.reindexer_subscribe_go_updates(binding.rx, 0))
+}
+
 func (binding *Builtin) Finalize() error {
+	updatesMtx.Lock()
+	delete(updatesHandlers, uintptr(binding.rx))
+	updatesMtx.Unlock()
 	C.destroy_reindexer(binding.rx)
 	binding.rx = 0
 	return nil
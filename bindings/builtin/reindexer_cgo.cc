@@ -1,4 +1,3 @@
-
 #include "reindexer_cgo.h"
 #include <string.h>
 #include "core/cbinding/reindexer_c.h"
@@ -14,3 +13,12 @@ void reindexer_enable_go_logger() {
 }
 
 void reindexer_disable_go_logger() { reindexer_disable_logger(); }
+
+reindexer_error reindexer_subscribe_go_updates(uintptr_t rx, int subscribe) {
+	return reindexer_subscribe_updates(rx,
+									   [](uintptr_t rx, reindexer_string ns, int mode, reindexer_string data) {
+										   CGoUpdates((GoUintptr)rx, GoString{(const char *)ns.p, (GoInt)ns.n}, (GoInt)mode,
+													  GoString{(const char *)data.p, (GoInt)data.n});
+									   },
+									   subscribe);
+}
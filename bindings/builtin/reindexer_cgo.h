@@ -4,9 +4,14 @@
 extern "C" {
 #endif
 
+#include <stdint.h>
+#include "core/cbinding/reindexer_ctypes.h"
+
 void reindexer_enable_go_logger();
 void reindexer_disable_go_logger();
 
+reindexer_error reindexer_subscribe_go_updates(uintptr_t rx, int subscribe);
+
 #ifdef __cplusplus
 }
 #endif
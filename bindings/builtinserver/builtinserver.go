@@ -4,6 +4,7 @@ package builtinserver
 // #include <stdlib.h>
 import "C"
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"reflect"
@@ -45,9 +46,18 @@ type BuiltinServer struct {
 	builtin         bindings.RawBinding
 	wg              sync.WaitGroup
 	shutdownTimeout time.Duration
+	tlsProxy        *tlsProxy
+	// dbName, user and pass are database and credentials from DSN, which are used for CreateDatabase and DropDatabase
+	dbName string
+	user   string
+	pass   string
 }
 
 func (server *BuiltinServer) stopServer(timeout time.Duration) error {
+	if server.tlsProxy != nil {
+		server.tlsProxy.close()
+		server.tlsProxy = nil
+	}
 	if err := err2go(C.stop_reindexer_server()); err != nil {
 		return err
 	}
@@ -94,6 +104,17 @@ func (server *BuiltinServer) Init(u *url.URL, options ...interface{}) error {
 		}
 	}
 
+	if serverCfg.Net.TLSCert != "" {
+		// Config is copied, because RPC address is replaced by address of server behind TLS proxy
+		cfg := *serverCfg
+		proxy, err := newTLSProxy(&cfg.Net)
+		if err != nil {
+			return err
+		}
+		server.tlsProxy = proxy
+		serverCfg = &cfg
+	}
+
 	yamlStr, err := serverCfg.GetYamlString()
 	if err != nil {
 		return err
@@ -116,10 +137,12 @@ func (server *BuiltinServer) Init(u *url.URL, options ...interface{}) error {
 		time.Sleep(time.Second)
 	}
 
-	pass, _ := u.User.Password()
+	server.dbName = u.Host
+	server.user = u.User.Username()
+	server.pass, _ = u.User.Password()
 
 	var rx C.uintptr_t = 0
-	if err := err2go(C.get_reindexer_instance(str2c(u.Host), str2c(u.User.Username()), str2c(pass), &rx)); err != nil {
+	if err := err2go(C.get_reindexer_instance(str2c(server.dbName), str2c(server.user), str2c(server.pass), &rx)); err != nil {
 		return err
 	}
 
@@ -134,60 +157,111 @@ func (server *BuiltinServer) Clone() bindings.RawBinding {
 	return &BuiltinServer{}
 }
 
-func (server *BuiltinServer) OpenNamespace(namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error {
-	return server.builtin.OpenNamespace(namespace, enableStorage, dropOnFileFormatError, cacheMode)
+func (server *BuiltinServer) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error {
+	return server.builtin.OpenNamespace(ctx, namespace, enableStorage, dropOnFileFormatError, cacheMode)
 }
 
-func (server *BuiltinServer) CloseNamespace(namespace string) error {
-	return server.builtin.CloseNamespace(namespace)
+func (server *BuiltinServer) CloseNamespace(ctx context.Context, namespace string) error {
+	return server.builtin.CloseNamespace(ctx, namespace)
 }
 
-func (server *BuiltinServer) DropNamespace(namespace string) error {
-	return server.builtin.DropNamespace(namespace)
+func (server *BuiltinServer) DropNamespace(ctx context.Context, namespace string) error {
+	return server.builtin.DropNamespace(ctx, namespace)
 }
 
 func (server *BuiltinServer) EnableStorage(namespace string) error {
 	return server.builtin.EnableStorage(namespace)
 }
 
-func (server *BuiltinServer) AddIndex(namespace string, indexDef bindings.IndexDef) error {
-	return server.builtin.AddIndex(namespace, indexDef)
+func (server *BuiltinServer) AddIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	return server.builtin.AddIndex(ctx, namespace, indexDef)
+}
+
+func (server *BuiltinServer) UpdateIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	return server.builtin.UpdateIndex(ctx, namespace, indexDef)
+}
+
+func (server *BuiltinServer) DropIndex(ctx context.Context, namespace, index string) error {
+	return server.builtin.DropIndex(ctx, namespace, index)
+}
+
+func (server *BuiltinServer) PutMeta(ctx context.Context, namespace, key, data string) error {
+	return server.builtin.PutMeta(ctx, namespace, key, data)
+}
+
+func (server *BuiltinServer) GetMeta(ctx context.Context, namespace, key string) (bindings.RawBuffer, error) {
+	return server.builtin.GetMeta(ctx, namespace, key)
+}
+
+func (server *BuiltinServer) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, percepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
+	return server.builtin.ModifyItem(ctx, nsHash, namespace, format, data, mode, percepts, stateToken, txID)
 }
 
-func (server *BuiltinServer) UpdateIndex(namespace string, indexDef bindings.IndexDef) error {
-	return server.builtin.UpdateIndex(namespace, indexDef)
+func (server *BuiltinServer) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	return server.builtin.Select(ctx, query, withItems, ptVersions, fetchCount)
 }
 
-func (server *BuiltinServer) DropIndex(namespace, index string) error {
-	return server.builtin.DropIndex(namespace, index)
+func (server *BuiltinServer) SelectQuery(ctx context.Context, rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	return server.builtin.SelectQuery(ctx, rawQuery, withItems, ptVersions, fetchCount)
 }
 
-func (server *BuiltinServer) PutMeta(namespace, key, data string) error {
-	return server.builtin.PutMeta(namespace, key, data)
+func (server *BuiltinServer) DeleteQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	return server.builtin.DeleteQuery(ctx, nsHash, rawQuery)
 }
 
-func (server *BuiltinServer) GetMeta(namespace, key string) (bindings.RawBuffer, error) {
-	return server.builtin.GetMeta(namespace, key)
+func (server *BuiltinServer) SubscribeUpdates(ctx context.Context, handler bindings.UpdatesHandler) error {
+	return server.builtin.(bindings.RawBindingUpdates).SubscribeUpdates(ctx, handler)
 }
 
-func (server *BuiltinServer) ModifyItem(nsHash int, namespace string, format int, data []byte, mode int, percepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
-	return server.builtin.ModifyItem(nsHash, namespace, format, data, mode, percepts, stateToken, txID)
+func (server *BuiltinServer) UnsubscribeUpdates(ctx context.Context) error {
+	return server.builtin.(bindings.RawBindingUpdates).UnsubscribeUpdates(ctx)
 }
 
-func (server *BuiltinServer) Select(query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
-	return server.builtin.Select(query, withItems, ptVersions, fetchCount)
+func (server *BuiltinServer) UpdateQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	return server.builtin.UpdateQuery(ctx, nsHash, rawQuery)
 }
 
-func (server *BuiltinServer) SelectQuery(rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
-	return server.builtin.SelectQuery(rawQuery, withItems, ptVersions, fetchCount)
+func (server *BuiltinServer) Commit(ctx context.Context, namespace string) error {
+	return server.builtin.Commit(ctx, namespace)
+}
+
+func (server *BuiltinServer) BeginTx(ctx context.Context, namespace string) (int, error) {
+	return server.builtin.(bindings.RawBindingTx).BeginTx(ctx, namespace)
+}
+
+func (server *BuiltinServer) CommitTx(ctx context.Context, txID int) (bindings.RawBuffer, error) {
+	return server.builtin.(bindings.RawBindingTx).CommitTx(ctx, txID)
+}
+
+func (server *BuiltinServer) RollbackTx(ctx context.Context, txID int) error {
+	return server.builtin.(bindings.RawBindingTx).RollbackTx(ctx, txID)
+}
+
+// CreateDatabase creates database dbName in storage of server, if it's not exists yet
+func (server *BuiltinServer) CreateDatabase(ctx context.Context, dbName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return err2go(C.create_reindexer_database(str2c(dbName), str2c(server.user), str2c(server.pass)))
+}
+
+// DropDatabase drops database dbName from storage of server. Database of binding itself can't be dropped
+func (server *BuiltinServer) DropDatabase(ctx context.Context, dbName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if dbName == server.dbName {
+		return bindings.NewError("Can't drop database, which is used by binding", bindings.ErrParams)
+	}
+	return err2go(C.drop_reindexer_database(str2c(dbName), str2c(server.user), str2c(server.pass)))
 }
 
-func (server *BuiltinServer) DeleteQuery(nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
-	return server.builtin.DeleteQuery(nsHash, rawQuery)
+func (server *BuiltinServer) EnumNamespaces(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	return server.builtin.EnumNamespaces(ctx)
 }
 
-func (server *BuiltinServer) Commit(namespace string) error {
-	return server.builtin.Commit(namespace)
+func (server *BuiltinServer) EnumMeta(ctx context.Context, namespace string) ([]string, error) {
+	return server.builtin.EnumMeta(ctx, namespace)
 }
 
 func (server *BuiltinServer) EnableLogger(logger bindings.Logger) {
@@ -212,6 +286,6 @@ func (server *BuiltinServer) Status() (status bindings.Status) {
 	return server.builtin.Status()
 }
 
-func (server *BuiltinServer) Ping() error {
-	return server.builtin.Ping()
+func (server *BuiltinServer) Ping(ctx context.Context) error {
+	return server.builtin.Ping(ctx)
 }
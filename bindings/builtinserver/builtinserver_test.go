@@ -0,0 +1,136 @@
+package builtinserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/builtinserver"
+	"github.com/restream/reindexer/bindings/builtinserver/config"
+	_ "github.com/restream/reindexer/bindings/cproto"
+)
+
+type tlsTestItem struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+}
+
+func TestBuiltinServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reindex_tls_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, pool, err := writeTestCertificate(dir)
+	if err != nil {
+		t.Fatalf("Can't generate certificate: %v", err)
+	}
+
+	cfg := config.DefaultServerConfig()
+	cfg.Storage.Path = filepath.Join(dir, "storage")
+	cfg.Net.HTTPAddr = freeAddr(t)
+	cfg.Net.RPCAddr = freeAddr(t)
+	cfg.Net.TLSCert = filepath.Join(dir, "cert.pem")
+	cfg.Net.TLSKey = filepath.Join(dir, "key.pem")
+	cfg.Net.TLSClientCA = filepath.Join(dir, "cert.pem")
+
+	server := reindexer.NewReindex("builtinserver://tls_test", reindexer.WithServerConfig(time.Minute, cfg))
+	if err := server.Status().Err; err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// Client without certificate is rejected
+	noCertClient := reindexer.NewReindex("cprotos://"+cfg.Net.RPCAddr+"/tls_test", reindexer.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if err := noCertClient.Ping(); err == nil {
+		t.Fatalf("Expected error on connection without client certificate")
+	}
+	noCertClient.Close()
+
+	client := reindexer.NewReindex("cprotos://"+cfg.Net.RPCAddr+"/tls_test", reindexer.WithTLSConfig(&tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+	}))
+	defer client.Close()
+	if err := client.OpenNamespace("tls_items", reindexer.DefaultNamespaceOptions(), tlsTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Upsert("tls_items", &tlsTestItem{ID: 1, Name: "tls"}); err != nil {
+		t.Fatal(err)
+	}
+	item, found := client.Query("tls_items").WhereInt("id", reindexer.EQ, 1).Get()
+	if !found || item.(*tlsTestItem).Name != "tls" {
+		t.Fatalf("Item was not found over TLS: %+v", item)
+	}
+
+	// Item is written to database of builtinserver
+	if err := server.OpenNamespace("tls_items", reindexer.DefaultNamespaceOptions(), tlsTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := server.Query("tls_items").WhereInt("id", reindexer.EQ, 1).Get(); !found {
+		t.Fatalf("Item was not found in builtinserver")
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// writeTestCertificate writes self-signed certificate and key to cert.pem and key.pem in dir
+func writeTestCertificate(dir string) (cert tls.Certificate, pool *x509.CertPool, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "reindexer test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err = ioutil.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0600); err != nil {
+		return
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0600); err != nil {
+		return
+	}
+	if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return
+	}
+	pool = x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	return
+}
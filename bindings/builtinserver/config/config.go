@@ -16,6 +16,11 @@ type NetConf struct {
 	RPCAddr  string `yaml:"rpcaddr"`
 	WebRoot  string `yaml:"webroot"`
 	Security bool   `yaml:"security"`
+	// TLSCert and TLSKey are paths to PEM encoded server certificate and key. If they are set, RPC connections are served over TLS
+	TLSCert string `yaml:"tlscert,omitempty"`
+	TLSKey  string `yaml:"tlskey,omitempty"`
+	// TLSClientCA is path to PEM encoded CA certificates. If it's set, client certificates are required and verified
+	TLSClientCA string `yaml:"tlsclientca,omitempty"`
 }
 
 type LoggerConf struct {
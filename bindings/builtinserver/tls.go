@@ -0,0 +1,138 @@
+package builtinserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/restream/reindexer/bindings/builtinserver/config"
+)
+
+// tlsProxy serves RPC connections over TLS. Reindexer server itself serves only plain connections,
+// so it listens on loopback address, and tlsProxy forwards to it connections, accepted on RPC address from config
+type tlsProxy struct {
+	l       net.Listener
+	rpcAddr string
+	conns   map[net.Conn]struct{}
+	lock    sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func newTLSConfig(netCfg *config.NetConf) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(netCfg.TLSCert, netCfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("rq: can't load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if netCfg.TLSClientCA != "" {
+		pem, err := ioutil.ReadFile(netCfg.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("rq: can't read TLS client CA: %v", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("rq: no certificates in TLS client CA '%s'", netCfg.TLSClientCA)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// newTLSProxy listens on RPC address from config with TLS, and replaces it in config by loopback address,
+// on which reindexer server should listen
+func newTLSProxy(netCfg *config.NetConf) (*tlsProxy, error) {
+	tlsConfig, err := newTLSConfig(netCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Free loopback port is reserved for reindexer server
+	rpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	rpcAddr := rpcListener.Addr().String()
+	rpcListener.Close()
+
+	l, err := tls.Listen("tcp", netCfg.RPCAddr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	netCfg.RPCAddr = rpcAddr
+
+	proxy := &tlsProxy{l: l, rpcAddr: rpcAddr, conns: make(map[net.Conn]struct{})}
+	proxy.wg.Add(1)
+	go proxy.acceptLoop()
+	return proxy, nil
+}
+
+func (proxy *tlsProxy) acceptLoop() {
+	defer proxy.wg.Done()
+	for {
+		conn, err := proxy.l.Accept()
+		if err != nil {
+			return
+		}
+		proxy.wg.Add(1)
+		go proxy.serve(conn)
+	}
+}
+
+func (proxy *tlsProxy) serve(conn net.Conn) {
+	defer proxy.wg.Done()
+	if !proxy.track(conn, true) {
+		return
+	}
+	defer proxy.track(conn, false)
+
+	rpcConn, err := net.Dial("tcp", proxy.rpcAddr)
+	if err != nil {
+		return
+	}
+	if !proxy.track(rpcConn, true) {
+		return
+	}
+	defer proxy.track(rpcConn, false)
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(rpcConn, conn)
+		rpcConn.(*net.TCPConn).CloseWrite()
+		close(done)
+	}()
+	io.Copy(conn, rpcConn)
+	conn.Close()
+	<-done
+}
+
+// track adds connection to set of connections, which are closed on proxy close. Returns false and closes conn, if proxy is closed
+func (proxy *tlsProxy) track(conn net.Conn, add bool) bool {
+	proxy.lock.Lock()
+	defer proxy.lock.Unlock()
+	if !add {
+		delete(proxy.conns, conn)
+		conn.Close()
+		return true
+	}
+	if proxy.conns == nil {
+		conn.Close()
+		return false
+	}
+	proxy.conns[conn] = struct{}{}
+	return true
+}
+
+func (proxy *tlsProxy) close() {
+	proxy.l.Close()
+	proxy.lock.Lock()
+	for conn := range proxy.conns {
+		conn.Close()
+	}
+	proxy.conns = nil
+	proxy.lock.Unlock()
+	proxy.wg.Wait()
+}
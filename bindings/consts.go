@@ -49,20 +49,28 @@ const (
 	ValueComposite = 10
 	ValueTuple     = 11
 
-	QueryCondition      = 0
-	QueryDistinct       = 1
-	QuerySortIndex      = 2
-	QueryJoinOn         = 3
-	QueryLimit          = 4
-	QueryOffset         = 5
-	QueryReqTotal       = 6
-	QueryDebugLevel     = 7
-	QueryAggregation    = 8
-	QuerySelectFilter   = 9
-	QuerySelectFunction = 10
-	QueryEnd            = 11
-	QueryExplain        = 12
-	QueryEqualPosition  = 13
+	QueryCondition         = 0
+	QueryDistinct          = 1
+	QuerySortIndex         = 2
+	QueryJoinOn            = 3
+	QueryLimit             = 4
+	QueryOffset            = 5
+	QueryReqTotal          = 6
+	QueryDebugLevel        = 7
+	QueryAggregation       = 8
+	QuerySelectFilter      = 9
+	QuerySelectFunction    = 10
+	QueryEnd               = 11
+	QueryExplain           = 12
+	QueryEqualPosition     = 13
+	QueryUpdateField       = 14
+	QueryAggregationLimit  = 15
+	QueryAggregationOffset = 16
+	QueryAggregationSort   = 17
+	QueryOpenBracket       = 18
+	QueryCloseBracket      = 19
+	QueryDropField         = 21
+	QueryAggregationFields = 22
 
 	LeftJoin    = 0
 	InnerJoin   = 1
@@ -81,6 +89,10 @@ const (
 	ModeUpsert = 2
 	ModeDelete = 3
 
+	UpdateFieldSet         = 0
+	UpdateFieldArrayAppend = 1
+	UpdateFieldArrayRemove = 2
+
 	ModeNoCalc        = 0
 	ModeCachedTotal   = 1
 	ModeAccurateTotal = 2
@@ -3,8 +3,12 @@ package cproto
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -15,6 +19,8 @@ import (
 
 type sig chan *NetBuffer
 
+var errConnClosed = errors.New("cproto: connection closed")
+
 const bufsCap = 16 * 1024
 const queueSize = 40
 
@@ -23,34 +29,41 @@ const cprotoVersion = 0x101
 const cprotoHdrLen = 16
 
 const (
-	cmdPing           = 0
-	cmdLogin          = 1
-	cmdOpenDatabase   = 2
-	cmdCloseDatabase  = 3
-	cmdDropDatabase   = 4
-	cmdOpenNamespace  = 16
-	cmdCloseNamespace = 17
-	cmdDropNamespace  = 18
-	cmdAddIndex       = 21
-	cmdEnumNamespaces = 22
-	cmdDropIndex      = 24
-	cmdUpdateIndex    = 25
-	cmdCommit         = 32
-	cmdModifyItem     = 33
-	cmdDeleteQuery    = 34
-	cmdSelect         = 48
-	cmdSelectSQL      = 49
-	cmdFetchResults   = 50
-	cmdCloseResults   = 51
-	cmdGetMeta        = 64
-	cmdPutMeta        = 65
-	cmdEnumMeta       = 66
-	cmdCodeMax        = 128
+	cmdPing             = 0
+	cmdLogin            = 1
+	cmdOpenDatabase     = 2
+	cmdCloseDatabase    = 3
+	cmdDropDatabase     = 4
+	cmdOpenNamespace    = 16
+	cmdCloseNamespace   = 17
+	cmdDropNamespace    = 18
+	cmdAddIndex         = 21
+	cmdEnumNamespaces   = 22
+	cmdDropIndex        = 24
+	cmdUpdateIndex      = 25
+	cmdCommit           = 32
+	cmdModifyItem       = 33
+	cmdDeleteQuery      = 34
+	cmdUpdateQuery      = 35
+	cmdStartTx          = 36
+	cmdCommitTx         = 37
+	cmdRollbackTx       = 38
+	cmdSelect           = 48
+	cmdSelectSQL        = 49
+	cmdFetchResults     = 50
+	cmdCloseResults     = 51
+	cmdGetMeta          = 64
+	cmdPutMeta          = 65
+	cmdEnumMeta         = 66
+	cmdSubscribeUpdates = 90
+	cmdUpdates          = 91
+	cmdCodeMax          = 128
 )
 
 type connection struct {
-	owner *NetCProto
-	conn  net.Conn
+	owner    *NetCProto
+	hostPool *hostPool
+	conn     net.Conn
 
 	wrBuf, wrBuf2 *bytes.Buffer
 	wrKick        chan struct{}
@@ -59,6 +72,11 @@ type connection struct {
 	repl  [queueSize]sig
 
 	seqs chan int
+	// reqSeqs are sequence numbers of requests, which are waiting for reply in each slot, or 0, if reply is not expected.
+	// Sequence number is slot + queueSize * generation, so late reply to timed out request is not delivered to the next request in this slot
+	reqSeqs [queueSize]uint32
+	seqGens [queueSize]uint32
+
 	lock sync.RWMutex
 
 	err   error
@@ -67,36 +85,64 @@ type connection struct {
 	lastReadStamp int64
 }
 
-func newConnection(owner *NetCProto) (c *connection, err error) {
+func newConnection(ctx context.Context, owner *NetCProto, hostPool *hostPool) (c *connection, err error) {
+	return newConnectionToDB(ctx, owner, hostPool, owner.dbName())
+}
+
+// newConnectionToDB creates connection, which is logged in to database dbName. If dbName is empty,
+// connection is not bound to any database, and can be used for databases administration.
+// Connect and login are limited by ctx and by connect timeout
+func newConnectionToDB(ctx context.Context, owner *NetCProto, hostPool *hostPool, dbName string) (c *connection, err error) {
 	c = &connection{
-		owner:  owner,
-		wrBuf:  bytes.NewBuffer(make([]byte, 0, bufsCap)),
-		wrBuf2: bytes.NewBuffer(make([]byte, 0, bufsCap)),
-		wrKick: make(chan struct{}, 1),
-		seqs:   make(chan int, queueSize),
-		errCh:  make(chan struct{}),
+		owner:    owner,
+		hostPool: hostPool,
+		wrBuf:    bytes.NewBuffer(make([]byte, 0, bufsCap)),
+		wrBuf2:   bytes.NewBuffer(make([]byte, 0, bufsCap)),
+		wrKick:   make(chan struct{}, 1),
+		seqs:     make(chan int, queueSize),
+		errCh:    make(chan struct{}),
 	}
 	for i := 0; i < queueSize; i++ {
 		c.seqs <- i
-		c.repl[i] = make(sig)
+		c.repl[i] = make(sig, 1)
 	}
-	if err = c.connect(); err != nil {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*connectTimeoutSec)
+	defer cancel()
+	if err = c.connect(ctx); err != nil {
 		c.onError(err)
 		return
 	}
-	if err = c.login(owner); err != nil {
+	if err = c.login(ctx, owner, dbName); err != nil {
 		c.onError(err)
 		return
 	}
 	return
 }
 
-func (c *connection) connect() (err error) {
-	c.conn, err = net.Dial("tcp", c.owner.url.Host)
+func (c *connection) connect(ctx context.Context) (err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.hostPool.host)
 	if err != nil {
 		return err
 	}
-	c.conn.(*net.TCPConn).SetNoDelay(true)
+	conn.(*net.TCPConn).SetNoDelay(true)
+	if tlsConfig := c.owner.tlsConfig; tlsConfig != nil {
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(c.hostPool.host)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+	c.conn = conn
 	c.rdBuf = bufio.NewReaderSize(c.conn, bufsCap)
 
 	go c.writeLoop()
@@ -104,17 +150,14 @@ func (c *connection) connect() (err error) {
 	return
 }
 
-func (c *connection) login(owner *NetCProto) (err error) {
-	password, username, path := "", "", owner.url.Path
+func (c *connection) login(ctx context.Context, owner *NetCProto, dbName string) (err error) {
+	password, username := "", ""
 	if owner.url.User != nil {
 		username = owner.url.User.Username()
 		password, _ = owner.url.User.Password()
 	}
-	if len(path) > 0 && path[0] == '/' {
-		path = path[1:]
-	}
 
-	buf, err := c.rpcCall(cmdLogin, username, password, path)
+	buf, err := c.rpcCall(ctx, cmdLogin, username, password, dbName)
 	if err != nil {
 		c.err = err
 		return
@@ -122,7 +165,7 @@ func (c *connection) login(owner *NetCProto) (err error) {
 	defer buf.Free()
 
 	if len(buf.args) > 1 {
-		owner.checkServerStartTime(buf.args[1].(int64))
+		owner.checkServerStartTime(c.hostPool, buf.args[1].(int64))
 	}
 	return
 }
@@ -146,9 +189,9 @@ func (c *connection) readReply(hdr []byte) (err error) {
 	ser := cjson.NewSerializer(hdr)
 	magic := ser.GetUInt32()
 	version := ser.GetUInt16()
-	_ = int(ser.GetUInt16())
+	cmd := int(ser.GetUInt16())
 	size := int(ser.GetUInt32())
-	rseq := int32(ser.GetUInt32())
+	rseq := ser.GetUInt32()
 	if magic != cprotoMagic {
 		return fmt.Errorf("Invalid cproto magic '%08X'", magic)
 	}
@@ -157,7 +200,6 @@ func (c *connection) readReply(hdr []byte) (err error) {
 		return fmt.Errorf("Unsupported cproto version '%04X'. This client expects reindexer server v1.9.8+", version)
 	}
 
-	repCh := c.repl[rseq]
 	answ := newNetBuffer()
 	answ.reset(size, c)
 
@@ -165,11 +207,19 @@ func (c *connection) readReply(hdr []byte) (err error) {
 		return
 	}
 
-	if repCh != nil {
-		repCh <- answ
-	} else {
-		return fmt.Errorf("unexpected answer: %v", answ)
+	if cmd == cmdUpdates {
+		// Updates are pushed by server without request, so there is no reply channel for them
+		c.owner.onUpdates(answ)
+		return
 	}
+
+	slot := int(rseq % queueSize)
+	if rseq == 0 || !atomic.CompareAndSwapUint32(&c.reqSeqs[slot], rseq, 0) {
+		// Request was timed out or canceled, and reply is not expected anymore
+		c.freeLateReply(cmd, answ)
+		return
+	}
+	c.repl[slot] <- answ
 	return
 }
 
@@ -210,10 +260,20 @@ func (c *connection) writeLoop() {
 	}
 }
 
-func (c *connection) rpcCall(cmd int, args ...interface{}) (buf *NetBuffer, err error) {
-	seq := <-c.seqs
-	reply := c.repl[seq]
-	in := newRPCEncoder(cmd, seq)
+func (c *connection) rpcCall(ctx context.Context, cmd int, args ...interface{}) (buf *NetBuffer, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var slot int
+	select {
+	case slot = <-c.seqs:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	reply := c.repl[slot]
+	seq := c.nextSeq(slot)
+	atomic.StoreUint32(&c.reqSeqs[slot], seq)
+	in := newRPCEncoder(cmd, int(seq))
 	for _, a := range args {
 		switch t := a.(type) {
 		case bool:
@@ -239,11 +299,23 @@ func (c *connection) rpcCall(cmd int, args ...interface{}) (buf *NetBuffer, err
 	select {
 	case buf = <-reply:
 	case <-c.errCh:
-		c.lock.RLock()
-		err = c.err
-		c.lock.RUnlock()
+		if atomic.CompareAndSwapUint32(&c.reqSeqs[slot], seq, 0) {
+			c.lock.RLock()
+			err = c.err
+			c.lock.RUnlock()
+		} else {
+			buf = <-reply
+		}
+	case <-ctx.Done():
+		if !atomic.CompareAndSwapUint32(&c.reqSeqs[slot], seq, 0) {
+			// Reply is already received, and is being delivered to slot
+			c.freeLateReply(cmd, <-reply)
+		}
+		// Late reply will be ignored by readReply, so slot can be reused right now
+		c.seqs <- slot
+		return nil, ctx.Err()
 	}
-	c.seqs <- seq
+	c.seqs <- slot
 	if err != nil {
 		return
 	}
@@ -253,6 +325,28 @@ func (c *connection) rpcCall(cmd int, args ...interface{}) (buf *NetBuffer, err
 	return
 }
 
+// freeLateReply frees reply to timed out or canceled request. If request opened query results on server,
+// they are closed in background, because readLoop must not wait for reply to close request
+func (c *connection) freeLateReply(cmd int, answ *NetBuffer) {
+	if cmd == cmdSelect || cmd == cmdSelectSQL {
+		if err := answ.parseArgs(); err == nil && len(answ.args) > 1 {
+			if reqID, ok := answ.args[1].(int); ok && reqID != -1 {
+				answ.reqID, answ.needClose = reqID, true
+				go answ.Free()
+				return
+			}
+		}
+	}
+	answ.Free()
+}
+
+// nextSeq returns sequence number for the next request in slot. It's never 0
+func (c *connection) nextSeq(slot int) uint32 {
+	const maxGen = math.MaxUint32/queueSize - 1
+	c.seqGens[slot] = c.seqGens[slot]%maxGen + 1
+	return uint32(slot) + queueSize*c.seqGens[slot]
+}
+
 func (c *connection) onError(err error) {
 	c.lock.Lock()
 	if c.err == nil {
@@ -269,6 +363,10 @@ func (c *connection) onError(err error) {
 	c.lock.Unlock()
 }
 
+func (c *connection) close() {
+	c.onError(errConnClosed)
+}
+
 func (c *connection) hasError() (has bool) {
 	c.lock.RLock()
 	has = c.err != nil
@@ -1,11 +1,14 @@
 package cproto
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"math"
-	"net"
 	"net/url"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,8 +17,9 @@ import (
 )
 
 const (
-	defConnPoolSize  = 8
-	pingerTimeoutSec = 60
+	defConnPoolSize   = 8
+	pingerTimeoutSec  = 60
+	connectTimeoutSec = 10
 
 	opRd = 0
 	opWr = 1
@@ -23,45 +27,101 @@ const (
 
 func init() {
 	bindings.RegisterBinding("cproto", new(NetCProto))
+	bindings.RegisterBinding("cprotos", new(NetCProto))
 }
 
 type NetCProto struct {
 	url              url.URL
-	pool             chan *connection
+	hosts            []*hostPool
+	master           int32
+	nextReplica      uint32
+	readFromReplicas bool
 	onChangeCallback func()
-	serverStartTime  int64
 	retryAttempts    bindings.OptionRetryAttempts
+	reconnectBackoff bindings.OptionReconnectBackoff
+	timeouts         bindings.OptionTimeouts
+	tlsConfig        *tls.Config
+	// txConns are connections of started transactions. Transaction exists on server only in connection, which started it
+	txConns map[int]*connection
+	txLock  sync.Mutex
+	// ctx is context of background operations of binding. It's canceled on Finalize
+	ctx          context.Context
+	cancel       context.CancelFunc
+	finalizeOnce sync.Once
+	// updatesConn is separate connection, to which server pushes updates of items
+	updatesConn    *connection
+	updatesHandler atomic.Value
+	updatesLock    sync.Mutex
 }
 
 func (binding *NetCProto) Init(u *url.URL, options ...interface{}) (err error) {
 
 	connPoolSize := defConnPoolSize
+	hosts := strings.Split(u.Host, ",")
 	for _, option := range options {
 		switch v := option.(type) {
 		case bindings.OptionConnPoolSize:
 			connPoolSize = v.ConnPoolSize
 		case bindings.OptionRetryAttempts:
 			binding.retryAttempts = v
+		case bindings.OptionTLSConfig:
+			binding.tlsConfig = v.Config
+		case bindings.OptionHosts:
+			hosts = append(hosts, v.Hosts...)
+		case bindings.OptionReadFromReplicas:
+			binding.readFromReplicas = true
+		case bindings.OptionReconnectBackoff:
+			binding.reconnectBackoff = v
+		case bindings.OptionTimeouts:
+			binding.timeouts = v
 		default:
 			fmt.Printf("Unknown cproto option: %v\n", option)
 		}
 	}
 
+	if binding.tlsConfig == nil && u.Scheme == "cprotos" {
+		binding.tlsConfig = &tls.Config{}
+	}
+
 	if binding.retryAttempts.Read < 0 {
 		binding.retryAttempts.Read = 0
 	}
 	if binding.retryAttempts.Write < 0 {
 		binding.retryAttempts.Write = 0
 	}
+	if binding.reconnectBackoff.MinDelay <= 0 {
+		binding.reconnectBackoff.MinDelay = defReconnectMinDelay
+	}
+	if binding.reconnectBackoff.MaxDelay < binding.reconnectBackoff.MinDelay {
+		binding.reconnectBackoff.MaxDelay = defReconnectMaxDelay
+		if binding.reconnectBackoff.MaxDelay < binding.reconnectBackoff.MinDelay {
+			binding.reconnectBackoff.MaxDelay = binding.reconnectBackoff.MinDelay
+		}
+	}
+	if binding.reconnectBackoff.Factor < 1 {
+		binding.reconnectBackoff.Factor = defReconnectFactor
+	}
+	if binding.reconnectBackoff.Jitter < 0 || binding.reconnectBackoff.Jitter > 1 {
+		binding.reconnectBackoff.Jitter = defReconnectJitter
+	}
 
 	binding.url = *u
-	binding.pool = make(chan *connection, connPoolSize)
-	for i := 0; i < connPoolSize; i++ {
-		conn, cerr := newConnection(binding)
-		if cerr != nil {
-			err = cerr
+	binding.ctx, binding.cancel = context.WithCancel(context.Background())
+	for _, host := range hosts {
+		if host != "" {
+			binding.hosts = append(binding.hosts, newHostPool(host, connPoolSize))
+		}
+	}
+	if len(binding.hosts) == 0 {
+		binding.hosts = append(binding.hosts, newHostPool(u.Host, connPoolSize))
+	}
+
+	// Master is the first available host
+	for i, p := range binding.hosts {
+		if err = p.connect(binding); err == nil {
+			binding.master = int32(i)
+			break
 		}
-		binding.pool <- conn
 	}
 	go binding.pinger()
 	return
@@ -71,11 +131,11 @@ func (binding *NetCProto) Clone() bindings.RawBinding {
 	return &NetCProto{}
 }
 
-func (binding *NetCProto) Ping() error {
-	return binding.rpcCallNoResults(opRd, cmdPing)
+func (binding *NetCProto) Ping(ctx context.Context) error {
+	return binding.rpcCallNoResults(ctx, opRd, cmdPing)
 }
 
-func (binding *NetCProto) ModifyItem(nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
+func (binding *NetCProto) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
 
 	var packedPercepts []byte
 	if len(precepts) != 0 {
@@ -89,8 +149,15 @@ func (binding *NetCProto) ModifyItem(nsHash int, namespace string, format int, d
 		packedPercepts = ser1.Bytes()
 	}
 
-	buf, err := binding.rpcCall(opWr, cmdModifyItem, namespace, format, data, mode, packedPercepts, stateToken, txID)
+	var buf *NetBuffer
+	var err error
+	if txID != 0 {
+		buf, err = binding.txCall(ctx, txID, cmdModifyItem, namespace, format, data, mode, packedPercepts, stateToken, txID)
+	} else {
+		buf, err = binding.rpcCall(ctx, opWr, cmdModifyItem, namespace, format, data, mode, packedPercepts, stateToken, txID)
+	}
 	if err != nil {
+		buf.Free()
 		return nil, err
 	}
 	buf.result = buf.args[0].([]byte)
@@ -98,7 +165,7 @@ func (binding *NetCProto) ModifyItem(nsHash int, namespace string, format int, d
 	return buf, nil
 }
 
-func (binding *NetCProto) OpenNamespace(namespace string, enableStorage, dropOnFormatError bool, cacheMode uint8) error {
+func (binding *NetCProto) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFormatError bool, cacheMode uint8) error {
 	storageOtps := bindings.StorageOpts{
 		EnableStorage:     enableStorage,
 		DropOnFormatError: dropOnFormatError,
@@ -116,45 +183,45 @@ func (binding *NetCProto) OpenNamespace(namespace string, enableStorage, dropOnF
 		return err
 	}
 
-	return binding.rpcCallNoResults(opWr, cmdOpenNamespace, bNamespaceDef)
+	return binding.rpcCallNoResults(ctx, opWr, cmdOpenNamespace, bNamespaceDef)
 }
 
-func (binding *NetCProto) CloseNamespace(namespace string) error {
-	return binding.rpcCallNoResults(opWr, cmdCloseNamespace, namespace)
+func (binding *NetCProto) CloseNamespace(ctx context.Context, namespace string) error {
+	return binding.rpcCallNoResults(ctx, opWr, cmdCloseNamespace, namespace)
 }
 
-func (binding *NetCProto) DropNamespace(namespace string) error {
-	return binding.rpcCallNoResults(opWr, cmdDropNamespace, namespace)
+func (binding *NetCProto) DropNamespace(ctx context.Context, namespace string) error {
+	return binding.rpcCallNoResults(ctx, opWr, cmdDropNamespace, namespace)
 }
 
-func (binding *NetCProto) AddIndex(namespace string, indexDef bindings.IndexDef) error {
+func (binding *NetCProto) AddIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
 	bIndexDef, err := json.Marshal(indexDef)
 	if err != nil {
 		return err
 	}
 
-	return binding.rpcCallNoResults(opWr, cmdAddIndex, namespace, bIndexDef)
+	return binding.rpcCallNoResults(ctx, opWr, cmdAddIndex, namespace, bIndexDef)
 }
 
-func (binding *NetCProto) UpdateIndex(namespace string, indexDef bindings.IndexDef) error {
+func (binding *NetCProto) UpdateIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
 	bIndexDef, err := json.Marshal(indexDef)
 	if err != nil {
 		return err
 	}
 
-	return binding.rpcCallNoResults(opWr, cmdUpdateIndex, namespace, bIndexDef)
+	return binding.rpcCallNoResults(ctx, opWr, cmdUpdateIndex, namespace, bIndexDef)
 }
 
-func (binding *NetCProto) DropIndex(namespace, index string) error {
-	return binding.rpcCallNoResults(opWr, cmdDropIndex, namespace, index)
+func (binding *NetCProto) DropIndex(ctx context.Context, namespace, index string) error {
+	return binding.rpcCallNoResults(ctx, opWr, cmdDropIndex, namespace, index)
 }
 
-func (binding *NetCProto) PutMeta(namespace, key, data string) error {
-	return binding.rpcCallNoResults(opWr, cmdPutMeta, namespace, key, data)
+func (binding *NetCProto) PutMeta(ctx context.Context, namespace, key, data string) error {
+	return binding.rpcCallNoResults(ctx, opWr, cmdPutMeta, namespace, key, data)
 }
 
-func (binding *NetCProto) GetMeta(namespace, key string) (bindings.RawBuffer, error) {
-	buf, err := binding.rpcCall(opRd, cmdGetMeta, namespace, key)
+func (binding *NetCProto) GetMeta(ctx context.Context, namespace, key string) (bindings.RawBuffer, error) {
+	buf, err := binding.rpcCall(ctx, opRd, cmdGetMeta, namespace, key)
 	if err != nil {
 		buf.Free()
 		return nil, err
@@ -164,7 +231,35 @@ func (binding *NetCProto) GetMeta(namespace, key string) (bindings.RawBuffer, er
 	return buf, nil
 }
 
-func (binding *NetCProto) Select(query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+func (binding *NetCProto) EnumMeta(ctx context.Context, namespace string) ([]string, error) {
+	buf, err := binding.rpcCall(ctx, opRd, cmdEnumMeta, namespace)
+	defer buf.Free()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(buf.args))
+	for _, arg := range buf.args {
+		keys = append(keys, string(arg.([]byte)))
+	}
+	return keys, nil
+}
+
+func (binding *NetCProto) EnumNamespaces(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	buf, err := binding.rpcCall(ctx, opRd, cmdEnumNamespaces)
+	defer buf.Free()
+	if err != nil {
+		return nil, err
+	}
+	var namespaces struct {
+		Items []bindings.NamespaceDef `json:"items"`
+	}
+	if err = json.Unmarshal(buf.args[0].([]byte), &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces.Items, nil
+}
+
+func (binding *NetCProto) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
 	flags := 0
 	if withItems {
 		flags |= bindings.ResultsJson
@@ -176,7 +271,7 @@ func (binding *NetCProto) Select(query string, withItems bool, ptVersions []int3
 		fetchCount = math.MaxInt32
 	}
 
-	buf, err := binding.rpcCall(opRd, cmdSelectSQL, query, flags, int32(fetchCount), ptVersions)
+	buf, err := binding.rpcCall(ctx, opRd, cmdSelectSQL, query, flags, int32(fetchCount), ptVersions)
 	if err != nil {
 		buf.Free()
 		return nil, err
@@ -187,7 +282,7 @@ func (binding *NetCProto) Select(query string, withItems bool, ptVersions []int3
 	return buf, nil
 }
 
-func (binding *NetCProto) SelectQuery(data []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+func (binding *NetCProto) SelectQuery(ctx context.Context, data []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
 	flags := 0
 	if withItems {
 		flags |= bindings.ResultsJson
@@ -199,7 +294,7 @@ func (binding *NetCProto) SelectQuery(data []byte, withItems bool, ptVersions []
 		fetchCount = math.MaxInt32
 	}
 
-	buf, err := binding.rpcCall(opRd, cmdSelect, data, flags, int32(fetchCount), ptVersions)
+	buf, err := binding.rpcCall(ctx, opRd, cmdSelect, data, flags, int32(fetchCount), ptVersions)
 	if err != nil {
 		buf.Free()
 		return nil, err
@@ -210,18 +305,194 @@ func (binding *NetCProto) SelectQuery(data []byte, withItems bool, ptVersions []
 	return buf, nil
 }
 
-func (binding *NetCProto) DeleteQuery(nsHash int, data []byte) (bindings.RawBuffer, error) {
-	buf, err := binding.rpcCall(opWr, cmdDeleteQuery, data)
+func (binding *NetCProto) DeleteQuery(ctx context.Context, nsHash int, data []byte) (bindings.RawBuffer, error) {
+	buf, err := binding.rpcCall(ctx, opWr, cmdDeleteQuery, data)
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	buf.result = buf.args[0].([]byte)
+	return buf, nil
+}
+
+func (binding *NetCProto) UpdateQuery(ctx context.Context, nsHash int, data []byte) (bindings.RawBuffer, error) {
+	buf, err := binding.rpcCall(ctx, opWr, cmdUpdateQuery, data)
+	if err != nil {
+		buf.Free()
+		return nil, err
+	}
+	buf.result = buf.args[0].([]byte)
+	return buf, nil
+}
+
+func (binding *NetCProto) Commit(ctx context.Context, namespace string) error {
+	return binding.rpcCallNoResults(ctx, opWr, cmdCommit, namespace)
+}
+
+// BeginTx starts transaction on connection to master. All requests of transaction are sent to this connection
+func (binding *NetCProto) BeginTx(ctx context.Context, namespace string) (int, error) {
+	ctx, cancel := binding.withTimeout(ctx, opWr)
+	defer cancel()
+
+	conn, err := binding.getMasterConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	buf, err := conn.rpcCall(ctx, cmdStartTx, namespace)
+	defer buf.Free()
+	if err != nil {
+		return 0, err
+	}
+	txID := buf.args[0].(int)
+
+	binding.txLock.Lock()
+	if binding.txConns == nil {
+		binding.txConns = make(map[int]*connection)
+	}
+	binding.txConns[txID] = conn
+	binding.txLock.Unlock()
+	return txID, nil
+}
+
+// CommitTx applies transaction. Transaction is finished even if commit fails
+func (binding *NetCProto) CommitTx(ctx context.Context, txID int) (bindings.RawBuffer, error) {
+	defer binding.finishTx(txID)
+	buf, err := binding.txCall(ctx, txID, cmdCommitTx, txID)
 	if err != nil {
 		buf.Free()
 		return nil, err
 	}
 	buf.result = buf.args[0].([]byte)
+	buf.reqID = -1
 	return buf, nil
 }
 
-func (binding *NetCProto) Commit(namespace string) error {
-	return binding.rpcCallNoResults(opWr, cmdCommit, namespace)
+// RollbackTx discards transaction
+func (binding *NetCProto) RollbackTx(ctx context.Context, txID int) error {
+	defer binding.finishTx(txID)
+	buf, err := binding.txCall(ctx, txID, cmdRollbackTx, txID)
+	buf.Free()
+	return err
+}
+
+// txCall calls cmd on connection of transaction. Requests of transaction are not retried,
+// because transaction is lost on server with its connection
+func (binding *NetCProto) txCall(ctx context.Context, txID int, cmd int, args ...interface{}) (*NetBuffer, error) {
+	binding.txLock.Lock()
+	conn := binding.txConns[txID]
+	binding.txLock.Unlock()
+	if conn == nil {
+		return nil, bindings.NewError(fmt.Sprintf("cproto: transaction %d not found", txID), bindings.ErrLogic)
+	}
+	ctx, cancel := binding.withTimeout(ctx, opWr)
+	defer cancel()
+	return conn.rpcCall(ctx, cmd, args...)
+}
+
+func (binding *NetCProto) finishTx(txID int) {
+	binding.txLock.Lock()
+	delete(binding.txConns, txID)
+	binding.txLock.Unlock()
+}
+
+// CreateDatabase creates database dbName on master, if it's not exists yet
+func (binding *NetCProto) CreateDatabase(ctx context.Context, dbName string) error {
+	return binding.adminCall(ctx, dbName, cmdOpenDatabase, dbName)
+}
+
+// DropDatabase drops database dbName on master
+func (binding *NetCProto) DropDatabase(ctx context.Context, dbName string) error {
+	return binding.adminCall(ctx, dbName, cmdDropDatabase)
+}
+
+// adminCall opens database dbName on separate connection to master, which is not bound to any database,
+// and calls cmd on it. Connections of pool can't be used, because database can be opened only once per connection
+func (binding *NetCProto) adminCall(ctx context.Context, dbName string, cmd int, args ...interface{}) error {
+	if dbName == "" {
+		return bindings.NewError("Database name is empty", bindings.ErrParams)
+	}
+	ctx, cancel := binding.withTimeout(ctx, opWr)
+	defer cancel()
+
+	conn, err := newConnectionToDB(ctx, binding, binding.masterPool(), "")
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	if cmd != cmdOpenDatabase {
+		buf, err := conn.rpcCall(ctx, cmdOpenDatabase, dbName)
+		buf.Free()
+		if err != nil {
+			return err
+		}
+	}
+	buf, err := conn.rpcCall(ctx, cmd, args...)
+	buf.Free()
+	return err
+}
+
+// dbName returns name of database from DSN path
+func (binding *NetCProto) dbName() string {
+	return strings.TrimPrefix(binding.url.Path, "/")
+}
+
+// SubscribeUpdates subscribes to updates of items in database. Server pushes updates to separate connection,
+// which is restored by pinger after connection failure. Updates, which were made while connection was broken, are lost
+func (binding *NetCProto) SubscribeUpdates(ctx context.Context, handler bindings.UpdatesHandler) error {
+	binding.updatesLock.Lock()
+	defer binding.updatesLock.Unlock()
+	binding.updatesHandler.Store(handler)
+	return binding.subscribeUpdates(ctx)
+}
+
+func (binding *NetCProto) UnsubscribeUpdates(ctx context.Context) error {
+	binding.updatesLock.Lock()
+	defer binding.updatesLock.Unlock()
+	binding.updatesHandler.Store(bindings.UpdatesHandler(nil))
+	conn := binding.updatesConn
+	binding.updatesConn = nil
+	if conn == nil || conn.hasError() {
+		return nil
+	}
+	defer conn.close()
+	buf, err := conn.rpcCall(ctx, cmdSubscribeUpdates, 0)
+	buf.Free()
+	return err
+}
+
+// subscribeUpdates opens separate connection, to which server will push updates. updatesLock must be held
+func (binding *NetCProto) subscribeUpdates(ctx context.Context) error {
+	if binding.updatesConn != nil && !binding.updatesConn.hasError() {
+		return nil
+	}
+	conn, err := newConnection(ctx, binding, binding.masterPool())
+	if err != nil {
+		return err
+	}
+	buf, err := conn.rpcCall(ctx, cmdSubscribeUpdates, 1)
+	buf.Free()
+	if err != nil {
+		conn.close()
+		return err
+	}
+	binding.updatesConn = conn
+	return nil
+}
+
+func (binding *NetCProto) onUpdates(buf *NetBuffer) {
+	defer buf.Free()
+	handler, _ := binding.updatesHandler.Load().(bindings.UpdatesHandler)
+	if handler == nil {
+		return
+	}
+	if err := buf.parseArgs(); err != nil || len(buf.args) < 3 {
+		return
+	}
+	namespace, _ := buf.args[0].([]byte)
+	mode, _ := buf.args[1].(int)
+	data, _ := buf.args[2].([]byte)
+	handler(string(namespace), mode, data)
 }
 
 func (binding *NetCProto) OnChangeCallback(f func()) {
@@ -241,40 +512,68 @@ func (binding *NetCProto) EnableStorage(path string) error {
 }
 
 func (binding *NetCProto) Status() bindings.Status {
-	var totalQueueSize, totalQueueUsage, connUsage int
-	for i := 0; i < cap(binding.pool); i++ {
-		conn := binding.getConn()
-		totalQueueSize += cap(conn.seqs)
-		queueUsage := cap(conn.seqs) - len(conn.seqs)
-		totalQueueUsage += queueUsage
-		if queueUsage > 0 {
-			connUsage++
+	var totalQueueSize, totalQueueUsage, connUsage, connPoolSize int
+	master := binding.masterPool()
+	hosts := make([]bindings.StatusCProtoHost, 0, len(binding.hosts))
+	for _, p := range binding.hosts {
+		connPoolSize += len(p.conns)
+		for _, conn := range p.established() {
+			totalQueueSize += cap(conn.seqs)
+			queueUsage := cap(conn.seqs) - len(conn.seqs)
+			totalQueueUsage += queueUsage
+			if queueUsage > 0 {
+				connUsage++
+			}
 		}
+		hosts = append(hosts, p.status(p == master))
 	}
 	return bindings.Status{
 		CProto: bindings.StatusCProto{
-			ConnPoolSize:   cap(binding.pool),
+			ConnPoolSize:   connPoolSize,
 			ConnPoolUsage:  connUsage,
 			ConnQueueSize:  totalQueueSize,
 			ConnQueueUsage: totalQueueUsage,
+			ConnState:      master.status(true).State,
+			Hosts:          hosts,
 		},
 	}
 }
 
 func (binding *NetCProto) Finalize() error {
+	binding.finalizeOnce.Do(func() {
+		binding.cancel()
+		for _, p := range binding.hosts {
+			p.close()
+		}
+		binding.updatesLock.Lock()
+		if binding.updatesConn != nil {
+			binding.updatesConn.close()
+			binding.updatesConn = nil
+		}
+		binding.updatesLock.Unlock()
+	})
 	return nil
 }
 
-func (binding *NetCProto) getConn() (conn *connection) {
-	conn = <-binding.pool
-	if conn.hasError() {
-		conn, _ = newConnection(binding)
+// withTimeout returns ctx, limited by timeout of request. Timeout from ctx overrides the default timeout of operation
+func (binding *NetCProto) withTimeout(ctx context.Context, op int) (context.Context, context.CancelFunc) {
+	timeout := binding.timeouts.Write
+	if op == opRd {
+		timeout = binding.timeouts.Read
 	}
-	binding.pool <- conn
-	return
+	if d, ok := bindings.TimeoutFromContext(ctx); ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
-func (binding *NetCProto) rpcCall(op int, cmd int, args ...interface{}) (buf *NetBuffer, err error) {
+func (binding *NetCProto) rpcCall(ctx context.Context, op int, cmd int, args ...interface{}) (buf *NetBuffer, err error) {
+	ctx, cancel := binding.withTimeout(ctx, op)
+	defer cancel()
+
 	var attempts int
 	switch op {
 	case opRd:
@@ -283,21 +582,37 @@ func (binding *NetCProto) rpcCall(op int, cmd int, args ...interface{}) (buf *Ne
 		attempts = binding.retryAttempts.Write + 1
 	}
 	for i := 0; i < attempts; i++ {
-		if buf, err = binding.getConn().rpcCall(cmd, args...); err == nil {
-			return
+		if i > 0 {
+			select {
+			case <-time.After(binding.reconnectDelay(i)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
-		switch err.(type) {
-		case net.Error, *net.OpError:
-			time.Sleep(time.Second * time.Duration(i))
-		default:
+		var conn *connection
+		if conn, err = binding.getConn(ctx, op); err == nil {
+			if buf, err = conn.rpcCall(ctx, cmd, args...); err == nil {
+				return
+			}
+		}
+		if !isConnError(err) || ctx.Err() != nil {
 			return
 		}
 	}
 	return
 }
 
-func (binding *NetCProto) rpcCallNoResults(op int, cmd int, args ...interface{}) error {
-	buf, err := binding.rpcCall(op, cmd, args...)
+// isConnError returns true, if err is connection error, and request can be retried. Errors, returned by server, are not retried
+func isConnError(err error) bool {
+	switch err.(type) {
+	case bindings.Error:
+		return false
+	}
+	return err != context.Canceled && err != context.DeadlineExceeded
+}
+
+func (binding *NetCProto) rpcCallNoResults(ctx context.Context, op int, cmd int, args ...interface{}) error {
+	buf, err := binding.rpcCall(ctx, op, cmd, args...)
 	buf.Free()
 	return err
 }
@@ -305,19 +620,51 @@ func (binding *NetCProto) rpcCallNoResults(op int, cmd int, args ...interface{})
 func (binding *NetCProto) pinger() {
 	timeout := time.Second * time.Duration(pingerTimeoutSec)
 	ticker := time.NewTicker(timeout)
-	for now := range ticker.C {
-		for i := 0; i < cap(binding.pool); i++ {
-			conn := binding.getConn()
-			if !conn.hasError() && conn.lastReadTime().Add(timeout).Before(now) {
-				buf, _ := conn.rpcCall(cmdPing)
-				buf.Free()
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-binding.ctx.Done():
+			return
+		}
+		// Broken connections to master are restored in background, and connections to replicas on demand
+		binding.masterPool().checkConns(binding)
+		for _, p := range binding.hosts {
+			for _, conn := range p.established() {
+				if !conn.hasError() && conn.lastReadTime().Add(timeout).Before(now) {
+					go binding.ping(conn, timeout)
+				}
+			}
+		}
+		binding.updatesLock.Lock()
+		if handler, _ := binding.updatesHandler.Load().(bindings.UpdatesHandler); handler != nil {
+			// Restore subscription after connection failure
+			ctx, cancel := context.WithTimeout(binding.ctx, time.Second*time.Duration(connectTimeoutSec))
+			binding.subscribeUpdates(ctx)
+			cancel()
+			// Updates connection is also pinged, otherwise server closes it as idle, while there are no updates
+			if conn := binding.updatesConn; conn != nil && !conn.hasError() && conn.lastReadTime().Add(timeout).Before(now) {
+				go binding.ping(conn, timeout)
 			}
 		}
+		binding.updatesLock.Unlock()
+	}
+}
+
+// ping checks idle connection. Connection, which does not reply in timeout, is closed to be reconnected
+func (binding *NetCProto) ping(conn *connection, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(binding.ctx, timeout)
+	defer cancel()
+	buf, err := conn.rpcCall(ctx, cmdPing)
+	buf.Free()
+	if err == context.DeadlineExceeded {
+		conn.onError(err)
 	}
 }
 
-func (binding *NetCProto) checkServerStartTime(timestamp int64) {
-	old := atomic.SwapInt64(&binding.serverStartTime, timestamp)
+func (binding *NetCProto) checkServerStartTime(p *hostPool, timestamp int64) {
+	old := atomic.SwapInt64(&p.serverStartTime, timestamp)
 	if old != 0 && old != timestamp && binding.onChangeCallback != nil {
 		binding.onChangeCallback()
 	}
@@ -1,10 +1,24 @@
 package cproto
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
 )
 
 func TestCprotoPool(t *testing.T) {
@@ -18,7 +32,7 @@ func TestCprotoPool(t *testing.T) {
 			return
 		}
 
-		pingErr := c.Ping()
+		pingErr := c.Ping(context.Background())
 		if pingErr == nil || pingErr.Error() != err.Error() {
 			t.Errorf("Must be connection error, but got: %v; want: %v", pingErr, err)
 		}
@@ -40,8 +54,8 @@ func TestCprotoPool(t *testing.T) {
 			return
 		}
 
-		if len(serv.conns) != connPoolSize {
-			t.Errorf("Unexpected connections count. Got %d; (want: %d)", len(serv.conns), connPoolSize)
+		if len(serv.conns) != defConnPoolSize {
+			t.Errorf("Unexpected connections count. Got %d; (want: %d)", len(serv.conns), defConnPoolSize)
 		}
 	})
 
@@ -83,3 +97,467 @@ func (s *testServer) acceptLoop() {
 func (s *testServer) Close() {
 	s.l.Close()
 }
+
+func TestCprotoTLS(t *testing.T) {
+	cert, pool, err := newTestCertificate()
+	if err != nil {
+		t.Fatalf("Can't generate certificate: %v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer l.Close()
+
+	// Server reads header of login request, and closes connection without reply
+	hdrCh := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		hdr := make([]byte, cprotoHdrLen)
+		if _, err := io.ReadFull(conn, hdr); err == nil {
+			hdrCh <- hdr
+		}
+	}()
+
+	addr, _ := url.Parse("cprotos://" + l.Addr().String() + "/db")
+	c := new(NetCProto)
+	defer c.Finalize()
+	c.Init(addr, bindings.OptionConnPoolSize{ConnPoolSize: 1}, bindings.OptionTLSConfig{Config: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+	}})
+
+	select {
+	case hdr := <-hdrCh:
+		ser := cjson.NewSerializer(hdr)
+		if magic := ser.GetUInt32(); magic != cprotoMagic {
+			t.Fatalf("Unexpected cproto magic '%08X'", magic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Login request was not received over TLS")
+	}
+}
+
+func newTestCertificate() (cert tls.Certificate, pool *x509.CertPool, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "reindexer test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(parsed)
+	cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: parsed}
+	return
+}
+
+func TestCprotoFailover(t *testing.T) {
+	master, err := runReplyServer()
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer master.Close()
+	replica, err := runReplyServer()
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer replica.Close()
+
+	addr, _ := url.Parse("cproto://" + master.Addr() + "," + replica.Addr() + "/db")
+	c := new(NetCProto)
+	if err = c.Init(addr, bindings.OptionConnPoolSize{ConnPoolSize: 2}, bindings.OptionReadFromReplicas{}); err != nil {
+		t.Fatalf("Can't init client: %v", err)
+	}
+	defer c.Finalize()
+
+	if err = c.rpcCallNoResults(context.Background(), opRd, cmdSelect); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.rpcCallNoResults(context.Background(), opWr, cmdModifyItem); err != nil {
+		t.Fatal(err)
+	}
+	if master.count(cmdSelect) != 0 || replica.count(cmdSelect) != 1 {
+		t.Fatalf("Read request must be routed to replica")
+	}
+	if master.count(cmdModifyItem) != 1 || replica.count(cmdModifyItem) != 0 {
+		t.Fatalf("Write request must be routed to master")
+	}
+
+	master.Close()
+	for _, conn := range c.masterPool().established() {
+		<-conn.errCh
+	}
+
+	if err = c.rpcCallNoResults(context.Background(), opWr, cmdModifyItem); err != nil {
+		t.Fatalf("Write request must fail over to replica, but got error: %v", err)
+	}
+	if replica.count(cmdModifyItem) != 1 {
+		t.Fatalf("Write request must be sent to replica after failover")
+	}
+	if c.masterPool().host != replica.Addr() {
+		t.Fatalf("Replica must become master, but master is %s", c.masterPool().host)
+	}
+}
+
+func TestCprotoReconnect(t *testing.T) {
+	serv, err := runReplyServer()
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer serv.Close()
+
+	addr, _ := url.Parse("cproto://" + serv.Addr() + "/db")
+	c := new(NetCProto)
+	backoff := bindings.OptionReconnectBackoff{MinDelay: 200 * time.Millisecond, MaxDelay: time.Second}
+	if err = c.Init(addr, bindings.OptionConnPoolSize{ConnPoolSize: 2}, backoff); err != nil {
+		t.Fatalf("Can't init client: %v", err)
+	}
+	defer c.Finalize()
+	if state := c.Status().CProto.ConnState; state != bindings.ConnStateConnected {
+		t.Fatalf("Expected connected state, but got %d", state)
+	}
+
+	serv.Close()
+	for _, conn := range c.masterPool().established() {
+		<-conn.errCh
+	}
+
+	if err = c.Ping(context.Background()); err == nil {
+		t.Fatalf("Expected connection error, while server is down")
+	}
+	status := c.Status().CProto
+	if status.ConnState != bindings.ConnStateDisconnected || len(status.Hosts) != 1 || status.Hosts[0].Failures != 1 || status.Hosts[0].LastError == nil {
+		t.Fatalf("Unexpected status, while server is down: %+v", status)
+	}
+
+	// Circuit breaker is open, so requests fail fast without reconnect
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if pingErr := c.Ping(context.Background()); pingErr == nil || pingErr.Error() != err.Error() {
+			t.Fatalf("Expected error '%v', but got '%v'", err, pingErr)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Requests must fail fast, while circuit breaker is open, but took %v", elapsed)
+	}
+
+	if serv, err = runReplyServerAt(serv.Addr()); err != nil {
+		t.Skipf("Can't restart test server: %v", err)
+	}
+	defer serv.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Ping(context.Background()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Connection is not restored after server restart: %+v", c.Status().CProto)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := c.Status().CProto.ConnState; state != bindings.ConnStateConnected {
+		t.Fatalf("Expected connected state after reconnect, but got %d", state)
+	}
+}
+
+func TestCprotoTimeouts(t *testing.T) {
+	serv, err := runReplyServer()
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer serv.Close()
+	serv.setDelay(cmdSelect, 300*time.Millisecond)
+	// Select opens query results with id 7 on server
+	serv.setResults(cmdSelect, "", 7)
+
+	addr, _ := url.Parse("cproto://" + serv.Addr() + "/db")
+	c := new(NetCProto)
+	if err = c.Init(addr, bindings.OptionConnPoolSize{ConnPoolSize: 1}, bindings.OptionTimeouts{Read: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("Can't init client: %v", err)
+	}
+	defer c.Finalize()
+
+	// Timed out requests must not hold sequence slots
+	for i := 0; i < queueSize+1; i++ {
+		if err = c.rpcCallNoResults(context.Background(), opRd, cmdSelect); err != context.DeadlineExceeded {
+			t.Fatalf("Expected timeout error, but got %v", err)
+		}
+	}
+
+	// Late replies to timed out requests must be ignored
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		buf, err := c.rpcCall(context.Background(), opWr, cmdModifyItem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd := buf.args[0].(int); cmd != cmdModifyItem {
+			t.Fatalf("Got reply to command %d instead of %d", cmd, cmdModifyItem)
+		}
+		buf.Free()
+	}
+
+	// Query results, opened by timed out requests, must be closed
+	for time.Now().Before(deadline.Add(time.Second)) && serv.count(cmdCloseResults) < queueSize+1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if closes := serv.count(cmdCloseResults); closes != queueSize+1 {
+		t.Fatalf("Expected %d close results requests, but got %d", queueSize+1, closes)
+	}
+
+	// Timeout from context overrides default timeout
+	ctx := bindings.ContextWithTimeout(context.Background(), time.Second)
+	if err = c.rpcCallNoResults(ctx, opRd, cmdSelect); err != nil {
+		t.Fatalf("Expected success with increased timeout, but got %v", err)
+	}
+}
+
+func TestCprotoDatabases(t *testing.T) {
+	serv, err := runReplyServer()
+	if err != nil {
+		t.Skipf("Can't run test server: %v", err)
+	}
+	defer serv.Close()
+
+	addr, _ := url.Parse("cproto://" + serv.Addr() + "/db")
+	c := new(NetCProto)
+	if err = c.Init(addr, bindings.OptionConnPoolSize{ConnPoolSize: 1}); err != nil {
+		t.Fatalf("Can't init client: %v", err)
+	}
+	defer c.Finalize()
+
+	if err = c.CreateDatabase(context.Background(), "newdb"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.DropDatabase(context.Background(), "newdb"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.CreateDatabase(context.Background(), ""); err == nil {
+		t.Fatalf("Expected error for empty database name")
+	}
+
+	// Databases are administrated on separate connections, which are not logged in to database from DSN
+	logins := serv.requestArgs(cmdLogin)
+	if len(logins) != 3 || logins[0][2] != "db" || logins[1][2] != "" || logins[2][2] != "" {
+		t.Fatalf("Unexpected logins: %q", logins)
+	}
+	if opens := serv.requestArgs(cmdOpenDatabase); len(opens) != 2 || opens[0][0] != "newdb" || opens[1][0] != "newdb" {
+		t.Fatalf("Unexpected open database requests: %q", opens)
+	}
+	if drops := serv.count(cmdDropDatabase); drops != 1 {
+		t.Fatalf("Expected 1 drop database request, but got %d", drops)
+	}
+
+	serv.setResults(cmdEnumNamespaces, `{"items":[{"name":"items","storage":{"enabled":true},"indexes":[{"name":"id","is_pk":true}]}]}`)
+	namespaces, err := c.EnumNamespaces(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 1 || namespaces[0].Namespace != "items" || !namespaces[0].StorageOpts.EnableStorage ||
+		len(namespaces[0].Indexes) != 1 || !namespaces[0].Indexes[0].IsPK {
+		t.Fatalf("Unexpected namespaces: %+v", namespaces)
+	}
+
+	serv.setResults(cmdEnumMeta, "key1", "key2")
+	keys, err := c.EnumMeta(context.Background(), "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "key1" || keys[1] != "key2" {
+		t.Fatalf("Unexpected meta keys: %v", keys)
+	}
+	if args := serv.requestArgs(cmdEnumMeta); len(args) != 1 || args[0][0] != "items" {
+		t.Fatalf("Unexpected enum meta requests: %q", args)
+	}
+}
+
+// replyServer replies with successful result to any request. By default result has one int argument, equal to command code,
+// and replies to commands from results have string and int arguments instead. Replies to commands from delays are sent with delay
+type replyServer struct {
+	l       net.Listener
+	lock    sync.Mutex
+	conns   []net.Conn
+	cmds    map[int]int
+	args    map[int][][]string
+	results map[int][]interface{}
+	delays  map[int]time.Duration
+}
+
+func runReplyServer() (*replyServer, error) {
+	return runReplyServerAt("127.0.0.1:0")
+}
+
+func runReplyServerAt(addr string) (*replyServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &replyServer{
+		l:       l,
+		cmds:    make(map[int]int),
+		args:    make(map[int][][]string),
+		results: make(map[int][]interface{}),
+		delays:  make(map[int]time.Duration),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.conns = append(s.conns, conn)
+			s.lock.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s, nil
+}
+
+func (s *replyServer) serve(conn net.Conn) {
+	var wrLock sync.Mutex
+	hdr := make([]byte, cprotoHdrLen)
+	for {
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		ser := cjson.NewSerializer(hdr)
+		ser.GetUInt32()
+		ser.GetUInt16()
+		cmd := int(ser.GetUInt16())
+		size := int(ser.GetUInt32())
+		seq := ser.GetUInt32()
+		req := make([]byte, size)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.cmds[cmd]++
+		s.args[cmd] = append(s.args[cmd], stringArgs(req))
+		delay := s.delays[cmd]
+		results, hasResults := s.results[cmd]
+		s.lock.Unlock()
+
+		reply := func() {
+			body := cjson.NewPoolSerializer()
+			body.PutVarUInt(0)
+			body.PutVString("")
+			if hasResults {
+				body.PutVarUInt(uint64(len(results)))
+				for _, res := range results {
+					switch res := res.(type) {
+					case int:
+						body.PutVarUInt(bindings.ValueInt)
+						body.PutVarInt(int64(res))
+					default:
+						body.PutVarUInt(bindings.ValueString)
+						body.PutVString(res.(string))
+					}
+				}
+			} else {
+				body.PutVarUInt(1)
+				body.PutVarUInt(bindings.ValueInt)
+				body.PutVarInt(int64(cmd))
+			}
+			reply := cjson.NewPoolSerializer()
+			reply.PutUInt32(cprotoMagic)
+			reply.PutUInt16(cprotoVersion)
+			reply.PutUInt16(uint16(cmd))
+			reply.PutUInt32(uint32(len(body.Bytes())))
+			reply.PutUInt32(seq)
+			reply.Write(body.Bytes())
+			wrLock.Lock()
+			conn.Write(reply.Bytes())
+			wrLock.Unlock()
+			body.Close()
+			reply.Close()
+		}
+		if delay != 0 {
+			go func() {
+				time.Sleep(delay)
+				reply()
+			}()
+		} else {
+			reply()
+		}
+	}
+}
+
+func (s *replyServer) setDelay(cmd int, delay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delays[cmd] = delay
+}
+
+func (s *replyServer) setResults(cmd int, results ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.results[cmd] = results
+}
+
+// requestArgs returns string arguments of all received requests with command cmd
+func (s *replyServer) requestArgs(cmd int) [][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.args[cmd]
+}
+
+// stringArgs decodes request arguments and returns string ones
+func stringArgs(req []byte) (args []string) {
+	ser := cjson.NewSerializer(req)
+	count := int(ser.GetVarUInt())
+	for i := 0; i < count; i++ {
+		switch ser.GetVarUInt() {
+		case bindings.ValueString:
+			args = append(args, ser.GetVString())
+		case bindings.ValueBool:
+			ser.GetVarUInt()
+		default:
+			ser.GetVarInt()
+		}
+	}
+	return
+}
+
+func (s *replyServer) count(cmd int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cmds[cmd]
+}
+
+func (s *replyServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *replyServer) Close() {
+	s.l.Close()
+	s.lock.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+}
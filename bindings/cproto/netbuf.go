@@ -1,6 +1,7 @@
 package cproto
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -19,7 +20,7 @@ type NetBuffer struct {
 	needClose bool
 }
 
-func (buf *NetBuffer) Fetch(offset, limit int, withItems bool) (err error) {
+func (buf *NetBuffer) Fetch(ctx context.Context, offset, limit int, withItems bool) (err error) {
 	flags := 0
 	if withItems {
 		flags |= bindings.ResultsJson
@@ -27,7 +28,9 @@ func (buf *NetBuffer) Fetch(offset, limit int, withItems bool) (err error) {
 		flags |= bindings.ResultsCJson | bindings.ResultsWithItemID
 	}
 	//fmt.Printf("cmdFetchResults(reqId=%d, offset=%d, limit=%d, json=%v, flags=%v)\n", buf.reqID, offset, limit, withItems, flags)
-	fetchBuf, err := buf.conn.rpcCall(cmdFetchResults, buf.reqID, flags, offset, limit)
+	ctx, cancel := buf.conn.owner.withTimeout(ctx, opRd)
+	defer cancel()
+	fetchBuf, err := buf.conn.rpcCall(ctx, cmdFetchResults, buf.reqID, flags, offset, limit)
 	defer fetchBuf.Free()
 	if err != nil {
 		buf.close()
@@ -89,7 +92,7 @@ func (buf *NetBuffer) parseArgs() (err error) {
 func (buf *NetBuffer) close() {
 	if buf.needClose && !buf.closed {
 		buf.closed = true
-		closeBuf, err := buf.conn.rpcCall(cmdCloseResults, buf.reqID)
+		closeBuf, err := buf.conn.rpcCall(context.Background(), cmdCloseResults, buf.reqID)
 		if err != nil {
 			fmt.Printf("rx: query close error: %v", err)
 		}
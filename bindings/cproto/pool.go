@@ -0,0 +1,297 @@
+package cproto
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+const (
+	defReconnectMinDelay = 100 * time.Millisecond
+	defReconnectMaxDelay = 10 * time.Second
+	defReconnectFactor   = 2.
+	defReconnectJitter   = 0.2
+)
+
+// hostPool is pool of connections to one server host. Connections are created on first use.
+// Broken connections are restored by reconnect loop with exponential backoff. While host is down,
+// the circuit breaker is open: requests fail fast with the last connection error, until the next reconnect attempt
+type hostPool struct {
+	host            string
+	serverStartTime int64
+	next            uint32
+
+	lock         sync.Mutex
+	conns        []*connection
+	state        int
+	failures     int
+	lastErr      error
+	retryAt      time.Time
+	reconnecting bool
+	// attempt is closed, when current reconnect attempt is finished
+	attempt chan struct{}
+}
+
+func newHostPool(host string, size int) *hostPool {
+	return &hostPool{
+		host:    host,
+		conns:   make([]*connection, size),
+		state:   bindings.ConnStateConnecting,
+		attempt: make(chan struct{}),
+	}
+}
+
+// connect creates all connections of pool synchronously. On error reconnect loop is started
+func (p *hostPool) connect(owner *NetCProto) (err error) {
+	err = p.connectBroken(owner)
+	p.lock.Lock()
+	p.onAttempt(owner, err)
+	if err != nil && !p.reconnecting {
+		p.reconnecting = true
+		go p.reconnectLoop(owner, true)
+	}
+	p.lock.Unlock()
+	return
+}
+
+// connectBroken creates new connections instead of broken ones. Dial is made without lock
+func (p *hostPool) connectBroken(owner *NetCProto) error {
+	for i := range p.conns {
+		p.lock.Lock()
+		conn := p.conns[i]
+		p.lock.Unlock()
+		if conn != nil && !conn.hasError() {
+			continue
+		}
+		conn, err := newConnection(owner.ctx, owner, p)
+		if err != nil {
+			return err
+		}
+		p.lock.Lock()
+		p.conns[i] = conn
+		p.lock.Unlock()
+	}
+	return nil
+}
+
+// onAttempt updates state of pool after reconnect attempt. lock must be held
+func (p *hostPool) onAttempt(owner *NetCProto, err error) {
+	if err == nil {
+		p.state, p.failures, p.lastErr = bindings.ConnStateConnected, 0, nil
+	} else {
+		p.failures++
+		p.state, p.lastErr = bindings.ConnStateDisconnected, err
+		p.retryAt = time.Now().Add(owner.reconnectDelay(p.failures))
+	}
+	close(p.attempt)
+	p.attempt = make(chan struct{})
+}
+
+// reconnectLoop restores broken connections until success, or until binding is finalized
+func (p *hostPool) reconnectLoop(owner *NetCProto, wait bool) {
+	for {
+		if wait {
+			p.lock.Lock()
+			delay := time.Until(p.retryAt)
+			p.lock.Unlock()
+			select {
+			case <-time.After(delay):
+			case <-owner.ctx.Done():
+				p.lock.Lock()
+				p.reconnecting = false
+				p.lock.Unlock()
+				return
+			}
+		}
+		p.lock.Lock()
+		p.state = bindings.ConnStateConnecting
+		p.lock.Unlock()
+
+		err := p.connectBroken(owner)
+
+		p.lock.Lock()
+		p.onAttempt(owner, err)
+		if err == nil {
+			p.reconnecting = false
+			p.lock.Unlock()
+			return
+		}
+		p.lock.Unlock()
+		wait = true
+	}
+}
+
+// startReconnect starts reconnect loop, if it's not started yet, and the next attempt is not delayed by backoff. lock must be held
+func (p *hostPool) startReconnect(owner *NetCProto) {
+	if !p.reconnecting && (p.state != bindings.ConnStateDisconnected || !time.Now().Before(p.retryAt)) {
+		p.reconnecting = true
+		go p.reconnectLoop(owner, false)
+	}
+}
+
+// checkConns starts reconnect of broken connections in background
+func (p *hostPool) checkConns(owner *NetCProto) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, conn := range p.conns {
+		if conn == nil || conn.hasError() {
+			p.startReconnect(owner)
+			return
+		}
+	}
+}
+
+// getConn returns healthy connection from pool in round robin order. If all connections are broken, it waits
+// for reconnect attempt. While circuit breaker is open, the last connection error is returned immediately
+func (p *hostPool) getConn(ctx context.Context, owner *NetCProto) (*connection, error) {
+	p.lock.Lock()
+	var conn *connection
+	hasBroken := false
+	start := int(atomic.AddUint32(&p.next, 1))
+	for i := range p.conns {
+		c := p.conns[(start+i)%len(p.conns)]
+		if c != nil && !c.hasError() {
+			if conn == nil {
+				conn = c
+			}
+		} else {
+			hasBroken = true
+		}
+	}
+
+	if hasBroken {
+		p.startReconnect(owner)
+	}
+	if conn != nil {
+		p.lock.Unlock()
+		return conn, nil
+	}
+	if p.state == bindings.ConnStateDisconnected && p.reconnecting {
+		err := p.lastErr
+		p.lock.Unlock()
+		return nil, err
+	}
+	attempt := p.attempt
+	p.lock.Unlock()
+
+	select {
+	case <-attempt:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.lastErr != nil {
+		return nil, p.lastErr
+	}
+	for _, c := range p.conns {
+		if c != nil && !c.hasError() {
+			return c, nil
+		}
+	}
+	return nil, errConnClosed
+}
+
+// available returns false, if circuit breaker of host is open
+func (p *hostPool) available() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.state != bindings.ConnStateDisconnected
+}
+
+// established returns established connections of pool without reconnect
+func (p *hostPool) established() (conns []*connection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, conn := range p.conns {
+		if conn != nil {
+			conns = append(conns, conn)
+		}
+	}
+	return
+}
+
+func (p *hostPool) status(master bool) bindings.StatusCProtoHost {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return bindings.StatusCProtoHost{
+		Host:      p.host,
+		Master:    master,
+		State:     p.state,
+		Failures:  p.failures,
+		LastError: p.lastErr,
+		RetryAt:   p.retryAt,
+	}
+}
+
+func (p *hostPool) close() {
+	for _, conn := range p.established() {
+		conn.close()
+	}
+}
+
+// reconnectDelay returns delay before reconnect attempt with exponential backoff and jitter
+func (binding *NetCProto) reconnectDelay(attempt int) time.Duration {
+	b := binding.reconnectBackoff
+	delay := float64(b.MinDelay) * math.Pow(b.Factor, float64(attempt-1))
+	if delay > float64(b.MaxDelay) {
+		delay = float64(b.MaxDelay)
+	}
+	delay -= delay * b.Jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+func (binding *NetCProto) masterPool() *hostPool {
+	return binding.hosts[atomic.LoadInt32(&binding.master)]
+}
+
+// getConn returns connection for operation. Read operations are routed to replicas, if it's enabled.
+// Otherwise connection to master is returned
+func (binding *NetCProto) getConn(ctx context.Context, op int) (*connection, error) {
+	if op == opRd && binding.readFromReplicas {
+		if conn := binding.getReplicaConn(ctx); conn != nil {
+			return conn, nil
+		}
+	}
+	return binding.getMasterConn(ctx)
+}
+
+// getMasterConn returns connection to master. If master is unavailable, the next available host becomes master
+func (binding *NetCProto) getMasterConn(ctx context.Context) (*connection, error) {
+	master := int(atomic.LoadInt32(&binding.master))
+	conn, err := binding.hosts[master].getConn(ctx, binding)
+	if err == nil || ctx.Err() != nil {
+		return conn, err
+	}
+	for i := 1; i < len(binding.hosts); i++ {
+		next := (master + i) % len(binding.hosts)
+		if nextConn, nextErr := binding.hosts[next].getConn(ctx, binding); nextErr == nil {
+			if atomic.CompareAndSwapInt32(&binding.master, int32(master), int32(next)) && binding.onChangeCallback != nil {
+				binding.onChangeCallback()
+			}
+			return nextConn, nil
+		}
+	}
+	return nil, err
+}
+
+// getReplicaConn returns connection to one of available replicas in round robin order, or nil, if there are no available replicas
+func (binding *NetCProto) getReplicaConn(ctx context.Context) *connection {
+	master := int(atomic.LoadInt32(&binding.master))
+	for i := 0; i < len(binding.hosts); i++ {
+		next := int(atomic.AddUint32(&binding.nextReplica, 1)) % len(binding.hosts)
+		if next == master || !binding.hosts[next].available() {
+			continue
+		}
+		if conn, err := binding.hosts[next].getConn(ctx, binding); err == nil {
+			return conn
+		}
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+const (
+	defConnPoolSize = 8
+
+	opRd = 0
+	opWr = 1
+)
+
+func init() {
+	bindings.RegisterBinding("http", new(HTTP))
+	bindings.RegisterBinding("https", new(HTTP))
+}
+
+// HTTP is binding, which works over HTTP REST API of reindexer server: http://host:9088/db
+// It can be used, when RPC port of server is not reachable, e.g. server is behind L7 load balancer.
+// DSN path can contain prefix of API before database name: http://balancer/reindexer/db
+// Items and query results are transferred in JSON. HTTP API does not support meta, update queries,
+// precepts and brackets in queries, so these features are not supported by binding
+type HTTP struct {
+	client   *nethttp.Client
+	apiURL   string
+	dbName   string
+	user     *url.Userinfo
+	timeouts bindings.OptionTimeouts
+}
+
+func (binding *HTTP) Init(u *url.URL, options ...interface{}) error {
+	connPoolSize := defConnPoolSize
+	var tlsConfig *tls.Config
+	for _, option := range options {
+		switch v := option.(type) {
+		case bindings.OptionConnPoolSize:
+			connPoolSize = v.ConnPoolSize
+		case bindings.OptionTLSConfig:
+			tlsConfig = v.Config
+		case bindings.OptionTimeouts:
+			binding.timeouts = v
+		default:
+			fmt.Printf("Unknown http option: %v\n", option)
+		}
+	}
+
+	path := strings.Trim(u.Path, "/")
+	prefix := ""
+	if pos := strings.LastIndexByte(path, '/'); pos >= 0 {
+		prefix, path = "/"+path[:pos], path[pos+1:]
+	}
+	binding.dbName = path
+	binding.apiURL = u.Scheme + "://" + u.Host + prefix + "/api/v1"
+	binding.user = u.User
+	binding.client = &nethttp.Client{
+		Transport: &nethttp.Transport{
+			Proxy:               nethttp.ProxyFromEnvironment,
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConnsPerHost: connPoolSize,
+		},
+	}
+	return binding.Ping(context.Background())
+}
+
+func (binding *HTTP) Clone() bindings.RawBinding {
+	return &HTTP{}
+}
+
+// JSONOnly returns true, because HTTP API accepts and returns items only in JSON format
+func (binding *HTTP) JSONOnly() bool {
+	return true
+}
+
+func (binding *HTTP) Ping(ctx context.Context) error {
+	_, err := binding.request(ctx, opRd, "GET", "/check", nil)
+	return err
+}
+
+func (binding *HTTP) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFormatError bool, cacheMode uint8) error {
+	if _, err := binding.getNamespace(ctx, namespace); !isNotFound(err) {
+		return err
+	}
+	nsDef := bindings.NamespaceDef{
+		Namespace:   namespace,
+		StorageOpts: bindings.StorageOpts{EnableStorage: enableStorage, DropOnFormatError: dropOnFormatError},
+		CacheMode:   cacheMode,
+	}
+	err := binding.requestJSON(ctx, opWr, "POST", binding.dbPath("namespaces"), nsDef, nil)
+	if err != nil {
+		// Namespace can be created concurrently by another client
+		if _, getErr := binding.getNamespace(ctx, namespace); getErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// CloseNamespace does nothing, because HTTP API can't close namespace without drop
+func (binding *HTTP) CloseNamespace(ctx context.Context, namespace string) error {
+	return ctx.Err()
+}
+
+func (binding *HTTP) DropNamespace(ctx context.Context, namespace string) error {
+	_, err := binding.request(ctx, opWr, "DELETE", binding.dbPath("namespaces", namespace), nil)
+	return err
+}
+
+// AddIndex adds index to namespace. HTTP API rejects existing indexes, so error is ignored, if index already exists
+func (binding *HTTP) AddIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	err := binding.requestJSON(ctx, opWr, "POST", binding.dbPath("namespaces", namespace, "indexes"), indexDef, nil)
+	if err != nil {
+		if nsDef, getErr := binding.getNamespace(ctx, namespace); getErr == nil {
+			for _, idx := range nsDef.Indexes {
+				if idx.Name == indexDef.Name {
+					return nil
+				}
+			}
+		}
+	}
+	return err
+}
+
+func (binding *HTTP) UpdateIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	return binding.requestJSON(ctx, opWr, "PUT", binding.dbPath("namespaces", namespace, "indexes"), indexDef, nil)
+}
+
+func (binding *HTTP) DropIndex(ctx context.Context, namespace, index string) error {
+	_, err := binding.request(ctx, opWr, "DELETE", binding.dbPath("namespaces", namespace, "indexes", index), nil)
+	return err
+}
+
+func (binding *HTTP) PutMeta(ctx context.Context, namespace, key, data string) error {
+	return errNotSupported("Meta")
+}
+
+func (binding *HTTP) GetMeta(ctx context.Context, namespace, key string) (bindings.RawBuffer, error) {
+	return nil, errNotSupported("Meta")
+}
+
+func (binding *HTTP) EnumMeta(ctx context.Context, namespace string) ([]string, error) {
+	return nil, errNotSupported("Meta")
+}
+
+// ModifyItem sends item in JSON format. HTTP API has no upsert, so upsert is made by insert and update, if item already exists
+func (binding *HTTP) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
+	if format != bindings.FormatJson {
+		return nil, errNotSupported("CJSON format of items")
+	}
+	if len(precepts) != 0 {
+		return nil, errNotSupported("Precepts")
+	}
+
+	path := binding.dbPath("namespaces", namespace, "items")
+	var method string
+	switch mode {
+	case bindings.ModeInsert, bindings.ModeUpsert:
+		method = "POST"
+	case bindings.ModeUpdate:
+		method = "PUT"
+	case bindings.ModeDelete:
+		method = "DELETE"
+	default:
+		return nil, bindings.NewError(fmt.Sprintf("Unknown modify mode %d", mode), bindings.ErrParams)
+	}
+
+	var res struct {
+		Updated int `json:"updated"`
+	}
+	if err := binding.requestResult(ctx, opWr, method, path, data, &res); err != nil {
+		return nil, err
+	}
+	if mode == bindings.ModeUpsert && res.Updated == 0 {
+		if err := binding.requestResult(ctx, opWr, "PUT", path, data, &res); err != nil {
+			return nil, err
+		}
+	}
+	return countResults(res.Updated), nil
+}
+
+// Select executes SQL query. Results are always returned in JSON format, and are not fetched by parts
+func (binding *HTTP) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	var res queryResponse
+	if err := binding.requestResult(ctx, opRd, "POST", binding.dbPath("sqlquery"), []byte(query), &res); err != nil {
+		return nil, err
+	}
+	return res.results(), nil
+}
+
+// SelectQuery executes query, converted to JSON DSL. Results are always returned in JSON format, and are not fetched by parts
+func (binding *HTTP) SelectQuery(ctx context.Context, rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	q, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	var res queryResponse
+	if err = binding.requestJSON(ctx, opRd, "POST", binding.dbPath("query"), q, &res); err != nil {
+		return nil, err
+	}
+	return res.results(), nil
+}
+
+func (binding *HTTP) DeleteQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	q, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Updated int `json:"updated"`
+	}
+	if err = binding.requestJSON(ctx, opWr, "DELETE", binding.dbPath("query"), q, &res); err != nil {
+		return nil, err
+	}
+	return countResults(res.Updated), nil
+}
+
+func (binding *HTTP) UpdateQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	return nil, errNotSupported("Update query")
+}
+
+// Commit does nothing, because server commits namespace after each modification by HTTP API
+func (binding *HTTP) Commit(ctx context.Context, namespace string) error {
+	return ctx.Err()
+}
+
+func (binding *HTTP) CreateDatabase(ctx context.Context, dbName string) error {
+	return binding.requestJSON(ctx, opWr, "POST", "/db", map[string]string{"name": dbName}, nil)
+}
+
+func (binding *HTTP) DropDatabase(ctx context.Context, dbName string) error {
+	_, err := binding.request(ctx, opWr, "DELETE", "/db/"+url.PathEscape(dbName), nil)
+	return err
+}
+
+// EnumNamespaces returns definitions of namespaces. HTTP API enumerates only opened namespaces
+func (binding *HTTP) EnumNamespaces(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	var res struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	if err := binding.requestResult(ctx, opRd, "GET", binding.dbPath("namespaces"), nil, &res); err != nil {
+		return nil, err
+	}
+	nsDefs := make([]bindings.NamespaceDef, 0, len(res.Items))
+	for _, item := range res.Items {
+		nsDef, err := binding.getNamespace(ctx, item.Name)
+		if isNotFound(err) {
+			// Namespace was dropped concurrently
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		nsDefs = append(nsDefs, *nsDef)
+	}
+	return nsDefs, nil
+}
+
+func (binding *HTTP) EnableLogger(logger bindings.Logger) {
+}
+
+func (binding *HTTP) DisableLogger() {
+}
+
+func (binding *HTTP) EnableStorage(path string) error {
+	return nil
+}
+
+func (binding *HTTP) Status() bindings.Status {
+	return bindings.Status{}
+}
+
+func (binding *HTTP) Finalize() error {
+	if binding.client != nil {
+		binding.client.Transport.(*nethttp.Transport).CloseIdleConnections()
+	}
+	return nil
+}
+
+func (binding *HTTP) getNamespace(ctx context.Context, namespace string) (*bindings.NamespaceDef, error) {
+	nsDef := &bindings.NamespaceDef{}
+	if err := binding.requestResult(ctx, opRd, "GET", binding.dbPath("namespaces", namespace), nil, nsDef); err != nil {
+		return nil, err
+	}
+	return nsDef, nil
+}
+
+// dbPath returns path of API of database, joined from escaped elems
+func (binding *HTTP) dbPath(elems ...string) string {
+	path := "/db/" + url.PathEscape(binding.dbName)
+	for _, elem := range elems {
+		path += "/" + url.PathEscape(elem)
+	}
+	return path
+}
+
+// requestJSON sends body, encoded to JSON, and decodes JSON response to result, if it's not nil
+func (binding *HTTP) requestJSON(ctx context.Context, op int, method, path string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return binding.requestResult(ctx, op, method, path, data, result)
+}
+
+// requestResult sends request, and decodes JSON response to result, if it's not nil
+func (binding *HTTP) requestResult(ctx context.Context, op int, method, path string, body []byte, result interface{}) error {
+	data, err := binding.request(ctx, op, method, path, body)
+	if err != nil || result == nil {
+		return err
+	}
+	if err = json.Unmarshal(data, result); err != nil {
+		return bindings.NewError(fmt.Sprintf("Can't parse response of server: %v", err), bindings.ErrParseJson)
+	}
+	return nil
+}
+
+// request sends request to path of API, and returns body of successful response. Error responses are converted to bindings.Error
+func (binding *HTTP) request(ctx context.Context, op int, method, path string, body []byte) ([]byte, error) {
+	ctx, cancel := binding.withTimeout(ctx, op)
+	defer cancel()
+
+	req, err := nethttp.NewRequest(method, binding.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if binding.user != nil {
+		password, _ := binding.user.Password()
+		req.SetBasicAuth(binding.user.Username(), password)
+	}
+
+	resp, err := binding.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, responseError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// withTimeout returns ctx, limited by timeout of request. Timeout from ctx overrides the default timeout of operation
+func (binding *HTTP) withTimeout(ctx context.Context, op int) (context.Context, context.CancelFunc) {
+	timeout := binding.timeouts.Write
+	if op == opRd {
+		timeout = binding.timeouts.Read
+	}
+	if d, ok := bindings.TimeoutFromContext(ctx); ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// responseError converts error response of server to bindings.Error. Server does not return code of error,
+// so code is restored from HTTP status
+func responseError(status int, data []byte) error {
+	var res struct {
+		Description string `json:"description"`
+	}
+	if json.Unmarshal(data, &res) != nil || res.Description == "" {
+		res.Description = fmt.Sprintf("Unexpected response status of server: %d %s", status, nethttp.StatusText(status))
+	}
+	code := bindings.ErrLogic
+	switch status {
+	case nethttp.StatusBadRequest:
+		code = bindings.ErrParams
+	case nethttp.StatusUnauthorized, nethttp.StatusForbidden:
+		code = bindings.ErrForbidden
+	case nethttp.StatusNotFound:
+		code = bindings.ErrNotFound
+	case nethttp.StatusBadGateway, nethttp.StatusServiceUnavailable, nethttp.StatusGatewayTimeout:
+		code = bindings.ErrNetwork
+	}
+	return bindings.NewError(res.Description, code)
+}
+
+func isNotFound(err error) bool {
+	rerr, ok := err.(bindings.Error)
+	return ok && rerr.Code() == bindings.ErrNotFound
+}
+
+func errNotSupported(feature string) error {
+	return bindings.NewError(feature+" is not supported by http binding", bindings.ErrLogic)
+}
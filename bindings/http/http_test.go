@@ -0,0 +1,318 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/restream/reindexer"
+	"github.com/restream/reindexer/bindings"
+	_ "github.com/restream/reindexer/bindings/http"
+)
+
+type testItem struct {
+	ID   int    `reindex:"id,,pk" json:"id"`
+	Name string `reindex:"name" json:"name"`
+	Year int    `reindex:"year,tree" json:"year"`
+}
+
+func TestHTTPBinding(t *testing.T) {
+	serv := newFakeServer()
+	ts := httptest.NewServer(serv)
+	defer ts.Close()
+
+	db := reindexer.NewReindex("http://" + ts.Listener.Addr().String() + "/api-prefix/testdb")
+	defer db.Close()
+	if err := db.Status().Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.OpenNamespace("items", reindexer.DefaultNamespaceOptions(), testItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Namespace and indexes already exist on server
+	db2 := reindexer.NewReindex("http://" + ts.Listener.Addr().String() + "/api-prefix/testdb")
+	defer db2.Close()
+	if err := db2.OpenNamespace("items", reindexer.DefaultNamespaceOptions(), testItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := db.Upsert("items", &testItem{ID: i, Name: fmt.Sprintf("item%d", i), Year: 2000 + i%2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Upsert("items", &testItem{ID: 4, Name: "updated", Year: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	if cnt, err := db.Insert("items", &testItem{ID: 4, Name: "inserted", Year: 2000}); err != nil || cnt != 0 {
+		t.Fatalf("Expected, that existing item is not inserted, but got %d, %v", cnt, err)
+	}
+
+	it := db.Query("items").WhereInt("year", reindexer.EQ, 2000).Sort("id", false).Limit(2).ReqTotal().Exec()
+	defer it.Close()
+	var names []string
+	for it.Next() {
+		names = append(names, it.Object().(*testItem).Name)
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "item0" || names[1] != "item2" || it.TotalCount() != 3 {
+		t.Fatalf("Unexpected results: %v, total %d", names, it.TotalCount())
+	}
+
+	expectedDSL := `{"namespace":"items","limit":2,"filters":[{"op":"and","field":"year","cond":"eq","value":[2000]}],` +
+		`"sort":[{"field":"id","desc":false}],"req_total":"enabled"}`
+	if dsl := serv.lastQuery(); dsl != expectedDSL {
+		t.Fatalf("Unexpected DSL of query:\n%s\nexpected:\n%s", dsl, expectedDSL)
+	}
+
+	q := db.Query("items")
+	q.AggregateFacet("name", "year").Sort("count", true).Limit(3).Offset(1)
+	if _, err := q.Exec().FetchAll(); err != nil {
+		t.Fatal(err)
+	}
+	expectedDSL = `{"namespace":"items","aggregations":[{"fields":["name","year"],"type":"facet",` +
+		`"sort":[{"field":"count","desc":true}],"limit":3,"offset":1}]}`
+	if dsl := serv.lastQuery(); dsl != expectedDSL {
+		t.Fatalf("Unexpected DSL of query:\n%s\nexpected:\n%s", dsl, expectedDSL)
+	}
+
+	data, err := db.Query("items").WhereInt("id", reindexer.EQ, 4).ExecToJson().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"items":[{"id":4,"name":"updated","year":2000}]}` {
+		t.Fatalf("Unexpected JSON results: %s", string(data))
+	}
+
+	if cnt, err := db.Query("items").WhereInt("year", reindexer.EQ, 2001).Delete(); err != nil || cnt != 2 {
+		t.Fatalf("Expected 2 deleted items, but got %d, %v", cnt, err)
+	}
+	if _, found := db.Query("items").WhereInt("id", reindexer.EQ, 1).Get(); found {
+		t.Fatalf("Item is not deleted")
+	}
+
+	namespaces, err := db.ListNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 1 || namespaces[0].Namespace != "items" || len(namespaces[0].Indexes) != 3 {
+		t.Fatalf("Unexpected namespaces: %+v", namespaces)
+	}
+
+	if _, err = db.GetMeta("items", "key"); err == nil {
+		t.Fatalf("Expected error, because meta is not supported")
+	}
+	if _, err = db.Query("items").OpenBracket().WhereInt("id", reindexer.EQ, 1).CloseBracket().Exec().FetchAll(); err == nil {
+		t.Fatalf("Expected error, because brackets are not supported")
+	}
+	if err = db.DropNamespace("unknown"); err == nil || err.(bindings.Error).Code() != bindings.ErrNotFound {
+		t.Fatalf("Expected not found error, but got %v", err)
+	}
+}
+
+// fakeServer is stand-in of HTTP API of reindexer server. It keeps items in memory, and supports only
+// equality filters, sort by one field, limit and offset in queries
+type fakeServer struct {
+	lock       sync.Mutex
+	namespaces map[string]*fakeNamespace
+	queries    []string
+}
+
+type fakeNamespace struct {
+	def   bindings.NamespaceDef
+	items map[string]map[string]interface{}
+}
+
+type fakeQuery struct {
+	Namespace string `json:"namespace"`
+	Limit     *int   `json:"limit"`
+	Offset    int    `json:"offset"`
+	Filters   []struct {
+		Field string        `json:"field"`
+		Cond  string        `json:"cond"`
+		Value []interface{} `json:"value"`
+	} `json:"filters"`
+	Sort []struct {
+		Field string `json:"field"`
+		Desc  bool   `json:"desc"`
+	} `json:"sort"`
+	ReqTotal string `json:"req_total"`
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{namespaces: make(map[string]*fakeNamespace)}
+}
+
+func (s *fakeServer) lastQuery() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.queries[len(s.queries)-1]
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api-prefix/api/v1/"), "/")
+	route := r.Method + " " + strings.Join(path, "/")
+
+	switch {
+	case route == "GET check":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": "test"})
+	case len(path) < 3 || path[0] != "db" || path[1] != "testdb":
+		writeStatus(w, http.StatusNotFound, "Not found")
+	case path[2] == "namespaces":
+		s.serveNamespaces(w, r.Method, path[3:], body)
+	case route == "POST db/testdb/query" || route == "DELETE db/testdb/query":
+		s.queries = append(s.queries, string(body))
+		var q fakeQuery
+		if err := json.Unmarshal(body, &q); err != nil {
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.serveQuery(w, r.Method, &q)
+	default:
+		writeStatus(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *fakeServer) serveNamespaces(w http.ResponseWriter, method string, path []string, body []byte) {
+	if len(path) == 0 {
+		switch method {
+		case "GET":
+			var items []map[string]interface{}
+			for name := range s.namespaces {
+				items = append(items, map[string]interface{}{"name": name})
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total_items": len(items)})
+		case "POST":
+			var def bindings.NamespaceDef
+			json.Unmarshal(body, &def)
+			if _, ok := s.namespaces[def.Namespace]; ok {
+				writeStatus(w, http.StatusBadRequest, "Namespace already exists")
+				return
+			}
+			s.namespaces[def.Namespace] = &fakeNamespace{def: def, items: make(map[string]map[string]interface{})}
+			writeStatus(w, http.StatusOK, "")
+		}
+		return
+	}
+
+	ns, ok := s.namespaces[path[0]]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "Namespace is not found")
+		return
+	}
+	switch method + " " + strings.Join(path[1:], "/") {
+	case "GET ":
+		writeJSON(w, http.StatusOK, ns.def)
+	case "DELETE ":
+		delete(s.namespaces, path[0])
+		writeStatus(w, http.StatusOK, "")
+	case "POST indexes":
+		var def bindings.IndexDef
+		json.Unmarshal(body, &def)
+		for _, idx := range ns.def.Indexes {
+			if idx.Name == def.Name {
+				writeStatus(w, http.StatusBadRequest, "Index already exists")
+				return
+			}
+		}
+		ns.def.Indexes = append(ns.def.Indexes, def)
+		writeStatus(w, http.StatusOK, "")
+	case "POST items", "PUT items", "DELETE items":
+		var item map[string]interface{}
+		if err := json.Unmarshal(body, &item); err != nil {
+			writeStatus(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		pk := fmt.Sprint(item["id"])
+		_, exists := ns.items[pk]
+		updated := 0
+		switch {
+		case method == "POST" && !exists, method == "PUT" && exists:
+			ns.items[pk] = item
+			updated = 1
+		case method == "DELETE" && exists:
+			delete(ns.items, pk)
+			updated = 1
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"updated": updated, "success": true})
+	default:
+		writeStatus(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *fakeServer) serveQuery(w http.ResponseWriter, method string, q *fakeQuery) {
+	ns, ok := s.namespaces[q.Namespace]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "Namespace is not found")
+		return
+	}
+
+	var items []map[string]interface{}
+	var pks []string
+	for pk, item := range ns.items {
+		matched := true
+		for _, f := range q.Filters {
+			if f.Cond != "eq" || fmt.Sprint(item[f.Field]) != fmt.Sprint(f.Value[0]) {
+				matched = false
+			}
+		}
+		if matched {
+			items = append(items, item)
+			pks = append(pks, pk)
+		}
+	}
+	sortField := "id"
+	desc := false
+	if len(q.Sort) != 0 {
+		sortField, desc = q.Sort[0].Field, q.Sort[0].Desc
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return (items[i][sortField].(float64) < items[j][sortField].(float64)) != desc
+	})
+
+	if method == "DELETE" {
+		for _, pk := range pks {
+			delete(ns.items, pk)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"updated": len(pks)})
+		return
+	}
+
+	total := len(items)
+	if q.Offset < len(items) {
+		items = items[q.Offset:]
+	} else {
+		items = nil
+	}
+	if q.Limit != nil && *q.Limit < len(items) {
+		items = items[:*q.Limit]
+	}
+	res := map[string]interface{}{"namespaces": []string{q.Namespace}, "items": items}
+	if q.ReqTotal == "enabled" {
+		res["query_total_items"] = total
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeStatus(w http.ResponseWriter, status int, description string) {
+	writeJSON(w, status, map[string]interface{}{"success": status == http.StatusOK, "response_code": status, "description": description})
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"fmt"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
+)
+
+// dslQuery is query in JSON DSL format of HTTP API. Fields of joined queries are set only for join queries
+type dslQuery struct {
+	Namespace       string           `json:"namespace"`
+	Type            string           `json:"type,omitempty"`
+	Limit           *int             `json:"limit,omitempty"`
+	Offset          int              `json:"offset,omitempty"`
+	Distinct        string           `json:"distinct,omitempty"`
+	Filters         []dslFilter      `json:"filters,omitempty"`
+	Sort            []dslSort        `json:"sort,omitempty"`
+	On              []dslJoinOn      `json:"on,omitempty"`
+	JoinQueries     []*dslQuery      `json:"join_queries,omitempty"`
+	MergeQueries    []*dslQuery      `json:"merge_queries,omitempty"`
+	SelectFilter    []string         `json:"select_filter,omitempty"`
+	SelectFunctions []string         `json:"select_functions,omitempty"`
+	ReqTotal        string           `json:"req_total,omitempty"`
+	Aggregations    []dslAggregation `json:"aggregations,omitempty"`
+	Explain         bool             `json:"explain,omitempty"`
+}
+
+type dslFilter struct {
+	Op    string        `json:"op"`
+	Field string        `json:"field"`
+	Cond  string        `json:"cond"`
+	Value []interface{} `json:"value"`
+}
+
+type dslSort struct {
+	Field  string        `json:"field"`
+	Desc   bool          `json:"desc"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+type dslJoinOn struct {
+	Op         string `json:"op"`
+	Cond       string `json:"cond"`
+	LeftField  string `json:"left_field"`
+	RightField string `json:"right_field"`
+}
+
+type dslAggregation struct {
+	Fields []string             `json:"fields"`
+	Type   string               `json:"type"`
+	Sort   []dslAggregationSort `json:"sort,omitempty"`
+	Limit  *int                 `json:"limit,omitempty"`
+	Offset int                  `json:"offset,omitempty"`
+}
+
+type dslAggregationSort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+var dslConds = map[int]string{
+	bindings.ANY:    "any",
+	bindings.EQ:     "eq",
+	bindings.LT:     "lt",
+	bindings.LE:     "le",
+	bindings.GT:     "gt",
+	bindings.GE:     "ge",
+	bindings.RANGE:  "range",
+	bindings.SET:    "set",
+	bindings.ALLSET: "allset",
+	bindings.EMPTY:  "empty",
+}
+
+var dslOps = map[int]string{
+	bindings.OpOr:  "or",
+	bindings.OpAnd: "and",
+	bindings.OpNot: "not",
+}
+
+var dslJoinTypes = map[int]string{
+	bindings.LeftJoin:    "left",
+	bindings.InnerJoin:   "inner",
+	bindings.OrInnerJoin: "orinner",
+}
+
+var dslAggTypes = map[int]string{
+	bindings.AggSum:   "sum",
+	bindings.AggAvg:   "avg",
+	bindings.AggFacet: "facet",
+	bindings.AggMin:   "min",
+	bindings.AggMax:   "max",
+}
+
+var dslReqTotal = map[int]string{
+	bindings.ModeNoCalc:        "disabled",
+	bindings.ModeCachedTotal:   "cached",
+	bindings.ModeAccurateTotal: "enabled",
+}
+
+// parseQuery converts binary query with its joined and merged queries, serialized by reindexer.Query, to JSON DSL
+func parseQuery(data []byte) (q *dslQuery, err error) {
+	defer func() {
+		if ret := recover(); ret != nil {
+			err = bindings.NewError(fmt.Sprintf("Can't parse query: %v", ret), bindings.ErrParseBin)
+		}
+	}()
+
+	ser := cjson.NewSerializer(data)
+	if q, err = parseQueryBody(&ser); err != nil {
+		return nil, err
+	}
+
+	parent := q
+	for !ser.Eof() {
+		joinType := int(ser.GetVarUInt())
+		sq, err := parseQueryBody(&ser)
+		if err != nil {
+			return nil, err
+		}
+		if joinType == bindings.Merge {
+			q.MergeQueries = append(q.MergeQueries, sq)
+			parent = sq
+		} else {
+			sq.Type = dslJoinTypes[joinType]
+			parent.JoinQueries = append(parent.JoinQueries, sq)
+		}
+	}
+	return q, nil
+}
+
+func parseQueryBody(ser *cjson.Serializer) (*dslQuery, error) {
+	q := &dslQuery{Namespace: ser.GetVString()}
+
+	for {
+		// Delete queries are sent without QueryEnd
+		tag := bindings.QueryEnd
+		if !ser.Eof() {
+			tag = int(ser.GetVarUInt())
+		}
+		switch tag {
+		case bindings.QueryEnd:
+			return q, nil
+		case bindings.QueryCondition:
+			f := dslFilter{Field: ser.GetVString()}
+			f.Op = dslOps[int(ser.GetVarUInt())]
+			f.Cond = dslConds[int(ser.GetVarUInt())]
+			f.Value = readValues(ser)
+			q.Filters = append(q.Filters, f)
+		case bindings.QueryOpenBracket, bindings.QueryCloseBracket:
+			return nil, errNotSupported("Brackets in query")
+		case bindings.QueryDistinct:
+			if q.Distinct != "" {
+				return nil, errNotSupported("Several distinct in query")
+			}
+			q.Distinct = ser.GetVString()
+		case bindings.QuerySortIndex:
+			s := dslSort{Field: ser.GetVString()}
+			s.Desc = ser.GetVarUInt() != 0
+			s.Values = readValues(ser)
+			q.Sort = append(q.Sort, s)
+		case bindings.QueryJoinOn:
+			on := dslJoinOn{}
+			on.Op = dslOps[int(ser.GetVarUInt())]
+			on.Cond = dslConds[int(ser.GetVarUInt())]
+			on.LeftField = ser.GetVString()
+			on.RightField = ser.GetVString()
+			q.On = append(q.On, on)
+		case bindings.QueryLimit:
+			limit := int(ser.GetVarUInt())
+			q.Limit = &limit
+		case bindings.QueryOffset:
+			q.Offset = int(ser.GetVarUInt())
+		case bindings.QueryReqTotal:
+			q.ReqTotal = dslReqTotal[int(ser.GetVarUInt())]
+		case bindings.QueryDebugLevel:
+			ser.GetVarUInt()
+		case bindings.QueryAggregation:
+			a := dslAggregation{Fields: []string{ser.GetVString()}}
+			a.Type = dslAggTypes[int(ser.GetVarUInt())]
+			q.Aggregations = append(q.Aggregations, a)
+		case bindings.QueryAggregationFields, bindings.QueryAggregationSort, bindings.QueryAggregationLimit, bindings.QueryAggregationOffset:
+			if len(q.Aggregations) == 0 {
+				return nil, bindings.NewError("Can't parse query: aggregation parameters without aggregation", bindings.ErrParseBin)
+			}
+			a := &q.Aggregations[len(q.Aggregations)-1]
+			switch tag {
+			case bindings.QueryAggregationFields:
+				a.Fields = make([]string, int(ser.GetVarUInt()))
+				for i := range a.Fields {
+					a.Fields[i] = ser.GetVString()
+				}
+			case bindings.QueryAggregationSort:
+				s := dslAggregationSort{Field: ser.GetVString()}
+				s.Desc = ser.GetVarUInt() != 0
+				a.Sort = append(a.Sort, s)
+			case bindings.QueryAggregationLimit:
+				limit := int(ser.GetVarUInt())
+				a.Limit = &limit
+			case bindings.QueryAggregationOffset:
+				a.Offset = int(ser.GetVarUInt())
+			}
+		case bindings.QuerySelectFilter:
+			q.SelectFilter = append(q.SelectFilter, ser.GetVString())
+		case bindings.QuerySelectFunction:
+			q.SelectFunctions = append(q.SelectFunctions, ser.GetVString())
+		case bindings.QueryExplain:
+			q.Explain = true
+		case bindings.QueryEqualPosition:
+			return nil, errNotSupported("Equal position in query")
+		case bindings.QueryUpdateField, bindings.QueryDropField:
+			return nil, errNotSupported("Update query")
+		default:
+			return nil, bindings.NewError(fmt.Sprintf("Can't parse query: unknown query tag %d", tag), bindings.ErrParseBin)
+		}
+	}
+}
+
+func readValues(ser *cjson.Serializer) []interface{} {
+	cnt := int(ser.GetVarUInt())
+	values := make([]interface{}, cnt)
+	for i := range values {
+		values[i] = readValue(ser)
+	}
+	return values
+}
+
+func readValue(ser *cjson.Serializer) interface{} {
+	switch t := int(ser.GetVarUInt()); t {
+	case bindings.ValueInt, bindings.ValueInt64:
+		return ser.GetVarInt()
+	case bindings.ValueDouble:
+		return ser.GetDouble()
+	case bindings.ValueString:
+		return ser.GetVString()
+	case bindings.ValueBool:
+		return ser.GetVarUInt() != 0
+	case bindings.ValueNull:
+		return nil
+	case bindings.ValueTuple:
+		return readValues(ser)
+	default:
+		panic(fmt.Errorf("unknown value type %d", t))
+	}
+}
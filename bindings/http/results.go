@@ -0,0 +1,63 @@
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
+)
+
+type rawResultBuffer struct {
+	buf []byte
+}
+
+func (buf *rawResultBuffer) GetBuf() []byte {
+	return buf.buf
+}
+
+func (buf *rawResultBuffer) Free() {
+}
+
+// queryResponse is response of query endpoints of HTTP API
+type queryResponse struct {
+	Items           []json.RawMessage `json:"items"`
+	Aggregations    []json.RawMessage `json:"aggregations"`
+	Explain         json.RawMessage   `json:"explain"`
+	QueryTotalItems int               `json:"query_total_items"`
+}
+
+// results converts response to the same layout of results as reindexer core returns: query params, extra results and items.
+// Items are in JSON format without ids, so they are not cached by client
+func (res *queryResponse) results() *rawResultBuffer {
+	ser := cjson.NewSerializer(nil)
+	ser.PutVarUInt(bindings.ResultsJson)
+	ser.PutVarUInt(uint64(res.QueryTotalItems))
+	ser.PutVarUInt(uint64(len(res.Items)))
+	ser.PutVarUInt(uint64(len(res.Items)))
+
+	for _, agg := range res.Aggregations {
+		ser.PutVarUInt(bindings.QueryResultAggregation)
+		ser.PutBytes(agg)
+	}
+	if len(res.Explain) != 0 {
+		ser.PutVarUInt(bindings.QueryResultExplain)
+		ser.PutBytes(res.Explain)
+	}
+	ser.PutVarUInt(bindings.QueryResultEnd)
+
+	for _, item := range res.Items {
+		ser.PutBytes(item)
+	}
+	return &rawResultBuffer{buf: ser.Bytes()}
+}
+
+// countResults returns results of modification with count of modified items, but without items
+func countResults(count int) *rawResultBuffer {
+	ser := cjson.NewSerializer(nil)
+	ser.PutVarUInt(bindings.ResultsPure)
+	ser.PutVarUInt(0)
+	ser.PutVarUInt(uint64(count))
+	ser.PutVarUInt(uint64(count))
+	ser.PutVarUInt(bindings.QueryResultEnd)
+	return &rawResultBuffer{buf: ser.Bytes()}
+}
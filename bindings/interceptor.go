@@ -0,0 +1,299 @@
+package bindings
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// Names of intercepted binding methods, passed to Interceptor in Call.Method
+const (
+	MethodOpenNamespace  = "OpenNamespace"
+	MethodCloseNamespace = "CloseNamespace"
+	MethodDropNamespace  = "DropNamespace"
+	MethodAddIndex       = "AddIndex"
+	MethodUpdateIndex    = "UpdateIndex"
+	MethodDropIndex      = "DropIndex"
+	MethodPutMeta        = "PutMeta"
+	MethodGetMeta        = "GetMeta"
+	MethodModifyItem     = "ModifyItem"
+	MethodSelect         = "Select"
+	MethodSelectQuery    = "SelectQuery"
+	MethodDeleteQuery    = "DeleteQuery"
+	MethodUpdateQuery    = "UpdateQuery"
+	MethodCommit         = "Commit"
+	MethodPing           = "Ping"
+	MethodCreateDatabase = "CreateDatabase"
+	MethodDropDatabase   = "DropDatabase"
+	MethodEnumNamespaces = "EnumNamespaces"
+	MethodEnumMeta       = "EnumMeta"
+	MethodBeginTx        = "BeginTx"
+	MethodCommitTx       = "CommitTx"
+	MethodRollbackTx     = "RollbackTx"
+)
+
+// Call describes intercepted call of binding method. Only fields, related to Method are set
+type Call struct {
+	Method    string
+	Namespace string
+	// SQL is query text of Select
+	SQL string
+	// RawQuery is serialized query of SelectQuery, DeleteQuery and UpdateQuery
+	RawQuery []byte
+	// Item is packed item of ModifyItem in Format (FormatJson or FormatCJson), and Mode is one of ModeInsert, ModeUpdate, ModeUpsert or ModeDelete
+	Item   []byte
+	Format int
+	Mode   int
+	// Index is name of index for AddIndex, UpdateIndex and DropIndex
+	Index string
+	// Key is meta key for PutMeta and GetMeta
+	Key string
+	// Database is name of database for CreateDatabase and DropDatabase
+	Database string
+	// TxID is id of transaction for ModifyItem, CommitTx and RollbackTx. It's 0 for ModifyItem out of transaction
+	TxID int
+}
+
+// Invoker calls next interceptor in chain, or binding method. RawBuffer is nil for methods, which return only error
+type Invoker func(ctx context.Context) (RawBuffer, error)
+
+// Interceptor is called instead of binding method. It must call invoker to continue call,
+// and can modify ctx, inspect results, or return own error without calling invoker
+type Interceptor func(ctx context.Context, call *Call, invoker Invoker) (RawBuffer, error)
+
+// OptionInterceptors wraps binding with interceptors. The first interceptor is the outermost
+type OptionInterceptors struct {
+	Interceptors []Interceptor
+}
+
+// InterceptedBinding is binding, wrapped with chain of interceptors.
+// Fetching of the next parts of results with FetchMore is not intercepted
+type InterceptedBinding struct {
+	RawBinding
+	interceptors []Interceptor
+}
+
+// NewInterceptedBinding wraps binding with interceptors
+func NewInterceptedBinding(binding RawBinding, interceptors ...Interceptor) *InterceptedBinding {
+	return &InterceptedBinding{RawBinding: binding, interceptors: interceptors}
+}
+
+// Unwrap returns wrapped binding
+func (binding *InterceptedBinding) Unwrap() RawBinding {
+	return binding.RawBinding
+}
+
+func (binding *InterceptedBinding) call(ctx context.Context, call *Call, invoker Invoker) (RawBuffer, error) {
+	for i := len(binding.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := binding.interceptors[i], invoker
+		invoker = func(ctx context.Context) (RawBuffer, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return invoker(ctx)
+}
+
+func (binding *InterceptedBinding) callNoResults(ctx context.Context, call *Call, invoker func(ctx context.Context) error) error {
+	_, err := binding.call(ctx, call, func(ctx context.Context) (RawBuffer, error) {
+		return nil, invoker(ctx)
+	})
+	return err
+}
+
+func (binding *InterceptedBinding) Clone() RawBinding {
+	return NewInterceptedBinding(binding.RawBinding.Clone(), binding.interceptors...)
+}
+
+func (binding *InterceptedBinding) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodOpenNamespace, Namespace: namespace}, func(ctx context.Context) error {
+		return binding.RawBinding.OpenNamespace(ctx, namespace, enableStorage, dropOnFileFormatError, cacheMode)
+	})
+}
+
+func (binding *InterceptedBinding) CloseNamespace(ctx context.Context, namespace string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodCloseNamespace, Namespace: namespace}, func(ctx context.Context) error {
+		return binding.RawBinding.CloseNamespace(ctx, namespace)
+	})
+}
+
+func (binding *InterceptedBinding) DropNamespace(ctx context.Context, namespace string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodDropNamespace, Namespace: namespace}, func(ctx context.Context) error {
+		return binding.RawBinding.DropNamespace(ctx, namespace)
+	})
+}
+
+func (binding *InterceptedBinding) AddIndex(ctx context.Context, namespace string, indexDef IndexDef) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodAddIndex, Namespace: namespace, Index: indexDef.Name}, func(ctx context.Context) error {
+		return binding.RawBinding.AddIndex(ctx, namespace, indexDef)
+	})
+}
+
+func (binding *InterceptedBinding) UpdateIndex(ctx context.Context, namespace string, indexDef IndexDef) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodUpdateIndex, Namespace: namespace, Index: indexDef.Name}, func(ctx context.Context) error {
+		return binding.RawBinding.UpdateIndex(ctx, namespace, indexDef)
+	})
+}
+
+func (binding *InterceptedBinding) DropIndex(ctx context.Context, namespace, index string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodDropIndex, Namespace: namespace, Index: index}, func(ctx context.Context) error {
+		return binding.RawBinding.DropIndex(ctx, namespace, index)
+	})
+}
+
+func (binding *InterceptedBinding) PutMeta(ctx context.Context, namespace, key, data string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodPutMeta, Namespace: namespace, Key: key}, func(ctx context.Context) error {
+		return binding.RawBinding.PutMeta(ctx, namespace, key, data)
+	})
+}
+
+func (binding *InterceptedBinding) GetMeta(ctx context.Context, namespace, key string) (RawBuffer, error) {
+	return binding.call(ctx, &Call{Method: MethodGetMeta, Namespace: namespace, Key: key}, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.GetMeta(ctx, namespace, key)
+	})
+}
+
+func (binding *InterceptedBinding) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, percepts []string, stateToken int, txID int) (RawBuffer, error) {
+	call := &Call{Method: MethodModifyItem, Namespace: namespace, Item: data, Format: format, Mode: mode, TxID: txID}
+	return binding.call(ctx, call, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.ModifyItem(ctx, nsHash, namespace, format, data, mode, percepts, stateToken, txID)
+	})
+}
+
+func (binding *InterceptedBinding) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error) {
+	return binding.call(ctx, &Call{Method: MethodSelect, SQL: query}, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.Select(ctx, query, withItems, ptVersions, fetchCount)
+	})
+}
+
+func (binding *InterceptedBinding) SelectQuery(ctx context.Context, rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error) {
+	call := &Call{Method: MethodSelectQuery, Namespace: rawQueryNamespace(rawQuery), RawQuery: rawQuery}
+	return binding.call(ctx, call, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.SelectQuery(ctx, rawQuery, withItems, ptVersions, fetchCount)
+	})
+}
+
+func (binding *InterceptedBinding) DeleteQuery(ctx context.Context, nsHash int, rawQuery []byte) (RawBuffer, error) {
+	call := &Call{Method: MethodDeleteQuery, Namespace: rawQueryNamespace(rawQuery), RawQuery: rawQuery}
+	return binding.call(ctx, call, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.DeleteQuery(ctx, nsHash, rawQuery)
+	})
+}
+
+func (binding *InterceptedBinding) UpdateQuery(ctx context.Context, nsHash int, rawQuery []byte) (RawBuffer, error) {
+	call := &Call{Method: MethodUpdateQuery, Namespace: rawQueryNamespace(rawQuery), RawQuery: rawQuery}
+	return binding.call(ctx, call, func(ctx context.Context) (RawBuffer, error) {
+		return binding.RawBinding.UpdateQuery(ctx, nsHash, rawQuery)
+	})
+}
+
+func (binding *InterceptedBinding) Commit(ctx context.Context, namespace string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodCommit, Namespace: namespace}, func(ctx context.Context) error {
+		return binding.RawBinding.Commit(ctx, namespace)
+	})
+}
+
+func (binding *InterceptedBinding) Ping(ctx context.Context) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodPing}, func(ctx context.Context) error {
+		return binding.RawBinding.Ping(ctx)
+	})
+}
+
+func (binding *InterceptedBinding) CreateDatabase(ctx context.Context, dbName string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodCreateDatabase, Database: dbName}, func(ctx context.Context) error {
+		return binding.RawBinding.CreateDatabase(ctx, dbName)
+	})
+}
+
+func (binding *InterceptedBinding) DropDatabase(ctx context.Context, dbName string) error {
+	return binding.callNoResults(ctx, &Call{Method: MethodDropDatabase, Database: dbName}, func(ctx context.Context) error {
+		return binding.RawBinding.DropDatabase(ctx, dbName)
+	})
+}
+
+func (binding *InterceptedBinding) EnumNamespaces(ctx context.Context) (defs []NamespaceDef, err error) {
+	err = binding.callNoResults(ctx, &Call{Method: MethodEnumNamespaces}, func(ctx context.Context) (err error) {
+		defs, err = binding.RawBinding.EnumNamespaces(ctx)
+		return
+	})
+	return
+}
+
+func (binding *InterceptedBinding) EnumMeta(ctx context.Context, namespace string) (keys []string, err error) {
+	err = binding.callNoResults(ctx, &Call{Method: MethodEnumMeta, Namespace: namespace}, func(ctx context.Context) (err error) {
+		keys, err = binding.RawBinding.EnumMeta(ctx, namespace)
+		return
+	})
+	return
+}
+
+// OnChangeCallback is forwarded to wrapped binding, if it implements RawBindingChanging
+func (binding *InterceptedBinding) OnChangeCallback(f func()) {
+	if changing, ok := binding.RawBinding.(RawBindingChanging); ok {
+		changing.OnChangeCallback(f)
+	}
+}
+
+// JSONOnly is forwarded to wrapped binding, if it implements RawBindingJSON
+func (binding *InterceptedBinding) JSONOnly() bool {
+	j, ok := binding.RawBinding.(RawBindingJSON)
+	return ok && j.JSONOnly()
+}
+
+// SubscribeUpdates is forwarded to wrapped binding, if it implements RawBindingUpdates
+func (binding *InterceptedBinding) SubscribeUpdates(ctx context.Context, handler UpdatesHandler) error {
+	if updates, ok := binding.RawBinding.(RawBindingUpdates); ok {
+		return updates.SubscribeUpdates(ctx, handler)
+	}
+	return NewError("Binding does not support updates subscription", ErrLogic)
+}
+
+// UnsubscribeUpdates is forwarded to wrapped binding, if it implements RawBindingUpdates
+func (binding *InterceptedBinding) UnsubscribeUpdates(ctx context.Context) error {
+	if updates, ok := binding.RawBinding.(RawBindingUpdates); ok {
+		return updates.UnsubscribeUpdates(ctx)
+	}
+	return nil
+}
+
+// BeginTx is forwarded to wrapped binding, if it implements RawBindingTx
+func (binding *InterceptedBinding) BeginTx(ctx context.Context, namespace string) (txID int, err error) {
+	tx, ok := binding.RawBinding.(RawBindingTx)
+	if !ok {
+		return 0, NewError("Binding does not support transactions", ErrLogic)
+	}
+	err = binding.callNoResults(ctx, &Call{Method: MethodBeginTx, Namespace: namespace}, func(ctx context.Context) (err error) {
+		txID, err = tx.BeginTx(ctx, namespace)
+		return
+	})
+	return
+}
+
+// CommitTx is forwarded to wrapped binding, if it implements RawBindingTx
+func (binding *InterceptedBinding) CommitTx(ctx context.Context, txID int) (RawBuffer, error) {
+	tx, ok := binding.RawBinding.(RawBindingTx)
+	if !ok {
+		return nil, NewError("Binding does not support transactions", ErrLogic)
+	}
+	return binding.call(ctx, &Call{Method: MethodCommitTx, TxID: txID}, func(ctx context.Context) (RawBuffer, error) {
+		return tx.CommitTx(ctx, txID)
+	})
+}
+
+// RollbackTx is forwarded to wrapped binding, if it implements RawBindingTx
+func (binding *InterceptedBinding) RollbackTx(ctx context.Context, txID int) error {
+	tx, ok := binding.RawBinding.(RawBindingTx)
+	if !ok {
+		return NewError("Binding does not support transactions", ErrLogic)
+	}
+	return binding.callNoResults(ctx, &Call{Method: MethodRollbackTx, TxID: txID}, func(ctx context.Context) error {
+		return tx.RollbackTx(ctx, txID)
+	})
+}
+
+// rawQueryNamespace returns namespace of serialized query. Serialized query starts with namespace name
+func rawQueryNamespace(rawQuery []byte) string {
+	l, n := binary.Uvarint(rawQuery)
+	if n <= 0 || uint64(len(rawQuery)-n) < l {
+		return ""
+	}
+	return string(rawQuery[n : n+int(l)])
+}
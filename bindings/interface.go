@@ -1,6 +1,8 @@
 package bindings
 
 import (
+	"context"
+	"crypto/tls"
 	"net/url"
 	"time"
 
@@ -31,6 +33,7 @@ type NamespaceDef struct {
 	Namespace   string      `json:"name"`
 	StorageOpts StorageOpts `json:"storage"`
 	CacheMode   uint8       `json:"cached_mode"`
+	Indexes     []IndexDef  `json:"indexes,omitempty"`
 }
 
 type StorageOptions uint8
@@ -62,7 +65,7 @@ type RawBuffer interface {
 
 // FetchMore interface for partial loading results (used in cproto)
 type FetchMore interface {
-	Fetch(offset, limit int, withItems bool) (err error)
+	Fetch(ctx context.Context, offset, limit int, withItems bool) (err error)
 }
 
 // Logger interface for reindexer
@@ -105,26 +108,33 @@ type Stats struct {
 }
 
 // Raw binding to reindexer
+// Methods, which are sending requests to reindexer accept context.Context.
+// Binding must stop waiting for the result and return ctx.Err() when ctx is done
 type RawBinding interface {
 	Init(u *url.URL, options ...interface{}) error
 	Clone() RawBinding
-	OpenNamespace(namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error
-	CloseNamespace(namespace string) error
-	DropNamespace(namespace string) error
+	OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error
+	CloseNamespace(ctx context.Context, namespace string) error
+	DropNamespace(ctx context.Context, namespace string) error
 	EnableStorage(namespace string) error
-	AddIndex(namespace string, indexDef IndexDef) error
-	UpdateIndex(namespace string, indexDef IndexDef) error
-	DropIndex(namespace, index string) error
-	PutMeta(namespace, key, data string) error
-	GetMeta(namespace, key string) (RawBuffer, error)
-	ModifyItem(nsHash int, namespace string, format int, data []byte, mode int, percepts []string, stateToken int, txID int) (RawBuffer, error)
-	Select(query string, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error)
-	SelectQuery(rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error)
-	DeleteQuery(nsHash int, rawQuery []byte) (RawBuffer, error)
-	Commit(namespace string) error
+	AddIndex(ctx context.Context, namespace string, indexDef IndexDef) error
+	UpdateIndex(ctx context.Context, namespace string, indexDef IndexDef) error
+	DropIndex(ctx context.Context, namespace, index string) error
+	PutMeta(ctx context.Context, namespace, key, data string) error
+	GetMeta(ctx context.Context, namespace, key string) (RawBuffer, error)
+	ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, percepts []string, stateToken int, txID int) (RawBuffer, error)
+	Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error)
+	SelectQuery(ctx context.Context, rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (RawBuffer, error)
+	DeleteQuery(ctx context.Context, nsHash int, rawQuery []byte) (RawBuffer, error)
+	UpdateQuery(ctx context.Context, nsHash int, rawQuery []byte) (RawBuffer, error)
+	Commit(ctx context.Context, namespace string) error
+	CreateDatabase(ctx context.Context, dbName string) error
+	DropDatabase(ctx context.Context, dbName string) error
+	EnumNamespaces(ctx context.Context) ([]NamespaceDef, error)
+	EnumMeta(ctx context.Context, namespace string) ([]string, error)
 	EnableLogger(logger Logger)
 	DisableLogger()
-	Ping() error
+	Ping(ctx context.Context) error
 	Finalize() error
 	Status() Status
 }
@@ -133,6 +143,32 @@ type RawBindingChanging interface {
 	OnChangeCallback(f func())
 }
 
+// UpdatesHandler is called by binding on each modification of item in reindexer
+// mode is one of ModeInsert, ModeUpdate, ModeUpsert or ModeDelete, and data is JSON of item
+// Handler must not block, and must not keep data after return
+type UpdatesHandler func(namespace string, mode int, data []byte)
+
+// RawBindingUpdates is implemented by bindings, which are able to deliver modifications of items
+type RawBindingUpdates interface {
+	SubscribeUpdates(ctx context.Context, handler UpdatesHandler) error
+	UnsubscribeUpdates(ctx context.Context) error
+}
+
+// RawBindingTx is implemented by bindings, which support transactions of reindexer.
+// Items are added to transaction by ModifyItem with txID, returned by BeginTx. Changes are not visible until CommitTx,
+// which applies all of them atomically and returns results with ids of changed items. RollbackTx discards changes
+type RawBindingTx interface {
+	BeginTx(ctx context.Context, namespace string) (txID int, err error)
+	CommitTx(ctx context.Context, txID int) (RawBuffer, error)
+	RollbackTx(ctx context.Context, txID int) error
+}
+
+// RawBindingJSON is implemented by bindings, which exchange items with server only in JSON format, e.g. http binding.
+// Items are sent to such bindings in JSON, and results of queries are returned in JSON instead of CJSON
+type RawBindingJSON interface {
+	JSONOnly() bool
+}
+
 var availableBindings = make(map[string]RawBinding)
 
 func RegisterBinding(name string, binding RawBinding) {
@@ -164,6 +200,56 @@ type OptionRetryAttempts struct {
 	Write int
 }
 
+// OptionHosts adds hosts to list of cproto server hosts, in addition to hosts from DSN.
+// DSN can also contain several comma separated hosts: cproto://host1:6534,host2:6534/db
+// The first available host is used as master. If master is unavailable, the binding fails over to the next available host
+type OptionHosts struct {
+	Hosts []string
+}
+
+// OptionReadFromReplicas routes read requests of cproto binding to replicas, i.e. hosts except current master.
+// If there are no available replicas, read requests are sent to master
+type OptionReadFromReplicas struct {
+}
+
+// OptionReconnectBackoff sets delays between reconnect attempts and retries of requests of cproto binding.
+// The delay grows from MinDelay by Factor with each failed attempt up to MaxDelay, and is randomly decreased by up to Jitter part.
+// Zero fields are set to defaults
+type OptionReconnectBackoff struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	Factor   float64
+	Jitter   float64
+}
+
+// OptionTimeouts sets default timeouts of read and write requests of cproto and http bindings. Zero timeout means no timeout
+type OptionTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+type timeoutKey struct{}
+
+// ContextWithTimeout returns ctx, which overrides default timeout of binding requests, set by OptionTimeouts
+func ContextWithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// TimeoutFromContext returns timeout of binding requests, set by ContextWithTimeout
+func TimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	return timeout, ok
+}
+
+// OptionTLSConfig enables TLS for cproto connections. It's enabled by default for cprotos:// DSN.
+// For http binding it sets TLS config of https:// connections.
+// If ServerName is not set in Config, host from DSN is used.
+// Builtinserver serves TLS, if TLSCert and TLSKey are set in NetConf of server config. Standalone reindexer server
+// does not serve cproto over TLS itself, so cprotos:// requires TLS terminating proxy in front of it
+type OptionTLSConfig struct {
+	Config *tls.Config
+}
+
 type OptionBuiltinWithServer struct {
 	StartupTimeout  time.Duration
 	ShutdownTimeout time.Duration
@@ -181,6 +267,28 @@ type StatusCProto struct {
 	ConnPoolUsage  int
 	ConnQueueSize  int
 	ConnQueueUsage int
+	// ConnState is state of connections to master: ConnStateConnecting, ConnStateConnected or ConnStateDisconnected
+	ConnState int
+	Hosts     []StatusCProtoHost
+}
+
+// Connection states of cproto host
+const (
+	ConnStateConnecting   = 0
+	ConnStateConnected    = 1
+	ConnStateDisconnected = 2
+)
+
+// StatusCProtoHost is status of connections to one cproto server host
+type StatusCProtoHost struct {
+	Host   string
+	Master bool
+	State  int
+	// Failures is number of reconnect attempts failed in a row
+	Failures  int
+	LastError error
+	// RetryAt is time of the next reconnect attempt. Requests fail fast with LastError until this time
+	RetryAt time.Time
 }
 
 type StatusBuiltin struct {
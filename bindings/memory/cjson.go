@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/restream/reindexer/cjson"
+)
+
+// ctag layout is the same as in cjson package: type | name << 3 | field << 15.
+// Memory binding has no payload, so all values are stored in tuple, and field is always 0
+const (
+	ctagTypeBits = 3
+	ctagNameBits = 12
+)
+
+func mkctag(tagType, tagName int) uint64 {
+	return uint64(tagType | tagName<<ctagTypeBits)
+}
+
+// decodeCJSON decodes item, packed by cjson.Encoder. If item contains updated tagsMatcher, it is used instead of tags
+func decodeCJSON(data []byte, tags []string) (obj map[string]interface{}, err error) {
+	defer func() {
+		if ret := recover(); ret != nil {
+			err = fmt.Errorf("Can't decode CJSON: %v", ret)
+		}
+	}()
+
+	if len(data) > 5 && data[0] == cjson.TAG_END {
+		offset := int(binary.LittleEndian.Uint32(data[1:5]))
+		if offset > len(data) || offset < 5 {
+			return nil, fmt.Errorf("Can't decode CJSON: invalid tags matcher offset %d", offset)
+		}
+		tmSer := cjson.NewSerializer(data[offset:])
+		tags = make([]string, int(tmSer.GetVarUInt()))
+		for i := range tags {
+			tags[i] = tmSer.GetVString()
+		}
+		data = data[5:offset]
+	}
+
+	dec := cjsonDecoder{ser: cjson.NewSerializer(data), tags: tags}
+	tagType, _ := dec.readCtag()
+	if tagType != cjson.TAG_OBJECT {
+		return nil, fmt.Errorf("Can't decode CJSON: item must be an object")
+	}
+	return dec.readObject(), nil
+}
+
+type cjsonDecoder struct {
+	ser  cjson.Serializer
+	tags []string
+}
+
+func (dec *cjsonDecoder) readCtag() (tagType int, name string) {
+	tag := int(dec.ser.GetVarUInt())
+	tagType = tag & (1<<ctagTypeBits - 1)
+	tagName := (tag >> ctagTypeBits) & (1<<ctagNameBits - 1)
+	if tag>>(ctagTypeBits+ctagNameBits) != 0 {
+		panic(fmt.Errorf("payload fields are not supported"))
+	}
+	if tagName != 0 {
+		if tagName > len(dec.tags) {
+			panic(fmt.Errorf("unknown tag %d", tagName))
+		}
+		name = dec.tags[tagName-1]
+	}
+	return tagType, name
+}
+
+func (dec *cjsonDecoder) readObject() map[string]interface{} {
+	obj := make(map[string]interface{})
+	for {
+		tagType, name := dec.readCtag()
+		if tagType == cjson.TAG_END {
+			return obj
+		}
+		obj[name] = dec.readValue(tagType)
+	}
+}
+
+func (dec *cjsonDecoder) readValue(tagType int) interface{} {
+	switch tagType {
+	case cjson.TAG_VARINT:
+		return dec.ser.GetVarInt()
+	case cjson.TAG_DOUBLE:
+		return dec.ser.GetDouble()
+	case cjson.TAG_STRING:
+		return dec.ser.GetVString()
+	case cjson.TAG_BOOL:
+		return dec.ser.GetVarUInt() != 0
+	case cjson.TAG_NULL:
+		return nil
+	case cjson.TAG_OBJECT:
+		return dec.readObject()
+	case cjson.TAG_ARRAY:
+		atag := dec.ser.GetUInt32()
+		count := int(atag & (1<<24 - 1))
+		subtag := int(atag >> 24)
+		arr := make([]interface{}, count)
+		for i := range arr {
+			if subtag == cjson.TAG_OBJECT {
+				elemType, _ := dec.readCtag()
+				arr[i] = dec.readValue(elemType)
+			} else {
+				arr[i] = dec.readValue(subtag)
+			}
+		}
+		return arr
+	}
+	panic(fmt.Errorf("invalid tag type %d", tagType))
+}
+
+// encodeCJSON packs item to CJSON with namespace's tags. New tags are added to namespace
+func (ns *namespace) encodeCJSON(ser *cjson.Serializer, obj map[string]interface{}) {
+	ns.encodeValue(ser, obj, 0)
+}
+
+func (ns *namespace) encodeValue(ser *cjson.Serializer, v interface{}, tagName int) {
+	switch v := v.(type) {
+	case nil:
+		ser.PutVarUInt(mkctag(cjson.TAG_NULL, tagName))
+	case bool:
+		ser.PutVarUInt(mkctag(cjson.TAG_BOOL, tagName))
+		ns.putScalar(ser, v)
+	case int64:
+		ser.PutVarUInt(mkctag(cjson.TAG_VARINT, tagName))
+		ns.putScalar(ser, v)
+	case float64:
+		ser.PutVarUInt(mkctag(cjson.TAG_DOUBLE, tagName))
+		ns.putScalar(ser, v)
+	case string:
+		ser.PutVarUInt(mkctag(cjson.TAG_STRING, tagName))
+		ns.putScalar(ser, v)
+	case map[string]interface{}:
+		ser.PutVarUInt(mkctag(cjson.TAG_OBJECT, tagName))
+		for k, e := range v {
+			ns.encodeValue(ser, e, ns.name2tag(k))
+		}
+		ser.PutVarUInt(mkctag(cjson.TAG_END, 0))
+	case []interface{}:
+		// Elements are encoded with own tags, because type of array in Go is unknown here: Go decoder can't decode
+		// array of scalar subtag to slice of pointers, e.g. []*int, but decodes tagged elements to any slice
+		ser.PutVarUInt(mkctag(cjson.TAG_ARRAY, tagName))
+		ser.PutUInt32(uint32(len(v) | cjson.TAG_OBJECT<<24))
+		for _, e := range v {
+			ns.encodeValue(ser, e, 0)
+		}
+	default:
+		panic(fmt.Errorf("Internal error: unexpected value type %T", v))
+	}
+}
+
+func (ns *namespace) putScalar(ser *cjson.Serializer, v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			ser.PutVarUInt(1)
+		} else {
+			ser.PutVarUInt(0)
+		}
+	case int64:
+		ser.PutVarInt(v)
+	case float64:
+		ser.PutDouble(v)
+	case string:
+		ser.PutVString(v)
+	}
+}
@@ -0,0 +1,702 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+const namespacesNamespaceName = "#namespaces"
+
+func init() {
+	bindings.RegisterBinding("memory", new(Memory))
+}
+
+// Memory is pure Go binding, which keeps namespaces in memory of process.
+// It is intended for unit tests, which can't use C++ library or reindexer server.
+// Query engine supports conditions, brackets, sorts, distinct, limits, joins, merges and aggregations,
+// but SQL and full text search ranking are not supported
+type Memory struct {
+	lock       sync.Mutex
+	namespaces map[string]*namespace
+	updates    bindings.UpdatesHandler
+	logger     bindings.Logger
+	txs        map[int]*transaction
+	nextTxID   int
+}
+
+// transaction is list of modifications of namespace items, which are applied together on commit
+type transaction struct {
+	namespace string
+	steps     []txStep
+}
+
+type txStep struct {
+	obj      map[string]interface{}
+	mode     int
+	precepts []string
+}
+
+type updateEvent struct {
+	namespace string
+	mode      int
+	data      []byte
+}
+
+func (binding *Memory) Init(u *url.URL, options ...interface{}) error {
+	binding.namespaces = make(map[string]*namespace)
+	binding.txs = make(map[int]*transaction)
+	return nil
+}
+
+func (binding *Memory) Clone() bindings.RawBinding {
+	return &Memory{}
+}
+
+func errNsNotFound(namespace string) error {
+	return bindings.NewError(fmt.Sprintf("Namespace '%s' does not exist", namespace), bindings.ErrNotFound)
+}
+
+// getNamespace returns namespace by name. System namespaces are created on demand
+func (binding *Memory) getNamespace(name string) (*namespace, error) {
+	name = strings.ToLower(name)
+	ns, ok := binding.namespaces[name]
+	if !ok {
+		if !strings.HasPrefix(name, "#") {
+			return nil, errNsNotFound(name)
+		}
+		ns = newNamespace(name)
+		ns.indexes = []bindings.IndexDef{{Name: "name", JSONPaths: []string{"name"}, IndexType: "hash", FieldType: "string", IsPK: true}}
+		binding.namespaces[name] = ns
+	}
+	if name == namespacesNamespaceName {
+		binding.describeNamespaces(ns)
+	}
+	return ns, nil
+}
+
+// describeNamespaces fills #namespaces with descriptions of user's namespaces
+func (binding *Memory) describeNamespaces(sysNs *namespace) {
+	exists := make(map[string]bool)
+	for name, ns := range binding.namespaces {
+		if strings.HasPrefix(name, "#") {
+			continue
+		}
+		indexes := make([]interface{}, 0, len(ns.indexes))
+		for _, def := range ns.indexes {
+			data, _ := json.Marshal(def)
+			idx, _ := parseJSON(data)
+			idx["is_sortable"] = def.IndexType != "text"
+			idx["is_fulltext"] = def.IndexType == "text"
+			indexes = append(indexes, idx)
+		}
+		sysNs.modify(map[string]interface{}{"name": name, "indexes": indexes, "storage_enabled": false}, bindings.ModeUpsert)
+		exists[name] = true
+	}
+	for _, it := range sysNs.sortedItems() {
+		if name, _ := it.obj["name"].(string); !exists[name] {
+			sysNs.modify(it.obj, bindings.ModeDelete)
+		}
+	}
+}
+
+func (binding *Memory) OpenNamespace(ctx context.Context, namespace string, enableStorage, dropOnFileFormatError bool, cacheMode uint8) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	name := strings.ToLower(namespace)
+	if _, ok := binding.namespaces[name]; !ok {
+		binding.namespaces[name] = newNamespace(name)
+	}
+	return nil
+}
+
+// CloseNamespace drops namespace, because memory binding has no storage
+func (binding *Memory) CloseNamespace(ctx context.Context, namespace string) error {
+	return binding.DropNamespace(ctx, namespace)
+}
+
+func (binding *Memory) DropNamespace(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	name := strings.ToLower(namespace)
+	if _, ok := binding.namespaces[name]; !ok {
+		return errNsNotFound(name)
+	}
+	delete(binding.namespaces, name)
+	return nil
+}
+
+func (binding *Memory) EnableStorage(namespace string) error {
+	return nil
+}
+
+func (binding *Memory) AddIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	if idx := ns.findIndex(indexDef.Name); idx >= 0 {
+		if sameIndexDef(ns.indexes[idx], indexDef) {
+			return nil
+		}
+		return bindings.NewError(fmt.Sprintf("Index '%s.%s' already exists with different settings", ns.name, indexDef.Name), bindings.ErrConflict)
+	}
+	ns.indexes = append(ns.indexes, indexDef)
+	ns.rebuildPKs()
+	return nil
+}
+
+// sameIndexDef compares definitions of index as core does: config is ignored, and default index type and collate mode
+// are equal to explicit ones
+func sameIndexDef(a, b bindings.IndexDef) bool {
+	a.Config, b.Config = nil, nil
+	a.IndexType, b.IndexType = indexType(a), indexType(b)
+	if a.CollateMode == "" {
+		a.CollateMode = "none"
+	}
+	if b.CollateMode == "" {
+		b.CollateMode = "none"
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func indexType(def bindings.IndexDef) string {
+	switch {
+	case def.IndexType != "":
+		return def.IndexType
+	case def.FieldType == "double":
+		return "tree"
+	case def.FieldType == "bool":
+		return "-"
+	}
+	return "hash"
+}
+
+func (binding *Memory) UpdateIndex(ctx context.Context, namespace string, indexDef bindings.IndexDef) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	idx := ns.findIndex(indexDef.Name)
+	if idx < 0 {
+		return bindings.NewError(fmt.Sprintf("Index '%s.%s' does not exist", ns.name, indexDef.Name), bindings.ErrParams)
+	}
+	ns.indexes[idx] = indexDef
+	ns.rebuildPKs()
+	return nil
+}
+
+func (binding *Memory) DropIndex(ctx context.Context, namespace, index string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	idx := ns.findIndex(index)
+	if idx < 0 {
+		return bindings.NewError(fmt.Sprintf("Index '%s.%s' does not exist", ns.name, index), bindings.ErrParams)
+	}
+	ns.indexes = append(ns.indexes[:idx], ns.indexes[idx+1:]...)
+	ns.rebuildPKs()
+	return nil
+}
+
+func (binding *Memory) PutMeta(ctx context.Context, namespace, key, data string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return err
+	}
+	ns.meta[key] = data
+	return nil
+}
+
+func (binding *Memory) GetMeta(ctx context.Context, namespace, key string) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &rawResultBuffer{buf: []byte(ns.meta[key])}, nil
+}
+
+func (binding *Memory) ModifyItem(ctx context.Context, nsHash int, namespace string, format int, data []byte, mode int, precepts []string, stateToken int, txID int) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	binding.lock.Lock()
+	var events []updateEvent
+	var res bindings.RawBuffer
+	var err error
+	if txID != 0 {
+		res, err = binding.addToTx(txID, namespace, format, data, mode, precepts, stateToken)
+	} else {
+		events, res, err = binding.modifyItem(namespace, format, data, mode, precepts, stateToken)
+	}
+	binding.lock.Unlock()
+	binding.sendUpdates(events)
+	return res, err
+}
+
+func (binding *Memory) modifyItem(nsName string, format int, data []byte, mode int, precepts []string, stateToken int) ([]updateEvent, bindings.RawBuffer, error) {
+	ns, err := binding.getNamespace(nsName)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := ns.decodeItem(format, data, stateToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mode != bindings.ModeDelete {
+		if err = ns.applyPrecepts(obj, precepts, now); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	w := newResultsWriter(bindings.ResultsCJson | bindings.ResultsWithItemID | bindings.ResultsWithPayloadTypes)
+	w.namespaces = []*namespace{ns}
+
+	var events []updateEvent
+	if it := ns.modify(obj, mode); it != nil {
+		w.putItem(ns, 0, it, nil)
+		w.count++
+		events = binding.updateEvent(ns, mode, it, events)
+	}
+	return events, &rawResultBuffer{buf: w.bytes(0, nil, false)}, nil
+}
+
+// decodeItem decodes item from JSON or CJSON. CJSON item must be packed with actual state of namespace
+func (ns *namespace) decodeItem(format int, data []byte, stateToken int) (obj map[string]interface{}, err error) {
+	switch format {
+	case bindings.FormatJson:
+		obj, err = parseJSON(data)
+	case bindings.FormatCJson:
+		if stateToken != int(ns.stateToken) {
+			return nil, bindings.NewError(fmt.Sprintf("State token mismatch for namespace '%s'", ns.name), bindings.ErrStateInvalidated)
+		}
+		obj, err = decodeCJSON(data, ns.tags)
+		if err != nil {
+			err = bindings.NewError(err.Error(), bindings.ErrParseBin)
+		}
+	default:
+		err = bindings.NewError(fmt.Sprintf("Invalid item format %d", format), bindings.ErrParams)
+	}
+	return obj, err
+}
+
+// addToTx decodes item and adds it to transaction. Item is applied on commit, so results are empty
+func (binding *Memory) addToTx(txID int, nsName string, format int, data []byte, mode int, precepts []string, stateToken int) (bindings.RawBuffer, error) {
+	tx, ok := binding.txs[txID]
+	if !ok {
+		return nil, bindings.NewError(fmt.Sprintf("Transaction %d not found", txID), bindings.ErrParams)
+	}
+	if !strings.EqualFold(tx.namespace, nsName) {
+		return nil, bindings.NewError(fmt.Sprintf("Item of namespace '%s' can't be added to transaction of namespace '%s'", nsName, tx.namespace), bindings.ErrParams)
+	}
+	ns, err := binding.getNamespace(nsName)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := ns.decodeItem(format, data, stateToken)
+	if err != nil {
+		return nil, err
+	}
+	tx.steps = append(tx.steps, txStep{obj: obj, mode: mode, precepts: precepts})
+
+	w := newResultsWriter(bindings.ResultsCJson | bindings.ResultsWithItemID)
+	return &rawResultBuffer{buf: w.bytes(0, nil, false)}, nil
+}
+
+func (binding *Memory) BeginTx(ctx context.Context, namespace string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	if _, err := binding.getNamespace(namespace); err != nil {
+		return 0, err
+	}
+	binding.nextTxID++
+	binding.txs[binding.nextTxID] = &transaction{namespace: namespace}
+	return binding.nextTxID, nil
+}
+
+// CommitTx applies all items of transaction under single lock. Precepts of all items are applied before
+// modification of namespace, so failed commit does not change namespace
+func (binding *Memory) CommitTx(ctx context.Context, txID int) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	binding.lock.Lock()
+	events, res, err := binding.commitTx(txID)
+	binding.lock.Unlock()
+	binding.sendUpdates(events)
+	return res, err
+}
+
+func (binding *Memory) commitTx(txID int) ([]updateEvent, bindings.RawBuffer, error) {
+	tx, ok := binding.txs[txID]
+	if !ok {
+		return nil, nil, bindings.NewError(fmt.Sprintf("Transaction %d not found", txID), bindings.ErrParams)
+	}
+	delete(binding.txs, txID)
+	ns, err := binding.getNamespace(tx.namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serials := make(map[string]int64, len(ns.serials))
+	for path, v := range ns.serials {
+		serials[path] = v
+	}
+	for _, step := range tx.steps {
+		if step.mode == bindings.ModeDelete {
+			continue
+		}
+		if err = ns.applyPrecepts(step.obj, step.precepts, now); err != nil {
+			ns.serials = serials
+			return nil, nil, err
+		}
+	}
+
+	w := newResultsWriter(bindings.ResultsCJson | bindings.ResultsWithItemID | bindings.ResultsWithPayloadTypes)
+	w.namespaces = []*namespace{ns}
+	var events []updateEvent
+	for _, step := range tx.steps {
+		if it := ns.modify(step.obj, step.mode); it != nil {
+			w.putItem(ns, 0, it, nil)
+			w.count++
+			events = binding.updateEvent(ns, step.mode, it, events)
+		}
+	}
+	return events, &rawResultBuffer{buf: w.bytes(0, nil, false)}, nil
+}
+
+func (binding *Memory) RollbackTx(ctx context.Context, txID int) error {
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	if _, ok := binding.txs[txID]; !ok {
+		return bindings.NewError(fmt.Sprintf("Transaction %d not found", txID), bindings.ErrParams)
+	}
+	delete(binding.txs, txID)
+	return nil
+}
+
+func now(unit string) int64 {
+	t := time.Now().UnixNano()
+	switch unit {
+	case "msec":
+		return t / int64(time.Millisecond)
+	case "usec":
+		return t / int64(time.Microsecond)
+	case "nsec":
+		return t
+	}
+	return t / int64(time.Second)
+}
+
+// modify applies modification to namespace, and returns modified item, or nil if item was not changed
+func (ns *namespace) modify(obj map[string]interface{}, mode int) *item {
+	key, hasPK := ns.pkKey(obj)
+	var old *item
+	if id, ok := ns.pks[key]; ok && hasPK {
+		old = ns.items[id]
+	}
+
+	switch mode {
+	case bindings.ModeInsert:
+		if old != nil {
+			return nil
+		}
+	case bindings.ModeUpdate:
+		if old == nil {
+			return nil
+		}
+	case bindings.ModeDelete:
+		if old == nil {
+			return nil
+		}
+		delete(ns.items, old.id)
+		delete(ns.pks, key)
+		ns.version++
+		return old
+	}
+
+	ns.version++
+	if old != nil {
+		old.obj = obj
+		old.version = ns.version
+		return old
+	}
+	it := &item{id: ns.nextID, version: ns.version, obj: obj}
+	ns.nextID++
+	ns.items[it.id] = it
+	if hasPK {
+		ns.pks[key] = it.id
+	}
+	return it
+}
+
+func (binding *Memory) Select(ctx context.Context, query string, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	return nil, bindings.NewError("SQL queries are not supported by memory binding", bindings.ErrParseSQL)
+}
+
+func (binding *Memory) SelectQuery(ctx context.Context, rawQuery []byte, withItems bool, ptVersions []int32, fetchCount int) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+
+	res, err := binding.execQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := bindings.ResultsWithItemID
+	if withItems {
+		flags |= bindings.ResultsJson
+	} else {
+		flags |= bindings.ResultsCJson | bindings.ResultsWithPayloadTypes
+	}
+	if res.withJoined {
+		flags |= bindings.ResultsWithJoined
+	}
+	if len(res.namespaces) > 1 {
+		flags |= bindings.ResultsWithNsID
+	}
+
+	w := newResultsWriter(flags)
+	w.namespaces = res.namespaces
+	w.putResults(res)
+
+	total := 0
+	if res.reqTotal {
+		total = res.total
+	}
+	return &rawResultBuffer{buf: w.bytes(total, res.aggs, res.explain)}, nil
+}
+
+func (binding *Memory) DeleteQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	return binding.modifyQuery(ctx, rawQuery, bindings.ModeDelete)
+}
+
+func (binding *Memory) UpdateQuery(ctx context.Context, nsHash int, rawQuery []byte) (bindings.RawBuffer, error) {
+	return binding.modifyQuery(ctx, rawQuery, bindings.ModeUpdate)
+}
+
+// modifyQuery deletes or updates items, matched query, and returns their ids
+func (binding *Memory) modifyQuery(ctx context.Context, rawQuery []byte, mode int) (bindings.RawBuffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	binding.lock.Lock()
+	events, res, err := binding.modifyQueryItems(q, mode)
+	binding.lock.Unlock()
+	binding.sendUpdates(events)
+	return res, err
+}
+
+func (binding *Memory) modifyQueryItems(q *query, mode int) ([]updateEvent, bindings.RawBuffer, error) {
+	ns, err := binding.getNamespace(q.namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := newSelectCtx(ns)
+	items, _, _ := ctx.selectItems(q, nil)
+	items, _ = applyLimit(q, items, nil)
+
+	// Build all updated items before modifying namespace, so invalid update does not leave namespace partially updated
+	objs := make([]map[string]interface{}, len(items))
+	for i, it := range items {
+		objs[i] = it.obj
+		if mode == bindings.ModeUpdate {
+			objs[i] = ctx.applyUpdates(q, it.obj)
+			oldKey, _ := ns.pkKey(it.obj)
+			if newKey, _ := ns.pkKey(objs[i]); newKey != oldKey {
+				return nil, nil, bindings.NewError("Update query can't change primary key of item", bindings.ErrParams)
+			}
+		}
+	}
+
+	w := newResultsWriter(bindings.ResultsPure | bindings.ResultsWithItemID)
+	var events []updateEvent
+	for _, obj := range objs {
+		if it := ns.modify(obj, mode); it != nil {
+			w.putItem(ns, 0, it, nil)
+			w.count++
+			events = binding.updateEvent(ns, mode, it, events)
+		}
+	}
+	return events, &rawResultBuffer{buf: w.bytes(0, nil, false)}, nil
+}
+
+func (binding *Memory) Commit(ctx context.Context, namespace string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	_, err := binding.getNamespace(namespace)
+	return err
+}
+
+// CreateDatabase is not supported by memory binding: it keeps single database
+func (binding *Memory) CreateDatabase(ctx context.Context, dbName string) error {
+	return bindings.NewError("Create database is not supported by memory binding", bindings.ErrLogic)
+}
+
+// DropDatabase is not supported by memory binding
+func (binding *Memory) DropDatabase(ctx context.Context, dbName string) error {
+	return bindings.NewError("Drop database is not supported by memory binding", bindings.ErrLogic)
+}
+
+// EnumNamespaces returns definitions of user's namespaces sorted by name
+func (binding *Memory) EnumNamespaces(ctx context.Context) ([]bindings.NamespaceDef, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	defs := make([]bindings.NamespaceDef, 0, len(binding.namespaces))
+	for name, ns := range binding.namespaces {
+		if strings.HasPrefix(name, "#") {
+			continue
+		}
+		defs = append(defs, bindings.NamespaceDef{Namespace: name, Indexes: append([]bindings.IndexDef(nil), ns.indexes...)})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Namespace < defs[j].Namespace })
+	return defs, nil
+}
+
+// EnumMeta returns sorted meta keys of namespace
+func (binding *Memory) EnumMeta(ctx context.Context, namespace string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	ns, err := binding.getNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(ns.meta))
+	for key := range ns.meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (binding *Memory) EnableLogger(logger bindings.Logger) {
+	binding.logger = logger
+}
+
+func (binding *Memory) DisableLogger() {
+	binding.logger = nil
+}
+
+func (binding *Memory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (binding *Memory) Finalize() error {
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	binding.namespaces = make(map[string]*namespace)
+	binding.updates = nil
+	return nil
+}
+
+func (binding *Memory) Status() bindings.Status {
+	return bindings.Status{}
+}
+
+func (binding *Memory) SubscribeUpdates(ctx context.Context, handler bindings.UpdatesHandler) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	binding.updates = handler
+	return nil
+}
+
+func (binding *Memory) UnsubscribeUpdates(ctx context.Context) error {
+	binding.lock.Lock()
+	defer binding.lock.Unlock()
+	binding.updates = nil
+	return nil
+}
+
+func (binding *Memory) updateEvent(ns *namespace, mode int, it *item, events []updateEvent) []updateEvent {
+	if binding.updates == nil {
+		return events
+	}
+	data, _ := json.Marshal(it.obj)
+	return append(events, updateEvent{namespace: ns.name, mode: mode, data: data})
+}
+
+// sendUpdates calls updates handler without lock, so handler can use binding
+func (binding *Memory) sendUpdates(events []updateEvent) {
+	if len(events) == 0 {
+		return
+	}
+	binding.lock.Lock()
+	handler := binding.updates
+	binding.lock.Unlock()
+	if handler == nil {
+		return
+	}
+	for _, ev := range events {
+		handler(ev.namespace, ev.mode, ev.data)
+	}
+}
@@ -0,0 +1,417 @@
+package memory_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/memory"
+	"github.com/restream/reindexer/dsl"
+)
+
+type testActor struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+}
+
+type testItem struct {
+	ID       int      `reindex:"id,,pk"`
+	Name     string   `reindex:"name"`
+	Year     int      `reindex:"year,tree"`
+	Genres   []string `reindex:"genres"`
+	Rate     float64  `reindex:"rate"`
+	ActorID  int      `reindex:"actor_id"`
+	Location struct {
+		City string `reindex:"city"`
+	}
+	Actors []*testActor `reindex:"actors,,joined"`
+}
+
+func newTestDB(t *testing.T) *reindexer.Reindexer {
+	db := reindexer.NewReindex("memory://")
+	if err := db.Status().Err; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.OpenNamespace("items", reindexer.DefaultNamespaceOptions(), testItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.OpenNamespace("actors", reindexer.DefaultNamespaceOptions(), testActor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		it := &testItem{
+			ID:      i,
+			Name:    "item" + string(rune('a'+i)),
+			Year:    2000 + i%5,
+			Genres:  []string{"drama", []string{"comedy", "action"}[i%2]},
+			Rate:    float64(i) / 2,
+			ActorID: i % 3,
+		}
+		it.Location.City = []string{"Moscow", "London"}[i%2]
+		if err := db.Upsert("items", it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Upsert("actors", &testActor{ID: i, Name: "actor" + string(rune('a'+i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func fetchIDs(t *testing.T, it *reindexer.Iterator) (ids []int) {
+	items, err := it.FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		ids = append(ids, item.(*testItem).ID)
+	}
+	return ids
+}
+
+func checkIDs(t *testing.T, name string, ids []int, expected ...int) {
+	if len(ids) != len(expected) {
+		t.Fatalf("%s: expected ids %v, but got %v", name, expected, ids)
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Fatalf("%s: expected ids %v, but got %v", name, expected, ids)
+		}
+	}
+}
+
+func TestMemoryModify(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	item, found := db.Query("items").WhereInt("id", reindexer.EQ, 3).Get()
+	if !found {
+		t.Fatal("Item 3 is not found")
+	}
+	if it := item.(*testItem); it.Name != "itemd" || it.Year != 2003 || it.Rate != 1.5 || it.Location.City != "London" || len(it.Genres) != 2 {
+		t.Fatalf("Unexpected item: %+v", it)
+	}
+
+	if cnt, err := db.Insert("items", &testItem{ID: 3, Name: "dup"}); err != nil || cnt != 0 {
+		t.Fatalf("Insert of existing item must return 0, but got %d, %v", cnt, err)
+	}
+	if cnt, err := db.Update("items", &testItem{ID: 3, Name: "updated", Year: 2003}); err != nil || cnt != 1 {
+		t.Fatalf("Update of existing item must return 1, but got %d, %v", cnt, err)
+	}
+	if cnt, err := db.Update("items", &testItem{ID: 100}); err != nil || cnt != 0 {
+		t.Fatalf("Update of missing item must return 0, but got %d, %v", cnt, err)
+	}
+	item, _ = db.Query("items").WhereInt("id", reindexer.EQ, 3).Get()
+	if item.(*testItem).Name != "updated" {
+		t.Fatalf("Item is not updated: %+v", item)
+	}
+
+	if err := db.Upsert("items", []byte(`{"ID":20,"Name":"json","Year":1999,"Location":{"City":"Paris"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "upsert json", fetchIDs(t, db.Query("items").WhereString("city", reindexer.EQ, "Paris").Exec()), 20)
+
+	if err := db.Delete("items", &testItem{ID: 20}); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, "delete", fetchIDs(t, db.Query("items").WhereInt("id", reindexer.EQ, 20).Exec()))
+}
+
+func TestMemoryQuery(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	checkIDs(t, "range", fetchIDs(t, db.Query("items").WhereInt("year", reindexer.RANGE, 2001, 2002).Exec()), 1, 2, 6, 7)
+	checkIDs(t, "set", fetchIDs(t, db.Query("items").WhereInt("id", reindexer.SET, 8, 1, 4).Exec()), 1, 4, 8)
+	checkIDs(t, "not", fetchIDs(t, db.Query("items").WhereInt("id", reindexer.LT, 4).Not().WhereInt("id", reindexer.EQ, 2).Exec()), 0, 1, 3)
+	checkIDs(t, "or", fetchIDs(t, db.Query("items").WhereInt("id", reindexer.EQ, 1).Or().WhereInt("id", reindexer.EQ, 7).Exec()), 1, 7)
+	checkIDs(t, "array", fetchIDs(t, db.Query("items").WhereString("genres", reindexer.ALLSET, "drama", "action").WhereDouble("rate", reindexer.GE, 3).Exec()), 7, 9)
+	checkIDs(t, "nested", fetchIDs(t, db.Query("items").WhereString("city", reindexer.EQ, "Moscow").WhereInt("id", reindexer.GT, 5).Exec()), 6, 8)
+	checkIDs(t, "brackets", fetchIDs(t, db.Query("items").
+		WhereInt("year", reindexer.EQ, 2000).
+		OpenBracket().WhereInt("id", reindexer.EQ, 0).Or().WhereInt("id", reindexer.EQ, 1).CloseBracket().
+		Or().
+		OpenBracket().WhereInt("id", reindexer.EQ, 5).CloseBracket().
+		Exec()), 0, 5)
+
+	checkIDs(t, "sort", fetchIDs(t, db.Query("items").Sort("year", true).Sort("id", false).Limit(4).Exec()), 4, 9, 3, 8)
+	checkIDs(t, "forced sort", fetchIDs(t, db.Query("items").Sort("id", false, 7, 2).Limit(3).Exec()), 7, 2, 0)
+	checkIDs(t, "distinct", fetchIDs(t, db.Query("items").Distinct("year").Exec()), 0, 1, 2, 3, 4)
+
+	it := db.Query("items").WhereInt("year", reindexer.GE, 2003).Offset(1).Limit(2).ReqTotal().Exec()
+	if it.TotalCount() != 4 {
+		t.Fatalf("Expected total count 4, but got %d", it.TotalCount())
+	}
+	checkIDs(t, "limit", fetchIDs(t, it), 4, 8)
+}
+
+func TestMemoryJoin(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	q := db.Query("items").WhereInt("id", reindexer.LT, 6)
+	q.InnerJoin(db.Query("actors"), "actors").On("actor_id", reindexer.EQ, "id")
+	items, err := q.Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 items, but got %d", len(items))
+	}
+	for _, item := range items {
+		it := item.(*testItem)
+		if len(it.Actors) != 1 || it.Actors[0].ID != it.ActorID {
+			t.Fatalf("Unexpected joined actors of item %d: %+v", it.ID, it.Actors)
+		}
+	}
+
+	q = db.Query("items").WhereInt("id", reindexer.LT, 3)
+	q.LeftJoin(db.Query("actors"), "actors").On("actor_id", reindexer.EQ, "id")
+	items, err = q.Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || len(items[2].(*testItem).Actors) != 0 {
+		t.Fatalf("Unexpected left join results: %+v", items)
+	}
+
+	// Value of joined item is compared with value of main item: actors with id < actor_id
+	q = db.Query("items").WhereInt("id", reindexer.LT, 6).Sort("id", false)
+	q.InnerJoin(db.Query("actors"), "actors").On("actor_id", reindexer.LT, "id")
+	items, err = q.Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("Expected 4 items, but got %d", len(items))
+	}
+	for _, item := range items {
+		it := item.(*testItem)
+		if len(it.Actors) != it.ActorID {
+			t.Fatalf("Unexpected joined actors of item %d: %+v", it.ID, it.Actors)
+		}
+	}
+}
+
+func TestMemoryAggregate(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	it := db.Query("items").
+		Aggregate("year", reindexer.AggSum).
+		Aggregate("rate", reindexer.AggAvg).
+		Aggregate("rate", reindexer.AggMax).
+		Aggregate("city", reindexer.AggFacet).
+		Limit(0).Exec()
+	defer it.Close()
+	if it.Error() != nil {
+		t.Fatal(it.Error())
+	}
+
+	aggs := it.AggResults()
+	if len(aggs) != 4 {
+		t.Fatalf("Expected 4 aggregation results, but got %d", len(aggs))
+	}
+	if aggs[0].Value != 20020 || aggs[1].Value != 2.25 || aggs[2].Value != 4.5 {
+		t.Fatalf("Unexpected aggregation results: %+v", aggs)
+	}
+	if len(aggs[3].Facets) != 2 || aggs[3].Facets[0].Value != "London" || aggs[3].Facets[0].Count != 5 {
+		t.Fatalf("Unexpected facet results: %+v", aggs[3])
+	}
+}
+
+func TestMemoryAggregateFacet(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	checkFacets := func(agg reindexer.AggregationResult, expected ...string) {
+		var got []string
+		for _, f := range agg.Facets {
+			got = append(got, fmt.Sprintf("%s:%d", strings.Join(f.Values, ","), f.Count))
+		}
+		if strings.Join(got, " ") != strings.Join(expected, " ") {
+			t.Fatalf("Unexpected facets %v, expected %v", got, expected)
+		}
+	}
+
+	q := db.Query("items").Limit(0)
+	q.AggregateFacet("city", "genres")
+	q.AggregateFacet("genres").Sort("count", true).Sort("genres", true).Offset(1).Limit(1)
+	it := q.Exec()
+	defer it.Close()
+	if it.Error() != nil {
+		t.Fatal(it.Error())
+	}
+	aggs := it.AggResults()
+	if len(aggs) != 2 || len(aggs[0].Fields) != 2 || aggs[0].Fields[1] != "genres" {
+		t.Fatalf("Unexpected aggregation results: %+v", aggs)
+	}
+	checkFacets(aggs[0], "London,action:5", "London,drama:5", "Moscow,comedy:5", "Moscow,drama:5")
+	checkFacets(aggs[1], "comedy:5")
+	if aggs[1].Facets[0].Value != "comedy" {
+		t.Fatalf("Unexpected facet of single field: %+v", aggs[1].Facets[0])
+	}
+
+	var d dsl.DSL
+	data := `{"namespace":"items","limit":0,"aggregations":[{"fields":["city","genres"],"type":"facet","sort":[{"field":"genres","desc":true}],"limit":2}]}`
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatal(err)
+	}
+	dq, err := db.QueryFrom(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it = dq.Exec()
+	defer it.Close()
+	if it.Error() != nil {
+		t.Fatal(it.Error())
+	}
+	checkFacets(it.AggResults()[0], "London,drama:5", "Moscow,drama:5")
+
+	d.Aggregations[0].Type = "sum"
+	if _, err = db.QueryFrom(d); err != reindexer.ErrAggInvalid {
+		t.Fatalf("Expected error of invalid aggregation, but got %v", err)
+	}
+
+	q = db.Query("items").Limit(0)
+	q.AggregateFacet("city").Sort("year", false)
+	it = q.Exec()
+	defer it.Close()
+	if it.Error() == nil {
+		t.Fatalf("Expected error of facet sort by field, which is not in facet")
+	}
+}
+
+func TestMemoryUpdateDeleteQuery(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	cnt, err := db.Query("items").WhereInt("year", reindexer.EQ, 2000).Set("name", "updated").ArrayAppend("genres", "horror").Update()
+	if err != nil || cnt != 2 {
+		t.Fatalf("Expected 2 updated items, but got %d, %v", cnt, err)
+	}
+	item, _ := db.Query("items").WhereInt("id", reindexer.EQ, 5).Get()
+	if it := item.(*testItem); it.Name != "updated" || len(it.Genres) != 3 || it.Genres[2] != "horror" {
+		t.Fatalf("Item is not updated: %+v", it)
+	}
+
+	cnt, err = db.Query("items").WhereInt("year", reindexer.EQ, 2001).Delete()
+	if err != nil || cnt != 2 {
+		t.Fatalf("Expected 2 deleted items, but got %d, %v", cnt, err)
+	}
+	checkIDs(t, "deleted", fetchIDs(t, db.Query("items").WhereInt("year", reindexer.EQ, 2001).Exec()))
+}
+
+func TestMemoryJSON(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	data, err := db.Query("items").WhereInt("id", reindexer.EQ, 2).ExecToJson().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Items []testItem `json:"items"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 || res.Items[0].ID != 2 || res.Items[0].Name != "itemc" {
+		t.Fatalf("Unexpected JSON results: %s", string(data))
+	}
+
+	// Joined items are embedded into JSON of item as "joined_<ns>" fields
+	q := db.Query("items").WhereInt("id", reindexer.LT, 3).Sort("id", false)
+	q.LeftJoin(db.Query("actors"), "actors").On("actor_id", reindexer.EQ, "id")
+	if data, err = q.ExecToJson().FetchAll(); err != nil {
+		t.Fatal(err)
+	}
+	var joinedRes struct {
+		Items []struct {
+			ID     int         `json:"ID"`
+			Actors []testActor `json:"joined_actors"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(data, &joinedRes); err != nil {
+		t.Fatalf("Can't unmarshal JSON results %s: %v", string(data), err)
+	}
+	items := joinedRes.Items
+	if len(items) != 3 || len(items[1].Actors) != 1 || items[1].Actors[0].Name != "actorb" || items[2].Actors != nil {
+		t.Fatalf("Unexpected joined JSON results: %s", string(data))
+	}
+}
+
+func TestMemoryPointerArrays(t *testing.T) {
+	type pointersActor struct {
+		Name string `json:"name"`
+	}
+	type pointersItem struct {
+		ID      int              `reindex:"id,,pk"`
+		Ints    []*int           `json:"ints"`
+		Strings []*string        `json:"strings"`
+		Actors  []*pointersActor `json:"actors"`
+		Mixed   []interface{}    `json:"mixed"`
+	}
+	db := reindexer.NewReindex("memory://")
+	defer db.Close()
+	if err := db.OpenNamespace("pointers", reindexer.DefaultNamespaceOptions(), pointersItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	one, two, str := 1, 2, "str"
+	src := &pointersItem{
+		ID:      1,
+		Ints:    []*int{&one, nil, &two},
+		Strings: []*string{&str},
+		Actors:  []*pointersActor{{Name: "actor"}, nil},
+		Mixed:   []interface{}{int64(1), "str", true},
+	}
+	if err := db.Upsert("pointers", src); err != nil {
+		t.Fatal(err)
+	}
+
+	item, found := db.Query("pointers").WhereInt("id", reindexer.EQ, 1).Get()
+	if !found {
+		t.Fatal("Item was not found")
+	}
+	got, _ := json.Marshal(item)
+	expected, _ := json.Marshal(src)
+	if string(got) != string(expected) {
+		t.Fatalf("Expected item %s, but got %s", string(expected), string(got))
+	}
+}
+
+func TestMemoryEnum(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	namespaces, err := db.ListNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 2 || namespaces[0].Namespace != "actors" || namespaces[1].Namespace != "items" {
+		t.Fatalf("Unexpected namespaces: %+v", namespaces)
+	}
+	if len(namespaces[1].Indexes) == 0 || namespaces[1].Indexes[0].Name != "id" || !namespaces[1].Indexes[0].IsPK {
+		t.Fatalf("Unexpected indexes of namespace: %+v", namespaces[1].Indexes)
+	}
+
+	db.PutMeta("items", "key2", []byte("value2"))
+	db.PutMeta("items", "key1", []byte("value1"))
+	keys, err := db.EnumMeta("items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "key1" || keys[1] != "key2" {
+		t.Fatalf("Unexpected meta keys: %v", keys)
+	}
+
+	if err = db.CreateDatabase("newdb"); err == nil {
+		t.Fatalf("Expected error, because memory binding does not support databases")
+	}
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+type item struct {
+	id      int
+	version int
+	obj     map[string]interface{}
+}
+
+type namespace struct {
+	name       string
+	indexes    []bindings.IndexDef
+	items      map[int]*item
+	pks        map[string]int
+	nextID     int
+	version    int
+	tags       []string
+	tagNames   map[string]int
+	stateToken int32
+	tmVersion  int32
+	meta       map[string]string
+	serials    map[string]int64
+}
+
+// field is resolved index or json path, used in conditions, sorts and aggregations
+type field struct {
+	paths     []string
+	collate   int
+	fulltext  bool
+	composite []field
+}
+
+func newNamespace(name string) *namespace {
+	return &namespace{
+		name:       name,
+		items:      make(map[int]*item),
+		pks:        make(map[string]int),
+		tagNames:   make(map[string]int),
+		stateToken: rand.Int31n(1<<31-2) + 1,
+		meta:       make(map[string]string),
+		serials:    make(map[string]int64),
+	}
+}
+
+func (ns *namespace) name2tag(name string) int {
+	tag, ok := ns.tagNames[name]
+	if !ok {
+		ns.tags = append(ns.tags, name)
+		tag = len(ns.tags)
+		ns.tagNames[name] = tag
+		ns.tmVersion++
+	}
+	return tag
+}
+
+func (ns *namespace) findIndex(name string) int {
+	for i := range ns.indexes {
+		if strings.EqualFold(ns.indexes[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseCollate(mode string) int {
+	switch mode {
+	case "ascii":
+		return bindings.CollateASCII
+	case "utf8":
+		return bindings.CollateUTF8
+	case "numeric":
+		return bindings.CollateNumeric
+	case "custom":
+		return bindings.CollateCustom
+	}
+	return bindings.CollateNone
+}
+
+// resolveField returns field by index name. If there is no such index, name is used as json path
+func (ns *namespace) resolveField(name string) field {
+	idx := ns.findIndex(name)
+	if idx < 0 {
+		return field{paths: []string{name}}
+	}
+	def := &ns.indexes[idx]
+	f := field{collate: parseCollate(def.CollateMode), fulltext: def.IndexType == "text"}
+	if def.FieldType == "composite" {
+		for _, p := range def.JSONPaths {
+			sub := ns.resolveField(p)
+			if f.fulltext {
+				f.paths = append(f.paths, sub.paths...)
+			} else {
+				f.composite = append(f.composite, sub)
+			}
+		}
+		return f
+	}
+	f.paths = def.JSONPaths
+	if len(f.paths) == 0 {
+		f.paths = []string{def.Name}
+	}
+	return f
+}
+
+// values returns all values of field in item. Value of composite field is single tuple
+func (f *field) values(obj map[string]interface{}) (values []interface{}) {
+	if len(f.composite) != 0 {
+		tuple := make([]interface{}, len(f.composite))
+		for i := range f.composite {
+			if vals := f.composite[i].values(obj); len(vals) != 0 {
+				tuple[i] = vals[0]
+			}
+		}
+		return []interface{}{tuple}
+	}
+	for _, p := range f.paths {
+		values = append(values, getPath(obj, p)...)
+	}
+	return values
+}
+
+func (ns *namespace) pkField() (field, bool) {
+	for _, def := range ns.indexes {
+		if def.IsPK {
+			return ns.resolveField(def.Name), true
+		}
+	}
+	return field{}, false
+}
+
+// pkKey returns key of item's primary key. ok is false, if namespace has no primary key
+func (ns *namespace) pkKey(obj map[string]interface{}) (key string, ok bool) {
+	f, ok := ns.pkField()
+	if !ok {
+		return "", false
+	}
+	return valueKey(f.values(obj)), true
+}
+
+func (ns *namespace) rebuildPKs() {
+	ns.pks = make(map[string]int, len(ns.items))
+	for id, it := range ns.items {
+		if key, ok := ns.pkKey(it.obj); ok {
+			ns.pks[key] = id
+		}
+	}
+}
+
+// sortedItems returns items in order of insertion
+func (ns *namespace) sortedItems() []*item {
+	items := make([]*item, 0, len(ns.items))
+	for _, it := range ns.items {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].id < items[j].id })
+	return items
+}
+
+// applyPrecepts applies precepts like 'id=serial()' or 'updated=now(msec)' to item
+func (ns *namespace) applyPrecepts(obj map[string]interface{}, precepts []string, now func(unit string) int64) error {
+	for _, precept := range precepts {
+		parts := strings.SplitN(precept, "=", 2)
+		if len(parts) != 2 {
+			return bindings.NewError(fmt.Sprintf("Invalid precept '%s'", precept), bindings.ErrParams)
+		}
+		name, fn := strings.TrimSpace(parts[0]), strings.ToLower(strings.TrimSpace(parts[1]))
+		path := name
+		if idx := ns.findIndex(name); idx >= 0 && len(ns.indexes[idx].JSONPaths) != 0 {
+			path = ns.indexes[idx].JSONPaths[0]
+		}
+		switch {
+		case fn == "serial()":
+			last, ok := ns.serials[path]
+			if !ok {
+				for _, it := range ns.items {
+					for _, v := range getPath(it.obj, path) {
+						if f, ok := toFloat(v); ok && int64(f) > last {
+							last = int64(f)
+						}
+					}
+				}
+			}
+			last++
+			ns.serials[path] = last
+			setPath(obj, path, last)
+		case strings.HasPrefix(fn, "now(") && strings.HasSuffix(fn, ")"):
+			setPath(obj, path, now(strings.TrimSuffix(strings.TrimPrefix(fn, "now("), ")")))
+		default:
+			return bindings.NewError(fmt.Sprintf("Unsupported precept '%s'", precept), bindings.ErrParams)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
+)
+
+// query is parsed binary query, serialized by reindexer.Query
+type query struct {
+	namespace    string
+	entries      []*queryEntry
+	joinOn       []joinOnEntry
+	sort         []sortEntry
+	distinct     []string
+	aggs         []aggEntry
+	selectFilter []string
+	updates      []updateEntry
+	limit        int
+	offset       int
+	reqTotal     bool
+	explain      bool
+	joinType     int
+	joins        []*query
+	merged       []*query
+}
+
+// queryEntry is condition or bracket with nested conditions
+type queryEntry struct {
+	op       int
+	field    string
+	cond     int
+	values   []interface{}
+	bracket  bool
+	children []*queryEntry
+}
+
+type joinOnEntry struct {
+	op        int
+	cond      int
+	index     string
+	joinIndex string
+}
+
+type sortEntry struct {
+	field  string
+	desc   bool
+	forced []interface{}
+}
+
+type aggEntry struct {
+	fields  []string
+	aggType int
+	// sort, limit and offset of facets
+	sort   []aggSortEntry
+	limit  int
+	offset int
+}
+
+type aggSortEntry struct {
+	field string
+	desc  bool
+}
+
+type updateEntry struct {
+	field   string
+	mode    int
+	isArray bool
+	drop    bool
+	values  []interface{}
+}
+
+const noLimit = -1
+
+// parseQuery parses query with its joined and merged queries, as they are serialized by reindexer.Query
+func parseQuery(data []byte) (q *query, err error) {
+	defer func() {
+		if ret := recover(); ret != nil {
+			err = bindings.NewError(fmt.Sprintf("Can't parse query: %v", ret), bindings.ErrParseBin)
+		}
+	}()
+
+	ser := cjson.NewSerializer(data)
+	if q, err = parseQueryBody(&ser); err != nil {
+		return nil, err
+	}
+
+	parent := q
+	for !ser.Eof() {
+		joinType := int(ser.GetVarUInt())
+		sq, err := parseQueryBody(&ser)
+		if err != nil {
+			return nil, err
+		}
+		sq.joinType = joinType
+		if joinType == bindings.Merge {
+			q.merged = append(q.merged, sq)
+			parent = sq
+		} else {
+			parent.joins = append(parent.joins, sq)
+		}
+	}
+	return q, nil
+}
+
+func parseQueryBody(ser *cjson.Serializer) (*query, error) {
+	q := &query{namespace: ser.GetVString(), limit: noLimit}
+	stack := []*[]*queryEntry{&q.entries}
+
+	for {
+		// Delete and update queries are sent without QueryEnd
+		tag := bindings.QueryEnd
+		if !ser.Eof() {
+			tag = int(ser.GetVarUInt())
+		}
+		switch tag {
+		case bindings.QueryEnd:
+			if len(stack) != 1 {
+				return nil, bindings.NewError("Can't parse query: bracket is not closed", bindings.ErrParseBin)
+			}
+			return q, nil
+		case bindings.QueryCondition:
+			e := &queryEntry{field: ser.GetVString()}
+			e.op = int(ser.GetVarUInt())
+			e.cond = int(ser.GetVarUInt())
+			e.values = readValues(ser)
+			*stack[len(stack)-1] = append(*stack[len(stack)-1], e)
+		case bindings.QueryOpenBracket:
+			e := &queryEntry{op: int(ser.GetVarUInt()), bracket: true}
+			*stack[len(stack)-1] = append(*stack[len(stack)-1], e)
+			stack = append(stack, &e.children)
+		case bindings.QueryCloseBracket:
+			if len(stack) == 1 {
+				return nil, bindings.NewError("Can't parse query: close bracket without open bracket", bindings.ErrParseBin)
+			}
+			stack = stack[:len(stack)-1]
+		case bindings.QueryDistinct:
+			q.distinct = append(q.distinct, ser.GetVString())
+		case bindings.QuerySortIndex:
+			s := sortEntry{field: ser.GetVString()}
+			s.desc = ser.GetVarUInt() != 0
+			s.forced = readValues(ser)
+			q.sort = append(q.sort, s)
+		case bindings.QueryJoinOn:
+			on := joinOnEntry{}
+			on.op = int(ser.GetVarUInt())
+			on.cond = int(ser.GetVarUInt())
+			on.index = ser.GetVString()
+			on.joinIndex = ser.GetVString()
+			q.joinOn = append(q.joinOn, on)
+		case bindings.QueryLimit:
+			q.limit = int(ser.GetVarUInt())
+		case bindings.QueryOffset:
+			q.offset = int(ser.GetVarUInt())
+		case bindings.QueryReqTotal:
+			q.reqTotal = ser.GetVarUInt() != bindings.ModeNoCalc
+		case bindings.QueryDebugLevel:
+			ser.GetVarUInt()
+		case bindings.QueryAggregation:
+			a := aggEntry{fields: []string{ser.GetVString()}, limit: noLimit}
+			a.aggType = int(ser.GetVarUInt())
+			q.aggs = append(q.aggs, a)
+		case bindings.QueryAggregationFields, bindings.QueryAggregationSort, bindings.QueryAggregationLimit, bindings.QueryAggregationOffset:
+			// Parameters of aggregation are applied to the last aggregation of query
+			if len(q.aggs) == 0 {
+				return nil, bindings.NewError("Can't parse query: aggregation parameters without aggregation", bindings.ErrParseBin)
+			}
+			a := &q.aggs[len(q.aggs)-1]
+			switch tag {
+			case bindings.QueryAggregationFields:
+				a.fields = make([]string, ser.GetVarUInt())
+				for i := range a.fields {
+					a.fields[i] = ser.GetVString()
+				}
+			case bindings.QueryAggregationSort:
+				s := aggSortEntry{field: ser.GetVString()}
+				s.desc = ser.GetVarUInt() != 0
+				a.sort = append(a.sort, s)
+			case bindings.QueryAggregationLimit:
+				a.limit = int(ser.GetVarUInt())
+			case bindings.QueryAggregationOffset:
+				a.offset = int(ser.GetVarUInt())
+			}
+		case bindings.QuerySelectFilter:
+			q.selectFilter = append(q.selectFilter, ser.GetVString())
+		case bindings.QuerySelectFunction:
+			// Select functions (snippets, highlights) are not supported, and ignored
+			ser.GetVString()
+		case bindings.QueryExplain:
+			q.explain = true
+		case bindings.QueryEqualPosition:
+			for cnt := int(ser.GetVarUInt()); cnt > 0; cnt-- {
+				ser.GetVString()
+			}
+		case bindings.QueryUpdateField:
+			u := updateEntry{field: ser.GetVString()}
+			u.mode = int(ser.GetVarUInt())
+			u.isArray = ser.GetVarUInt() != 0
+			u.values = readValues(ser)
+			q.updates = append(q.updates, u)
+		case bindings.QueryDropField:
+			q.updates = append(q.updates, updateEntry{field: ser.GetVString(), drop: true})
+		default:
+			return nil, bindings.NewError(fmt.Sprintf("Can't parse query: unknown query tag %d", tag), bindings.ErrParseBin)
+		}
+	}
+}
+
+func readValues(ser *cjson.Serializer) []interface{} {
+	cnt := int(ser.GetVarUInt())
+	values := make([]interface{}, cnt)
+	for i := range values {
+		values[i] = readValue(ser)
+	}
+	return values
+}
+
+func readValue(ser *cjson.Serializer) interface{} {
+	switch t := int(ser.GetVarUInt()); t {
+	case bindings.ValueInt, bindings.ValueInt64:
+		return ser.GetVarInt()
+	case bindings.ValueDouble:
+		return ser.GetDouble()
+	case bindings.ValueString:
+		return ser.GetVString()
+	case bindings.ValueBool:
+		return ser.GetVarUInt() != 0
+	case bindings.ValueNull:
+		return nil
+	case bindings.ValueTuple:
+		return readValues(ser)
+	default:
+		panic(fmt.Errorf("unknown value type %d", t))
+	}
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
+)
+
+type rawResultBuffer struct {
+	buf []byte
+}
+
+func (buf *rawResultBuffer) GetBuf() []byte {
+	return buf.buf
+}
+
+func (buf *rawResultBuffer) Free() {
+}
+
+// resultsWriter writes results in the same layout as reindexer core: query params, payload types, extra results and items
+type resultsWriter struct {
+	flags      int
+	namespaces []*namespace
+	items      cjson.Serializer
+	count      int
+}
+
+func newResultsWriter(flags int) *resultsWriter {
+	return &resultsWriter{flags: flags, items: cjson.NewSerializer(nil)}
+}
+
+func (w *resultsWriter) putItem(ns *namespace, nsid int, it *item, filter []string) {
+	w.items.PutVarUInt(uint64(it.id))
+	w.items.PutVarUInt(uint64(it.version))
+	if w.flags&bindings.ResultsWithNsID != 0 {
+		w.items.PutVarUInt(uint64(nsid))
+	}
+
+	obj := it.obj
+	if len(filter) != 0 {
+		obj = project(ns, obj, filter)
+	}
+
+	switch w.flags & bindings.ResultsFormatMask {
+	case bindings.ResultsJson:
+		data, _ := json.Marshal(obj)
+		w.items.PutBytes(data)
+	case bindings.ResultsCJson:
+		ser := cjson.NewSerializer(nil)
+		ns.encodeCJSON(&ser, obj)
+		w.items.PutBytes(ser.Bytes())
+	}
+}
+
+func (w *resultsWriter) putResults(res *queryResults) {
+	for _, r := range res.results {
+		for i, it := range r.items {
+			w.putItem(r.ns, r.nsid, it, r.q.selectFilter)
+			w.count++
+			if w.flags&bindings.ResultsWithJoined == 0 {
+				continue
+			}
+			w.items.PutVarUInt(uint64(len(r.joinNsids)))
+			for j, jit := range r.joined[i] {
+				jq := r.q.joins[j]
+				w.items.PutVarUInt(uint64(len(jit)))
+				for _, ji := range jit {
+					w.putItem(res.namespaces[r.joinNsids[j]], r.joinNsids[j], ji, jq.selectFilter)
+				}
+			}
+		}
+	}
+}
+
+// bytes returns complete results. It must be called after all items are written,
+// because encoding of items can add new tags to namespaces
+func (w *resultsWriter) bytes(total int, aggs [][]byte, explain bool) []byte {
+	ser := cjson.NewSerializer(nil)
+	ser.PutVarUInt(uint64(w.flags))
+	ser.PutVarUInt(uint64(total))
+	ser.PutVarUInt(uint64(w.count))
+	ser.PutVarUInt(uint64(w.count))
+
+	if w.flags&bindings.ResultsWithPayloadTypes != 0 {
+		ser.PutVarUInt(uint64(len(w.namespaces)))
+		for nsid, ns := range w.namespaces {
+			ser.PutVarUInt(uint64(nsid))
+			ser.PutVString(ns.name)
+			ser.PutVarUInt(uint64(ns.stateToken))
+			ser.PutVarUInt(uint64(ns.tmVersion))
+			ser.PutVarUInt(uint64(len(ns.tags)))
+			for _, tag := range ns.tags {
+				ser.PutVString(tag)
+			}
+			// Memory binding has no payload: all fields of items are stored in tuple
+			ser.PutVarUInt(0)
+			ser.PutVarUInt(0)
+		}
+	}
+
+	for _, agg := range aggs {
+		ser.PutVarUInt(bindings.QueryResultAggregation)
+		ser.PutBytes(agg)
+	}
+	if explain {
+		ser.PutVarUInt(bindings.QueryResultExplain)
+		ser.PutBytes([]byte("{}"))
+	}
+	ser.PutVarUInt(bindings.QueryResultEnd)
+
+	ser.Write(w.items.Bytes())
+	return ser.Bytes()
+}
+
+// project returns copy of object with only selected fields
+func project(ns *namespace, obj map[string]interface{}, filter []string) map[string]interface{} {
+	res := make(map[string]interface{})
+	for _, name := range filter {
+		if name == "*" {
+			return obj
+		}
+		f := ns.resolveField(name)
+		for _, p := range f.paths {
+			if v, ok := lookupPath(obj, p); ok {
+				setPath(res, p, v)
+			}
+		}
+	}
+	return res
+}
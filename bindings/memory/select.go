@@ -0,0 +1,691 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+// nsResult is result of one query (main or merged) over namespace
+type nsResult struct {
+	q         *query
+	ns        *namespace
+	nsid      int
+	items     []*item
+	joined    [][][]*item
+	joinNsids []int
+}
+
+// queryResults is result of query with its merged and joined queries
+type queryResults struct {
+	// namespaces in order of nsid: main, merged, joined to main, joined to merged
+	namespaces []*namespace
+	results    []nsResult
+	total      int
+	reqTotal   bool
+	aggs       [][]byte
+	explain    bool
+	withJoined bool
+}
+
+type aggResult struct {
+	Field  string       `json:"field"`
+	Fields []string     `json:"fields"`
+	Type   string       `json:"type"`
+	Value  float64      `json:"value"`
+	Facets []facetValue `json:"facets,omitempty"`
+}
+
+type facetValue struct {
+	// Value is set only for facet of single field
+	Value  string   `json:"value"`
+	Values []string `json:"values"`
+	Count  int      `json:"count"`
+}
+
+var aggTypeNames = map[int]string{
+	bindings.AggSum:   "sum",
+	bindings.AggAvg:   "avg",
+	bindings.AggFacet: "facet",
+	bindings.AggMin:   "min",
+	bindings.AggMax:   "max",
+}
+
+// selectCtx caches resolved fields of namespace during query execution
+type selectCtx struct {
+	ns     *namespace
+	fields map[string]*field
+}
+
+func newSelectCtx(ns *namespace) *selectCtx {
+	return &selectCtx{ns: ns, fields: make(map[string]*field)}
+}
+
+func (ctx *selectCtx) field(name string) *field {
+	f, ok := ctx.fields[name]
+	if !ok {
+		ff := ctx.ns.resolveField(name)
+		f = &ff
+		ctx.fields[name] = f
+	}
+	return f
+}
+
+func (binding *Memory) execQuery(q *query) (*queryResults, error) {
+	res := &queryResults{explain: q.explain}
+
+	queries := append([]*query{q}, q.merged...)
+	for _, mq := range queries {
+		ns, err := binding.getNamespace(mq.namespace)
+		if err != nil {
+			return nil, err
+		}
+		res.namespaces = append(res.namespaces, ns)
+	}
+
+	joinNsids := make([][]int, len(queries))
+	joinNamespaces := make([][]*namespace, len(queries))
+	for i, mq := range queries {
+		for _, jq := range mq.joins {
+			ns, err := binding.getNamespace(jq.namespace)
+			if err != nil {
+				return nil, err
+			}
+			joinNsids[i] = append(joinNsids[i], len(res.namespaces))
+			joinNamespaces[i] = append(joinNamespaces[i], ns)
+			res.namespaces = append(res.namespaces, ns)
+			res.withJoined = true
+		}
+	}
+
+	for i, mq := range queries {
+		ctx := newSelectCtx(res.namespaces[i])
+		items, joined, total := ctx.selectItems(mq, joinNamespaces[i])
+		if i == 0 {
+			aggs, err := ctx.aggregate(q.aggs, items)
+			if err != nil {
+				return nil, err
+			}
+			res.aggs = aggs
+		}
+		items, joined = applyLimit(mq, items, joined)
+		res.results = append(res.results, nsResult{
+			q:         mq,
+			ns:        res.namespaces[i],
+			nsid:      i,
+			items:     items,
+			joined:    joined,
+			joinNsids: joinNsids[i],
+		})
+		res.total += total
+		res.reqTotal = res.reqTotal || mq.reqTotal
+	}
+	return res, nil
+}
+
+// selectItems returns all items, matched query, sorted and filtered by distinct, but without limit and offset
+func (ctx *selectCtx) selectItems(q *query, joinNamespaces []*namespace) (items []*item, joined [][][]*item, total int) {
+	joinCtxs := make([]*selectCtx, len(q.joins))
+	joinItems := make([][]*item, len(q.joins))
+	for i, jq := range q.joins {
+		joinCtxs[i] = newSelectCtx(joinNamespaces[i])
+		joinItems[i] = joinCtxs[i].filter(jq, joinNamespaces[i].sortedItems())
+	}
+
+	for _, it := range ctx.ns.sortedItems() {
+		if !ctx.matchEntries(it.obj, q.entries) {
+			continue
+		}
+		matched := true
+		var itJoined [][]*item
+		if len(q.joins) != 0 {
+			itJoined = make([][]*item, len(q.joins))
+			// As in core, inner joins are applied one by one to items, which match conditions.
+			// OrInnerJoin is OR with result of previous joins, and chain stops on mismatch, which is not followed by OrInnerJoin
+			for i, jq := range q.joins {
+				found := ctx.joinItems(it, jq, joinCtxs[i], joinItems[i])
+				switch jq.joinType {
+				case bindings.InnerJoin:
+					matched = matched && len(found) != 0
+				case bindings.OrInnerJoin:
+					matched = matched || len(found) != 0
+				}
+				itJoined[i] = found
+				if !matched && (i+1 == len(q.joins) || q.joins[i+1].joinType != bindings.OrInnerJoin) {
+					break
+				}
+			}
+		}
+		if matched {
+			items = append(items, it)
+			joined = append(joined, itJoined)
+		}
+	}
+
+	items, joined = ctx.distinct(q, items, joined)
+	ctx.sort(q, items, joined)
+	return items, joined, len(items)
+}
+
+// filter returns items, matched query conditions, in order of query sort, without limit and offset
+func (ctx *selectCtx) filter(q *query, items []*item) (result []*item) {
+	for _, it := range items {
+		if ctx.matchEntries(it.obj, q.entries) {
+			result = append(result, it)
+		}
+	}
+	ctx.sort(q, result, nil)
+	return result
+}
+
+// joinItems returns items of joined namespace, matched join conditions with item of main namespace
+func (ctx *selectCtx) joinItems(it *item, jq *query, jctx *selectCtx, candidates []*item) (found []*item) {
+	for _, jit := range candidates {
+		res, cur, have := true, false, false
+		for _, on := range jq.joinOn {
+			f := ctx.field(on.index)
+			jf := jctx.field(on.joinIndex)
+			// As in core, value of joined item is compared with value of main item, so On("a", LT, "b") matches joined items with b < a
+			v := matchCond(jf.values(jit.obj), on.cond, f.values(it.obj), jf)
+			res, cur, have = combine(res, cur, have, on.op, v)
+		}
+		if have {
+			res = res && cur
+		}
+		if res {
+			found = append(found, jit)
+		}
+	}
+	found, _ = applyLimit(jq, found, nil)
+	return found
+}
+
+// combine adds result of condition to chain of conditions. OR has priority over AND, so 'A AND B OR C' is 'A AND (B OR C)'
+func combine(res, cur, have bool, op int, v bool) (bool, bool, bool) {
+	switch {
+	case op == bindings.OpOr && have:
+		return res, cur || v, true
+	case op == bindings.OpNot:
+		if have {
+			res = res && cur
+		}
+		return res, !v, true
+	default:
+		if have {
+			res = res && cur
+		}
+		return res, v, true
+	}
+}
+
+func (ctx *selectCtx) matchEntries(obj map[string]interface{}, entries []*queryEntry) bool {
+	res, cur, have := true, false, false
+	for _, e := range entries {
+		var v bool
+		if e.bracket {
+			v = ctx.matchEntries(obj, e.children)
+		} else {
+			f := ctx.field(e.field)
+			v = matchCond(f.values(obj), e.cond, e.values, f)
+		}
+		res, cur, have = combine(res, cur, have, e.op, v)
+	}
+	if have {
+		res = res && cur
+	}
+	return res
+}
+
+func matchCond(values []interface{}, cond int, keys []interface{}, f *field) bool {
+	switch cond {
+	case bindings.ANY:
+		return len(values) != 0
+	case bindings.EMPTY:
+		return len(values) == 0
+	case bindings.EQ, bindings.SET:
+		if f.fulltext {
+			return matchFulltext(values, keys)
+		}
+		for _, v := range values {
+			for _, k := range keys {
+				if compareValues(v, k, f.collate) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	case bindings.ALLSET:
+		for _, k := range keys {
+			found := false
+			for _, v := range values {
+				if compareValues(v, k, f.collate) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return len(keys) != 0
+	case bindings.LT, bindings.LE, bindings.GT, bindings.GE:
+		if len(keys) == 0 {
+			return false
+		}
+		for _, v := range values {
+			r := compareValues(v, keys[0], f.collate)
+			if (cond == bindings.LT && r < 0) || (cond == bindings.LE && r <= 0) ||
+				(cond == bindings.GT && r > 0) || (cond == bindings.GE && r >= 0) {
+				return true
+			}
+		}
+		return false
+	case bindings.RANGE:
+		if len(keys) < 2 {
+			return false
+		}
+		for _, v := range values {
+			if compareValues(v, keys[0], f.collate) >= 0 && compareValues(v, keys[1], f.collate) <= 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// matchFulltext is simple replacement of reindexer's full text search: item matches, if it contains any of terms.
+// Terms with '+' must be present, terms with '-' must be absent, and terms with '*' match by prefix or suffix
+func matchFulltext(values []interface{}, keys []interface{}) bool {
+	words := make(map[string]bool)
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			for _, w := range splitWords(s) {
+				words[w] = true
+			}
+		}
+	}
+	matchTerm := func(term string) bool {
+		prefix, suffix := strings.HasSuffix(term, "*"), strings.HasPrefix(term, "*")
+		term = strings.Trim(term, "*")
+		if term == "" {
+			return false
+		}
+		if !prefix && !suffix {
+			return words[term]
+		}
+		for w := range words {
+			if (prefix && strings.HasPrefix(w, term)) || (suffix && strings.HasSuffix(w, term)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched := false
+	for _, k := range keys {
+		s, _ := k.(string)
+		for _, term := range strings.Fields(strings.ToLower(s)) {
+			if strings.HasPrefix(term, "@") {
+				continue
+			}
+			if i := strings.IndexByte(term, '~'); i >= 0 {
+				term = term[:i]
+			}
+			term = strings.Trim(term, "\"'")
+			switch {
+			case strings.HasPrefix(term, "-"):
+				if matchTerm(term[1:]) {
+					return false
+				}
+			case strings.HasPrefix(term, "+"):
+				if !matchTerm(term[1:]) {
+					return false
+				}
+				matched = true
+			default:
+				matched = matched || matchTerm(term)
+			}
+		}
+	}
+	return matched
+}
+
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (ctx *selectCtx) distinct(q *query, items []*item, joined [][][]*item) ([]*item, [][][]*item) {
+	if len(q.distinct) == 0 {
+		return items, joined
+	}
+	seen := make([]map[string]bool, len(q.distinct))
+	for i := range seen {
+		seen[i] = make(map[string]bool)
+	}
+	resItems, resJoined := items[:0:0], joined[:0:0]
+	for i, it := range items {
+		unique := true
+		for d, name := range q.distinct {
+			values := ctx.field(name).values(it.obj)
+			if len(values) == 0 {
+				values = []interface{}{nil}
+			}
+			hasNew := false
+			for _, v := range values {
+				key := valueKey(v)
+				if !seen[d][key] {
+					seen[d][key] = true
+					hasNew = true
+				}
+			}
+			unique = unique && hasNew
+		}
+		if unique {
+			resItems = append(resItems, it)
+			resJoined = append(resJoined, joined[i])
+		}
+	}
+	return resItems, resJoined
+}
+
+func (ctx *selectCtx) sort(q *query, items []*item, joined [][][]*item) {
+	if len(q.sort) == 0 {
+		return
+	}
+	type sortKey struct {
+		rank  int
+		value interface{}
+	}
+	keys := make(map[*item][]sortKey, len(items))
+	for _, it := range items {
+		itKeys := make([]sortKey, len(q.sort))
+		for s, se := range q.sort {
+			f := ctx.field(se.field)
+			if values := f.values(it.obj); len(values) != 0 {
+				itKeys[s].value = values[0]
+			}
+			itKeys[s].rank = len(se.forced)
+			for r, fv := range se.forced {
+				if itKeys[s].value != nil && compareValues(itKeys[s].value, fv, f.collate) == 0 {
+					itKeys[s].rank = r
+					break
+				}
+			}
+		}
+		keys[it] = itKeys
+	}
+
+	less := func(a, b *item) bool {
+		ka, kb := keys[a], keys[b]
+		for s, se := range q.sort {
+			if ka[s].rank != kb[s].rank {
+				return ka[s].rank < kb[s].rank
+			}
+			r := compareValues(ka[s].value, kb[s].value, ctx.field(se.field).collate)
+			if se.desc {
+				r = -r
+			}
+			if r != 0 {
+				return r < 0
+			}
+		}
+		return false
+	}
+
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return less(items[idx[i]], items[idx[j]]) })
+
+	sortedItems := make([]*item, len(items))
+	for i, k := range idx {
+		sortedItems[i] = items[k]
+	}
+	copy(items, sortedItems)
+	if joined != nil {
+		sortedJoined := make([][][]*item, len(joined))
+		for i, k := range idx {
+			sortedJoined[i] = joined[k]
+		}
+		copy(joined, sortedJoined)
+	}
+}
+
+func applyLimit(q *query, items []*item, joined [][][]*item) ([]*item, [][][]*item) {
+	begin, end := q.offset, len(items)
+	if begin > len(items) {
+		begin = len(items)
+	}
+	if q.limit != noLimit && begin+q.limit < end {
+		end = begin + q.limit
+	}
+	if joined != nil {
+		joined = joined[begin:end]
+	}
+	return items[begin:end], joined
+}
+
+func (ctx *selectCtx) aggregate(aggs []aggEntry, items []*item) (results [][]byte, err error) {
+	for _, agg := range aggs {
+		typeName, ok := aggTypeNames[agg.aggType]
+		if !ok {
+			return nil, bindings.NewError(fmt.Sprintf("Unknown aggregation type %d", agg.aggType), bindings.ErrParams)
+		}
+		res := aggResult{Field: strings.Join(agg.fields, "+"), Fields: agg.fields, Type: typeName}
+
+		if agg.aggType == bindings.AggFacet {
+			if res.Facets, err = ctx.facets(agg, items); err != nil {
+				return nil, err
+			}
+		} else {
+			if len(agg.fields) != 1 || len(agg.sort) != 0 || agg.limit != noLimit || agg.offset != 0 {
+				return nil, bindings.NewError("Only facet aggregation can have several fields, sort, limit and offset", bindings.ErrParams)
+			}
+			f := ctx.field(agg.fields[0])
+			count := 0
+			for _, it := range items {
+				for _, v := range f.values(it.obj) {
+					fv, ok := toFloat(v)
+					if !ok {
+						continue
+					}
+					switch {
+					case agg.aggType == bindings.AggSum || agg.aggType == bindings.AggAvg:
+						res.Value += fv
+					case count == 0:
+						res.Value = fv
+					case agg.aggType == bindings.AggMin && fv < res.Value:
+						res.Value = fv
+					case agg.aggType == bindings.AggMax && fv > res.Value:
+						res.Value = fv
+					}
+					count++
+				}
+			}
+			if agg.aggType == bindings.AggAvg && count != 0 {
+				res.Value /= float64(count)
+			}
+		}
+
+		data, err := json.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	return results, nil
+}
+
+// facets counts items by each combination of values of aggregation fields.
+// Facets are sorted by values of fields, if other sort is not set, and paginated by limit and offset of aggregation
+func (ctx *selectCtx) facets(agg aggEntry, items []*item) ([]facetValue, error) {
+	fields := make([]*field, len(agg.fields))
+	for i, name := range agg.fields {
+		fields[i] = ctx.field(name)
+	}
+
+	// sortBy is index of field for each sort entry, or -1 for sort by count
+	sortBy := make([]int, 0, len(agg.sort)+len(fields))
+	desc := make([]bool, 0, len(agg.sort)+len(fields))
+	for _, s := range agg.sort {
+		idx := -1
+		for i, name := range agg.fields {
+			if strings.EqualFold(name, s.field) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 && !strings.EqualFold(s.field, "count") {
+			return nil, bindings.NewError(fmt.Sprintf("Facet can be sorted only by count or by one of its fields, but not by '%s'", s.field), bindings.ErrParams)
+		}
+		sortBy, desc = append(sortBy, idx), append(desc, s.desc)
+	}
+	for i := range fields {
+		sortBy, desc = append(sortBy, i), append(desc, false)
+	}
+
+	type facet struct {
+		values []interface{}
+		count  int
+	}
+	var facets []*facet
+	byKey := make(map[string]*facet)
+	for _, it := range items {
+		combinations := [][]interface{}{nil}
+		for _, f := range fields {
+			values := f.values(it.obj)
+			next := make([][]interface{}, 0, len(combinations)*len(values))
+			for _, c := range combinations {
+				for _, v := range values {
+					next = append(next, append(c[:len(c):len(c)], v))
+				}
+			}
+			combinations = next
+		}
+		for _, values := range combinations {
+			keys := make([]string, len(values))
+			for i, v := range values {
+				keys[i] = valueKey(v)
+			}
+			key := strings.Join(keys, "\x00")
+			fc := byKey[key]
+			if fc == nil {
+				fc = &facet{values: values}
+				byKey[key] = fc
+				facets = append(facets, fc)
+			}
+			fc.count++
+		}
+	}
+
+	sort.SliceStable(facets, func(i, j int) bool {
+		for s, idx := range sortBy {
+			r := 0
+			if idx < 0 {
+				if facets[i].count < facets[j].count {
+					r = -1
+				} else if facets[i].count > facets[j].count {
+					r = 1
+				}
+			} else {
+				r = compareValues(facets[i].values[idx], facets[j].values[idx], fields[idx].collate)
+			}
+			if r != 0 {
+				return (r < 0) != desc[s]
+			}
+		}
+		return false
+	})
+
+	if agg.offset > len(facets) {
+		agg.offset = len(facets)
+	}
+	facets = facets[agg.offset:]
+	if agg.limit != noLimit && agg.limit < len(facets) {
+		facets = facets[:agg.limit]
+	}
+
+	res := make([]facetValue, 0, len(facets))
+	for _, fc := range facets {
+		fv := facetValue{Values: make([]string, len(fc.values)), Count: fc.count}
+		for i, v := range fc.values {
+			fv.Values[i] = valueKey(v)
+		}
+		if len(fv.Values) == 1 {
+			fv.Value = fv.Values[0]
+		}
+		res = append(res, fv)
+	}
+	return res, nil
+}
+
+// applyUpdates applies Set, ArrayAppend, ArrayRemove and Drop of update query to copy of item's object
+func (ctx *selectCtx) applyUpdates(q *query, obj map[string]interface{}) map[string]interface{} {
+	obj = copyValue(obj).(map[string]interface{})
+	for _, u := range q.updates {
+		path := u.field
+		if f := ctx.field(u.field); len(f.paths) != 0 {
+			path = f.paths[0]
+		}
+		if u.drop {
+			dropPath(obj, path)
+			continue
+		}
+		values := make([]interface{}, len(u.values))
+		for i, v := range u.values {
+			values[i] = copyValue(v)
+		}
+
+		switch u.mode {
+		case bindings.UpdateFieldSet:
+			if u.isArray {
+				setPath(obj, path, values)
+			} else if len(values) != 0 {
+				setPath(obj, path, values[0])
+			} else {
+				setPath(obj, path, nil)
+			}
+		case bindings.UpdateFieldArrayAppend:
+			old, ok := lookupPath(obj, path)
+			var arr []interface{}
+			switch oldv := old.(type) {
+			case []interface{}:
+				arr = oldv
+			default:
+				if ok && old != nil {
+					arr = []interface{}{old}
+				}
+			}
+			setPath(obj, path, append(arr, values...))
+		case bindings.UpdateFieldArrayRemove:
+			old, _ := lookupPath(obj, path)
+			arr, ok := old.([]interface{})
+			if !ok {
+				continue
+			}
+			res := make([]interface{}, 0, len(arr))
+			for _, e := range arr {
+				remove := false
+				for _, v := range values {
+					if compareValues(e, v, bindings.CollateNone) == 0 {
+						remove = true
+						break
+					}
+				}
+				if !remove {
+					res = append(res, e)
+				}
+			}
+			setPath(obj, path, res)
+		}
+	}
+	return obj
+}
@@ -0,0 +1,293 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+// Items are stored as generic trees of values:
+// map[string]interface{}, []interface{}, int64, float64, string, bool and nil
+
+func parseJSON(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, bindings.NewError(fmt.Sprintf("Can't parse JSON: %s", err.Error()), bindings.ErrParseJson)
+	}
+	obj, ok := normalizeJSON(v).(map[string]interface{})
+	if !ok {
+		return nil, bindings.NewError("Item JSON must be an object", bindings.ErrParseJson)
+	}
+	return obj, nil
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeJSON(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeJSON(e)
+		}
+		return v
+	}
+	return v
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyValue(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
+	}
+	return v
+}
+
+// getPath returns all scalar values by dotted json path. Arrays on the path are flattened
+func getPath(v interface{}, path string) (values []interface{}) {
+	return appendPath(nil, v, strings.Split(path, "."))
+}
+
+func appendPath(values []interface{}, v interface{}, path []string) []interface{} {
+	switch vv := v.(type) {
+	case []interface{}:
+		for _, e := range vv {
+			values = appendPath(values, e, path)
+		}
+		return values
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return values
+		}
+		e, ok := vv[path[0]]
+		if !ok {
+			return values
+		}
+		return appendPath(values, e, path[1:])
+	case nil:
+		return values
+	}
+	if len(path) != 0 {
+		return values
+	}
+	return append(values, v)
+}
+
+// setPath sets value by dotted json path, creating intermediate objects
+func setPath(obj map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := obj[p].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[p] = next
+		}
+		obj = next
+	}
+	obj[parts[len(parts)-1]] = value
+}
+
+// lookupPath returns raw value by dotted json path without flattening arrays
+func lookupPath(obj map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := obj[p].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		obj = next
+	}
+	v, ok := obj[parts[len(parts)-1]]
+	return v, ok
+}
+
+// dropPath removes value by dotted json path. Returns false, if there is no such field
+func dropPath(obj map[string]interface{}, path string) bool {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := obj[p].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		obj = next
+	}
+	last := parts[len(parts)-1]
+	if _, ok := obj[last]; !ok {
+		return false
+	}
+	delete(obj, last)
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareValues compares 2 values with collate mode. Numbers are comparable with numeric strings
+func compareValues(a, b interface{}, collate int) int {
+	switch av := a.(type) {
+	case int64:
+		switch bv := b.(type) {
+		case int64:
+			if av < bv {
+				return -1
+			} else if av > bv {
+				return 1
+			}
+			return 0
+		case float64:
+			return cmpFloat(float64(av), bv)
+		case string:
+			if f, ok := toFloat(bv); ok {
+				return cmpFloat(float64(av), f)
+			}
+		}
+	case float64:
+		if f, ok := toFloat(b); ok && typeRank(b) >= 2 {
+			return cmpFloat(av, f)
+		}
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return compareStrings(av, bv, collate)
+		case int64, float64:
+			if f, ok := toFloat(av); ok {
+				fb, _ := toFloat(bv)
+				return cmpFloat(f, fb)
+			}
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0
+			} else if !av {
+				return -1
+			}
+			return 1
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			for i := 0; i < len(av) && i < len(bv); i++ {
+				if r := compareValues(av[i], bv[i], collate); r != 0 {
+					return r
+				}
+			}
+			return len(av) - len(bv)
+		}
+	}
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+	return compareStrings(fmt.Sprint(a), fmt.Sprint(b), collate)
+}
+
+func compareStrings(a, b string, collate int) int {
+	switch collate {
+	case bindings.CollateASCII, bindings.CollateUTF8:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	case bindings.CollateNumeric:
+		na, resta := numericPrefix(a)
+		nb, restb := numericPrefix(b)
+		if r := cmpFloat(na, nb); r != 0 {
+			return r
+		}
+		return strings.Compare(strings.ToLower(resta), strings.ToLower(restb))
+	}
+	return strings.Compare(a, b)
+}
+
+// numericPrefix splits string to leading number and rest of string
+func numericPrefix(s string) (float64, string) {
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	n, _ := strconv.ParseFloat(s[:i], 64)
+	return n, s[i:]
+}
+
+// valueKey returns string representation of value, used as key of maps for distinct, facets and pk lookup
+func valueKey(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = valueKey(e)
+		}
+		return strings.Join(parts, "\x00")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,371 @@
+package cjson
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+)
+
+// ItemEncoder is implemented by types with generated codec (see cmd/rxgen). Encoder calls EncodeCJSON instead of reflection.
+// EncodeCJSON must write fields of object with PutField* methods of ser
+type ItemEncoder interface {
+	EncodeCJSON(ser *Serializer)
+}
+
+// ItemDecoder is implemented by types with generated codec (see cmd/rxgen). Decoder calls DecodeCJSON instead of reflection.
+// DecodeCJSON must read fields of object, while r.Next() returns true
+type ItemDecoder interface {
+	DecodeCJSON(r *Reader)
+}
+
+func (s *Serializer) name2tag(name string) int {
+	if len(name) == 0 {
+		return 0
+	}
+	if s.enc == nil {
+		panic(fmt.Errorf("Serializer is not bound to encoder: fields can be written only from EncodeCJSON"))
+	}
+	return s.enc.name2tag(name)
+}
+
+// PutFieldNull writes null field with name. Empty name is used for array elements
+func (s *Serializer) PutFieldNull(name string) {
+	s.PutVarUInt(mkctag(TAG_NULL, s.name2tag(name), 0))
+}
+
+// PutFieldInt writes integer field with name
+func (s *Serializer) PutFieldInt(name string, v int64) {
+	s.PutVarUInt(mkctag(TAG_VARINT, s.name2tag(name), 0))
+	s.PutVarInt(v)
+}
+
+// PutFieldDouble writes float field with name
+func (s *Serializer) PutFieldDouble(name string, v float64) {
+	s.PutVarUInt(mkctag(TAG_DOUBLE, s.name2tag(name), 0))
+	s.PutDouble(v)
+}
+
+// PutFieldBool writes bool field with name
+func (s *Serializer) PutFieldBool(name string, v bool) {
+	s.PutVarUInt(mkctag(TAG_BOOL, s.name2tag(name), 0))
+	s.PutBool(v)
+}
+
+// PutFieldString writes string field with name
+func (s *Serializer) PutFieldString(name string, v string) {
+	s.PutVarUInt(mkctag(TAG_STRING, s.name2tag(name), 0))
+	s.PutVString(v)
+}
+
+// PutFieldBytes writes bytes field with name as base64 string
+func (s *Serializer) PutFieldBytes(name string, v []byte) {
+	s.PutVarUInt(mkctag(TAG_STRING, s.name2tag(name), 0))
+	s.PutVString(base64.StdEncoding.EncodeToString(v))
+}
+
+// PutFieldObject starts object field with name. Fields of object must be followed by PutFieldEnd
+func (s *Serializer) PutFieldObject(name string) {
+	s.PutVarUInt(mkctag(TAG_OBJECT, s.name2tag(name), 0))
+}
+
+// PutFieldEnd ends object
+func (s *Serializer) PutFieldEnd() {
+	s.PutVarUInt(mkctag(TAG_END, 0, 0))
+}
+
+// PutFieldArray starts array field with name and count elements of elemTag type. Elements of TAG_OBJECT arrays are written
+// as fields with empty name, elements of other arrays are written by PutVarInt, PutDouble, PutBool or PutVString
+func (s *Serializer) PutFieldArray(name string, count int, elemTag int) {
+	s.PutVarUInt(mkctag(TAG_ARRAY, s.name2tag(name), 0))
+	s.PutUInt32(mkcarraytag(count, elemTag))
+}
+
+// PutFieldValue writes field with name by reflection. It's used for values, which are not supported by generated code
+func (s *Serializer) PutFieldValue(name string, v interface{}, omitEmpty bool) {
+	vv := reflect.ValueOf(v)
+	if !vv.IsValid() {
+		if !omitEmpty {
+			s.PutFieldNull(name)
+		}
+		return
+	}
+	f := mkFieldInfo(vv, s.name2tag(name), false)
+	f.isOmitEmpty = omitEmpty
+	s.enc.encodeValue(vv, s, f, nil)
+}
+
+// PutBool writes bool value
+func (s *Serializer) PutBool(v bool) {
+	if v {
+		s.PutVarUInt(1)
+	} else {
+		s.PutVarUInt(0)
+	}
+}
+
+// Reader reads fields of CJSON object. It's used by DecodeCJSON methods, generated by rxgen
+type Reader struct {
+	dec          *Decoder
+	pl           *payloadIface
+	ser          *Serializer
+	fieldsoutcnt []int
+	// path is names of objects, which are read now
+	path []int
+
+	// type, name and payload field of current value
+	tagType int
+	name    int
+	field   int
+}
+
+// ArrayReader reads elements of array field
+type ArrayReader struct {
+	r       *Reader
+	count   int
+	elemTag int
+	field   int
+}
+
+func (r *Reader) readTag() {
+	ctag := ctag(r.ser.GetVarUInt())
+	r.tagType, r.name, r.field = ctag.Type(), ctag.Name(), ctag.Field()
+}
+
+// Next reads next field of current object. It returns false at the end of object. Null fields are skipped
+func (r *Reader) Next() bool {
+	for {
+		r.readTag()
+		switch r.tagType {
+		case TAG_END:
+			r.path = r.path[:len(r.path)-1]
+			return false
+		case TAG_NULL:
+			continue
+		}
+		return true
+	}
+}
+
+// Name returns name of current field
+func (r *Reader) Name() string {
+	return r.dec.state.tagsMatcher.tag2name(r.name)
+}
+
+// Object starts reading of current object value. It returns false, if value is null
+func (r *Reader) Object() bool {
+	switch r.tagType {
+	case TAG_NULL:
+		return false
+	case TAG_OBJECT:
+		r.path = append(r.path, r.name)
+		return true
+	}
+	panic(fmt.Errorf("Can't read %s as object", tagTypeName(r.tagType)))
+}
+
+// Array starts reading of current array value
+func (r *Reader) Array() ArrayReader {
+	if r.tagType != TAG_ARRAY {
+		panic(fmt.Errorf("Can't read %s as array", tagTypeName(r.tagType)))
+	}
+	if r.field >= 0 {
+		return ArrayReader{r: r, count: int(r.ser.GetVarUInt()), elemTag: r.pl.tagType(r.field), field: r.field}
+	}
+	atag := carraytag(r.ser.GetUInt32())
+	return ArrayReader{r: r, count: atag.Count(), elemTag: atag.Tag(), field: -1}
+}
+
+// Len returns count of elements of array
+func (a *ArrayReader) Len() int {
+	return a.count
+}
+
+// Next makes next element of array current value of reader. It returns false, if element is null
+func (a *ArrayReader) Next() bool {
+	if a.elemTag == TAG_OBJECT {
+		a.r.readTag()
+	} else {
+		a.r.tagType, a.r.name, a.r.field = a.elemTag, 0, a.field
+	}
+	return a.r.tagType != TAG_NULL
+}
+
+// payloadIdx returns index of next value of payload field
+func (r *Reader) payloadIdx() int {
+	idx := r.fieldsoutcnt[r.field]
+	r.fieldsoutcnt[r.field]++
+	return idx
+}
+
+// Int returns current value as integer
+func (r *Reader) Int() int64 {
+	if r.field < 0 {
+		return asInt(r.ser, r.tagType)
+	}
+	idx := r.payloadIdx()
+	switch r.pl.t.Fields[r.field].Type {
+	case valueInt:
+		return int64(r.pl.getInt(r.field, idx))
+	case valueInt64:
+		return r.pl.getInt64(r.field, idx)
+	case valueDouble:
+		return int64(r.pl.getFloat64(r.field, idx))
+	case valueBool:
+		if r.pl.getBool(r.field, idx) {
+			return 1
+		}
+		return 0
+	}
+	panic(fmt.Errorf("Can't convert key value type %d to int", r.pl.t.Fields[r.field].Type))
+}
+
+// Float returns current value as float
+func (r *Reader) Float() float64 {
+	if r.field < 0 {
+		return asFloat(r.ser, r.tagType)
+	}
+	if r.pl.t.Fields[r.field].Type == valueDouble {
+		return r.pl.getFloat64(r.field, r.payloadIdx())
+	}
+	return float64(r.Int())
+}
+
+// Bool returns current value as bool
+func (r *Reader) Bool() bool {
+	if r.field < 0 {
+		return asInt(r.ser, r.tagType) != 0
+	}
+	return r.Int() != 0
+}
+
+// String returns current value as string
+func (r *Reader) String() string {
+	if r.field < 0 {
+		return asString(r.ser, r.tagType)
+	}
+	if r.pl.t.Fields[r.field].Type != valueString {
+		panic(fmt.Errorf("Can't convert key value type %d to string", r.pl.t.Fields[r.field].Type))
+	}
+	return r.pl.getString(r.field, r.payloadIdx())
+}
+
+// Bytes returns current value, which is base64 string, as bytes
+func (r *Reader) Bytes() []byte {
+	str := r.String()
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		panic(fmt.Errorf("Can't base64 decode %s", str))
+	}
+	return b
+}
+
+// Value decodes current value to v by reflection. v must be pointer. It's used for values, which are not supported by generated code
+func (r *Reader) Value(v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	cctagsPath := make([]int, 0, len(r.path)+1)
+	for _, name := range r.path {
+		if name != 0 {
+			cctagsPath = append(cctagsPath, name)
+		}
+	}
+	if r.name != 0 {
+		cctagsPath = append(cctagsPath, r.name)
+	}
+	r.dec.decodeTag(r.pl, r.ser, rv, ctag(mkctag(r.tagType, 0, r.field+1)), r.fieldsoutcnt, cctagsPath)
+}
+
+// Skip skips current value
+func (r *Reader) Skip() {
+	r.dec.skipStruct(r.pl, r.ser, r.fieldsoutcnt, ctag(mkctag(r.tagType, r.name, r.field+1)))
+}
+
+// DeepCopyValue deep copies value, pointed by src, to value, pointed by dst, by reflection. dst and src must be pointers
+// to values of the same type. It's used by generated DeepCopy for values, which are not supported by generated code.
+// Unexported fields of structs are copied shallowly
+func DeepCopyValue(dst, src interface{}) {
+	deepCopyValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func deepCopyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		deepCopyValue(v.Elem(), src.Elem())
+		dst.Set(v)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		deepCopyValue(v, src.Elem())
+		dst.Set(v)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for _, k := range src.MapKeys() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			deepCopyValue(v, src.MapIndex(k))
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopyValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// decodeItem decodes object with generated codec
+func (dec *Decoder) decodeItem(pl *payloadIface, rdser *Serializer, dest ItemDecoder, fieldsoutcnt []int) {
+	r := &Reader{dec: dec, pl: pl, ser: rdser, fieldsoutcnt: fieldsoutcnt, path: make([]int, 0, 8)}
+	r.readTag()
+	if r.Object() {
+		dest.DecodeCJSON(r)
+	}
+}
+
+// tagType returns ctag type of values of payload field
+func (pl *payloadIface) tagType(field int) int {
+	switch pl.t.Fields[field].Type {
+	case valueBool:
+		return TAG_BOOL
+	case valueDouble:
+		return TAG_DOUBLE
+	case valueString:
+		return TAG_STRING
+	}
+	return TAG_VARINT
+}
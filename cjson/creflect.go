@@ -188,6 +188,28 @@ func (pl *payloadIface) getArray(field int, startIdx int, cnt int, v reflect.Val
 		return
 	}
 
+	if a, ok := v.Addr().Interface().(*[]interface{}); ok {
+		// Array of item, which is decoded to map
+		*a = make([]interface{}, cnt, cnt)
+		for i := 0; i < cnt; i++ {
+			(*a)[i] = pl.getIfaceValue(field, i+startIdx)
+		}
+		return
+	}
+
+	if hook := unmarshalHook(v.Type().Elem()); hook != hookNone {
+		// Array of values with custom unmarshaler
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), cnt, cnt))
+		}
+		for i := 0; i < cnt; i++ {
+			if err := unmarshalValue(v.Index(i), hook, pl.getIfaceValue(field, i+startIdx)); err != nil {
+				panic(fmt.Errorf("Can't unmarshal %s: %s", v.Type().Elem().String(), err.Error()))
+			}
+		}
+		return
+	}
+
 	ptr := pl.ptr(field, startIdx, pl.t.Fields[field].Type)
 	l := pl.getArrayLen(field) - startIdx
 
@@ -294,6 +316,27 @@ func (pl *payloadIface) getArray(field int, startIdx int, cnt int, v reflect.Val
 	}
 }
 
+// getIfaceValue returns value of field with the same go type, as it's decoded from cjson to interface
+func (pl *payloadIface) getIfaceValue(field, idx int) interface{} {
+	switch pl.t.Fields[field].Type {
+	case valueBool:
+		return pl.getBool(field, idx)
+	case valueInt:
+		return pl.getInt(field, idx)
+	case valueInt64:
+		v := pl.getInt64(field, idx)
+		if v < int64(minInt) || v > int64(maxInt) {
+			return v
+		}
+		return int(v)
+	case valueDouble:
+		return pl.getFloat64(field, idx)
+	case valueString:
+		return pl.getString(field, idx)
+	}
+	panic(fmt.Errorf("Unknown key value type %d", pl.t.Fields[field].Type))
+}
+
 // Slow and generic method: convert c payload to go interface
 // Use only for debug purposes
 func (pl *payloadIface) getIface(field int) interface{} {
@@ -162,6 +162,8 @@ func asIface(rdser *Serializer, tagType int) interface{} {
 		return rdser.GetDouble()
 	case TAG_BOOL:
 		return rdser.GetVarInt() != 0
+	case TAG_STRING:
+		return rdser.GetVString()
 	default:
 		panic(fmt.Errorf("Can't convert tagType %s to iface", tagTypeName(tagType)))
 	}
@@ -249,7 +251,13 @@ func (dec *Decoder) decodeSlice(pl *payloadIface, rdser *Serializer, v *reflect.
 		panic(fmt.Errorf("Can't set array to %s", v.Type().Kind().String()))
 	}
 
-	if subtag != TAG_OBJECT {
+	if hook := unmarshalHook(v.Type().Elem()); subtag != TAG_OBJECT && hook != hookNone {
+		for i := 0; i < count; i++ {
+			if err := unmarshalValue(v.Index(i), hook, asIface(rdser, subtag)); err != nil {
+				panic(fmt.Errorf("Can't unmarshal %s: %s", v.Type().Elem().String(), err.Error()))
+			}
+		}
+	} else if subtag != TAG_OBJECT {
 		switch v.Type().Elem().Kind() {
 		case reflect.Int:
 			sl := (*[1 << 28]int)(ptr)[:count:count]
@@ -341,8 +349,12 @@ func (dec *Decoder) decodeSlice(pl *payloadIface, rdser *Serializer, v *reflect.
 }
 
 func (dec *Decoder) decodeValue(pl *payloadIface, rdser *Serializer, v reflect.Value, fieldsoutcnt []int, cctagsPath []int) bool {
+	return dec.decodeTag(pl, rdser, v, ctag(rdser.GetVarUInt()), fieldsoutcnt, cctagsPath)
+}
+
+// decodeTag decodes value with already read ctag to v
+func (dec *Decoder) decodeTag(pl *payloadIface, rdser *Serializer, v reflect.Value, ctag ctag, fieldsoutcnt []int, cctagsPath []int) bool {
 
-	ctag := ctag(rdser.GetVarUInt())
 	ctagType := ctag.Type()
 
 	switch ctagType {
@@ -428,6 +440,16 @@ func (dec *Decoder) decodeValue(pl *payloadIface, rdser *Serializer, v reflect.V
 
 	//fmt.Printf("intf=%s, name='%s' %s,tagspath=%v,idx=%v\n", v.Type().Name(), dec.state.tagsMatcher.tag2name(ctagName), ctag.Dump(), cctagsPath, *idx)
 
+	// Value with custom unmarshaler is decoded to generic value, which is passed to unmarshaler. Decoded item itself is always decoded field by field
+	var hooked reflect.Value
+	hook := hookNone
+	if len(cctagsPath) != 0 || ctagName != 0 {
+		if hook = unmarshalHook(v.Type()); hook != hookNone {
+			hooked, v = v, mkValue(ctagType)
+			k = v.Kind()
+		}
+	}
+
 	if ctagField >= 0 {
 		// get data from payload object
 		cnt := &fieldsoutcnt[ctagField]
@@ -483,6 +505,13 @@ func (dec *Decoder) decodeValue(pl *payloadIface, rdser *Serializer, v reflect.V
 		}
 	}
 
+	if hook != hookNone {
+		if err := unmarshalValue(hooked, hook, v.Interface()); err != nil {
+			panic(fmt.Errorf("Can't unmarshal %s: %s", hooked.Type().String(), err.Error()))
+		}
+		v = hooked
+	}
+
 	if isMap {
 		if mv.Type().Elem().Kind() == reflect.Ptr {
 			v = v.Addr()
@@ -536,7 +565,11 @@ func (dec *Decoder) DecodeCPtr(cptr uintptr, dest interface{}) (err error) {
 	fieldsoutcnt := make([]int, 64, 64)
 	ctagsPath := make([]int, 0, 8)
 
-	dec.decodeValue(pl, ser, reflect.ValueOf(dest), fieldsoutcnt, ctagsPath)
+	if id, ok := dest.(ItemDecoder); ok {
+		dec.decodeItem(pl, ser, id, fieldsoutcnt)
+	} else {
+		dec.decodeValue(pl, ser, reflect.ValueOf(dest), fieldsoutcnt, ctagsPath)
+	}
 	if !ser.Eof() {
 		panic(fmt.Errorf("Internal error - left unparsed data"))
 	}
@@ -569,7 +602,11 @@ func (dec *Decoder) Decode(cjson []byte, dest interface{}) (err error) {
 	fieldsoutcnt := make([]int, 64, 64)
 	ctagsPath := make([]int, 0, 8)
 
-	dec.decodeValue(nil, ser, reflect.ValueOf(dest), fieldsoutcnt, ctagsPath)
+	if id, ok := dest.(ItemDecoder); ok {
+		dec.decodeItem(nil, ser, id, fieldsoutcnt)
+	} else {
+		dec.decodeValue(nil, ser, reflect.ValueOf(dest), fieldsoutcnt, ctagsPath)
+	}
 	// if !ser.Eof() {
 	// 	panic(fmt.Errorf("Internal error - left unparsed data"))
 	// }
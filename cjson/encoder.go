@@ -26,6 +26,8 @@ type fieldInfo struct {
 	isOmitEmpty bool
 	isTime      bool
 	isPtr       bool
+	hook        int
+	elemHook    int
 }
 
 func mkFieldInfo(v reflect.Value, ctagName int, anon bool) fieldInfo {
@@ -44,14 +46,23 @@ func mkFieldInfo(v reflect.Value, ctagName int, anon bool) fieldInfo {
 		kind:       kk,
 		ctagName:   ctagName,
 		isTime:     kk == reflect.Struct && t.String() == "time.Time",
+		hook:       marshalHook(t),
 	}
 	if kk == reflect.Slice || kk == reflect.Array {
 		f.elemKind = t.Elem().Kind()
+		f.elemHook = marshalHook(t.Elem())
 	}
 
 	return f
 }
 
+// mkRootFieldInfo returns info of encoded item. Item itself is always encoded field by field, even if it has custom marshaler
+func mkRootFieldInfo(v reflect.Value) fieldInfo {
+	f := mkFieldInfo(v, 0, false)
+	f.hook = hookNone
+	return f
+}
+
 // non allocating version of strings.Split
 func splitStr(in string, sep byte) (s1, s2, s3 string) {
 
@@ -177,14 +188,19 @@ func (enc *Encoder) encodeSlice(v reflect.Value, rdser *Serializer, f fieldInfo,
 	if l == 0 && f.isOmitEmpty {
 		return
 	}
-	if f.elemKind == reflect.Uint8 {
+	elemKind := f.elemKind
+	if f.elemHook != hookNone {
+		// elements with custom marshaler are encoded one by one
+		elemKind = reflect.Invalid
+	}
+	if elemKind == reflect.Uint8 {
 		rdser.PutVarUInt(mkctag(TAG_STRING, f.ctagName, 0))
 		rdser.PutVString(base64.StdEncoding.EncodeToString(v.Bytes()))
 	} else {
 		rdser.PutVarUInt(mkctag(TAG_ARRAY, f.ctagName, 0))
 
 		subTag := TAG_OBJECT
-		switch f.elemKind {
+		switch elemKind {
 		case reflect.Int, reflect.Int16, reflect.Int64, reflect.Int8, reflect.Int32,
 			reflect.Uint, reflect.Uint16, reflect.Uint64, reflect.Uint32:
 			subTag = TAG_VARINT
@@ -205,7 +221,7 @@ func (enc *Encoder) encodeSlice(v reflect.Value, rdser *Serializer, f fieldInfo,
 			ptr = unsafe.Pointer(v.Index(0).Addr().Pointer())
 		}
 
-		switch f.elemKind {
+		switch elemKind {
 		case reflect.Int:
 			sl := (*[1 << 28]int)(ptr)[:l:l]
 			for _, v := range sl {
@@ -303,6 +319,10 @@ func (enc *Encoder) encodeValue(v reflect.Value, rdser *Serializer, f fieldInfo,
 	if f.isPtr {
 		v = v.Elem()
 	}
+	if f.hook != hookNone {
+		enc.encodeMarshaler(v, rdser, f)
+		return
+	}
 	switch f.kind {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		val := v.Int()
@@ -366,6 +386,37 @@ func (enc *Encoder) encodeValue(v reflect.Value, rdser *Serializer, f fieldInfo,
 	}
 }
 
+// encodeMarshaler encodes value, returned by custom marshaler of v
+func (enc *Encoder) encodeMarshaler(v reflect.Value, rdser *Serializer, f fieldInfo) {
+	val, err := marshalValue(v, f.hook)
+	if err != nil {
+		panic(fmt.Errorf("Can't marshal %s: %s", v.Type().String(), err.Error()))
+	}
+	vv := reflect.ValueOf(val)
+	if !vv.IsValid() {
+		if !f.isOmitEmpty {
+			rdser.PutVarUInt(mkctag(TAG_NULL, f.ctagName, 0))
+		}
+		return
+	}
+	ff := mkFieldInfo(vv, f.ctagName, false)
+	ff.isOmitEmpty = f.isOmitEmpty
+	enc.encodeValue(vv, rdser, ff, nil)
+}
+
+// encodeItem encodes item with its generated codec, or by reflection
+func (enc *Encoder) encodeItem(src interface{}, v reflect.Value, wrser *Serializer) {
+	if ie, ok := src.(ItemEncoder); ok {
+		wrser.enc = enc
+		defer func() { wrser.enc = nil }()
+		wrser.PutFieldObject("")
+		ie.EncodeCJSON(wrser)
+		wrser.PutFieldEnd()
+		return
+	}
+	enc.encodeValue(v, wrser, mkRootFieldInfo(v), make([]int, 0, 10))
+}
+
 func (enc *Encoder) Encode(src interface{}, wrser *Serializer) (stateToken int, err error) {
 
 	v := reflect.ValueOf(src)
@@ -376,7 +427,7 @@ func (enc *Encoder) Encode(src interface{}, wrser *Serializer) (stateToken int,
 	wrser.PutUInt32(0)
 	enc.tagsMatcher = &enc.state.tagsMatcher
 	enc.tmUpdated = false
-	enc.encodeValue(v, wrser, mkFieldInfo(v, 0, false), make([]int, 0, 10))
+	enc.encodeItem(src, v, wrser)
 
 	if enc.tmUpdated {
 		*(*uint32)(unsafe.Pointer(&wrser.Bytes()[pos+1])) = uint32(len(wrser.buf) - pos)
@@ -397,7 +448,7 @@ func (enc *Encoder) EncodeRaw(src interface{}, wrser *Serializer) error {
 	enc.tmUpdated = false
 
 	enc.tagsMatcher = &enc.state.tagsMatcher
-	enc.encodeValue(v, wrser, mkFieldInfo(v, 0, false), make([]int, 0, 10))
+	enc.encodeItem(src, v, wrser)
 	if enc.tmUpdated {
 		enc.state.tagsMatcher = *enc.tagsMatcher
 	}
@@ -0,0 +1,189 @@
+package cjson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Marshaler is the interface implemented by types, which can marshal themselves to value, stored in CJSON.
+// Value must be nil, bool, number, string, or slice or map of them
+type Marshaler interface {
+	MarshalCJSON() (interface{}, error)
+}
+
+// Unmarshaler is the interface implemented by types, which can unmarshal value, decoded from CJSON.
+// Value is nil, bool, int, int64, float64, string, []interface{} or map[string]interface{}
+type Unmarshaler interface {
+	UnmarshalCJSON(v interface{}) error
+}
+
+// Kinds of custom marshaling hooks. If type implements several interfaces, the first one in this order is used
+const (
+	hookNone = iota
+	hookCJSON
+	hookJSON
+	hookText
+)
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	marshalHooks   sync.Map
+	unmarshalHooks sync.Map
+)
+
+// marshalHook returns kind of marshaling hook of type t, with methods of *t.
+// time.Time is encoded by encoder itself, so it has no hook
+func marshalHook(t reflect.Type) int {
+	if h, ok := marshalHooks.Load(t); ok {
+		return h.(int)
+	}
+	h := hookNone
+	if pt := reflect.PtrTo(t); t.Kind() != reflect.Interface && t.String() != "time.Time" {
+		switch {
+		case pt.Implements(marshalerType):
+			h = hookCJSON
+		case pt.Implements(jsonMarshalerType):
+			h = hookJSON
+		case pt.Implements(textMarshalerType):
+			h = hookText
+		}
+	}
+	marshalHooks.Store(t, h)
+	return h
+}
+
+// unmarshalHook returns kind of unmarshaling hook of type t, with methods of *t
+func unmarshalHook(t reflect.Type) int {
+	if h, ok := unmarshalHooks.Load(t); ok {
+		return h.(int)
+	}
+	h := hookNone
+	if pt := reflect.PtrTo(t); t.Kind() != reflect.Interface && t.String() != "time.Time" {
+		switch {
+		case pt.Implements(unmarshalerType):
+			h = hookCJSON
+		case pt.Implements(jsonUnmarshalerType):
+			h = hookJSON
+		case pt.Implements(textUnmarshalerType):
+			h = hookText
+		}
+	}
+	unmarshalHooks.Store(t, h)
+	return h
+}
+
+// HasMarshaler returns true, if values of type t are encoded by custom Marshaler, json.Marshaler or encoding.TextMarshaler
+func HasMarshaler(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return marshalHook(t) != hookNone
+}
+
+// MarshaledKind returns kind of value, to which zero value of type t is marshaled by its custom marshaler
+func MarshaledKind(t reflect.Type) (reflect.Kind, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	h := marshalHook(t)
+	if h == hookNone {
+		return t.Kind(), nil
+	}
+	val, err := marshalValue(reflect.New(t).Elem(), h)
+	if err != nil {
+		return reflect.Invalid, err
+	}
+	k := reflect.ValueOf(val).Kind()
+	if h == hookJSON && k == reflect.Int64 {
+		// JSON has no integer numbers, so integer zero value does not mean, that all values are integer
+		k = reflect.Float64
+	}
+	return k, nil
+}
+
+// marshalValue marshals v by its hook to value, which can be encoded to CJSON
+func marshalValue(v reflect.Value, hook int) (val interface{}, err error) {
+	var pv interface{}
+	if v.CanAddr() {
+		pv = v.Addr().Interface()
+	} else {
+		// Value is copied, so methods with pointer receiver can be called
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		pv = p.Interface()
+	}
+
+	switch hook {
+	case hookCJSON:
+		return pv.(Marshaler).MarshalCJSON()
+	case hookJSON:
+		data, err := pv.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err = dec.Decode(&val); err != nil {
+			return nil, err
+		}
+		return fromJSONValue(val), nil
+	case hookText:
+		data, err := pv.(encoding.TextMarshaler).MarshalText()
+		return string(data), err
+	}
+	return nil, fmt.Errorf("Unknown marshaling hook %d", hook)
+}
+
+// unmarshalValue sets decoded value val to v by its hook. v must be addressable
+func unmarshalValue(v reflect.Value, hook int, val interface{}) error {
+	pv := v.Addr().Interface()
+
+	switch hook {
+	case hookCJSON:
+		return pv.(Unmarshaler).UnmarshalCJSON(val)
+	case hookJSON:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		return pv.(json.Unmarshaler).UnmarshalJSON(data)
+	case hookText:
+		str, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("Can't unmarshal %T to %s: string is expected", val, v.Type().String())
+		}
+		return pv.(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+	return fmt.Errorf("Unknown unmarshaling hook %d", hook)
+}
+
+// fromJSONValue replaces json.Number in value, decoded from JSON, by int64 or float64
+func fromJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = fromJSONValue(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = fromJSONValue(v[k])
+		}
+	}
+	return val
+}
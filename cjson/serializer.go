@@ -15,6 +15,8 @@ type Serializer struct {
 	buf  []byte
 	pos  int
 	pool bool
+	// enc is encoder of item, which is written by generated EncodeCJSON
+	enc *Encoder
 }
 
 func NewPoolSerializer() *Serializer {
@@ -41,7 +41,7 @@ func (enc *Validator) validateLevel(src reflect.Type, fieldName string) error {
 			t = t.Elem()
 		}
 
-		if t.Kind() == reflect.Struct {
+		if t.Kind() == reflect.Struct && !HasMarshaler(t) {
 			if err := enc.validateLevel(t, fname); err != nil {
 				return err
 			}
@@ -0,0 +1,97 @@
+// rxdump dumps namespaces of reindexer database to file, and restores them from dump.
+//
+// Dump all namespaces of database:
+//
+//	rxdump -dsn cproto://127.0.0.1:6534/mydb -file mydb.rxdump
+//
+// Dump selected namespaces to stdout:
+//
+//	rxdump -dsn cproto://127.0.0.1:6534/mydb -ns items,users
+//
+// Restore namespaces from dump:
+//
+//	rxdump -dsn cproto://127.0.0.1:6534/otherdb -restore -file mydb.rxdump
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/cproto"
+	_ "github.com/restream/reindexer/bindings/http"
+)
+
+func main() {
+	dsn := ""
+	nsList := ""
+	fileName := ""
+	restore := false
+
+	flag.StringVar(&dsn, "dsn", "cproto://127.0.0.1:6534/db", "DSN of database")
+	flag.StringVar(&nsList, "ns", "", "Comma separated list of namespaces to dump. All namespaces are dumped, if empty")
+	flag.StringVar(&fileName, "file", "", "Dump file. Stdout or stdin is used, if empty")
+	flag.BoolVar(&restore, "restore", false, "Restore namespaces from dump file")
+	flag.Parse()
+
+	db := reindexer.NewReindex(dsn)
+	defer db.Close()
+	if err := db.Status().Err; err != nil {
+		fail(err)
+	}
+
+	if restore {
+		var r io.Reader = os.Stdin
+		if fileName != "" {
+			f, err := os.Open(fileName)
+			if err != nil {
+				fail(err)
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := db.RestoreNamespace(r); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	var namespaces []string
+	if nsList != "" {
+		namespaces = strings.Split(nsList, ",")
+	} else {
+		defs, err := db.ListNamespaces()
+		if err != nil {
+			fail(err)
+		}
+		for _, def := range defs {
+			// System namespaces are not dumped
+			if !strings.HasPrefix(def.Namespace, "#") {
+				namespaces = append(namespaces, def.Namespace)
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if fileName != "" {
+		f, err := os.Create(fileName)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	for _, ns := range namespaces {
+		if err := db.DumpNamespace(strings.TrimSpace(ns), w); err != nil {
+			fail(fmt.Errorf("Can't dump namespace '%s': %v", ns, err))
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,696 @@
+// rxgen generates reflection-free CJSON codecs for structs, which are stored in reindexer.
+//
+// For each struct type it generates EncodeCJSON and DecodeCJSON methods, which are used by cjson encoder and decoder
+// instead of reflection, and DeepCopy method, which enables object cache (if type has no DeepCopy method yet).
+// Codecs are also generated for struct types of the same package, which are used in fields of generated types.
+// Fields of interface, map, time.Time, custom marshaler and types of other packages are encoded by reflection.
+// DeepCopy copies such fields by reflection (see cjson.DeepCopyValue).
+//
+// Usage with go generate:
+//
+//	//go:generate rxgen -type Item,User
+//
+// If -type is not set, codecs are generated for all structs with reindex or json tags.
+// Codecs must be regenerated after change of struct.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const cjsonImport = "github.com/restream/reindexer/cjson"
+
+// Kinds of values, supported by generated code
+const (
+	kindFallback = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindString
+	kindBytes
+	kindStruct
+)
+
+// typeInfo is type of field
+type typeInfo struct {
+	kind int
+	// expr is go expression of type
+	expr string
+	// ptr is set for pointer to scalar or struct
+	ptr bool
+	// byte is set for byte kind, slices of which are stored as base64 string
+	byte bool
+	// elem is type of elements of slice or array
+	elem  *typeInfo
+	array bool
+	// ast is source type of field
+	ast ast.Expr
+}
+
+type field struct {
+	name      string
+	path      string
+	typ       typeInfo
+	omitEmpty bool
+	// embeds are paths of embedded pointers, which lead to field
+	embeds []embed
+}
+
+type embed struct {
+	path string
+	typ  string
+	// name is field name of embedded pointer, which is written as null, when pointer is nil
+	name      string
+	omitEmpty bool
+}
+
+type typeDecl struct {
+	spec *ast.TypeSpec
+	file *ast.File
+}
+
+type generator struct {
+	pkg       string
+	decls     map[string]typeDecl
+	methods   map[string]map[string]bool
+	imports   map[string]string
+	used      map[string]bool
+	generated map[string]bool
+	queue     []string
+	out       bytes.Buffer
+}
+
+func main() {
+	typeList := ""
+	dir := "."
+	output := "cjson_gen.go"
+
+	flag.StringVar(&typeList, "type", "", "Comma separated list of struct types. All structs with reindex or json tags are used, if empty")
+	flag.StringVar(&dir, "dir", ".", "Directory of package")
+	flag.StringVar(&output, "output", "cjson_gen.go", "Output file name in package directory")
+	flag.Parse()
+
+	g := &generator{
+		decls:     make(map[string]typeDecl),
+		methods:   make(map[string]map[string]bool),
+		imports:   make(map[string]string),
+		used:      make(map[string]bool),
+		generated: make(map[string]bool),
+	}
+	if err := g.parse(dir, output); err != nil {
+		fail(err)
+	}
+
+	var names []string
+	if typeList != "" {
+		for _, name := range strings.Split(typeList, ",") {
+			name = strings.TrimSpace(name)
+			if !g.isCodecStruct(name) {
+				fail(fmt.Errorf("rxgen: type '%s' is not struct of package", name))
+			}
+			names = append(names, name)
+		}
+	} else {
+		for name, decl := range g.decls {
+			if st, ok := decl.spec.Type.(*ast.StructType); ok && hasTags(st) && g.isCodecStruct(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fail(fmt.Errorf("rxgen: no struct types are found"))
+	}
+
+	code, err := g.generate(names)
+	if err != nil {
+		fail(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, output), code, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// parse reads declarations of types and methods of package in dir. Previously generated output file is skipped
+func (g *generator) parse(dir, output string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool { return fi.Name() != output }, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	var pkg *ast.Package
+	for name, p := range pkgs {
+		// Types of external test package can't be used by package itself
+		if !strings.HasSuffix(name, "_test") {
+			g.pkg, pkg = name, p
+		}
+	}
+	if pkg == nil {
+		return fmt.Errorf("rxgen: no go package in %s", dir)
+	}
+
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						g.decls[ts.Name.Name] = typeDecl{spec: ts, file: file}
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil || len(decl.Recv.List) == 0 {
+					continue
+				}
+				recv := decl.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if ident, ok := recv.(*ast.Ident); ok {
+					if g.methods[ident.Name] == nil {
+						g.methods[ident.Name] = make(map[string]bool)
+					}
+					g.methods[ident.Name][decl.Name.Name] = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasTags(st *ast.StructType) bool {
+	for _, f := range st.Fields.List {
+		if f.Tag != nil && (strings.Contains(f.Tag.Value, "reindex:") || strings.Contains(f.Tag.Value, "json:")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMarshaler returns true, if local type has custom marshaler, so it's encoded by reflection
+func (g *generator) hasMarshaler(name string) bool {
+	m := g.methods[name]
+	return m["MarshalCJSON"] || m["MarshalJSON"] || m["MarshalText"]
+}
+
+// isCodecStruct returns true, if codec can be generated for type
+func (g *generator) isCodecStruct(name string) bool {
+	decl, ok := g.decls[name]
+	if !ok || g.hasMarshaler(name) {
+		return false
+	}
+	_, ok = decl.spec.Type.(*ast.StructType)
+	return ok
+}
+
+// classify returns type info of field type
+func (g *generator) classify(expr ast.Expr) typeInfo {
+	ti := typeInfo{kind: kindFallback, expr: types.ExprString(expr), ast: expr}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "int", "int8", "int16", "int32", "int64", "rune":
+			ti.kind = kindInt
+		case "uint", "uint16", "uint32", "uint64":
+			ti.kind = kindUint
+		case "uint8", "byte":
+			ti.kind, ti.byte = kindUint, true
+		case "float32", "float64":
+			ti.kind = kindFloat
+		case "bool":
+			ti.kind = kindBool
+		case "string":
+			ti.kind = kindString
+		default:
+			decl, ok := g.decls[e.Name]
+			if !ok || g.hasMarshaler(e.Name) {
+				return ti
+			}
+			if _, ok := decl.spec.Type.(*ast.StructType); ok {
+				ti.kind = kindStruct
+				return ti
+			}
+			// Named type is handled as its underlying type
+			under := g.classify(decl.spec.Type)
+			under.expr, under.ast = ti.expr, expr
+			if under.ptr {
+				return ti
+			}
+			return under
+		}
+	case *ast.StarExpr:
+		elem := g.classify(e.X)
+		if elem.ptr || elem.elem != nil || elem.kind == kindFallback || elem.kind == kindBytes {
+			return ti
+		}
+		elem.ptr, elem.expr, elem.ast = true, ti.expr, expr
+		return elem
+	case *ast.ArrayType:
+		elem := g.classify(e.Elt)
+		if elem.elem != nil || elem.kind == kindFallback || elem.kind == kindBytes || (elem.ptr && elem.kind != kindStruct) {
+			return ti
+		}
+		if elem.byte {
+			// Byte slices are stored as base64 string. Slices of named byte types and byte arrays are encoded by reflection
+			if e.Len == nil && !elem.ptr && (elem.expr == "byte" || elem.expr == "uint8") {
+				ti.kind = kindBytes
+			}
+			return ti
+		}
+		ti.elem, ti.array = &elem, e.Len != nil
+		ti.kind = elem.kind
+	}
+	return ti
+}
+
+// fields returns fields of struct, including fields of embedded structs
+func (g *generator) fields(st *ast.StructType, prefix string, embeds []embed, seen map[string]bool) ([]field, error) {
+	var fields []field
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		jsonName, opts := reflect.StructTag(tag).Get("json"), ""
+		if sep := strings.IndexByte(jsonName, ','); sep >= 0 {
+			jsonName, opts = jsonName[:sep], jsonName[sep+1:]
+		}
+		if jsonName == "-" {
+			continue
+		}
+
+		names := make([]string, 0, len(f.Names))
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			// Embedded struct: its fields are encoded as fields of parent
+			typ, ptr := f.Type, false
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ, ptr = star.X, true
+			}
+			ident, ok := typ.(*ast.Ident)
+			if !ok || !ast.IsExported(ident.Name) {
+				if sel, ok := typ.(*ast.SelectorExpr); ok && ast.IsExported(sel.Sel.Name) {
+					return nil, fmt.Errorf("rxgen: embedded type %s of other package is not supported", types.ExprString(typ))
+				}
+				continue
+			}
+			if !g.isCodecStruct(ident.Name) {
+				return nil, fmt.Errorf("rxgen: embedded type %s is not supported", ident.Name)
+			}
+			path := prefix + ident.Name
+			eembeds := embeds
+			if ptr {
+				ename := jsonName
+				if ename == "" {
+					ename = ident.Name
+				}
+				eembeds = append(append([]embed(nil), embeds...), embed{path: path, typ: ident.Name, name: ename, omitEmpty: opts == "omitempty"})
+			}
+			efields, err := g.fields(g.decls[ident.Name].spec.Type.(*ast.StructType), path+".", eembeds, seen)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, efields...)
+			continue
+		}
+
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			fname := jsonName
+			if fname == "" {
+				fname = name
+			}
+			// The first field with name is used, as by reflection decoder
+			if seen[fname] {
+				continue
+			}
+			seen[fname] = true
+			fields = append(fields, field{
+				name:      fname,
+				path:      prefix + name,
+				typ:       g.classify(f.Type),
+				omitEmpty: opts == "omitempty",
+				embeds:    embeds,
+			})
+		}
+	}
+	return fields, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+// use marks packages of type expression as used by generated code
+func (g *generator) use(ti typeInfo, file *ast.File) {
+	ast.Inspect(ti.ast, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				for _, imp := range file.Imports {
+					path, _ := strconv.Unquote(imp.Path.Value)
+					name := filepath.Base(path)
+					if imp.Name != nil {
+						name = imp.Name.Name
+					}
+					if name == ident.Name {
+						g.imports[name] = path
+						g.used[name] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// enqueue adds struct type of field to generated types
+func (g *generator) enqueue(ti typeInfo) {
+	if ti.kind != kindStruct {
+		return
+	}
+	name := ti.expr
+	if ti.elem != nil {
+		name = ti.elem.expr
+	}
+	name = strings.TrimPrefix(name, "*")
+	if !g.generated[name] {
+		g.generated[name] = true
+		g.queue = append(g.queue, name)
+	}
+}
+
+func (g *generator) generate(names []string) ([]byte, error) {
+	for _, name := range names {
+		if !g.generated[name] {
+			g.generated[name] = true
+			g.queue = append(g.queue, name)
+		}
+	}
+
+	var body bytes.Buffer
+	for len(g.queue) != 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		decl := g.decls[name]
+		fields, err := g.fields(decl.spec.Type.(*ast.StructType), "", nil, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			g.enqueue(f.typ)
+		}
+		g.out.Reset()
+		g.genEncode(name, fields)
+		g.genDecode(name, fields)
+		if !g.methods[name]["DeepCopy"] {
+			g.genDeepCopy(name, fields, decl.file)
+		}
+		body.Write(g.out.Bytes())
+	}
+
+	g.out.Reset()
+	g.printf("// Code generated by rxgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg)
+	var imports []string
+	for name := range g.used {
+		imports = append(imports, name)
+	}
+	sort.Strings(imports)
+	for _, name := range imports {
+		if path := g.imports[name]; filepath.Base(path) == name {
+			g.printf("\t%q\n", path)
+		} else {
+			g.printf("\t%s %q\n", name, path)
+		}
+	}
+	g.printf("\n\t%q\n)\n", cjsonImport)
+	g.out.Write(body.Bytes())
+
+	code, err := format.Source(g.out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("rxgen: invalid generated code: %v\n%s", err, g.out.String())
+	}
+	return code, nil
+}
+
+// scalarTag returns ctag type constant of scalar kind
+func scalarTag(kind int) string {
+	switch kind {
+	case kindFloat:
+		return "cjson.TAG_DOUBLE"
+	case kindBool:
+		return "cjson.TAG_BOOL"
+	case kindString:
+		return "cjson.TAG_STRING"
+	}
+	return "cjson.TAG_VARINT"
+}
+
+// baseType returns go type, which is used by serializer and reader for scalar kind
+func baseType(kind int) string {
+	switch kind {
+	case kindFloat:
+		return "float64"
+	case kindBool:
+		return "bool"
+	case kindString:
+		return "string"
+	}
+	return "int64"
+}
+
+// convert returns expression, which converts v of type expr to type to
+func convert(to, expr, v string) string {
+	if to == expr {
+		return v
+	}
+	return fmt.Sprintf("%s(%s)", to, v)
+}
+
+// putScalar returns statement, which writes field with name and scalar value v of type expr
+func putScalar(kind int, expr, name, v string) string {
+	put := map[int]string{kindFloat: "PutFieldDouble", kindBool: "PutFieldBool", kindString: "PutFieldString"}[kind]
+	if put == "" {
+		put = "PutFieldInt"
+	}
+	return fmt.Sprintf("ser.%s(%s, %s)\n", put, name, convert(baseType(kind), expr, v))
+}
+
+// putElem returns statement, which writes scalar element v of array of type expr
+func putElem(kind int, expr, v string) string {
+	put := map[int]string{kindFloat: "PutDouble", kindBool: "PutBool", kindString: "PutVString"}[kind]
+	if put == "" {
+		put = "PutVarInt"
+	}
+	return fmt.Sprintf("ser.%s(%s)\n", put, convert(baseType(kind), expr, v))
+}
+
+// nonZero returns condition, which is true, if scalar v is not zero
+func nonZero(kind int, v string) string {
+	switch kind {
+	case kindBool:
+		return v
+	case kindString:
+		return fmt.Sprintf("len(%s) != 0", v)
+	}
+	return fmt.Sprintf("%s != 0", v)
+}
+
+// getScalar returns expression, which reads current scalar value of reader as type expr
+func getScalar(kind int, expr string) string {
+	get := map[int]string{kindFloat: "r.Float()", kindBool: "r.Bool()", kindString: "r.String()"}[kind]
+	if get == "" {
+		get = "r.Int()"
+	}
+	return convert(expr, baseType(kind), get)
+}
+
+// wrap returns code, which is executed only if embedded pointers, which lead to field, are not nil
+func wrap(embeds []embed, code string) string {
+	if code == "" {
+		return ""
+	}
+	for i := len(embeds) - 1; i >= 0; i-- {
+		code = fmt.Sprintf("if v.%s != nil {\n%s}\n", embeds[i].path, code)
+	}
+	return code
+}
+
+func (g *generator) genEncode(name string, fields []field) {
+	g.printf("\n// EncodeCJSON writes fields of %s to ser\nfunc (v *%s) EncodeCJSON(ser *cjson.Serializer) {\n", name, name)
+	nulls := map[string]bool{}
+	for _, f := range fields {
+		// nil embedded pointer is written as null field before its first field, like reflection does
+		for i, e := range f.embeds {
+			if !nulls[e.path] && !e.omitEmpty {
+				g.printf("%s", wrap(f.embeds[:i], fmt.Sprintf("if v.%s == nil {\nser.PutFieldNull(%q)\n}\n", e.path, e.name)))
+			}
+			nulls[e.path] = true
+		}
+		v, t := "v."+f.path, f.typ
+		qname := strconv.Quote(f.name)
+		code := ""
+
+		switch {
+		case t.kind == kindFallback:
+			code = fmt.Sprintf("ser.PutFieldValue(%s, %s, %v)\n", qname, v, f.omitEmpty)
+		case t.kind == kindBytes:
+			code = fmt.Sprintf("ser.PutFieldBytes(%s, %s)\n", qname, v)
+		case t.elem != nil && t.kind == kindStruct:
+			code = fmt.Sprintf("ser.PutFieldArray(%s, len(%s), cjson.TAG_OBJECT)\nfor _, e := range %s {\n", qname, v, v)
+			if t.elem.ptr {
+				code += "if e == nil {\nser.PutFieldNull(\"\")\ncontinue\n}\n"
+			}
+			code += "ser.PutFieldObject(\"\")\ne.EncodeCJSON(ser)\nser.PutFieldEnd()\n}\n"
+			if !t.elem.ptr {
+				code = strings.Replace(code, "for _, e := range "+v, "for i := range "+v, 1)
+				code = strings.Replace(code, "e.EncodeCJSON", v+"[i].EncodeCJSON", 1)
+			}
+		case t.elem != nil:
+			code = fmt.Sprintf("ser.PutFieldArray(%s, len(%s), %s)\nfor _, e := range %s {\n%s}\n",
+				qname, v, scalarTag(t.kind), v, putElem(t.kind, t.elem.expr, "e"))
+		case t.kind == kindStruct:
+			code = fmt.Sprintf("ser.PutFieldObject(%s)\n%s.EncodeCJSON(ser)\nser.PutFieldEnd()\n", qname, v)
+		case t.ptr:
+			code = putScalar(t.kind, strings.TrimPrefix(t.expr, "*"), qname, "*"+v)
+		default:
+			code = putScalar(t.kind, t.expr, qname, v)
+		}
+
+		// Nil values are written as null, and empty values are omitted with omitempty, as by reflection encoder
+		nullable := t.kind != kindFallback && (t.ptr || (t.elem != nil && !t.array) || t.kind == kindBytes)
+		switch {
+		case t.kind == kindFallback:
+		case nullable && f.omitEmpty && t.ptr && t.kind != kindStruct:
+			code = fmt.Sprintf("if %s != nil && %s {\n%s}\n", v, nonZero(t.kind, "*"+v), code)
+		case nullable && f.omitEmpty && t.ptr:
+			code = fmt.Sprintf("if %s != nil {\n%s}\n", v, code)
+		case nullable && f.omitEmpty:
+			code = fmt.Sprintf("if len(%s) != 0 {\n%s}\n", v, code)
+		case nullable:
+			code = fmt.Sprintf("if %s == nil {\nser.PutFieldNull(%s)\n} else {\n%s}\n", v, qname, code)
+		case f.omitEmpty && t.elem == nil && t.kind != kindStruct:
+			code = fmt.Sprintf("if %s {\n%s}\n", nonZero(t.kind, v), code)
+		}
+		g.printf("%s", wrap(f.embeds, code))
+	}
+	g.printf("}\n")
+}
+
+func (g *generator) genDecode(name string, fields []field) {
+	g.printf("\n// DecodeCJSON reads fields of %s from r\nfunc (v *%s) DecodeCJSON(r *cjson.Reader) {\n", name, name)
+	g.printf("for r.Next() {\nswitch r.Name() {\n")
+	for _, f := range fields {
+		v, t := "v."+f.path, f.typ
+		g.printf("case %q:\n", f.name)
+		for _, e := range f.embeds {
+			g.printf("if v.%s == nil {\nv.%s = new(%s)\n}\n", e.path, e.path, e.typ)
+		}
+
+		switch {
+		case t.kind == kindFallback:
+			g.printf("r.Value(&%s)\n", v)
+		case t.kind == kindBytes:
+			g.printf("%s = r.Bytes()\n", v)
+		case t.elem != nil:
+			g.printf("a := r.Array()\n")
+			if t.array {
+				g.printf("for i := 0; i < a.Len(); i++ {\n")
+			} else {
+				g.printf("%s = make(%s, a.Len())\nfor i := range %s {\n", v, t.expr, v)
+			}
+			switch {
+			case t.kind == kindStruct && t.elem.ptr:
+				g.printf("if a.Next() && r.Object() {\n%s[i] = new(%s)\n%s[i].DecodeCJSON(r)\n}\n", v, strings.TrimPrefix(t.elem.expr, "*"), v)
+			case t.kind == kindStruct:
+				g.printf("if a.Next() && r.Object() {\n%s[i].DecodeCJSON(r)\n}\n", v)
+			default:
+				g.printf("if a.Next() {\n%s[i] = %s\n}\n", v, getScalar(t.kind, t.elem.expr))
+			}
+			g.printf("}\n")
+		case t.kind == kindStruct:
+			g.printf("if r.Object() {\n")
+			if t.ptr {
+				g.printf("if %s == nil {\n%s = new(%s)\n}\n", v, v, strings.TrimPrefix(t.expr, "*"))
+			}
+			g.printf("%s.DecodeCJSON(r)\n}\n", v)
+		case t.ptr:
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", v, v, strings.TrimPrefix(t.expr, "*"))
+			g.printf("*%s = %s\n", v, getScalar(t.kind, strings.TrimPrefix(t.expr, "*")))
+		default:
+			g.printf("%s = %s\n", v, getScalar(t.kind, t.expr))
+		}
+	}
+	g.printf("default:\nr.Skip()\n}\n}\n}\n")
+}
+
+func (g *generator) genDeepCopy(name string, fields []field, file *ast.File) {
+	g.printf("\n// DeepCopy returns deep copy of %s\nfunc (v *%s) DeepCopy() interface{} {\nc := *v\n", name, name)
+
+	copied := make(map[string]bool)
+	for _, f := range fields {
+		for _, e := range f.embeds {
+			if !copied[e.path] {
+				copied[e.path] = true
+				g.printf("if v.%s != nil {\ne := *v.%s\nc.%s = &e\n}\n", e.path, e.path, e.path)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		v, c, t := "v."+f.path, "c."+f.path, f.typ
+		code := ""
+
+		switch {
+		case t.kind == kindFallback:
+			code = fmt.Sprintf("cjson.DeepCopyValue(&%s, &%s)\n", c, v)
+		case t.kind == kindBytes || (t.elem != nil && t.kind != kindStruct):
+			if !t.array {
+				code = fmt.Sprintf("if %s != nil {\n%s = append(%s[:0:0], %s...)\n}\n", v, c, v, v)
+			}
+		case t.elem != nil:
+			elemType := strings.TrimPrefix(t.elem.expr, "*")
+			loop := fmt.Sprintf("for i := range %s {\n%s[i] = *%s[i].DeepCopy().(*%s)\n}\n", v, c, v, elemType)
+			if t.elem.ptr {
+				loop = fmt.Sprintf("for i, e := range %s {\nif e != nil {\n%s[i] = e.DeepCopy().(*%s)\n}\n}\n", v, c, elemType)
+			}
+			if t.array {
+				code = loop
+			} else {
+				code = fmt.Sprintf("if %s != nil {\n%s = make(%s, len(%s))\n%s}\n", v, c, t.expr, v, loop)
+			}
+		case t.kind == kindStruct && t.ptr:
+			code = fmt.Sprintf("if %s != nil {\n%s = %s.DeepCopy().(*%s)\n}\n", v, c, v, strings.TrimPrefix(t.expr, "*"))
+		case t.kind == kindStruct:
+			code = fmt.Sprintf("%s = *%s.DeepCopy().(*%s)\n", c, v, t.expr)
+		case t.ptr:
+			code = fmt.Sprintf("if %s != nil {\ne := *%s\n%s = &e\n}\n", v, v, c)
+		}
+		g.printf("%s", wrap(f.embeds, code))
+	}
+	g.printf("return &c\n}\n")
+}
@@ -0,0 +1,67 @@
+// rxmigrate shows status of data migrations, which are executed by package github.com/restream/reindexer/migrate:
+// applied version and lock of namespaces.
+//
+//	rxmigrate -dsn cproto://127.0.0.1:6534/mydb
+//	rxmigrate -dsn cproto://127.0.0.1:6534/mydb -ns items,users
+//
+// Pending migrations are registered in application, so they are shown only by migrate.Registry.Status
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/cproto"
+	_ "github.com/restream/reindexer/bindings/http"
+	"github.com/restream/reindexer/migrate"
+)
+
+func main() {
+	dsn := ""
+	nsList := ""
+
+	flag.StringVar(&dsn, "dsn", "cproto://127.0.0.1:6534/db", "DSN of database")
+	flag.StringVar(&nsList, "ns", "", "Comma separated list of namespaces. All namespaces are shown, if empty")
+	flag.Parse()
+
+	db := reindexer.NewReindex(dsn)
+	defer db.Close()
+	if err := db.Status().Err; err != nil {
+		fail(err)
+	}
+
+	var namespaces []string
+	if nsList != "" {
+		namespaces = strings.Split(nsList, ",")
+	} else {
+		defs, err := db.ListNamespaces()
+		if err != nil {
+			fail(err)
+		}
+		for _, def := range defs {
+			if !strings.HasPrefix(def.Namespace, "#") {
+				namespaces = append(namespaces, def.Namespace)
+			}
+		}
+	}
+
+	statuses := make([]migrate.Status, 0, len(namespaces))
+	for _, ns := range namespaces {
+		st, err := migrate.ReadStatus(db, strings.TrimSpace(ns))
+		if err != nil {
+			fail(fmt.Errorf("Can't read status of namespace '%s': %v", ns, err))
+		}
+		statuses = append(statuses, *st)
+	}
+	if err := migrate.WriteStatus(os.Stdout, statuses); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -1,13 +1,35 @@
 #include "core/aggregator.h"
+#include <algorithm>
 #include <limits>
 #include "core/query/queryresults.h"
+#include "tools/stringstools.h"
 
 namespace reindexer {
 
-Aggregator::Aggregator(AggType aggType, const string &name) : aggType_(aggType), name_(name) {
+// Compares values of facets. Values of not indexed fields can have different types
+static int compareFacetValues(const Variant &lhs, const Variant &rhs) {
+	if (lhs.Type() != rhs.Type()) return lhs.Type() < rhs.Type() ? -1 : 1;
+	if (lhs.Type() == KeyValueNull) return 0;
+	return lhs.Compare(rhs);
+}
+
+bool Aggregator::FacetEqual::operator()(const VariantArray &lhs, const VariantArray &rhs) const {
+	if (lhs.size() != rhs.size()) return false;
+	for (size_t i = 0; i < lhs.size(); ++i) {
+		if (compareFacetValues(lhs[i], rhs[i]) != 0) return false;
+	}
+	return true;
+}
+
+Aggregator::Aggregator(AggType aggType, const h_vector<string, 1> &fields, const SortingEntries &sort, unsigned limit, unsigned offset)
+	: aggType_(aggType), names_(fields), limit_(limit), offset_(offset) {
+	for (auto &field : fields) {
+		if (&field != &*fields.begin()) name_ += '+';
+		name_ += field;
+	}
 	switch (aggType_) {
 		case AggFacet:
-			facets_.reset(new fast_hash_map<Variant, int>());
+			facets_.reset(new FacetMap());
 			break;
 		case AggMin:
 			result_ = std::numeric_limits<double>::max();
@@ -21,21 +43,43 @@ Aggregator::Aggregator(AggType aggType, const string &name) : aggType_(aggType),
 		default:
 			throw Error(errParams, "Unknown aggregation type %d", int(aggType_));
 	}
+	if (aggType_ != AggFacet && (fields.size() != 1 || !sort.empty() || limit != UINT_MAX || offset != 0)) {
+		throw Error(errParams, "Only facet aggregation can have several fields, sort, limit and offset");
+	}
+
+	for (const SortingEntry &se : sort) {
+		FacetSort facetSort{-1, se.desc};
+		for (size_t i = 0; i < fields.size(); ++i) {
+			if (iequals(fields[i], se.column)) {
+				facetSort.field = i;
+				break;
+			}
+		}
+		if (facetSort.field < 0 && !iequals(se.column, "count"_sv)) {
+			throw Error(errParams, "Facet can be sorted only by count or by one of its fields, but not by '%s'", se.column.c_str());
+		}
+		sort_.push_back(facetSort);
+	}
+	// Facets with equal sort values are sorted by values of fields
+	for (size_t i = 0; i < fields.size(); ++i) sort_.push_back({int(i), false});
 }
 
 void Aggregator::Bind(PayloadType type, int fieldIdx, const TagsPath &fieldPath) {
 	payloadType_ = type;
+	Field field;
 	if (fieldIdx >= 0) {
-		fieldType_ = &type->Field(fieldIdx);
+		field.type = &type->Field(fieldIdx);
 	} else {
-		fieldPath_ = fieldPath;
+		field.path = fieldPath;
 	}
+	fields_.push_back(std::move(field));
 }
 
 AggregationResult Aggregator::GetResult() const {
 	AggregationResult ret;
 	ret.field = name_;
 	ret.type = aggType_;
+	if (names_.size() > 1) ret.fields = names_;
 
 	switch (aggType_) {
 		case AggAvg:
@@ -46,36 +90,90 @@ AggregationResult Aggregator::GetResult() const {
 		case AggMax:
 			ret.value = result_;
 			break;
-		case AggFacet:
-			for (auto &it : *facets_) {
-				ret.facets.push_back(FacetResult(it.first.As<string>(), it.second));
+		case AggFacet: {
+			using FacetPtr = const FacetMap::value_type *;
+			vector<FacetPtr> facets;
+			facets.reserve(facets_->size());
+			for (auto &it : *facets_) facets.push_back(&it);
+
+			std::stable_sort(facets.begin(), facets.end(), [this](FacetPtr lhs, FacetPtr rhs) {
+				for (const FacetSort &s : sort_) {
+					int res = 0;
+					if (s.field < 0) {
+						res = (lhs->second == rhs->second) ? 0 : (lhs->second < rhs->second ? -1 : 1);
+					} else {
+						res = compareFacetValues(lhs->first[s.field], rhs->first[s.field]);
+					}
+					if (res != 0) return s.desc ? res > 0 : res < 0;
+				}
+				return false;
+			});
+
+			size_t begin = std::min(size_t(offset_), facets.size());
+			size_t end = (limit_ == UINT_MAX) ? facets.size() : std::min(begin + limit_, facets.size());
+			for (size_t i = begin; i < end; ++i) {
+				FacetResult facet;
+				facet.count = facets[i]->second;
+				for (const Variant &v : facets[i]->first) facet.values.push_back(v.As<string>());
+				if (facet.values.size() == 1) facet.value = facet.values[0];
+				ret.facets.push_back(std::move(facet));
 			}
 			break;
+		}
 		default:
 			abort();
 	}
 	return ret;
 }
 
-void Aggregator::Aggregate(const PayloadValue &data) {
-	if (!fieldType_) {
+void Aggregator::getValues(const Field &field, const PayloadValue &data, VariantArray &values) const {
+	if (!field.type) {
 		ConstPayload pl(payloadType_, data);
-		VariantArray va;
-		pl.GetByJsonPath(fieldPath_, va, KeyValueUndefined);
-		for (const Variant &v : va) aggregate(v);
+		pl.GetByJsonPath(field.path, values, KeyValueUndefined);
 		return;
 	}
 
-	if (!fieldType_->IsArray()) {
-		aggregate(PayloadFieldValue(*fieldType_, data.Ptr() + fieldType_->Offset()).Get());
+	if (!field.type->IsArray()) {
+		values.push_back(PayloadFieldValue(*field.type, data.Ptr() + field.type->Offset()).Get());
 		return;
 	}
 
-	PayloadFieldValue::Array *arr = reinterpret_cast<PayloadFieldValue::Array *>(data.Ptr() + fieldType_->Offset());
+	PayloadFieldValue::Array *arr = reinterpret_cast<PayloadFieldValue::Array *>(data.Ptr() + field.type->Offset());
 
 	uint8_t *ptr = data.Ptr() + arr->offset;
-	for (int i = 0; i < arr->len; i++, ptr += fieldType_->Sizeof()) {
-		aggregate(PayloadFieldValue(*fieldType_, ptr).Get());
+	for (int i = 0; i < arr->len; i++, ptr += field.type->Sizeof()) {
+		values.push_back(PayloadFieldValue(*field.type, ptr).Get());
+	}
+}
+
+void Aggregator::Aggregate(const PayloadValue &data) {
+	if (aggType_ != AggFacet) {
+		VariantArray va;
+		getValues(fields_[0], data, va);
+		for (const Variant &v : va) aggregate(v);
+		return;
+	}
+
+	// Count each combination of values of fields
+	h_vector<VariantArray, 1> values(fields_.size());
+	for (size_t i = 0; i < fields_.size(); ++i) {
+		getValues(fields_[i], data, values[i]);
+		if (values[i].empty()) return;
+	}
+	h_vector<size_t, 2> pos(fields_.size());
+	for (auto &p : pos) p = 0;
+	VariantArray key;
+	for (;;) {
+		key.clear();
+		for (size_t i = 0; i < fields_.size(); ++i) key.push_back(values[i][pos[i]]);
+		(*facets_)[key]++;
+
+		size_t i = fields_.size();
+		for (; i > 0; --i) {
+			if (++pos[i - 1] < values[i - 1].size()) break;
+			pos[i - 1] = 0;
+		}
+		if (i == 0) break;
 	}
 }
 
@@ -93,8 +191,6 @@ void Aggregator::aggregate(const Variant &v) {
 			result_ = std::max(v.As<double>(), result_);
 			break;
 		case AggFacet:
-			(*facets_)[v]++;
-			break;
 		case AggUnknown:
 			break;
 	};
@@ -2,6 +2,7 @@
 
 #include "core/keyvalue/variant.h"
 #include "core/payload/payloadiface.h"
+#include "core/query/querywhere.h"
 #include "core/type_consts.h"
 
 namespace reindexer {
@@ -10,7 +11,8 @@ struct AggregationResult;
 
 class Aggregator {
 public:
-	Aggregator(AggType aggType, const string &name);
+	Aggregator(AggType aggType, const h_vector<string, 1> &fields, const SortingEntries &sort = {}, unsigned limit = UINT_MAX,
+			   unsigned offset = 0);
 	Aggregator() = default;
 	Aggregator(Aggregator &&) = default;
 	Aggregator &operator=(Aggregator &&) = default;
@@ -18,23 +20,45 @@ public:
 	Aggregator &operator=(const Aggregator &) = delete;
 
 	void Aggregate(const PayloadValue &lhs);
+	// Binds next field of aggregation. Should be called for each of fields in the same order
 	void Bind(PayloadType type, int fieldIdx, const TagsPath &fieldPath);
 	AggregationResult GetResult() const;
 
 protected:
+	struct Field {
+		// Field type for indexed field
+		const PayloadFieldType *type = nullptr;
+		// Json path to field, for non indexed field
+		TagsPath path;
+	};
+	// Sort entry of facets: index of field, or -1 for sort by count
+	struct FacetSort {
+		int field;
+		bool desc;
+	};
+	struct FacetHash {
+		size_t operator()(const VariantArray &values) const { return values.Hash(); }
+	};
+	struct FacetEqual {
+		bool operator()(const VariantArray &lhs, const VariantArray &rhs) const;
+	};
+	using FacetMap = fast_hash_map<VariantArray, int, FacetHash, FacetEqual>;
+
 	void aggregate(const Variant &variant);
+	void getValues(const Field &field, const PayloadValue &data, VariantArray &values) const;
 
 	PayloadType payloadType_;
-	// Field type for indexed field
-	const PayloadFieldType *fieldType_ = nullptr;
-
-	// Json path to field, for non indexed field
-	TagsPath fieldPath_;
+	h_vector<Field, 1> fields_;
 	double result_ = 0;
 	int hitCount_ = 0;
 	AggType aggType_;
-	std::unique_ptr<fast_hash_map<Variant, int>> facets_;
+	// Count of items for each combination of values of fields
+	std::unique_ptr<FacetMap> facets_;
 	string name_;
+	h_vector<string, 1> names_;
+	h_vector<FacetSort, 1> sort_;
+	unsigned limit_ = UINT_MAX;
+	unsigned offset_ = 0;
 };
 
 }  // namespace reindexer
@@ -2,12 +2,18 @@
 
 #include <stdlib.h>
 #include <string.h>
+#include <atomic>
 #include <locale>
+#include <map>
+#include <memory>
 #include <mutex>
+#include <unordered_map>
 
+#include "core/itemimpl.h"
 #include "core/reindexer.h"
 #include "core/selectfunc/selectfuncparser.h"
 #include "debug/allocdebug.h"
+#include "replicator/updatesobserver.h"
 #include "resultserializer.h"
 #include "tools/logger.h"
 #include "tools/stringstools.h"
@@ -101,10 +107,82 @@ uintptr_t init_reindexer() {
 	return reinterpret_cast<uintptr_t>(db);
 }
 
+typedef void (*updates_writer_t)(uintptr_t rx, reindexer_string ns, int mode, reindexer_string data);
+
+// Passes modified items of reindexer db to updates writer
+class UpdatesObserverC : public IUpdatesObserver {
+public:
+	UpdatesObserverC(uintptr_t rx) : rx_(rx) {}
+
+	Error OnModifyItem(const string& nsName, ItemImpl* item, int modifyMode) override {
+		updates_writer_t writer = writer_.load();
+		if (!writer) return errOK;
+		string_view json = item->GetJSON();
+		writer(rx_, reindexer_string{const_cast<char*>(nsName.data()), int(nsName.size())}, modifyMode,
+			   reindexer_string{const_cast<char*>(json.data()), int(json.size())});
+		return errOK;
+	}
+	Error OnNewNamespace(const string&) override { return errOK; }
+	Error OnModifyIndex(const string&, const IndexDef&, int) override { return errOK; }
+	Error OnDropIndex(const string&, const string&) override { return errOK; }
+	Error OnDropNamespace(const string&) override { return errOK; }
+	Error OnPutMeta(const string&, const string&, const string&) override { return errOK; }
+
+	std::atomic<updates_writer_t> writer_{nullptr};
+	bool subscribed_ = false;
+
+protected:
+	uintptr_t rx_;
+};
+
+// Observers are deleted only with db, because db can call observer concurrently with unsubscribe
+static std::mutex updates_lck;
+static std::map<uintptr_t, std::unique_ptr<UpdatesObserverC>> updates_observers;
+
+// Transactions, started by binding. Items of transaction are added by reindexer_modify_item_packed with tx_id.
+// Transaction is removed on commit or rollback, or with db
+struct TransactionC {
+	uintptr_t rx;
+	Transaction tx;
+};
+
+static std::mutex tx_lck;
+static std::unordered_map<int, std::unique_ptr<TransactionC>> transactions;
+static int tx_counter;
+
+static std::unique_ptr<TransactionC> take_transaction(uintptr_t rx, int tx_id) {
+	std::lock_guard<std::mutex> lck(tx_lck);
+	auto it = transactions.find(tx_id);
+	if (it == transactions.end() || it->second->rx != rx) return nullptr;
+	auto tr = std::move(it->second);
+	transactions.erase(it);
+	return tr;
+}
+
+static Error add_to_transaction(uintptr_t rx, int tx_id, const string& ns, Item&& item, int mode) {
+	std::lock_guard<std::mutex> lck(tx_lck);
+	auto it = transactions.find(tx_id);
+	if (it == transactions.end() || it->second->rx != rx) return Error(errParams, "Transaction %d not found", tx_id);
+	Transaction& tx = it->second->tx;
+	if (!iequals(tx.GetName(), ns)) {
+		return Error(errParams, "Item of namespace '%s' can't be added to transaction of namespace '%s'", ns.c_str(), tx.GetName().c_str());
+	}
+	tx.Modify(std::move(item), mode);
+	return errOK;
+}
+
 void destroy_reindexer(uintptr_t rx) {
+	{
+		std::lock_guard<std::mutex> lck(tx_lck);
+		for (auto it = transactions.begin(); it != transactions.end();) {
+			it = it->second->rx == rx ? transactions.erase(it) : std::next(it);
+		}
+	}
 	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
 	delete db;
 	db = nullptr;
+	std::lock_guard<std::mutex> lck(updates_lck);
+	updates_observers.erase(rx);
 }
 
 reindexer_error reindexer_ping(uintptr_t rx) {
@@ -119,7 +197,6 @@ reindexer_ret reindexer_modify_item_packed(uintptr_t rx, reindexer_buffer args,
 	int mode = ser.GetVarUint();
 	int state_token = ser.GetVarUint();
 	int tx_id = ser.GetVarUint();
-	(void)tx_id;
 	unsigned preceptsCount = ser.GetVarUint();
 	vector<string> precepts;
 	while (preceptsCount--) {
@@ -133,42 +210,49 @@ reindexer_ret reindexer_modify_item_packed(uintptr_t rx, reindexer_buffer args,
 		Item item = db->NewItem(ns);
 
 		if (item.Status().ok()) {
+			// Item of transaction is applied later, so it must keep copy of data
+			item.Unsafe(!tx_id);
 			switch (format) {
 				case FormatJson:
-					err = item.Unsafe().FromJSON(string_view(reinterpret_cast<const char*>(data.data), data.len), 0, mode == ModeDelete);
+					err = item.FromJSON(string_view(reinterpret_cast<const char*>(data.data), data.len), 0, mode == ModeDelete);
 					break;
 				case FormatCJson:
 					if (item.GetStateToken() != state_token)
 						err = Error(errStateInvalidated, "stateToken mismatch:  %08X, need %08X. Can't process item", state_token,
 									item.GetStateToken());
 					else
-						err = item.Unsafe().FromCJSON(string_view(reinterpret_cast<const char*>(data.data), data.len), mode == ModeDelete);
+						err = item.FromCJSON(string_view(reinterpret_cast<const char*>(data.data), data.len), mode == ModeDelete);
 					break;
 				default:
 					err = Error(-1, "Invalid source item format %d", format);
 			}
 			if (err.ok()) {
 				item.SetPrecepts(precepts);
-				switch (mode) {
-					case ModeUpsert:
-						err = db->Upsert(ns, item);
-						break;
-					case ModeInsert:
-						err = db->Insert(ns, item);
-						break;
-					case ModeUpdate:
-						err = db->Update(ns, item);
-						break;
-					case ModeDelete:
-						err = db->Delete(ns, item);
-						break;
+				if (tx_id) {
+					err = add_to_transaction(rx, tx_id, ns, std::move(item), mode);
+				} else {
+					switch (mode) {
+						case ModeUpsert:
+							err = db->Upsert(ns, item);
+							break;
+						case ModeInsert:
+							err = db->Insert(ns, item);
+							break;
+						case ModeUpdate:
+							err = db->Update(ns, item);
+							break;
+						case ModeDelete:
+							err = db->Delete(ns, item);
+							break;
+					}
 				}
 				if (err.ok()) {
 					QueryResultsWrapper* res = new_results();
 					if (!res) return ret2c(err_too_many_queries, out);
-					res->AddItem(item);
+					// Item of transaction is not applied yet, so results are empty
+					if (!tx_id) res->AddItem(item);
 					int32_t ptVers = -1;
-					bool tmUpdated = item.IsTagsUpdated();
+					bool tmUpdated = !tx_id && item.IsTagsUpdated();
 					results2c(res, &out, 0, tmUpdated ? &ptVers : nullptr, tmUpdated ? 1 : 0);
 				}
 			}
@@ -179,6 +263,54 @@ reindexer_ret reindexer_modify_item_packed(uintptr_t rx, reindexer_buffer args,
 	return ret2c(err, out);
 }
 
+reindexer_tx_ret reindexer_start_transaction(uintptr_t rx, reindexer_string nsName) {
+	reindexer_tx_ret ret{0, error2c(Error(errOK))};
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (!db) {
+		ret.err = error2c(err_not_init);
+		return ret;
+	}
+	string ns = str2c(nsName);
+	Item item = db->NewItem(ns);
+	if (!item.Status().ok()) {
+		ret.err = error2c(item.Status());
+		return ret;
+	}
+
+	std::lock_guard<std::mutex> lck(tx_lck);
+	do {
+		ret.tx_id = ++tx_counter;
+		if (ret.tx_id <= 0) ret.tx_id = tx_counter = 1;
+	} while (transactions.count(ret.tx_id));
+	transactions.emplace(ret.tx_id, std::unique_ptr<TransactionC>(new TransactionC{rx, Transaction(ns)}));
+	return ret;
+}
+
+reindexer_ret reindexer_commit_transaction(uintptr_t rx, int tx_id) {
+	reindexer_resbuffer out{0, 0, 0};
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (!db) return ret2c(err_not_init, out);
+	auto tr = take_transaction(rx, tx_id);
+	if (!tr) return ret2c(Error(errParams, "Transaction %d not found", tx_id), out);
+
+	QueryResultsWrapper* res = new_results();
+	if (!res) return ret2c(err_too_many_queries, out);
+	Error err = db->CommitTransaction(tr->tx, *res);
+	if (err.ok()) {
+		// Items of transaction could update tags of namespace, so payload type is always returned
+		int32_t ptVers = -1;
+		results2c(res, &out, 0, &ptVers, 1);
+	} else {
+		put_results_to_pool(res);
+	}
+	return ret2c(err, out);
+}
+
+reindexer_error reindexer_rollback_transaction(uintptr_t rx, int tx_id) {
+	auto tr = take_transaction(rx, tx_id);
+	return error2c(tr ? Error(errOK) : Error(errParams, "Transaction %d not found", tx_id));
+}
+
 reindexer_error reindexer_open_namespace(uintptr_t rx, reindexer_string _namespace, StorageOpts opts, uint8_t cacheMode) {
 	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
 	return error2c(!db ? err_not_init : db->OpenNamespace(str2c(_namespace), opts, static_cast<CacheMode>(cacheMode)));
@@ -263,17 +395,21 @@ reindexer_ret reindexer_select_query(uintptr_t rx, struct reindexer_buffer in, i
 		Serializer ser(in.data, in.len);
 
 		Query q;
-		q.Deserialize(ser);
-		while (!ser.Eof()) {
-			Query q1;
-			q1.joinType = JoinType(ser.GetVarUint());
-			q1.Deserialize(ser);
-			q1.debugLevel = q.debugLevel;
-			if (q1.joinType == JoinType::Merge) {
-				q.mergeQueries_.emplace_back(std::move(q1));
-			} else {
-				q.joinQueries_.emplace_back(std::move(q1));
+		try {
+			q.Deserialize(ser);
+			while (!ser.Eof()) {
+				Query q1;
+				q1.joinType = JoinType(ser.GetVarUint());
+				q1.Deserialize(ser);
+				q1.debugLevel = q.debugLevel;
+				if (q1.joinType == JoinType::Merge) {
+					q.mergeQueries_.emplace_back(std::move(q1));
+				} else {
+					q.joinQueries_.emplace_back(std::move(q1));
+				}
 			}
+		} catch (const Error& err) {
+			return ret2c(err, out);
 		}
 
 		QueryResultsWrapper* result = new_results();
@@ -297,7 +433,11 @@ reindexer_ret reindexer_delete_query(uintptr_t rx, reindexer_buffer in) {
 		Serializer ser(in.data, in.len);
 
 		Query q;
-		q.Deserialize(ser);
+		try {
+			q.Deserialize(ser);
+		} catch (const Error& err) {
+			return ret2c(err, out);
+		}
 		QueryResultsWrapper* result = new_results();
 		if (!result) return ret2c(err_too_many_queries, out);
 		res = db->Delete(q, *result);
@@ -310,6 +450,32 @@ reindexer_ret reindexer_delete_query(uintptr_t rx, reindexer_buffer in) {
 	return ret2c(res, out);
 }
 
+reindexer_ret reindexer_update_query(uintptr_t rx, reindexer_buffer in) {
+	reindexer_resbuffer out{0, 0, 0};
+	Error res = err_not_init;
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (db) {
+		res = Error(errOK);
+		Serializer ser(in.data, in.len);
+
+		Query q;
+		try {
+			q.Deserialize(ser);
+		} catch (const Error& err) {
+			return ret2c(err, out);
+		}
+		QueryResultsWrapper* result = new_results();
+		if (!result) return ret2c(err_too_many_queries, out);
+		res = db->Update(q, *result);
+		if (q.debugLevel >= LogError && res.code() != errOK) logPrintf(LogError, "Query error %s", res.what().c_str());
+		if (res.ok())
+			results2c(result, &out);
+		else
+			put_results_to_pool(result);
+	}
+	return ret2c(res, out);
+}
+
 reindexer_error reindexer_put_meta(uintptr_t rx, reindexer_string ns, reindexer_string key, reindexer_string data) {
 	Error res = err_not_init;
 	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
@@ -329,10 +495,63 @@ reindexer_ret reindexer_get_meta(uintptr_t rx, reindexer_string ns, reindexer_st
 
 		string data;
 		res = db->GetMeta(str2c(ns), str2c(key), data);
-		results->ser.Write(data);
-		out.len = results->ser.Len();
-		out.data = uintptr_t(results->ser.Buf());
-		out.results_ptr = uintptr_t(results);
+		if (res.ok()) {
+			results->ser.Write(data);
+			out.len = results->ser.Len();
+			out.data = uintptr_t(results->ser.Buf());
+			out.results_ptr = uintptr_t(results);
+		} else {
+			put_results_to_pool(results);
+		}
+	}
+	return ret2c(res, out);
+}
+
+reindexer_ret reindexer_enum_meta(uintptr_t rx, reindexer_string ns) {
+	reindexer_resbuffer out{0, 0, 0};
+	Error res = err_not_init;
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (db) {
+		QueryResultsWrapper* results = new_results();
+		if (!results) return ret2c(err_too_many_queries, out);
+
+		vector<string> keys;
+		res = db->EnumMeta(str2c(ns), keys);
+		if (res.ok()) {
+			for (auto& key : keys) results->ser.PutVString(key);
+			out.len = results->ser.Len();
+			out.data = uintptr_t(results->ser.Buf());
+			out.results_ptr = uintptr_t(results);
+		} else {
+			put_results_to_pool(results);
+		}
+	}
+	return ret2c(res, out);
+}
+
+reindexer_ret reindexer_enum_namespaces(uintptr_t rx) {
+	reindexer_resbuffer out{0, 0, 0};
+	Error res = err_not_init;
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (db) {
+		QueryResultsWrapper* results = new_results();
+		if (!results) return ret2c(err_too_many_queries, out);
+
+		vector<NamespaceDef> defs;
+		res = db->EnumNamespaces(defs, true);
+		if (res.ok()) {
+			results->ser << "{\"items\":[";
+			for (unsigned i = 0; i < defs.size(); i++) {
+				if (i != 0) results->ser << ',';
+				defs[i].GetJSON(results->ser);
+			}
+			results->ser << "]}";
+			out.len = results->ser.Len();
+			out.data = uintptr_t(results->ser.Buf());
+			out.results_ptr = uintptr_t(results);
+		} else {
+			put_results_to_pool(results);
+		}
 	}
 	return ret2c(res, out);
 }
@@ -346,6 +565,21 @@ void reindexer_enable_logger(void (*logWriter)(int, char*)) { logInstallWriter(l
 
 void reindexer_disable_logger() { logInstallWriter(nullptr); }
 
+reindexer_error reindexer_subscribe_updates(uintptr_t rx, updates_writer_t updatesWriter, int subscribe) {
+	Reindexer* db = reinterpret_cast<Reindexer*>(rx);
+	if (!db) return error2c(err_not_init);
+
+	std::lock_guard<std::mutex> lck(updates_lck);
+	auto& observer = updates_observers[rx];
+	if (!observer) observer.reset(new UpdatesObserverC(rx));
+	observer->writer_ = subscribe ? updatesWriter : nullptr;
+	if (observer->subscribed_ == bool(subscribe)) return error2c(errOK);
+
+	Error err = db->SubscribeUpdates(observer.get(), subscribe);
+	if (err.ok()) observer->subscribed_ = subscribe;
+	return error2c(err);
+}
+
 reindexer_error reindexer_free_buffer(reindexer_resbuffer in) {
 	put_results_to_pool(reinterpret_cast<QueryResultsWrapper*>(in.results_ptr));
 	return error2c(Error(errOK));
@@ -24,10 +24,15 @@ reindexer_error reindexer_update_index(uintptr_t rx, reindexer_string _namespace
 reindexer_error reindexer_drop_index(uintptr_t rx, reindexer_string _namespace, reindexer_string index);
 
 reindexer_ret reindexer_modify_item_packed(uintptr_t rx, reindexer_buffer args, reindexer_buffer data);
+
+reindexer_tx_ret reindexer_start_transaction(uintptr_t rx, reindexer_string nsName);
+reindexer_ret reindexer_commit_transaction(uintptr_t rx, int tx_id);
+reindexer_error reindexer_rollback_transaction(uintptr_t rx, int tx_id);
 reindexer_ret reindexer_select(uintptr_t rx, reindexer_string query, int with_items, int32_t *pt_versions, int pt_versions_count);
 
 reindexer_ret reindexer_select_query(uintptr_t rx, reindexer_buffer in, int with_items, int32_t *pt_versions, int pt_versions_count);
 reindexer_ret reindexer_delete_query(uintptr_t rx, reindexer_buffer in);
+reindexer_ret reindexer_update_query(uintptr_t rx, reindexer_buffer in);
 
 reindexer_error reindexer_free_buffer(reindexer_resbuffer in);
 reindexer_error reindexer_free_buffers(reindexer_resbuffer *in, int count);
@@ -36,10 +41,16 @@ reindexer_error reindexer_commit(uintptr_t rx, reindexer_string _namespace);
 
 reindexer_error reindexer_put_meta(uintptr_t rx, reindexer_string ns, reindexer_string key, reindexer_string data);
 reindexer_ret reindexer_get_meta(uintptr_t rx, reindexer_string ns, reindexer_string key);
+reindexer_ret reindexer_enum_meta(uintptr_t rx, reindexer_string ns);
+
+reindexer_ret reindexer_enum_namespaces(uintptr_t rx);
 
 void reindexer_enable_logger(void (*logWriter)(int level, char *msg));
 void reindexer_disable_logger();
 
+reindexer_error reindexer_subscribe_updates(uintptr_t rx, void (*updatesWriter)(uintptr_t rx, reindexer_string ns, int mode, reindexer_string data),
+											int subscribe);
+
 #ifdef __cplusplus
 }
 #endif
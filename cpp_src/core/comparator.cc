@@ -6,6 +6,7 @@
 namespace reindexer {
 
 Comparator::Comparator() : fields_(), cmpComposite(payloadType_, fields_), cmpEqualPosition(payloadType_, KeyValueNull) {}
+Comparator::Comparator(shared_ptr<const vector<bool>> rowIds) : Comparator() { rowIds_ = std::move(rowIds); }
 Comparator::~Comparator() {}
 
 Comparator::Comparator(CondType cond, KeyValueType type, const VariantArray &values, bool isArray, bool distinct, PayloadType payloadType,
@@ -84,6 +85,7 @@ void Comparator::BindEqualPosition(const TagsPath &tagsPath, const VariantArray
 }
 
 bool Comparator::Compare(const PayloadValue &data, int rowId) {
+	if (rowIds_) return size_t(rowId) < rowIds_->size() && (*rowIds_)[rowId];
 	if (fields_.getTagsPathsLength() > 0) {
 		VariantArray rhs;
 		Payload pl(payloadType_, const_cast<PayloadValue &>(data));
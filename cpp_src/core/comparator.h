@@ -12,6 +12,8 @@ public:
 	Comparator();
 	Comparator(CondType cond, KeyValueType type, const VariantArray &values, bool isArray, bool distinct, PayloadType payloadType,
 			   const FieldsSet &fields, void *rawData = nullptr, const CollateOpts &collateOpts = CollateOpts());
+	// Comparator, which matches items by rowId. Used for conditions in brackets, which are selected beforehand
+	explicit Comparator(shared_ptr<const vector<bool>> rowIds);
 	~Comparator();
 
 	bool Compare(const PayloadValue &lhs, int rowId);
@@ -87,6 +89,7 @@ protected:
 	ComparatorImpl<PayloadValue> cmpComposite;
 	CompositeArrayComparator cmpEqualPosition;
 	shared_ptr<fast_hash_set<Variant>> dist_;
+	shared_ptr<const vector<bool>> rowIds_;
 	bool equalPositionMode = false;
 };
 
@@ -0,0 +1,146 @@
+#include "core/itemmodifier.h"
+#include "core/cjson/jsonbuilder.h"
+#include "tools/jsontools.h"
+#include "tools/stringstools.h"
+
+namespace reindexer {
+
+ItemModifier::ItemModifier(const h_vector<UpdateEntry, 0> &entries) {
+	for (const UpdateEntry &ue : entries) {
+		Entry entry;
+		entry.data = &ue;
+		split(ue.column, ".", true, entry.path);
+		if (entry.path.empty()) throw Error(errParams, "Empty field name in update query");
+		entries_.push_back(std::move(entry));
+	}
+}
+
+string ItemModifier::Modify(string_view json) const {
+	string buf(json.data(), json.size());
+	for (const Entry &entry : entries_) {
+		JsonAllocator allocator;
+		JsonValue root;
+		char *endptr = nullptr;
+		string src = buf;
+		int status = jsonParse(&src[0], &endptr, &root, allocator);
+		if (status != JSON_OK) throw Error(errParseJson, "Could not parse JSON of item: %s", jsonStrError(status));
+		if (root.getTag() != JSON_OBJECT) throw Error(errParseJson, "JSON of item is not an object");
+
+		WrSerializer ser;
+		{
+			JsonBuilder builder(ser);
+			modifyObject(root, entry, 0, builder);
+		}
+		buf = ser.Slice().ToString();
+	}
+	return buf;
+}
+
+void ItemModifier::modifyObject(JsonValue obj, const Entry &entry, size_t depth, JsonBuilder &builder) const {
+	const UpdateEntry &ue = *entry.data;
+	const bool last = depth + 1 == entry.path.size();
+	bool found = false;
+
+	for (auto elem : obj) {
+		if (entry.path[depth] != elem->key) {
+			putRaw(elem->key, elem->value, builder);
+			continue;
+		}
+		found = true;
+		if (last) {
+			modifyField(elem->key, &elem->value, ue, builder);
+		} else if (elem->value.getTag() == JSON_OBJECT) {
+			auto nested = builder.Object(elem->key);
+			modifyObject(elem->value, entry, depth + 1, nested);
+		} else if (ue.mode == FieldModeSet || ue.mode == FieldModeArrayAppend) {
+			// Value, which is not an object, is replaced with object, containing new field
+			auto nested = builder.Object(elem->key);
+			putNewField(entry, depth + 1, nested);
+		} else {
+			putRaw(elem->key, elem->value, builder);
+		}
+	}
+	if (!found && (ue.mode == FieldModeSet || ue.mode == FieldModeArrayAppend)) putNewField(entry, depth, builder);
+}
+
+void ItemModifier::putNewField(const Entry &entry, size_t depth, JsonBuilder &builder) const {
+	const char *name = entry.path[depth].c_str();
+	if (depth + 1 == entry.path.size()) {
+		modifyField(name, nullptr, *entry.data, builder);
+		return;
+	}
+	auto nested = builder.Object(name);
+	putNewField(entry, depth + 1, nested);
+}
+
+void ItemModifier::modifyField(const char *name, const JsonValue *oldValue, const UpdateEntry &ue, JsonBuilder &builder) const {
+	switch (ue.mode) {
+		case FieldModeSet:
+			if (ue.isArray) {
+				auto arrNode = builder.Array(name);
+				for (const Variant &kv : ue.values) arrNode.Put(nullptr, kv);
+			} else if (ue.values.empty()) {
+				builder.Null(name);
+			} else {
+				builder.Put(name, ue.values[0]);
+			}
+			break;
+		case FieldModeArrayAppend: {
+			auto arrNode = builder.Array(name);
+			if (oldValue && oldValue->getTag() == JSON_ARRAY) {
+				for (auto elem : *oldValue) putRaw(nullptr, elem->value, arrNode);
+			} else if (oldValue && oldValue->getTag() != JSON_NULL) {
+				putRaw(nullptr, *oldValue, arrNode);
+			}
+			for (const Variant &kv : ue.values) arrNode.Put(nullptr, kv);
+			break;
+		}
+		case FieldModeArrayRemove: {
+			if (!oldValue) break;
+			if (oldValue->getTag() != JSON_ARRAY) {
+				putRaw(name, *oldValue, builder);
+				break;
+			}
+			auto arrNode = builder.Array(name);
+			for (auto elem : *oldValue) {
+				bool remove = false;
+				for (const Variant &kv : ue.values) {
+					if (isEqual(elem->value, kv)) {
+						remove = true;
+						break;
+					}
+				}
+				if (!remove) putRaw(nullptr, elem->value, arrNode);
+			}
+			break;
+		}
+		case FieldModeDrop:
+			break;
+	}
+}
+
+void ItemModifier::putRaw(const char *name, JsonValue value, JsonBuilder &builder) {
+	WrSerializer ser;
+	jsonValueToString(value, ser, 0, 0);
+	builder.Raw(name, ser.Slice());
+}
+
+bool ItemModifier::isEqual(JsonValue value, const Variant &kv) {
+	switch (value.getTag()) {
+		case JSON_NUMBER:
+			if (kv.Type() == KeyValueInt || kv.Type() == KeyValueInt64) return int64_t(value.toNumber()) == kv.As<int64_t>();
+			return kv.Type() == KeyValueDouble && double(int64_t(value.toNumber())) == kv.As<double>();
+		case JSON_DOUBLE:
+			if (kv.Type() != KeyValueInt && kv.Type() != KeyValueInt64 && kv.Type() != KeyValueDouble) return false;
+			return value.toDouble() == kv.As<double>();
+		case JSON_STRING:
+			return kv.Type() == KeyValueString && string_view(kv) == string_view(value.toString());
+		case JSON_TRUE:
+		case JSON_FALSE:
+			return kv.Type() == KeyValueBool && bool(kv) == (value.getTag() == JSON_TRUE);
+		default:
+			return false;
+	}
+}
+
+}  // namespace reindexer
@@ -0,0 +1,36 @@
+#pragma once
+
+#include "core/query/querywhere.h"
+#include "gason/gason.h"
+#include "tools/serializer.h"
+
+namespace reindexer {
+
+class JsonBuilder;
+
+// Applies fields modifications of update query to JSON of item
+class ItemModifier {
+public:
+	// Columns of entries must be json paths of fields
+	ItemModifier(const h_vector<UpdateEntry, 0> &entries);
+
+	// Modifies json and returns modified one
+	string Modify(string_view json) const;
+
+private:
+	struct Entry {
+		const UpdateEntry *data;
+		// Json path of field, splitted by '.'
+		h_vector<string, 1> path;
+	};
+
+	void modifyObject(JsonValue obj, const Entry &entry, size_t depth, JsonBuilder &builder) const;
+	void modifyField(const char *name, const JsonValue *oldValue, const UpdateEntry &ue, JsonBuilder &builder) const;
+	void putNewField(const Entry &entry, size_t depth, JsonBuilder &builder) const;
+	static void putRaw(const char *name, JsonValue value, JsonBuilder &builder);
+	static bool isEqual(JsonValue value, const Variant &kv);
+
+	h_vector<Entry, 1> entries_;
+};
+
+}  // namespace reindexer
@@ -6,6 +6,7 @@
 #include <string>
 #include <thread>
 #include "core/index/index.h"
+#include "core/itemmodifier.h"
 #include "core/nsselecter/nsselecter.h"
 #include "itemimpl.h"
 #include "storage/storagefactory.h"
@@ -534,7 +535,7 @@ void Namespace::doDelete(IdType id) {
 	}
 }
 
-void Namespace::Delete(const Query &q, QueryResults &result) {
+void Namespace::Delete(const Query &q, QueryResults &result, vector<std::unique_ptr<ItemImpl>> *deletedItems) {
 	PerfStatCalculatorMT calc(updatePerfCounter_, enablePerfCounters_);
 	WLock lock(mtx_);
 	calc.LockHit();
@@ -545,6 +546,7 @@ void Namespace::Delete(const Query &q, QueryResults &result) {
 
 	auto tmStart = high_resolution_clock::now();
 	for (auto &r : result.Items()) {
+		if (deletedItems) deletedItems->emplace_back(copyItem(r.id));
 		doDelete(r.id);
 		r.value = PayloadValue();
 	}
@@ -555,6 +557,134 @@ void Namespace::Delete(const Query &q, QueryResults &result) {
 	}
 }
 
+void Namespace::Update(const Query &q, QueryResults &result) {
+	PerfStatCalculatorMT calc(updatePerfCounter_, enablePerfCounters_);
+	cancelCommit_ = true;
+	WLock lock(mtx_);
+	cancelCommit_ = false;
+	calc.LockHit();
+
+	// Modifications are applied to json, so names of indexes are replaced with json paths of fields
+	h_vector<UpdateEntry, 0> updateFields = q.updateFields_;
+	if (updateFields.empty()) throw Error(errParams, "Update query without fields modifications");
+	for (UpdateEntry &ue : updateFields) {
+		int idx;
+		if (!getIndexByName(ue.column, idx)) continue;
+		if (idx >= indexes_.firstCompositePos()) throw Error(errParams, "Can't update composite index '%s'", ue.column.c_str());
+		if (indexes_[idx]->Opts().IsSparse()) {
+			ue.column = indexes_[idx]->Fields().getJsonPath(0);
+		} else if (!payloadType_.Field(idx).JsonPaths().empty()) {
+			ue.column = payloadType_.Field(idx).JsonPaths()[0];
+		}
+	}
+	ItemModifier modifier(updateFields);
+
+	NsSelecter selecter(this);
+	SelectCtx ctx(q);
+	selecter(result, ctx);
+
+	auto tmStart = high_resolution_clock::now();
+	// Build all modified items before changing namespace, so invalid update does not leave namespace partially updated
+	vector<std::unique_ptr<ItemImpl>> newItems;
+	newItems.reserve(result.Count());
+	for (auto &r : result.Items()) {
+		ItemImpl oldItem(payloadType_, items_[r.id], tagsMatcher_);
+		newItems.emplace_back(new ItemImpl(payloadType_, tagsMatcher_, pkFields()));
+		auto err = newItems.back()->FromJSON(modifier.Modify(oldItem.GetJSON()));
+		if (!err.ok()) throw err;
+		auto realItem = findByPK(newItems.back().get());
+		if (!realItem.second || realItem.first != r.id) throw Error(errParams, "Update query can't change primary key of item");
+	}
+
+	string jsonSlice;
+	for (size_t i = 0; i < newItems.size(); ++i) {
+		ItemImpl *itemImpl = newItems[i].get();
+		auto &r = result.Items()[i];
+		updateTagsMatcherFromItem(itemImpl, jsonSlice);
+		setFieldsBasedOnPrecepts(itemImpl);
+		doModifyItem(itemImpl, r.id, true, true);
+		r.value = items_[r.id];
+	}
+	// Results must be encoded with tags of updated fields
+	if (result.getMergedNSCount()) result.getTagsMatcher(0) = tagsMatcher_;
+
+	if (q.debugLevel >= LogInfo) {
+		logPrintf(LogInfo, "Updated %d items in %d µs", int(result.Count()),
+				  int(duration_cast<microseconds>(high_resolution_clock::now() - tmStart).count()));
+	}
+}
+
+void Namespace::CommitTransaction(Transaction &tx, QueryResults &result) {
+	PerfStatCalculatorMT calc(updatePerfCounter_, enablePerfCounters_);
+	cancelCommit_ = true;
+	WLock lock(mtx_);
+	cancelCommit_ = false;
+	calc.LockHit();
+
+	// Copies of items, changed by applied steps, are kept to revert transaction on failure. Nullptr if item did not exist
+	vector<std::unique_ptr<ItemImpl>> backup;
+	vector<Item *> applied;
+	string jsonSlice;
+	try {
+		for (auto &step : tx.GetSteps()) {
+			ItemImpl *itemImpl = step.item.impl_;
+			updateTagsMatcherFromItem(itemImpl, jsonSlice);
+
+			auto realItem = findByPK(itemImpl);
+			bool exists = realItem.second;
+			if ((exists && step.mode == ModeInsert) || (!exists && (step.mode == ModeUpdate || step.mode == ModeDelete))) {
+				step.item.setID(-1);
+				continue;
+			}
+
+			backup.emplace_back(exists ? copyItem(realItem.first) : nullptr);
+			applied.push_back(&step.item);
+			if (step.mode == ModeDelete) {
+				step.item.setID(realItem.first);
+				step.item.setLSN(lsnCounter_++);
+				doDelete(realItem.first);
+				continue;
+			}
+
+			IdType id = exists ? realItem.first : createItem(itemImpl->GetPayload().RealSize());
+			setFieldsBasedOnPrecepts(itemImpl);
+			step.item.setLSN(items_[id].GetLSN());
+			step.item.setID(id);
+			doModifyItem(itemImpl, id, exists, true);
+		}
+	} catch (const Error &) {
+		for (int i = int(applied.size()) - 1; i >= 0; --i) {
+			restoreItem(applied[i]->impl_, backup[i].get());
+		}
+		for (auto &step : tx.GetSteps()) step.item.setID(-1);
+		throw;
+	}
+
+	result.addNSContext(payloadType_, tagsMatcher_, FieldsSet());
+	for (auto &step : tx.GetSteps()) {
+		if (step.item.GetID() != -1) result.Add(ItemRef(step.item.GetID(), PayloadValue()));
+	}
+}
+
+// Strings of payload are owned by indexes, so item is copied before they are released
+ItemImpl *Namespace::copyItem(IdType id) {
+	ItemImpl item(payloadType_, items_[id], tagsMatcher_);
+	ItemImpl *copy = new ItemImpl(payloadType_, tagsMatcher_, pkFields());
+	copy->FromCJSON(&item);
+	return copy;
+}
+
+// Puts back item, which was changed by itemImpl, to state of backup. Backup is nullptr, if item did not exist
+void Namespace::restoreItem(ItemImpl *itemImpl, ItemImpl *backup) {
+	auto realItem = findByPK(backup ? backup : itemImpl);
+	if (!backup) {
+		if (realItem.second) doDelete(realItem.first);
+		return;
+	}
+	IdType id = realItem.second ? realItem.first : createItem(backup->GetPayload().RealSize());
+	doModifyItem(backup, id, realItem.second, true);
+}
+
 void Namespace::doUpsert(ItemImpl *ritem, IdType id, bool doUpdate) {
 	// Upsert fields to indexes
 	assert(items_.exists(id));
@@ -665,21 +795,26 @@ void Namespace::modifyItem(Item &item, bool store, int mode) {
 
 	auto realItem = findByPK(itemImpl);
 	bool exists = realItem.second;
-	auto newValue = itemImpl->GetPayload();
 
 	if ((exists && mode == ModeInsert) || (!exists && mode == ModeUpdate)) {
 		item.setID(-1);
 		return;
 	}
 
-	IdType id = exists ? realItem.first : createItem(newValue.RealSize());
+	IdType id = exists ? realItem.first : createItem(itemImpl->GetPayload().RealSize());
 
 	setFieldsBasedOnPrecepts(itemImpl);
 
-	int64_t lsn = lsnCounter_++;
 	item.setLSN(items_[id].GetLSN());
 	item.setID(id);
 
+	doModifyItem(itemImpl, id, exists, store);
+}
+
+void Namespace::doModifyItem(ItemImpl *itemImpl, IdType id, bool exists, bool store) {
+	int64_t lsn = lsnCounter_++;
+	auto newValue = itemImpl->GetPayload();
+
 	doUpsert(itemImpl, id, exists);
 	markUpdated();
 
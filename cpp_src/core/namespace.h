@@ -16,6 +16,7 @@
 #include "perfstatcounter.h"
 #include "query/querycache.h"
 #include "storage/idatastorage.h"
+#include "transaction.h"
 
 namespace reindexer {
 
@@ -110,7 +111,11 @@ public:
 	NamespaceMemStat GetMemStat();
 	NamespacePerfStat GetPerfStat();
 	vector<string> EnumMeta();
-	void Delete(const Query &query, QueryResults &result);
+	// Copies of deleted items are added to deletedItems, if it's not null
+	void Delete(const Query &query, QueryResults &result, vector<std::unique_ptr<ItemImpl>> *deletedItems = nullptr);
+	void Update(const Query &query, QueryResults &result);
+	// Applies all modifications of transaction with single lock. Applied items are added to result
+	void CommitTransaction(Transaction &tx, QueryResults &result);
 	void BackgroundRoutine();
 	void CloseStorage();
 	void SetCacheMode(CacheMode cacheMode);
@@ -141,9 +146,12 @@ protected:
 	void markUpdated();
 	void doUpsert(ItemImpl *ritem, IdType id, bool doUpdate);
 	void modifyItem(Item &item, bool store = true, int mode = ModeUpsert);
+	void doModifyItem(ItemImpl *itemImpl, IdType id, bool exists, bool store);
 	void updateTagsMatcherFromItem(ItemImpl *ritem, string &jsonSliceBuf);
 	void updateItems(PayloadType oldPlType, const FieldsSet &changedFields, int deltaFields);
 	void doDelete(IdType id);
+	ItemImpl *copyItem(IdType id);
+	void restoreItem(ItemImpl *itemImpl, ItemImpl *backup);
 	void commitIndexes();
 	void insertIndex(Index *newIndex, int idxNo, const string &realName);
 	void addIndex(const IndexDef &indexDef);
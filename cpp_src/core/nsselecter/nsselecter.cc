@@ -379,7 +379,7 @@ void NsSelecter::setLimitAndOffset(ItemRefVector &queryResult, size_t offset, si
 bool NsSelecter::containsFullTextIndexes(const QueryEntries &entries) {
 	bool result = false;
 	for (const QueryEntry &entry : entries) {
-		if ((entry.idxNo != IndexValueType::SetByJsonPath) && isFullText(ns_->indexes_[entry.idxNo]->Type())) {
+		if (!entry.bracket && (entry.idxNo != IndexValueType::SetByJsonPath) && isFullText(ns_->indexes_[entry.idxNo]->Type())) {
 			result = true;
 			break;
 		}
@@ -396,6 +396,11 @@ QueryEntries NsSelecter::lookupQueryIndexes(const QueryEntries &entries) {
 
 	for (auto entry = entries.begin(); entry != entries.end(); entry++) {
 		QueryEntry ce = *entry;
+		if (ce.bracket) {
+			// Indexes of bracket entries are looked up by nested select
+			ret.push_back(std::move(ce));
+			continue;
+		}
 		if (ce.idxNo == IndexValueType::NotSet) {
 			if (!ns_->getIndexByName(ce.index, ce.idxNo)) {
 				ce.idxNo = IndexValueType::SetByJsonPath;
@@ -420,6 +425,7 @@ QueryEntries NsSelecter::lookupQueryIndexes(const QueryEntries &entries) {
 }
 
 void NsSelecter::convertWhereValues(QueryEntry &ce) {
+	if (ce.bracket) return;
 	bool isIndexField = (ce.idxNo != IndexValueType::SetByJsonPath);
 	KeyValueType keyType = isIndexField ? ns_->indexes_[ce.idxNo]->SelectKeyType() : detectQueryEntryIndexType(ce);
 	const FieldsSet *fields = isIndexField ? &ns_->indexes_[ce.idxNo]->Fields() : nullptr;
@@ -439,7 +445,10 @@ void NsSelecter::prepareIteratorsForSelectLoop(const QueryEntries &entries, RawQ
 		SelectKeyResults selectResults;
 		bool sparseIndex = false;
 		bool byJsonPath = (qe.idxNo == IndexValueType::SetByJsonPath);
-		if (byJsonPath) {
+		bool bracket = bool(qe.bracket);
+		if (bracket) {
+			selectResults.push_back(selectBracket(*qe.bracket));
+		} else if (byJsonPath) {
 			FieldsSet fields;
 			tagsPath = ns_->tagsMatcher_.path2tag(qe.index);
 			fields.push_back(tagsPath);
@@ -473,18 +482,18 @@ void NsSelecter::prepareIteratorsForSelectLoop(const QueryEntries &entries, RawQ
 			switch (qe.op) {
 				case OpOr:
 					if (!result.size()) throw Error(errQueryExec, "OR operator in first condition");
-					if (byJsonPath || sparseIndex) {
+					if (byJsonPath || sparseIndex || bracket) {
 						result.back().Append(res);
 					} else {
 						result.back().AppendAndBind(res, ns_->payloadType_, qe.idxNo);
 					}
 					result.back().distinct |= qe.distinct;
-					result.back().name += " OR " + qe.index;
+					result.back().name += " OR " + (bracket ? "(...)" : qe.index);
 					break;
 				case OpNot:
 				case OpAnd:
-					result.push_back(SelectIterator(res, qe.op, qe.distinct, qe.index, fullText));
-					if (!byJsonPath && !sparseIndex) {
+					result.push_back(SelectIterator(res, qe.op, qe.distinct, bracket ? "(...)" : qe.index, fullText));
+					if (!byJsonPath && !sparseIndex && !bracket) {
 						result.back().Bind(ns_->payloadType_, qe.idxNo);
 					}
 					break;
@@ -498,6 +507,24 @@ void NsSelecter::prepareIteratorsForSelectLoop(const QueryEntries &entries, RawQ
 	}
 }
 
+SelectKeyResult NsSelecter::selectBracket(const QueryEntries &entries) {
+	// Conditions in bracket are selected by nested query, and matched rowIds are checked by comparator,
+	// so result of bracket does not depend on sort order of main query
+	Query q(ns_->name_);
+	q.entries = entries;
+	SelectCtx ctx(q);
+	QueryResults qr;
+	NsSelecter selecter(ns_);
+	selecter(qr, ctx);
+
+	auto rowIds = std::make_shared<vector<bool>>(ns_->items_.size(), false);
+	for (auto &it : qr.Items()) (*rowIds)[it.id] = true;
+
+	SelectKeyResult res;
+	res.comparators_.push_back(Comparator(rowIds));
+	return res;
+}
+
 void NsSelecter::prepareEqualPositionComparator(const Query &query, const QueryEntries &entries, RawQueryResult &result) {
 	if (query.equalPositions_.empty()) return;
 	for (const EqualPosition &ep : query.equalPositions_) {
@@ -759,17 +786,18 @@ h_vector<Aggregator, 4> NsSelecter::getAggregators(const Query &q) {
 	h_vector<Aggregator, 4> ret;
 
 	for (auto &ag : q.aggregations_) {
-		ret.push_back(Aggregator(ag.type_, ag.index_));
-		int idx = -1;
-
-		if (ns_->getIndexByName(ag.index_, idx)) {
-			if (ns_->indexes_[idx]->Opts().IsSparse()) {
-				ret.back().Bind(ns_->payloadType_, -1, ns_->indexes_[idx]->Fields().getTagsPath(0));
+		ret.push_back(Aggregator(ag.type_, ag.fields_, ag.sortingEntries_, ag.limit_, ag.offset_));
+		for (auto &field : ag.fields_) {
+			int idx = -1;
+			if (ns_->getIndexByName(field, idx)) {
+				if (ns_->indexes_[idx]->Opts().IsSparse()) {
+					ret.back().Bind(ns_->payloadType_, -1, ns_->indexes_[idx]->Fields().getTagsPath(0));
+				} else {
+					ret.back().Bind(ns_->payloadType_, idx, TagsPath());
+				}
 			} else {
-				ret.back().Bind(ns_->payloadType_, idx, TagsPath());
+				ret.back().Bind(ns_->payloadType_, -1, ns_->tagsMatcher_.path2tag(field));
 			}
-		} else {
-			ret.back().Bind(ns_->payloadType_, -1, ns_->tagsMatcher_.path2tag(ag.index_));
 		}
 	}
 
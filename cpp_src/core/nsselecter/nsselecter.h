@@ -88,6 +88,7 @@ private:
 
 	bool containsFullTextIndexes(const QueryEntries &entries);
 	void prepareIteratorsForSelectLoop(const QueryEntries &entries, RawQueryResult &result, SortType sortId, bool is_ft);
+	SelectKeyResult selectBracket(const QueryEntries &entries);
 	void prepareEqualPositionComparator(const Query &query, const QueryEntries &entries, RawQueryResult &result);
 	void addSelectResult(uint8_t proc, IdType rowId, IdType properRowId, const SelectCtx &sctx, h_vector<Aggregator, 4> &aggregators,
 						 QueryResults &result);
@@ -44,13 +44,22 @@ void AggregationResult::GetJSON(WrSerializer &ser) const {
 
 	if (value != 0) builder.Put("value", value);
 	if (!field.empty()) builder.Put("field", field);
+	if (fields.size()) {
+		auto fieldsNode = builder.Array("fields");
+		for (auto &f : fields) fieldsNode.Put(nullptr, f);
+	}
 	builder.Put("type", aggTypeToStr(type));
 
 	if (facets.size()) {
 		auto arrNode = builder.Array("facets");
 		for (auto &facet : facets) {
 			auto objNode = arrNode.Object();
-			objNode.Put("value", facet.value);
+			if (facet.values.size() > 1) {
+				auto valuesNode = objNode.Array("values");
+				for (auto &v : facet.values) valuesNode.Put(nullptr, v);
+			} else {
+				objNode.Put("value", facet.value);
+			}
 			objNode.Put("count", facet.count);
 		}
 	}
@@ -69,6 +78,13 @@ Error AggregationResult::FromJSON(char *json) {
 		for (auto elem : jvalue) {
 			parseJsonField("value", value, elem);
 			parseJsonField("field", field, elem);
+			if ("fields"_sv == elem->key) {
+				if (elem->value.getTag() != JSON_ARRAY) return Error(errParseJson, "Expected json array in 'fields' key");
+				for (auto subElem : elem->value) {
+					if (subElem->value.getTag() != JSON_STRING) return Error(errParseJson, "Expected string in array of 'fields'");
+					fields.push_back(subElem->value.toString());
+				}
+			}
 			if ("type"_sv == elem->key && elem->value.getTag() == JSON_STRING) {
 				type = strToAggType(elem->value.toString());
 			}
@@ -80,6 +96,15 @@ Error AggregationResult::FromJSON(char *json) {
 					for (auto objElem : subElem->value) {
 						parseJsonField("value", facet.value, objElem);
 						parseJsonField("count", facet.count, objElem);
+						if ("values"_sv == objElem->key) {
+							if (objElem->value.getTag() != JSON_ARRAY) return Error(errParseJson, "Expected json array in 'values' key");
+							for (auto valueElem : objElem->value) {
+								if (valueElem->value.getTag() != JSON_STRING) {
+									return Error(errParseJson, "Expected string in array of 'values'");
+								}
+								facet.values.push_back(valueElem->value.toString());
+							}
+						}
 					}
 					facets.push_back(facet);
 				}
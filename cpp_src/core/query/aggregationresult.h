@@ -14,6 +14,8 @@ struct FacetResult {
 	FacetResult(const std::string &v, int c) : value(v), count(c) {}
 	FacetResult() : count(0) {}
 	string value;
+	// Values of each of fields, for facet by several fields
+	h_vector<string, 1> values;
 	int count;
 };
 
@@ -22,6 +24,8 @@ struct AggregationResult {
 	Error FromJSON(char *json);
 	AggType type = AggSum;
 	string field;
+	// Fields of facet by several fields
+	h_vector<string, 1> fields;
 	double value = 0;
 	h_vector<FacetResult, 1> facets;
 
@@ -50,8 +50,14 @@ void encodeSorting(const Query& query, JsonBuilder& builder) {
 	}
 }
 
+void encodeFilters(const QueryEntries& entries, JsonBuilder& builder);
+
 void encodeFilter(const QueryEntry& qentry, JsonBuilder& builder) {
 	builder.Put("op", get(op_map, qentry.op));
+	if (qentry.bracket) {
+		encodeFilters(*qentry.bracket, builder);
+		return;
+	}
 	builder.Put("cond", get(cond_map, qentry.condition));
 	builder.Put("field", qentry.index);
 
@@ -65,10 +71,10 @@ void encodeFilter(const QueryEntry& qentry, JsonBuilder& builder) {
 	}
 }
 
-void encodeFilters(const Query& query, JsonBuilder& builder) {
+void encodeFilters(const QueryEntries& entries, JsonBuilder& builder) {
 	auto arrNode = builder.Array("filters");
 
-	for (const QueryEntry& qe : query.entries) {
+	for (const QueryEntry& qe : entries) {
 		auto node = arrNode.Object();
 		encodeFilter(qe, node);
 	}
@@ -99,7 +105,20 @@ void encodeAggregationFunctions(const Query& query, JsonBuilder& builder) {
 	auto arrNode = builder.Array("aggregations");
 
 	for (auto& entry : query.aggregations_) {
-		arrNode.Object().Put("field", entry.index_).Put("type", AggregationResult::aggTypeToStr(entry.type_));
+		auto aggNode = arrNode.Object();
+		aggNode.Put("type", AggregationResult::aggTypeToStr(entry.type_));
+		{
+			auto fieldsNode = aggNode.Array("fields");
+			for (auto& field : entry.fields_) fieldsNode.Put(nullptr, field);
+		}
+		if (!entry.sortingEntries_.empty()) {
+			auto sortNode = aggNode.Array("sort");
+			for (const SortingEntry& sortingEntry : entry.sortingEntries_) {
+				sortNode.Object().Put("field", sortingEntry.column).Put("desc", sortingEntry.desc);
+			}
+		}
+		if (entry.limit_ != UINT_MAX) aggNode.Put("limit", entry.limit_);
+		if (entry.offset_) aggNode.Put("offset", entry.offset_);
 	}
 }
 
@@ -124,7 +143,7 @@ void encodeJoins(const Query& query, JsonBuilder& builder) {
 		node.Put("limit", joinQuery.count);
 		node.Put("offset", joinQuery.start);
 
-		encodeFilters(joinQuery, node);
+		encodeFilters(joinQuery.entries, node);
 		encodeSorting(joinQuery, node);
 
 		auto arr1 = node.Array("on");
@@ -148,7 +167,7 @@ void toDsl(const Query& query, JsonBuilder& builder) {
 	encodeSelectFilter(query, builder);
 	encodeSelectFunctions(query, builder);
 	encodeSorting(query, builder);
-	encodeFilters(query, builder);
+	encodeFilters(query.entries, builder);
 	encodeMergedQueries(query, builder);
 	encodeAggregationFunctions(query, builder);
 	encodeJoins(query, builder);
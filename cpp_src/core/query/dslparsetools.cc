@@ -45,7 +45,7 @@ static const fast_hash_map<string, JoinType> join_types = {{"inner", InnerJoin},
 // additionalfor parse field 'filters'
 
 static const fast_hash_map<string, Filter> filter_map = {
-	{"cond", Filter::Cond}, {"op", Filter::Op}, {"field", Filter::Field}, {"value", Filter::Value}};
+	{"cond", Filter::Cond}, {"op", Filter::Op}, {"field", Filter::Field}, {"value", Filter::Value}, {"filters", Filter::Filters}};
 
 // additional for 'filter::cond' field
 
@@ -63,7 +63,9 @@ static const fast_hash_map<string, CalcTotalMode> reqtotal_values = {
 
 // additional for 'Root::Aggregations' field
 
-static const fast_hash_map<string, Aggregation> aggregation_map = {{"field", Aggregation::Field}, {"type", Aggregation::Type}};
+static const fast_hash_map<string, Aggregation> aggregation_map = {{"field", Aggregation::Field}, {"type", Aggregation::Type},
+																 {"fields", Aggregation::Fields}, {"sort", Aggregation::Sort},
+																 {"limit", Aggregation::Limit},	{"offset", Aggregation::Offset}};
 static const fast_hash_map<string, AggType> aggregation_types = {
 	{"sum", AggSum}, {"avg", AggAvg}, {"max", AggMax}, {"min", AggMin}, {"facet", AggFacet}};
 
@@ -147,7 +149,7 @@ void parseValues(JsonValue& values, VariantArray& kvs) {
 	}
 }
 
-void parseFilter(JsonValue& filter, Query& q) {
+void parseFilter(JsonValue& filter, QueryEntries& entries) {
 	QueryEntry qe;
 	checkJsonValueType(filter, "filter", JSON_OBJECT);
 	for (auto elem : filter) {
@@ -172,8 +174,18 @@ void parseFilter(JsonValue& filter, Query& q) {
 				checkJsonValueType(v, name, JSON_STRING);
 				qe.index.assign(v.toString());
 				break;
+
+			case Filter::Filters:
+				checkJsonValueType(v, name, JSON_ARRAY);
+				qe.bracket = std::make_shared<QueryEntries>();
+				for (auto subfilter : v) parseFilter(subfilter->value, *qe.bracket);
+				break;
 		}
 	}
+	if (qe.bracket) {
+		entries.push_back(qe);
+		return;
+	}
 	switch (qe.condition) {
 		case CondGe:
 		case CondGt:
@@ -205,7 +217,7 @@ void parseFilter(JsonValue& filter, Query& q) {
 			break;
 	}
 
-	q.entries.push_back(qe);
+	entries.push_back(qe);
 }
 
 void parseJoinedEntries(JsonValue& joinEntries, Query& qjoin) {
@@ -265,7 +277,7 @@ void parseJoins(JsonValue& joins, Query& query) {
 					break;
 				case JoinRoot::Filters:
 					checkJsonValueType(value, name, JSON_ARRAY);
-					for (auto filter : value) parseFilter(filter->value, qjoin);
+					for (auto filter : value) parseFilter(filter->value, qjoin.entries);
 					break;
 				case JoinRoot::Sort:
 					parseSort(value, qjoin);
@@ -312,8 +324,50 @@ void parseAggregation(JsonValue& aggregation, Query& query) {
 				checkJsonValueType(value, name, JSON_STRING);
 				aggEntry.type_ = get(aggregation_types, lower(value.toString()));
 				break;
+			case Aggregation::Fields:
+				checkJsonValueType(value, name, JSON_ARRAY);
+				parseStringArray(value, aggEntry.fields_);
+				break;
+			case Aggregation::Sort:
+				checkJsonValueType(value, name, JSON_ARRAY);
+				for (auto sortElem : value) {
+					auto& sortEntry = sortElem->value;
+					checkJsonValueType(sortEntry, "Sort", JSON_OBJECT);
+					SortingEntry sortingEntry;
+					for (auto subelement : sortEntry) {
+						auto& v = subelement->value;
+						string sortName = lower(subelement->key);
+						switch (get(sort_map, sortName)) {
+							case Sort::Desc:
+								checkJsonValueType(v, sortName, JSON_TRUE, JSON_FALSE);
+								sortingEntry.desc = (v.getTag() == JSON_TRUE);
+								break;
+							case Sort::Field:
+								checkJsonValueType(v, sortName, JSON_STRING);
+								sortingEntry.column = v.toString();
+								break;
+							case Sort::Values:
+								throw Error(errParseJson, "Forced sort order is not supported for aggregations");
+						}
+					}
+					aggEntry.sortingEntries_.push_back(std::move(sortingEntry));
+				}
+				break;
+			case Aggregation::Limit:
+				checkJsonValueType(value, name, JSON_NUMBER, JSON_DOUBLE);
+				aggEntry.limit_ = static_cast<unsigned>(value.toNumber());
+				break;
+			case Aggregation::Offset:
+				checkJsonValueType(value, name, JSON_NUMBER, JSON_DOUBLE);
+				aggEntry.offset_ = static_cast<unsigned>(value.toNumber());
+				break;
 		}
 	}
+	if (aggEntry.fields_.empty()) {
+		aggEntry.fields_.push_back(aggEntry.index_);
+	} else {
+		aggEntry.index_ = aggEntry.fields_[0];
+	}
 	query.aggregations_.push_back(aggEntry);
 }
 
@@ -348,7 +402,7 @@ void parse(JsonValue& root, Query& q) {
 
 			case Root::Filters:
 				checkJsonValueType(v, name, JSON_ARRAY);
-				for (auto filter : v) parseFilter(filter->value, q);
+				for (auto filter : v) parseFilter(filter->value, q.entries);
 				break;
 
 			case Root::NextOp:
@@ -28,8 +28,8 @@ enum class Root {
 enum class Sort { Desc, Field, Values };
 enum class JoinRoot { Type, On, Op, Namespace, Filters, Sort, Limit, Offset };
 enum class JoinEntry { LetfField, RightField, Cond, Op };
-enum class Filter { Cond, Op, Field, Value };
-enum class Aggregation { Field, Type };
+enum class Filter { Cond, Op, Field, Value, Filters };
+enum class Aggregation { Field, Type, Fields, Sort, Limit, Offset };
 
 void parse(JsonValue& value, Query& q);
 }  // namespace dsl
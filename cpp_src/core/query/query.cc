@@ -31,6 +31,7 @@ bool Query::operator==(const Query &obj) const {
 	if (selectFunctions_ != obj.selectFunctions_) return false;
 	if (joinQueries_ != obj.joinQueries_) return false;
 	if (mergeQueries_ != obj.mergeQueries_) return false;
+	if (updateFields_ != obj.updateFields_) return false;
 
 	return true;
 }
@@ -63,6 +64,13 @@ void Query::parseJson(const string &dsl) {
 }
 
 void Query::deserialize(Serializer &ser) {
+	// Sort, limit, offset and fields are applied to the last aggregation of query
+	auto lastAggregation = [this]() -> AggregateEntry & {
+		if (aggregations_.empty()) throw Error(errParseBin, "Aggregation parameters without aggregation in query");
+		return aggregations_.back();
+	};
+	// Entries of opened brackets. Conditions are added to the innermost one
+	h_vector<QueryEntries *, 2> brackets{&entries};
 	while (!ser.Eof()) {
 		QueryEntry qe;
 		QueryJoinEntry qje;
@@ -76,12 +84,46 @@ void Query::deserialize(Serializer &ser) {
 				int count = ser.GetVarUint();
 				qe.values.reserve(count);
 				while (count--) qe.values.push_back(ser.GetVariant().EnsureHold());
-				entries.push_back(std::move(qe));
+				brackets.back()->push_back(std::move(qe));
 				break;
 			}
+			case QueryOpenBracket: {
+				qe.op = OpType(ser.GetVarUint());
+				qe.bracket = std::make_shared<QueryEntries>();
+				QueryEntries *bracket = qe.bracket.get();
+				brackets.back()->push_back(std::move(qe));
+				brackets.push_back(bracket);
+				break;
+			}
+			case QueryCloseBracket:
+				if (brackets.size() == 1) throw Error(errParseBin, "Close bracket without open bracket in query");
+				brackets.pop_back();
+				break;
 			case QueryAggregation:
 				aggregations_.push_back({ser.GetVString().ToString(), AggType(ser.GetVarUint())});
 				break;
+			case QueryAggregationFields: {
+				AggregateEntry &ae = lastAggregation();
+				int fieldsCount = ser.GetVarUint();
+				ae.fields_.clear();
+				while (fieldsCount--) ae.fields_.push_back(ser.GetVString().ToString());
+				if (ae.fields_.empty()) throw Error(errParseBin, "Aggregation without fields in query");
+				ae.index_ = ae.fields_[0];
+				break;
+			}
+			case QueryAggregationSort: {
+				SortingEntry sortingEntry;
+				sortingEntry.column = ser.GetVString().ToString();
+				sortingEntry.desc = bool(ser.GetVarUint());
+				lastAggregation().sortingEntries_.push_back(std::move(sortingEntry));
+				break;
+			}
+			case QueryAggregationLimit:
+				lastAggregation().limit_ = ser.GetVarUint();
+				break;
+			case QueryAggregationOffset:
+				lastAggregation().offset_ = ser.GetVarUint();
+				break;
 			case QueryDistinct:
 				qe.index = ser.GetVString().ToString();
 				if (!qe.index.empty()) {
@@ -136,10 +178,31 @@ void Query::deserialize(Serializer &ser) {
 			case QuerySelectFunction:
 				selectFunctions_.push_back(ser.GetVString().ToString());
 				break;
+			case QueryUpdateField: {
+				UpdateEntry ue;
+				ue.column = ser.GetVString().ToString();
+				ue.mode = FieldModifyMode(ser.GetVarUint());
+				if (ue.mode != FieldModeSet && ue.mode != FieldModeArrayAppend && ue.mode != FieldModeArrayRemove) {
+					throw Error(errParseBin, "Unknown update mode %d of field '%s'", int(ue.mode), ue.column.c_str());
+				}
+				ue.isArray = bool(ser.GetVarUint());
+				int count = ser.GetVarUint();
+				ue.values.reserve(count);
+				while (count--) ue.values.push_back(ser.GetVariant().EnsureHold());
+				updateFields_.push_back(std::move(ue));
+				break;
+			}
+			case QueryDropField:
+				updateFields_.push_back({ser.GetVString().ToString(), FieldModeDrop});
+				break;
 			case QueryEnd:
+				if (brackets.size() != 1) throw Error(errParseBin, "Bracket is not closed in query");
 				return;
+			default:
+				throw Error(errParseBin, "Unknown query item type %d while parsing binary buffer", qtype);
 		}
 	}
+	if (brackets.size() != 1) throw Error(errParseBin, "Bracket is not closed in query");
 }
 
 int Query::Parse(tokenizer &parser) {
@@ -183,7 +246,12 @@ int Query::selectParse(tokenizer &parser) {
 			tok = parser.next_token();
 			AggType agg = AggregationResult::strToAggType(name.text());
 			if (agg != AggUnknown) {
-				aggregations_.push_back({tok.text().ToString(), agg});
+				AggregateEntry aggEntry(tok.text().ToString(), agg);
+				while (parser.peek_token().text() == ","_sv) {
+					parser.next_token();
+					aggEntry.fields_.push_back(parser.next_token().text().ToString());
+				}
+				aggregations_.push_back(std::move(aggEntry));
 			} else if (name.text() == "count"_sv) {
 				calcTotal = ModeAccurateTotal;
 				if (!wasSelectFilter) count = 0;
@@ -496,9 +564,15 @@ void Query::parseJoinEntries(tokenizer &parser, const string &mainNs) {
 	}
 }
 
-void Query::Serialize(WrSerializer &ser, uint8_t mode) const {
-	ser.PutVString(_namespace);
+void Query::serializeEntries(WrSerializer &ser, const QueryEntries &entries) {
 	for (auto &qe : entries) {
+		if (qe.bracket) {
+			ser.PutVarUint(QueryOpenBracket);
+			ser.PutVarUint(qe.op);
+			serializeEntries(ser, *qe.bracket);
+			ser.PutVarUint(QueryCloseBracket);
+			continue;
+		}
 		qe.distinct ? ser.PutVarUint(QueryDistinct) : ser.PutVarUint(QueryCondition);
 		ser.PutVString(qe.index);
 		if (qe.distinct) continue;
@@ -507,11 +581,34 @@ void Query::Serialize(WrSerializer &ser, uint8_t mode) const {
 		ser.PutVarUint(qe.values.size());
 		for (auto &kv : qe.values) ser.PutVariant(kv);
 	}
+}
+
+void Query::Serialize(WrSerializer &ser, uint8_t mode) const {
+	ser.PutVString(_namespace);
+	serializeEntries(ser, entries);
 
 	for (auto &agg : aggregations_) {
 		ser.PutVarUint(QueryAggregation);
 		ser.PutVString(agg.index_);
 		ser.PutVarUint(agg.type_);
+		if (agg.fields_.size() > 1) {
+			ser.PutVarUint(QueryAggregationFields);
+			ser.PutVarUint(agg.fields_.size());
+			for (auto &field : agg.fields_) ser.PutVString(field);
+		}
+		for (const SortingEntry &sortingEntry : agg.sortingEntries_) {
+			ser.PutVarUint(QueryAggregationSort);
+			ser.PutVString(sortingEntry.column);
+			ser.PutVarUint(sortingEntry.desc);
+		}
+		if (agg.limit_ != UINT_MAX) {
+			ser.PutVarUint(QueryAggregationLimit);
+			ser.PutVarUint(agg.limit_);
+		}
+		if (agg.offset_) {
+			ser.PutVarUint(QueryAggregationOffset);
+			ser.PutVarUint(agg.offset_);
+		}
 	}
 
 	for (const SortingEntry &sortginEntry : sortingEntries_) {
@@ -565,6 +662,20 @@ void Query::Serialize(WrSerializer &ser, uint8_t mode) const {
 		ser.PutVarUint(QueryExplain);
 	}
 
+	for (const UpdateEntry &ue : updateFields_) {
+		if (ue.mode == FieldModeDrop) {
+			ser.PutVarUint(QueryDropField);
+			ser.PutVString(ue.column);
+			continue;
+		}
+		ser.PutVarUint(QueryUpdateField);
+		ser.PutVString(ue.column);
+		ser.PutVarUint(ue.mode);
+		ser.PutVarUint(ue.isArray);
+		ser.PutVarUint(ue.values.size());
+		for (const Variant &v : ue.values) ser.PutVariant(v);
+	}
+
 	ser.PutVarUint(QueryEnd);  // finita la commedia... of root query
 
 	if (!(mode & SkipJoinQueries)) {
@@ -682,7 +793,12 @@ WrSerializer &Query::GetSQL(WrSerializer &ser, bool stripArgs) const {
 	if (aggregations_.size()) {
 		for (auto &a : aggregations_) {
 			if (&a != &*aggregations_.begin()) ser << ',';
-			ser << AggregationResult::aggTypeToStr(a.type_) << "(" << a.index_ << ')';
+			ser << AggregationResult::aggTypeToStr(a.type_) << '(';
+			for (auto &f : a.fields_) {
+				if (&f != &*a.fields_.begin()) ser << ',';
+				ser << f;
+			}
+			ser << ')';
 		}
 	} else if (selectFilter_.size()) {
 		for (auto &f : selectFilter_) {
@@ -365,6 +365,11 @@ protected:
 	/// @param ser - serializer object.
 	void deserialize(Serializer &ser);
 
+	/// Serializes query conditions, including conditions in brackets.
+	/// @param ser - serializer object for write.
+	/// @param entries - conditions to serialize.
+	static void serializeEntries(WrSerializer &ser, const QueryEntries &entries);
+
 	/// Parse join entries
 	void parseJoin(JoinType type, tokenizer &tok);
 
@@ -448,6 +453,9 @@ public:
 	/// List of same position fields for queries with arrays
 	h_vector<EqualPosition, 1> equalPositions_;
 
+	/// List of fields modifications for update query
+	h_vector<UpdateEntry, 0> updateFields_;
+
 	/// Explain query if true
 	bool explain_ = false;
 	QueryType type_ = QuerySelect;
@@ -15,6 +15,8 @@ bool QueryEntry::operator==(const QueryEntry &obj) const {
 	if (idxNo != obj.idxNo) return false;
 	if (distinct != obj.distinct) return false;
 	if (values != obj.values) return false;
+	if (bool(bracket) != bool(obj.bracket)) return false;
+	if (bracket && *bracket != *obj.bracket) return false;
 	return true;
 }
 
@@ -32,11 +34,25 @@ bool QueryJoinEntry::operator==(const QueryJoinEntry &obj) const {
 bool AggregateEntry::operator==(const AggregateEntry &obj) const {
 	if (index_ != obj.index_) return false;
 	if (type_ != obj.type_) return false;
+	if (fields_ != obj.fields_) return false;
+	if (sortingEntries_ != obj.sortingEntries_) return false;
+	if (limit_ != obj.limit_) return false;
+	if (offset_ != obj.offset_) return false;
 	return true;
 }
 
 bool AggregateEntry::operator!=(const AggregateEntry &obj) const { return !operator==(obj); }
 
+bool UpdateEntry::operator==(const UpdateEntry &obj) const {
+	if (column != obj.column) return false;
+	if (mode != obj.mode) return false;
+	if (isArray != obj.isArray) return false;
+	if (values != obj.values) return false;
+	return true;
+}
+
+bool UpdateEntry::operator!=(const UpdateEntry &obj) const { return !operator==(obj); }
+
 bool SortingEntry::operator==(const SortingEntry &obj) const {
 	if (column != obj.column) return false;
 	if (desc != obj.desc) return false;
@@ -104,6 +120,11 @@ static Variant token2kv(const token &tok, tokenizer &parser) {
 }
 
 int QueryWhere::ParseWhere(tokenizer &parser) {
+	parseEntries(parser, entries);
+	return 0;
+}
+
+void QueryWhere::parseEntries(tokenizer &parser, QueryEntries &entries) {
 	token tok;
 	OpType nextOp = OpAnd;
 
@@ -121,8 +142,12 @@ int QueryWhere::ParseWhere(tokenizer &parser) {
 		tok = parser.next_token(false);
 
 		if (tok.text() == "("_sv) {
-			throw Error(errParseSQL, "Found '(' - nestqed queries are not supported, %s", parser.where().c_str());
-
+			entry.bracket = std::make_shared<QueryEntries>();
+			parseEntries(parser, *entry.bracket);
+			tok = parser.next_token();
+			if (tok.text() != ")"_sv) {
+				throw Error(errParseSQL, "Expected ')', but found '%s' in query, %s", tok.text().data(), parser.where().c_str());
+			}
 		} else if (tok.type == TokenName || tok.type == TokenString) {
 			// Index name
 			entry.index = tok.text().ToString();
@@ -189,7 +214,6 @@ int QueryWhere::ParseWhere(tokenizer &parser) {
 
 		parser.next_token();
 	}
-	return 0;
 }
 
 const char *condNames[] = {"IS NOT NULL", "=", "<", "<=", ">", "=>", "RANGE", "IN", "ALLSET", "IS NULL"};
@@ -197,13 +221,22 @@ const char *opNames[] = {"-", "OR", "AND", "AND NOT"};
 
 void QueryWhere::dumpWhere(WrSerializer &ser, bool stripArgs) const {
 	if (entries.size()) ser << " WHERE";
+	dumpEntries(ser, entries, stripArgs);
+}
 
+void QueryWhere::dumpEntries(WrSerializer &ser, const QueryEntries &entries, bool stripArgs) {
 	for (auto &e : entries) {
 		if (&e != &*entries.begin() && unsigned(e.op) < sizeof(opNames) / sizeof(opNames[0])) {
 			ser << " " << opNames[e.op];
 		} else if (&e == &*entries.begin() && e.op == OpNot) {
 			ser << " NOT";
 		}
+		if (e.bracket) {
+			ser << " (";
+			dumpEntries(ser, *e.bracket, stripArgs);
+			ser << " )";
+			continue;
+		}
 		ser << " " << e.index << " ";
 		if (e.condition < sizeof(condNames) / sizeof(condNames[0]))
 			ser << condNames[e.condition] << " ";
@@ -245,6 +278,11 @@ string QueryEntry::Dump() const {
 				break;
 		}
 		result += " ";
+		if (bracket) {
+			result += "( ";
+			for (auto &e : *bracket) result += e.Dump();
+			return result + ") ";
+		}
 		result += index;
 		result += " ";
 
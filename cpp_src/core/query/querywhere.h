@@ -1,5 +1,6 @@
 #pragma once
 
+#include <climits>
 #include <memory>
 #include <string>
 #include <vector>
@@ -15,6 +16,7 @@ using std::vector;
 
 class QueryWhere;
 class tokenizer;
+struct QueryEntries;
 
 struct QueryEntry {
 	QueryEntry(OpType o, CondType cond, const string &idx, int idxN, bool dist = false)
@@ -30,6 +32,8 @@ struct QueryEntry {
 	CondType condition = CondType::CondAny;
 	bool distinct = false;
 	VariantArray values;
+	// Entries of bracket. If set, entry is group of conditions, joined to query with op
+	std::shared_ptr<QueryEntries> bracket;
 
 	string Dump() const;
 };
@@ -45,13 +49,6 @@ struct QueryJoinEntry {
 
 struct QueryEntries : public h_vector<QueryEntry, 4> {};
 
-struct AggregateEntry {
-	bool operator==(const AggregateEntry &) const;
-	bool operator!=(const AggregateEntry &) const;
-	string index_;
-	AggType type_;
-};
-
 struct SortingEntry {
 	SortingEntry() {}
 	SortingEntry(const string &c, bool d) : column(c), desc(d) {}
@@ -64,6 +61,33 @@ struct SortingEntry {
 
 struct SortingEntries : public h_vector<SortingEntry, 1> {};
 
+struct AggregateEntry {
+	AggregateEntry() = default;
+	AggregateEntry(const string &index, AggType type) : index_(index), type_(type), fields_{index} {}
+	bool operator==(const AggregateEntry &) const;
+	bool operator!=(const AggregateEntry &) const;
+	string index_;
+	AggType type_;
+	// Fields of aggregation, the first one is index_. Only facet can be aggregated by several fields
+	h_vector<string, 1> fields_;
+	// Sort, limit and offset of facets
+	SortingEntries sortingEntries_;
+	unsigned limit_ = UINT_MAX;
+	unsigned offset_ = 0;
+};
+
+struct UpdateEntry {
+	UpdateEntry() = default;
+	UpdateEntry(const string &c, FieldModifyMode m) : column(c), mode(m) {}
+	bool operator==(const UpdateEntry &) const;
+	bool operator!=(const UpdateEntry &) const;
+	string column;
+	FieldModifyMode mode = FieldModeSet;
+	// Set field to array of values, instead of single value
+	bool isArray = false;
+	VariantArray values;
+};
+
 struct EqualPosition : public h_vector<int, 2> {};
 
 class QueryWhere {
@@ -74,7 +98,9 @@ public:
 
 protected:
 	int ParseWhere(tokenizer &tok);
+	static void parseEntries(tokenizer &tok, QueryEntries &entries);
 	void dumpWhere(WrSerializer &, bool stripArgs) const;
+	static void dumpEntries(WrSerializer &, const QueryEntries &entries, bool stripArgs);
 	static CondType getCondType(string_view cond);
 
 public:
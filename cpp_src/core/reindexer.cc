@@ -26,6 +26,8 @@ Error Reindexer::PutMeta(const string& _namespace, const string& key, const stri
 }
 Error Reindexer::EnumMeta(const string& _namespace, vector<string>& keys) { return impl_->EnumMeta(_namespace, keys); }
 Error Reindexer::Delete(const Query& q, QueryResults& result) { return impl_->Delete(q, result); }
+Error Reindexer::Update(const Query& q, QueryResults& result) { return impl_->Update(q, result); }
+Error Reindexer::CommitTransaction(Transaction& tx, QueryResults& result) { return impl_->CommitTransaction(tx, result); }
 Error Reindexer::Select(const string_view& query, QueryResults& result, Completion cmpl) { return impl_->Select(query, result, cmpl); }
 Error Reindexer::Select(const Query& q, QueryResults& result, Completion cmpl) { return impl_->Select(q, result, cmpl); }
 Error Reindexer::Commit(const string& _namespace) { return impl_->Commit(_namespace); }
@@ -3,6 +3,7 @@
 #include "core/namespacedef.h"
 #include "core/query/query.h"
 #include "core/query/queryresults.h"
+#include "core/transaction.h"
 
 namespace reindexer {
 using std::vector;
@@ -98,6 +99,14 @@ public:
 	/// @param query - Query with conditions
 	/// @param result - QueryResults with IDs of deleted items
 	Error Delete(const Query &query, QueryResults &result);
+	/// Update fields of all items froms namespace, which matches provided Query
+	/// @param query - Query with conditions and fields modifications
+	/// @param result - QueryResults with updated items
+	Error Update(const Query &query, QueryResults &result);
+	/// Apply all modifications of transaction atomically. If some modification fails, none of them is applied
+	/// @param tx - Transaction with modifications of items of namespace
+	/// @param result - QueryResults with IDs of modified items
+	Error CommitTransaction(Transaction &tx, QueryResults &result);
 	/// Execute SQL Query and return results
 	/// @param query - SQL query. Only "SELECT" semantic is supported
 	/// @param result - QueryResults with found items
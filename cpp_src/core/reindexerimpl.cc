@@ -312,9 +312,42 @@ Error ReindexerImpl::Delete(const string& nsName, Item& item, Completion cmpl) {
 Error ReindexerImpl::Delete(const Query& q, QueryResults& result) {
 	try {
 		auto ns = getNamespace(q._namespace);
-		ns->Delete(q, result);
-		// TODO
-		// observers_.OnModifyItem(nsName, item.impl_, ModeDelete);
+		if (observers_.Empty()) {
+			ns->Delete(q, result);
+			return errOK;
+		}
+		vector<std::unique_ptr<ItemImpl>> deletedItems;
+		ns->Delete(q, result, &deletedItems);
+		for (auto &item : deletedItems) observers_.OnModifyItem(q._namespace, item.get(), ModeDelete);
+	} catch (const Error& err) {
+		return err;
+	}
+	return errOK;
+}
+
+Error ReindexerImpl::Update(const Query& q, QueryResults& result) {
+	try {
+		auto ns = getNamespace(q._namespace);
+		ns->Update(q, result);
+		for (auto it : result) {
+			Item item = it.GetItem();
+			observers_.OnModifyItem(q._namespace, item.impl_, ModeUpdate);
+		}
+	} catch (const Error& err) {
+		return err;
+	}
+	return errOK;
+}
+
+Error ReindexerImpl::CommitTransaction(Transaction& tx, QueryResults& result) {
+	try {
+		auto ns = getNamespace(tx.GetName());
+		ns->CommitTransaction(tx, result);
+		for (auto& step : tx.GetSteps()) {
+			if (step.item.GetID() == -1) continue;
+			if (step.mode != ModeDelete) updateSystemNamespace(tx.GetName(), step.item);
+			observers_.OnModifyItem(tx.GetName(), step.item.impl_, step.mode);
+		}
 	} catch (const Error& err) {
 		return err;
 	}
@@ -43,6 +43,8 @@ public:
 	Error Upsert(const string &_namespace, Item &item, Completion cmpl = nullptr);
 	Error Delete(const string &_namespace, Item &item, Completion cmpl = nullptr);
 	Error Delete(const Query &query, QueryResults &result);
+	Error Update(const Query &query, QueryResults &result);
+	Error CommitTransaction(Transaction &tx, QueryResults &result);
 	Error Select(const string_view &query, QueryResults &result, Completion cmpl = nullptr);
 	Error Select(const Query &query, QueryResults &result, Completion cmpl = nullptr);
 	Error Commit(const string &namespace_);
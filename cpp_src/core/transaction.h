@@ -0,0 +1,39 @@
+#pragma once
+
+#include <string>
+#include <vector>
+#include "core/item.h"
+
+namespace reindexer {
+
+using std::string;
+using std::vector;
+
+/// Transaction collects modifications of items of one namespace.<br>
+/// Modifications are not visible until commit. Commit applies all of them atomically: with single lock of namespace,
+/// and if some modification fails, already applied ones are reverted
+class Transaction {
+public:
+	struct Step {
+		Item item;
+		int mode;
+	};
+
+	/// Create transaction of namespace
+	/// @param nsName - Name of namespace
+	Transaction(const string &nsName) : nsName_(nsName) {}
+
+	/// Add modification of item to transaction
+	/// @param item - Item, obtained by call to NewItem of the same namespace
+	/// @param mode - Modification mode, one of ItemModifyMode
+	void Modify(Item &&item, int mode) { steps_.push_back({std::move(item), mode}); }
+
+	const string &GetName() const { return nsName_; }
+	vector<Step> &GetSteps() { return steps_; }
+
+protected:
+	string nsName_;
+	vector<Step> steps_;
+};
+
+}  // namespace reindexer
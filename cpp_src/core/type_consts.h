@@ -52,6 +52,14 @@ typedef enum QueryItemType {
 	QueryEnd,
 	QueryExplain,
 	QueryEqualPosition,
+	QueryUpdateField = 14,
+	QueryAggregationLimit = 15,
+	QueryAggregationOffset = 16,
+	QueryAggregationSort = 17,
+	QueryOpenBracket = 18,
+	QueryCloseBracket = 19,
+	QueryDropField = 21,
+	QueryAggregationFields = 22,
 } QueryItemType;
 
 typedef enum QuerySerializeMode {
@@ -145,6 +153,8 @@ enum CollateMode { CollateNone = 0, CollateASCII, CollateUTF8, CollateNumeric, C
 
 enum ItemModifyMode { ModeUpdate = 0, ModeInsert = 1, ModeUpsert = 2, ModeDelete = 3 };
 
+enum FieldModifyMode { FieldModeSet = 0, FieldModeArrayAppend = 1, FieldModeArrayRemove = 2, FieldModeDrop = 3 };
+
 typedef struct StorageOpts {
 #ifdef __cplusplus
 	StorageOpts() : options(0) {}
@@ -115,6 +115,7 @@ public:
 		std::unique_lock<Mutex> lck(mtx_);
 		return (head_ - tail_ + ring_.size()) % ring_.size();
 	}
+	unsigned capacity() { return ring_.size() - 1; }
 	void clear() {
 		std::unique_lock<Mutex> lck(mtx_);
 		head_ = tail_ = 0;
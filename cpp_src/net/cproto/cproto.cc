@@ -21,6 +21,10 @@ std::unordered_map<int, const char *> kRPCCodes = {
 	{kCmdCommit, "Commit"},
 	{kCmdModifyItem, "ModifyItem"},
 	{kCmdDeleteQuery, "DeleteQuery"},
+	{kCmdUpdateQuery, "UpdateQuery"},
+	{kCmdStartTransaction, "StartTransaction"},
+	{kCmdCommitTx, "CommitTx"},
+	{kCmdRollbackTx, "RollbackTx"},
 	{kCmdSelect, "Select"},
 	{kCmdSelectSQL, "SelectSQL"},
 	{kCmdFetchResults, "FetchResults"},
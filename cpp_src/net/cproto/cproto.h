@@ -22,6 +22,10 @@ enum CmdCode {
 	kCmdCommit = 32,
 	kCmdModifyItem = 33,
 	kCmdDeleteQuery = 34,
+	kCmdUpdateQuery = 35,
+	kCmdStartTransaction = 36,
+	kCmdCommitTx = 37,
+	kCmdRollbackTx = 38,
 
 	kCmdSelect = 48,
 	kCmdSelectSQL = 49,
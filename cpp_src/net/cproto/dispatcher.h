@@ -37,6 +37,8 @@ public:
 	virtual void WriteRPCReturn(Context &ctx, const Args &args) = 0;
 	virtual void SetClientData(ClientData::Ptr data) = 0;
 	virtual ClientData::Ptr GetClientData() = 0;
+	/// Call RPC on client side without request from client, e.g. to push updates. Can be called from any thread
+	virtual void CallRPC(CmdCode cmd, const Args &args) = 0;
 };
 
 struct Context {
@@ -11,27 +11,34 @@ namespace cproto {
 const auto kCProtoTimeoutSec = 300.;
 
 ServerConnection::ServerConnection(int fd, ev::dynamic_loop &loop, Dispatcher &dispatcher)
-	: net::ConnectionST(fd, loop), dispatcher_(dispatcher) {
+	: net::ConnectionMT(fd, loop), dispatcher_(dispatcher) {
 	timeout_.start(kCProtoTimeoutSec);
+	async_.start();
 	callback(io_, ev::READ);
 }
 
 bool ServerConnection::Restart(int fd) {
 	restart(fd);
 	respSent_ = false;
+	async_.start();
 	callback(io_, ev::READ);
 	timeout_.start(kCProtoTimeoutSec);
 	return true;
 }
 
 void ServerConnection::Attach(ev::dynamic_loop &loop) {
+	std::lock_guard<std::mutex> lck(asyncMtx_);
 	if (!attached_) {
 		attach(loop);
 		timeout_.start(kCProtoTimeoutSec);
+		async_.start();
+		// Data could be written by CallRPC, while connection was detached
+		if (wrBuf_.size()) async_.send();
 	}
 }
 
 void ServerConnection::Detach() {
+	std::lock_guard<std::mutex> lck(asyncMtx_);
 	if (attached_) detach();
 }
 
@@ -119,6 +126,29 @@ void ServerConnection::onRead() {
 	}
 }
 
+void ServerConnection::CallRPC(CmdCode cmd, const Args &args) {
+	// Client, which does not read data, can't get more calls, than write buffer can keep
+	if (wrBuf_.size() >= wrBuf_.capacity() / 2) return;
+
+	WrSerializer ser(wrBuf_.get_chunk());
+	CProtoHeader hdr;
+	hdr.len = 0;
+	hdr.magic = kCprotoMagic;
+	hdr.version = kCprotoVersion;
+	hdr.cmd = cmd;
+	hdr.seq = 0;
+
+	ser.Write(string_view(reinterpret_cast<char *>(&hdr), sizeof(hdr)));
+	ser.PutVarUint(errOK);
+	ser.PutVString(string_view());
+	args.Pack(ser);
+	reinterpret_cast<CProtoHeader *>(ser.Buf())->len = ser.Len() - sizeof(hdr);
+	wrBuf_.write(ser.DetachChunk());
+
+	std::lock_guard<std::mutex> lck(asyncMtx_);
+	if (attached_) async_.send();
+}
+
 void ServerConnection::responceRPC(Context &ctx, const Error &status, const Args &args) {
 	if (respSent_) {
 		fprintf(stderr, "Warning - RPC responce already sent\n");
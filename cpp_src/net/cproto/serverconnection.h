@@ -1,6 +1,7 @@
 #pragma once
 
 #include <string.h>
+#include <mutex>
 #include "dispatcher.h"
 #include "net/connection.h"
 #include "net/iserverconnection.h"
@@ -11,7 +12,7 @@ namespace cproto {
 
 using reindexer::h_vector;
 
-class ServerConnection : public ConnectionST, public IServerConnection, public Writer {
+class ServerConnection : public ConnectionMT, public IServerConnection, public Writer {
 public:
 	ServerConnection(int fd, ev::dynamic_loop &loop, Dispatcher &dispatcher);
 
@@ -29,6 +30,7 @@ public:
 	void WriteRPCReturn(Context &ctx, const Args &args) override final { responceRPC(ctx, errOK, args); }
 	void SetClientData(ClientData::Ptr data) override final { clientData_ = data; }
 	ClientData::Ptr GetClientData() override final { return clientData_; }
+	void CallRPC(CmdCode cmd, const Args &args) override final;
 
 protected:
 	void onRead() override;
@@ -37,6 +39,8 @@ protected:
 	void responceRPC(Context &ctx, const Error &error, const Args &args);
 
 	bool respSent_ = false;
+	// Guards async_ from CallRPC in other threads, while connection is moved to another loop
+	std::mutex asyncMtx_;
 
 	Dispatcher &dispatcher_;
 	ClientData::Ptr clientData_;
@@ -25,6 +25,11 @@ Error UpdatesObservers::Delete(IUpdatesObserver *observer) {
 	return errOK;
 }
 
+bool UpdatesObservers::Empty() {
+	shared_lock<shared_timed_mutex> lck(mtx_);
+	return observers_.empty();
+}
+
 Error UpdatesObservers::OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode) {
 	shared_lock<shared_timed_mutex> lck(mtx_);
 	for (unsigned i = 0; i < observers_.size(); i++) {
@@ -23,6 +23,7 @@ class UpdatesObservers {
 public:
 	Error Add(IUpdatesObserver *observer);
 	Error Delete(IUpdatesObserver *observer);
+	bool Empty();
 
 	Error OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode);
 	Error OnNewNamespace(const string &nsName);
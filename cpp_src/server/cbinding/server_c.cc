@@ -59,6 +59,25 @@ reindexer_error get_reindexer_instance(reindexer_string dbname, reindexer_string
 	return error2c(err);
 }
 
+reindexer_error create_reindexer_database(reindexer_string dbname, reindexer_string user, reindexer_string pass) {
+	Error err = err_not_init;
+	if (check_server_ready()) {
+		AuthContext ctx(str2c(user), str2c(pass));
+		err = svc->GetDBManager().OpenDatabase(str2c(dbname), ctx, true);
+	}
+	return error2c(err);
+}
+
+reindexer_error drop_reindexer_database(reindexer_string dbname, reindexer_string user, reindexer_string pass) {
+	Error err = err_not_init;
+	if (check_server_ready()) {
+		AuthContext ctx(str2c(user), str2c(pass));
+		err = svc->GetDBManager().OpenDatabase(str2c(dbname), ctx, false);
+		if (err.ok()) err = svc->GetDBManager().DropDatabase(ctx);
+	}
+	return error2c(err);
+}
+
 reindexer_error stop_reindexer_server() {
 	Error err = err_not_init;
 	if (svc) {
@@ -11,6 +11,8 @@ void destroy_reindexer_server();
 reindexer_error start_reindexer_server(reindexer_string config);
 reindexer_error stop_reindexer_server();
 reindexer_error get_reindexer_instance(reindexer_string dbname, reindexer_string user, reindexer_string pass, uintptr_t* rx);
+reindexer_error create_reindexer_database(reindexer_string dbname, reindexer_string user, reindexer_string pass);
+reindexer_error drop_reindexer_database(reindexer_string dbname, reindexer_string user, reindexer_string pass);
 int check_server_ready();
 
 #ifdef __cplusplus
@@ -1,10 +1,12 @@
 #include "rpcserver.h"
 #include <sys/stat.h>
 #include <sstream>
+#include "core/itemimpl.h"
 #include "net/cproto/cproto.h"
 #include "net/cproto/serverconnection.h"
 #include "net/listener.h"
 #include "reindexer_version.h"
+#include "tools/stringstools.h"
 
 namespace reindexer_server {
 
@@ -54,6 +56,10 @@ Error RPCServer::OpenDatabase(cproto::Context &ctx, p_string db) {
 
 Error RPCServer::CloseDatabase(cproto::Context &ctx) {
 	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	if (clientData->subscribedDB) {
+		subscribeUpdates(clientData->subscribedDB, ctx.writer, false);
+		clientData->subscribedDB.reset();
+	}
 	clientData->auth.ResetDB();
 	return 0;
 }
@@ -77,9 +83,13 @@ Error RPCServer::CheckAuth(cproto::Context &ctx) {
 }
 
 void RPCServer::OnClose(cproto::Context &ctx, const Error &err) {
-	(void)ctx;
 	(void)err;
 
+	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	if (clientData && clientData->subscribedDB) {
+		subscribeUpdates(clientData->subscribedDB, ctx.writer, false);
+		clientData->subscribedDB.reset();
+	}
 	logger_.info("RPC: Client disconnected");
 }
 
@@ -182,7 +192,7 @@ Error RPCServer::DropIndex(cproto::Context &ctx, p_string ns, p_string index) {
 }
 
 Error RPCServer::ModifyItem(cproto::Context &ctx, p_string nsName, int format, p_string itemData, int mode, p_string perceptsPack,
-							int stateToken, int /*txID*/) {
+							int stateToken, int txID) {
 	auto db = getDB(ctx, kRoleDataWrite);
 	string ns = nsName.toString();
 	auto item = Item(db->NewItem(ns));
@@ -192,16 +202,18 @@ Error RPCServer::ModifyItem(cproto::Context &ctx, p_string nsName, int format, p
 		return item.Status();
 	}
 
+	// Item of transaction is applied later, so it must keep copy of data
+	item.Unsafe(!txID);
 	switch (format) {
 		case FormatJson:
-			err = item.Unsafe().FromJSON(itemData, nullptr, mode == ModeDelete);
+			err = item.FromJSON(itemData, nullptr, mode == ModeDelete);
 			break;
 		case FormatCJson:
 			if (item.GetStateToken() != stateToken) {
 				err = Error(errStateInvalidated, "stateToken mismatch:  %08X, need %08X. Can't process item", stateToken,
 							item.GetStateToken());
 			} else {
-				err = item.Unsafe().FromCJSON(itemData, mode == ModeDelete);
+				err = item.FromCJSON(itemData, mode == ModeDelete);
 			}
 			break;
 		default:
@@ -222,6 +234,22 @@ Error RPCServer::ModifyItem(cproto::Context &ctx, p_string nsName, int format, p
 		}
 		item.SetPrecepts(precepts);
 	}
+	if (txID) {
+		auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+		auto it = clientData->txs.find(txID);
+		if (it == clientData->txs.end()) {
+			return Error(errParams, "Transaction %d not found", txID);
+		}
+		Transaction &tx = it->second;
+		if (!iequals(tx.GetName(), ns)) {
+			return Error(errParams, "Item of namespace '%s' can't be added to transaction of namespace '%s'", ns.c_str(),
+						 tx.GetName().c_str());
+		}
+		tx.Modify(std::move(item), mode);
+		// Item of transaction is not applied yet, so results are empty
+		QueryResults qres;
+		return sendResults(ctx, qres, -1, ResultFetchOpts{kResultsWithItemID, {}, 0, INT_MAX});
+	}
 	switch (mode) {
 		case ModeUpsert:
 			err = db->Upsert(ns, item);
@@ -255,7 +283,11 @@ Error RPCServer::ModifyItem(cproto::Context &ctx, p_string nsName, int format, p
 Error RPCServer::DeleteQuery(cproto::Context &ctx, p_string queryBin) {
 	Query query;
 	Serializer ser(queryBin.data(), queryBin.size());
-	query.Deserialize(ser);
+	try {
+		query.Deserialize(ser);
+	} catch (const Error &err) {
+		return err;
+	}
 
 	QueryResults qres;
 	auto err = getDB(ctx, kRoleDataWrite)->Delete(query, qres);
@@ -266,6 +298,72 @@ Error RPCServer::DeleteQuery(cproto::Context &ctx, p_string queryBin) {
 	return sendResults(ctx, qres, -1, opts);
 }
 
+Error RPCServer::UpdateQuery(cproto::Context &ctx, p_string queryBin) {
+	Query query;
+	Serializer ser(queryBin.data(), queryBin.size());
+	try {
+		query.Deserialize(ser);
+	} catch (const Error &err) {
+		return err;
+	}
+
+	QueryResults qres;
+	auto err = getDB(ctx, kRoleDataWrite)->Update(query, qres);
+	if (!err.ok()) {
+		return err;
+	}
+	ResultFetchOpts opts{0, {}, 0, INT_MAX};
+	return sendResults(ctx, qres, -1, opts);
+}
+
+static std::atomic<int> txCounter;
+
+Error RPCServer::StartTransaction(cproto::Context &ctx, p_string nsName) {
+	auto db = getDB(ctx, kRoleDataWrite);
+	string ns = nsName.toString();
+	auto item = db->NewItem(ns);
+	if (!item.Status().ok()) {
+		return item.Status();
+	}
+
+	// Transaction ids are unique for server, so client can find connection of transaction by its id
+	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	int txID = ++txCounter;
+	if (txID <= 0) txID = txCounter = 1;
+	clientData->txs.emplace(txID, Transaction(ns));
+	ctx.Return({cproto::Arg(txID)});
+	return errOK;
+}
+
+Error RPCServer::CommitTx(cproto::Context &ctx, int txID) {
+	auto db = getDB(ctx, kRoleDataWrite);
+	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	auto it = clientData->txs.find(txID);
+	if (it == clientData->txs.end()) {
+		return Error(errParams, "Transaction %d not found", txID);
+	}
+	Transaction tx = std::move(it->second);
+	clientData->txs.erase(it);
+
+	QueryResults qres;
+	auto err = db->CommitTransaction(tx, qres);
+	if (!err.ok()) {
+		return err;
+	}
+	// Items of transaction could update tags of namespace, so payload type is always returned
+	int32_t ptVers = -1;
+	ResultFetchOpts opts{kResultsWithItemID | kResultsWithPayloadTypes, span<int32_t>(&ptVers, 1), 0, INT_MAX};
+	return sendResults(ctx, qres, -1, opts);
+}
+
+Error RPCServer::RollbackTx(cproto::Context &ctx, int txID) {
+	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	if (!clientData->txs.erase(txID)) {
+		return Error(errParams, "Transaction %d not found", txID);
+	}
+	return errOK;
+}
+
 shared_ptr<Reindexer> RPCServer::getDB(cproto::Context &ctx, UserRole role) {
 	auto clientData = ctx.GetClientData().get();
 	if (clientData) {
@@ -336,7 +434,11 @@ static h_vector<int32_t, 4> pack2vec(p_string pack) {
 Error RPCServer::Select(cproto::Context &ctx, p_string queryBin, int flags, int limit, p_string ptVersionsPck) {
 	Query query;
 	Serializer ser(queryBin);
-	query.Deserialize(ser);
+	try {
+		query.Deserialize(ser);
+	} catch (const Error &err) {
+		return err;
+	}
 
 	int id = -1;
 	QueryResults &qres = getQueryResults(ctx, id);
@@ -420,8 +522,54 @@ Error RPCServer::EnumMeta(cproto::Context &ctx, p_string ns) {
 }
 
 Error RPCServer::SubscribeUpdates(cproto::Context &ctx, int flag) {
-	//
-	return getDB(ctx, kRoleDataRead)->SubscribeUpdates(this, flag);
+	auto clientData = dynamic_cast<RPCClientData *>(ctx.GetClientData().get());
+	auto db = getDB(ctx, kRoleDataRead);
+	if (clientData->subscribedDB) {
+		if (flag) return errOK;
+		auto err = subscribeUpdates(clientData->subscribedDB, ctx.writer, false);
+		clientData->subscribedDB.reset();
+		return err;
+	}
+	if (!flag) return errOK;
+	auto err = subscribeUpdates(db, ctx.writer, true);
+	if (err.ok()) clientData->subscribedDB = db;
+	return err;
+}
+
+Error RPCServer::subscribeUpdates(const shared_ptr<Reindexer> &db, cproto::Writer *writer, bool subscribe) {
+	std::lock_guard<std::mutex> lck(pushersMtx_);
+	auto &pusher = pushers_[db.get()];
+	if (!pusher) pusher.reset(new RPCUpdatesPusher);
+	if (!subscribe) {
+		return pusher->Delete(writer) ? db->SubscribeUpdates(pusher.get(), false) : errOK;
+	}
+	if (!pusher->Add(writer)) return errOK;
+	auto err = db->SubscribeUpdates(pusher.get(), true);
+	if (!err.ok()) pusher->Delete(writer);
+	return err;
+}
+
+bool RPCUpdatesPusher::Add(cproto::Writer *writer) {
+	std::lock_guard<std::mutex> lck(mtx_);
+	writers_.push_back(writer);
+	return writers_.size() == 1;
+}
+
+bool RPCUpdatesPusher::Delete(cproto::Writer *writer) {
+	std::lock_guard<std::mutex> lck(mtx_);
+	auto it = std::find(writers_.begin(), writers_.end(), writer);
+	if (it == writers_.end()) return false;
+	writers_.erase(it);
+	return writers_.empty();
+}
+
+Error RPCUpdatesPusher::OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode) {
+	string_view json = item->GetJSON();
+	cproto::Args args{cproto::Arg(p_string(&nsName)), cproto::Arg(modifyMode), cproto::Arg(p_string(&json))};
+	// Writer is removed from subscribers on close of connection under the same lock, so it's alive here
+	std::lock_guard<std::mutex> lck(mtx_);
+	for (auto writer : writers_) writer->CallRPC(cproto::kCmdUpdates, args);
+	return errOK;
 }
 
 bool RPCServer::Start(const string &addr, ev::dynamic_loop &loop) {
@@ -442,6 +590,10 @@ bool RPCServer::Start(const string &addr, ev::dynamic_loop &loop) {
 
 	dispatcher.Register(cproto::kCmdModifyItem, this, &RPCServer::ModifyItem);
 	dispatcher.Register(cproto::kCmdDeleteQuery, this, &RPCServer::DeleteQuery);
+	dispatcher.Register(cproto::kCmdUpdateQuery, this, &RPCServer::UpdateQuery);
+	dispatcher.Register(cproto::kCmdStartTransaction, this, &RPCServer::StartTransaction);
+	dispatcher.Register(cproto::kCmdCommitTx, this, &RPCServer::CommitTx);
+	dispatcher.Register(cproto::kCmdRollbackTx, this, &RPCServer::RollbackTx);
 
 	dispatcher.Register(cproto::kCmdSelect, this, &RPCServer::Select);
 	dispatcher.Register(cproto::kCmdSelectSQL, this, &RPCServer::SelectSQL);
@@ -463,40 +615,4 @@ bool RPCServer::Start(const string &addr, ev::dynamic_loop &loop) {
 	return listener_->Bind(addr);
 }
 
-Error RPCServer::OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode) {
-	(void)nsName;
-	(void)item;
-	(void)modifyMode;
-
-	return errOK;
-}
-
-Error RPCServer::OnNewNamespace(const string &nsName) {
-	(void)nsName;
-	return errOK;
-}
-Error RPCServer::OnModifyIndex(const string &nsName, const IndexDef &idx, int modifyMode) {
-	(void)nsName;
-	(void)idx;
-	(void)modifyMode;
-	return errOK;
-}
-
-Error RPCServer::OnDropIndex(const string &nsName, const string &indexName) {
-	(void)nsName;
-	(void)indexName;
-	return errOK;
-}
-
-Error RPCServer::OnDropNamespace(const string &nsName) {
-	(void)nsName;
-	return errOK;
-}
-Error RPCServer::OnPutMeta(const string &nsName, const string &key, const string &data) {
-	(void)nsName;
-	(void)key;
-	(void)data;
-	return errOK;
-}
-
 }  // namespace reindexer_server
@@ -1,6 +1,8 @@
 #pragma once
 
 #include <memory>
+#include <mutex>
+#include <unordered_map>
 #include "core/cbinding/resultserializer.h"
 #include "core/keyvalue/variant.h"
 #include "core/reindexer.h"
@@ -19,11 +21,35 @@ using namespace reindexer;
 
 struct RPCClientData : public cproto::ClientData {
 	h_vector<pair<QueryResults, bool>, 1> results;
+	// Transactions, started by client. Items are added to them by ModifyItem with txID
+	std::unordered_map<int, Transaction> txs;
+	// Database, to which updates client is subscribed
+	shared_ptr<Reindexer> subscribedDB;
 	AuthContext auth;
 	int connID;
 };
 
-class RPCServer : reindexer::IUpdatesObserver {
+// Pushes updates of items of database to subscribed clients
+class RPCUpdatesPusher : public reindexer::IUpdatesObserver {
+public:
+	// Returns true, if writer is the first subscriber
+	bool Add(cproto::Writer *writer);
+	// Returns true, if writer was the last subscriber
+	bool Delete(cproto::Writer *writer);
+
+	Error OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode) override;
+	Error OnNewNamespace(const string &) override { return errOK; }
+	Error OnModifyIndex(const string &, const IndexDef &, int) override { return errOK; }
+	Error OnDropIndex(const string &, const string &) override { return errOK; }
+	Error OnDropNamespace(const string &) override { return errOK; }
+	Error OnPutMeta(const string &, const string &, const string &) override { return errOK; }
+
+protected:
+	std::vector<cproto::Writer *> writers_;
+	std::mutex mtx_;
+};
+
+class RPCServer {
 public:
 	RPCServer(DBManager &dbMgr, LoggerWrapper logger, bool allocDebug = false);
 	~RPCServer();
@@ -51,6 +77,10 @@ public:
 	Error ModifyItem(cproto::Context &ctx, p_string nsName, int format, p_string itemData, int mode, p_string percepsPack, int stateToken,
 					 int txID);
 	Error DeleteQuery(cproto::Context &ctx, p_string query);
+	Error UpdateQuery(cproto::Context &ctx, p_string query);
+	Error StartTransaction(cproto::Context &ctx, p_string nsName);
+	Error CommitTx(cproto::Context &ctx, int txID);
+	Error RollbackTx(cproto::Context &ctx, int txID);
 
 	Error Select(cproto::Context &ctx, p_string query, int flags, int limit, p_string ptVersions);
 	Error SelectSQL(cproto::Context &ctx, p_string query, int flags, int limit, p_string ptVersions);
@@ -66,13 +96,6 @@ public:
 	void Logger(cproto::Context &ctx, const Error &err, const cproto::Args &ret);
 	void OnClose(cproto::Context &ctx, const Error &err);
 
-	Error OnModifyItem(const string &nsName, ItemImpl *item, int modifyMode) override;
-	Error OnNewNamespace(const string &nsName) override;
-	Error OnModifyIndex(const string &nsName, const IndexDef &idx, int modifyMode) override;
-	Error OnDropIndex(const string &nsName, const string &indexName) override;
-	Error OnDropNamespace(const string &nsName) override;
-	Error OnPutMeta(const string &nsName, const string &key, const string &data) override;
-
 protected:
 	Error sendResults(cproto::Context &ctx, QueryResults &qr, int reqId, const ResultFetchOpts &opts);
 	Error fetchResults(cproto::Context &ctx, int reqId, const ResultFetchOpts &opts);
@@ -80,6 +103,7 @@ protected:
 	QueryResults &getQueryResults(cproto::Context &ctx, int &id);
 
 	shared_ptr<Reindexer> getDB(cproto::Context &ctx, UserRole role);
+	Error subscribeUpdates(const shared_ptr<Reindexer> &db, cproto::Writer *writer, bool subscribe);
 
 	DBManager &dbMgr_;
 	cproto::Dispatcher dispatcher;
@@ -89,6 +113,10 @@ protected:
 	bool allocDebug_;
 
 	std::chrono::system_clock::time_point startTs_;
+
+	// Pushers of updates by databases. Pushers are deleted only with server, because db can call pusher concurrently with unsubscribe
+	std::unordered_map<Reindexer *, std::unique_ptr<RPCUpdatesPusher>> pushers_;
+	std::mutex pushersMtx_;
 };
 
 }  // namespace reindexer_server
@@ -35,6 +35,23 @@ func GetCondType(name string) (int, error) {
 	}
 }
 
+// Map from aggregation name to aggregation type
+var aggTypes = map[string]int{
+	"SUM":   AggSum,
+	"AVG":   AggAvg,
+	"FACET": AggFacet,
+	"MIN":   AggMin,
+	"MAX":   AggMax,
+}
+
+func GetAggType(name string) (int, error) {
+	aggType, ok := aggTypes[strings.ToUpper(name)]
+	if !ok {
+		return 0, ErrAggType
+	}
+	return aggType, nil
+}
+
 // Map from index type to cond name
 var queryNames = map[int]string{
 	EQ:    "EQ",
@@ -175,3 +192,33 @@ func (db *Reindexer) GetNamespaceMemStat(namespace string) (*NamespaceMemStat, e
 	}
 	return desc.(*NamespaceMemStat), nil
 }
+
+// GetNamespacesPerfStat makes a 'SELECT * FROM #perfstats' query to database.
+// Return NamespacePerfStat results, error
+func (db *Reindexer) GetNamespacesPerfStat() ([]*NamespacePerfStat, error) {
+	result := []*NamespacePerfStat{}
+
+	descs, err := db.Query(PerfstatsNamespaceName).Exec().FetchAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range descs {
+		nsdesc, ok := desc.(*NamespacePerfStat)
+		if ok {
+			result = append(result, nsdesc)
+		}
+	}
+
+	return result, nil
+}
+
+// GetNamespacePerfStat makes a 'SELECT * FROM #perfstats' query to database.
+// Return NamespacePerfStat results, error
+func (db *Reindexer) GetNamespacePerfStat(namespace string) (*NamespacePerfStat, error) {
+	desc, err := db.Query(PerfstatsNamespaceName).Where("name", EQ, namespace).Exec().FetchOne()
+	if err != nil {
+		return nil, err
+	}
+	return desc.(*NamespacePerfStat), nil
+}
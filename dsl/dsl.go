@@ -10,13 +10,29 @@ import (
 )
 
 type DSL struct {
-	Namespace string   `json:"namespace"`
-	Offset    int      `json:"offset"`
-	Limit     int      `json:"limit"`
-	Distinct  string   `json:"distinct"`
-	Sort      Sort     `json:"sort"`
-	Filters   []Filter `json:"filters"`
-	Explain   bool     `json:"explain,omitempty"`
+	Namespace    string        `json:"namespace"`
+	Offset       int           `json:"offset"`
+	Limit        int           `json:"limit"`
+	Distinct     string        `json:"distinct"`
+	Sort         Sort          `json:"sort"`
+	Filters      []Filter      `json:"filters"`
+	Aggregations []Aggregation `json:"aggregations,omitempty"`
+	Explain      bool          `json:"explain,omitempty"`
+}
+
+// Aggregation is aggregation of fields. Only facet aggregation can have several fields, sort, limit and offset
+type Aggregation struct {
+	Fields []string          `json:"fields"`
+	Type   string            `json:"type"`
+	Sort   []AggregationSort `json:"sort,omitempty"`
+	Limit  int               `json:"limit,omitempty"`
+	Offset int               `json:"offset,omitempty"`
+}
+
+// AggregationSort is sort order of facets by one of aggregation fields, or by "count"
+type AggregationSort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
 }
 
 type sort Sort
@@ -27,18 +43,21 @@ type Sort struct {
 	Values []interface{} `json:"values,omitempty"`
 }
 
+// Filter is single condition, or group of conditions in brackets, if Filters is not empty
 type Filter struct {
-	Op    string
-	Field string
-	Cond  string
-	Value interface{}
+	Op      string
+	Field   string
+	Cond    string
+	Value   interface{}
+	Filters []Filter
 }
 
 type filter struct {
-	Op    string `json:"op"`
-	Field string `json:"field"`
-	Cond  string `json:"cond"`
-	Value value  `json:"value"`
+	Op      string   `json:"op"`
+	Field   string   `json:"field"`
+	Cond    string   `json:"cond"`
+	Value   value    `json:"value"`
+	Filters []Filter `json:"filters"`
 }
 
 type value struct {
@@ -89,6 +108,11 @@ func (f *Filter) UnmarshalJSON(data []byte) error {
 	f.Op = flt.Op
 	f.Cond = flt.Cond
 	f.Field = flt.Field
+	f.Filters = flt.Filters
+
+	if len(f.Filters) != 0 {
+		return nil
+	}
 
 	return f.ParseValue(flt.Value.data)
 }
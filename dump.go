@@ -0,0 +1,211 @@
+package reindexer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+const (
+	// dumpFormatVersion is version of dump format, written to namespace record of dump
+	dumpFormatVersion = 1
+	// dumpBatchSize is count of items, which are read from namespace by one query on dump, and committed at once on restore
+	dumpBatchSize = 10000
+)
+
+// Types of dump records
+const (
+	dumpRecordNamespace = "namespace"
+	dumpRecordMeta      = "meta"
+	dumpRecordItem      = "item"
+)
+
+// dumpRecord is one line of namespace dump. Dump of namespace starts with namespace record with indexes definitions,
+// followed by meta records and item records. Dumps of several namespaces can be concatenated
+type dumpRecord struct {
+	Type      string                 `json:"type"`
+	Version   int                    `json:"version,omitempty"`
+	Namespace *bindings.NamespaceDef `json:"namespace,omitempty"`
+	Key       string                 `json:"key,omitempty"`
+	Value     []byte                 `json:"value,omitempty"`
+	Item      json.RawMessage        `json:"item,omitempty"`
+}
+
+// DumpNamespace writes definition of namespace with its indexes, all meta and all items to w in JSON lines format.
+// Namespace is not required to be opened by this client. If namespace is modified concurrently, dump is not consistent snapshot
+func (db *Reindexer) DumpNamespace(namespace string, w io.Writer) error {
+	return db.DumpNamespaceCtx(context.Background(), namespace, w)
+}
+
+// DumpNamespaceCtx writes dump of namespace to w
+// The ctx can be used to cancel or limit by deadline the dump
+func (db *Reindexer) DumpNamespaceCtx(ctx context.Context, namespace string, w io.Writer) error {
+	desc, err := db.DescribeNamespace(strings.ToLower(namespace))
+	if err != nil {
+		return err
+	}
+
+	def := &bindings.NamespaceDef{Namespace: desc.Name, Indexes: make([]bindings.IndexDef, 0, len(desc.Indexes))}
+	def.StorageOpts.EnableStorage = desc.StorageEnabled
+	for _, idx := range desc.Indexes {
+		def.Indexes = append(def.Indexes, bindings.IndexDef(idx.IndexDef))
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	if err = enc.Encode(&dumpRecord{Type: dumpRecordNamespace, Version: dumpFormatVersion, Namespace: def}); err != nil {
+		return err
+	}
+
+	keys, err := db.binding.EnumMeta(ctx, def.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, err := db.GetMetaCtx(ctx, def.Namespace, key)
+		if err != nil {
+			return err
+		}
+		if err = enc.Encode(&dumpRecord{Type: dumpRecordMeta, Key: key, Value: value}); err != nil {
+			return err
+		}
+	}
+
+	for offset := 0; ; offset += dumpBatchSize {
+		items, err := db.selectJSONItems(ctx, def.Namespace, offset, dumpBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err = enc.Encode(&dumpRecord{Type: dumpRecordItem, Item: item}); err != nil {
+				return err
+			}
+		}
+		if len(items) < dumpBatchSize {
+			break
+		}
+	}
+
+	return bw.Flush()
+}
+
+// selectJSONItems selects items of namespace in JSON format. Namespace is not required to be registered by this client
+func (db *Reindexer) selectJSONItems(ctx context.Context, namespace string, offset, limit int) ([]json.RawMessage, error) {
+	q := newQuery(db, namespace).Offset(offset).Limit(limit)
+	defer q.close()
+	q.ser.PutVarCUInt(queryEnd)
+
+	result, err := db.binding.SelectQuery(ctx, q.ser.Bytes(), true, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Free()
+
+	ser := newSerializer(result.GetBuf())
+	params := ser.readRawQueryParams()
+	items := make([]json.RawMessage, 0, params.count)
+	for i := 0; i < params.count; i++ {
+		item := ser.readRawtItemParams()
+		items = append(items, append(json.RawMessage(nil), item.data...))
+	}
+	return items, nil
+}
+
+// RestoreNamespace reads dump, written by DumpNamespace, and recreates namespace, its indexes, meta and items.
+// Existing namespace is not dropped: items from dump are upserted to it. If there are dumps of several namespaces in r, all of them are restored
+func (db *Reindexer) RestoreNamespace(r io.Reader) error {
+	return db.RestoreNamespaceCtx(context.Background(), r)
+}
+
+// RestoreNamespaceCtx restores namespaces from dump
+// The ctx can be used to cancel or limit by deadline the restore
+func (db *Reindexer) RestoreNamespaceCtx(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	namespace := ""
+	nsHash := 0
+	batch := 0
+
+	for {
+		var rec dumpRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if rec.Type != dumpRecordNamespace && namespace == "" {
+			return fmt.Errorf("rq: Invalid namespace dump: '%s' record before namespace record", rec.Type)
+		}
+
+		switch rec.Type {
+		case dumpRecordNamespace:
+			if rec.Version > dumpFormatVersion {
+				return fmt.Errorf("rq: Invalid namespace dump: unsupported version %d", rec.Version)
+			}
+			if rec.Namespace == nil {
+				return fmt.Errorf("rq: Invalid namespace dump: namespace record without namespace definition")
+			}
+			if namespace != "" {
+				if err := db.binding.Commit(ctx, namespace); err != nil {
+					return err
+				}
+			}
+			namespace, batch = strings.ToLower(rec.Namespace.Namespace), 0
+			if err := db.restoreNamespaceDef(ctx, rec.Namespace); err != nil {
+				return err
+			}
+			// Items of namespace, opened by this client, must be modified with its hash
+			if ns, err := db.getNS(namespace); err == nil {
+				nsHash = ns.nsHash
+			} else {
+				nsHash = 0
+			}
+		case dumpRecordMeta:
+			if err := db.binding.PutMeta(ctx, namespace, rec.Key, string(rec.Value)); err != nil {
+				return err
+			}
+		case dumpRecordItem:
+			out, err := db.binding.ModifyItem(ctx, nsHash, namespace, bindings.FormatJson, rec.Item, modeUpsert, nil, 0, 0)
+			if err != nil {
+				return err
+			}
+			out.Free()
+			if batch++; batch == dumpBatchSize {
+				if err = db.binding.Commit(ctx, namespace); err != nil {
+					return err
+				}
+				batch = 0
+			}
+		default:
+			return fmt.Errorf("rq: Invalid namespace dump: unknown record type '%s'", rec.Type)
+		}
+	}
+
+	if namespace == "" {
+		return nil
+	}
+	return db.binding.Commit(ctx, namespace)
+}
+
+func (db *Reindexer) restoreNamespaceDef(ctx context.Context, def *bindings.NamespaceDef) error {
+	cacheMode := uint8(bindings.CacheModeOn)
+	if ns, err := db.getNS(def.Namespace); err == nil {
+		cacheMode = ns.opts.cachedMode
+	}
+	if err := db.binding.OpenNamespace(ctx, def.Namespace, def.StorageOpts.EnableStorage, false, cacheMode); err != nil {
+		return err
+	}
+	for _, indexDef := range def.Indexes {
+		if err := db.binding.AddIndex(ctx, def.Namespace, indexDef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
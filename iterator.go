@@ -1,9 +1,13 @@
 package reindexer
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/restream/reindexer/bindings"
@@ -36,6 +40,7 @@ func errJSONIterator(err error) *JSONIterator {
 }
 
 func newIterator(
+	ctx context.Context,
 	q *Query,
 	result bindings.RawBuffer,
 	nsArray []nsArrayEntry,
@@ -49,6 +54,7 @@ func newIterator(
 	} else {
 		it = &Iterator{}
 	}
+	it.ctx = ctx
 	it.nsArray = nsArray
 	it.joinToFields = joinToFields
 	it.joinHandlers = joinHandlers
@@ -64,25 +70,31 @@ func newIterator(
 	return
 }
 
-func newJSONIterator(q *Query, json []byte, jsonOffsets []int, explain []byte) *JSONIterator {
+func newJSONIterator(ctx context.Context, q *Query, result bindings.RawBuffer, jsonRoot string, totalName string, nsNames []string) *JSONIterator {
 	var ji *JSONIterator
 	if q != nil {
 		ji = &q.jsonIterator
 	} else {
 		ji = &JSONIterator{}
 	}
-	ji.json = json
-	ji.jsonOffsets = jsonOffsets
-	ji.ptr = -1
+	ji.ctx = ctx
 	ji.query = q
-	ji.explain = explain
+	ji.jsonRoot = jsonRoot
+	ji.totalName = totalName
+	ji.nsNames = nsNames
+	ji.resPtr = 0
+	ji.ptr = 0
+	ji.current = nil
 	ji.err = nil
+	ji.setBuffer(result)
+	ji.explain = ji.rawQueryParams.explainResults
 
 	return ji
 }
 
 // Iterator presents query results
 type Iterator struct {
+	ctx            context.Context
 	ser            resultSerializer
 	rawQueryParams rawResultQueryParams
 	result         bindings.RawBuffer
@@ -113,13 +125,18 @@ func (it *Iterator) setBuffer(result bindings.RawBuffer) {
 // Next moves iterator pointer to the next element.
 // Returns bool, that indicates the availability of the next elements.
 func (it *Iterator) Next() (hasNext bool) {
+	return it.next(nil)
+}
+
+// next moves iterator pointer to the next element, and decodes it into dest, if it's not nil
+func (it *Iterator) next(dest interface{}) (hasNext bool) {
 	if it.ptr >= it.rawQueryParams.qcount || it.err != nil {
 		return
 	}
 	if it.needMore() {
 		it.fetchResults()
 	}
-	it.current.obj, it.current.rank = it.readItem()
+	it.current.obj, it.current.rank = it.readItem(dest)
 	if it.err != nil {
 		return
 	}
@@ -129,18 +146,10 @@ func (it *Iterator) Next() (hasNext bool) {
 }
 
 func (it *Iterator) joinedNsIndexOffset(parentNsID int) int {
-	if it.query == nil {
-		return 1
-	}
-
-	offset := 1 + len(it.query.mergedQueries)
-	for m := 0; m < parentNsID; m++ {
-		offset += len(it.query.mergedQueries[m].joinQueries)
-	}
-	return offset
+	return it.query.joinedNsIndexOffset(parentNsID)
 }
 
-func (it *Iterator) readItem() (item interface{}, rank int) {
+func (it *Iterator) readItem(dest interface{}) (item interface{}, rank int) {
 	params := it.ser.readRawtItemParams()
 	if (it.rawQueryParams.flags & bindings.ResultsWithPercents) != 0 {
 		rank = params.proc
@@ -151,7 +160,11 @@ func (it *Iterator) readItem() (item interface{}, rank int) {
 	if (it.rawQueryParams.flags & bindings.ResultsWithJoined) != 0 {
 		subNSRes = int(it.ser.GetVarUInt())
 	}
-	item, it.err = unpackItem(&it.nsArray[params.nsid], &params, it.allowUnsafe && (subNSRes == 0), (it.rawQueryParams.flags&bindings.ResultsWithItemID) == 0)
+	ns := &it.nsArray[params.nsid]
+	if dest != nil && reflect.TypeOf(dest).Elem() != ns.rtype {
+		dest = nil
+	}
+	item, it.err = unpackItem(ns, &params, it.allowUnsafe && (subNSRes == 0), (it.rawQueryParams.flags&bindings.ResultsWithItemID) == 0, dest)
 	if it.err != nil {
 		return
 	}
@@ -166,7 +179,7 @@ func (it *Iterator) readItem() (item interface{}, rank int) {
 		subitems := make([]interface{}, siRes)
 		for i := 0; i < siRes; i++ {
 			subparams := it.ser.readRawtItemParams()
-			subitems[i], it.err = unpackItem(&it.nsArray[nsIndex+nsIndexOffset], &subparams, it.allowUnsafe, (it.rawQueryParams.flags&bindings.ResultsWithItemID) == 0)
+			subitems[i], it.err = unpackItem(&it.nsArray[nsIndex+nsIndexOffset], &subparams, it.allowUnsafe, (it.rawQueryParams.flags&bindings.ResultsWithItemID) == 0, nil)
 			if it.err != nil {
 				return
 			}
@@ -191,7 +204,7 @@ func (it *Iterator) fetchResults() {
 			fetchCount = it.query.fetchCount
 		}
 
-		if it.err = fetchMore.Fetch(it.ptr, fetchCount, false); it.err != nil {
+		if it.err = fetchMore.Fetch(it.ctx, it.ptr, fetchCount, false); it.err != nil {
 			return
 		}
 		it.resPtr = 0
@@ -215,6 +228,10 @@ func (it *Iterator) join(nsIndex, nsIndexOffset, parentNsID int, item interface{
 
 	if joinable, ok := item.(Joinable); ok {
 		joinable.Join(field, subitems, it.queryContext)
+	} else if m, ok := item.(map[string]interface{}); ok {
+		// Item of dynamic namespace
+		joined, _ := m[field].([]interface{})
+		m[field] = append(joined, subitems...)
 	} else {
 
 		v := getJoinedField(reflect.ValueOf(item), it.nsArray[parentNsID].joined, field)
@@ -300,6 +317,59 @@ func (it *Iterator) FetchAll() (items []interface{}, err error) {
 	return
 }
 
+// FetchAllInto decodes all query results into dst and closes the iterator. dst must be pointer to slice
+// of items of namespace type, e.g. *[]*Item or *[]Item. ErrWrongType is returned, if type of result item does not match type of slice elements
+func (it *Iterator) FetchAllInto(dst interface{}) error {
+	defer it.Close()
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr {
+		return ErrMustBePointer
+	}
+	if v = v.Elem(); v.Kind() != reflect.Slice {
+		return ErrWrongType
+	}
+	if it.err != nil {
+		return it.err
+	}
+	items := reflect.MakeSlice(v.Type(), it.rawQueryParams.qcount, it.rawQueryParams.qcount)
+	for i := 0; i < items.Len(); i++ {
+		if !it.nextInto(items.Index(i)) {
+			if it.err != nil {
+				return it.err
+			}
+			items = items.Slice(0, i)
+			break
+		}
+	}
+	v.Set(items)
+	return nil
+}
+
+// nextInto moves iterator pointer to the next element, and stores it to v, which is item or pointer to item
+func (it *Iterator) nextInto(v reflect.Value) bool {
+	var dest interface{}
+	if v.Kind() != reflect.Ptr {
+		dest = v.Addr().Interface()
+	}
+	if !it.next(dest) {
+		return false
+	}
+	obj := reflect.ValueOf(it.current.obj)
+	switch {
+	case obj.Type() == v.Type():
+		v.Set(obj)
+	case obj.Kind() == reflect.Ptr && obj.Type().Elem() == v.Type():
+		// Item is not decoded into v, if it's returned from object cache
+		if obj.Pointer() != v.Addr().Pointer() {
+			v.Set(obj.Elem())
+		}
+	default:
+		it.err = ErrWrongType
+		return false
+	}
+	return true
+}
+
 // FetchOne returns first element and closes the iterator.
 // When it's impossible (count is 0) err will be ErrNotFound.
 func (it *Iterator) FetchOne() (item interface{}, err error) {
@@ -347,6 +417,15 @@ func (it *Iterator) AggResults() (v []AggregationResult) {
 
 	for i := 0; i < l; i++ {
 		json.Unmarshal(it.rawQueryParams.aggResults[i], &v[i])
+		// Single field aggregations can be returned without fields and values lists
+		if len(v[i].Fields) == 0 && v[i].Field != "" {
+			v[i].Fields = []string{v[i].Field}
+		}
+		for j := range v[i].Facets {
+			if len(v[i].Facets[j].Values) == 0 {
+				v[i].Facets[j].Values = []string{v[i].Facets[j].Value}
+			}
+		}
 	}
 
 	return
@@ -401,42 +480,159 @@ func (it *Iterator) findJoinFieldIndex(field string) (index int) {
 	return -1
 }
 
-// JSONIterator its iterator, but results presents as json documents
+// JSONIterator its iterator, but results presents as json documents.
+// Results are fetched from server by pages of FetchCount items, as by Iterator
 type JSONIterator struct {
-	json        []byte
-	jsonOffsets []int
-	query       *Query
-	err         error
-	ptr         int
-	explain     []byte
+	ctx            context.Context
+	ser            resultSerializer
+	rawQueryParams rawResultQueryParams
+	result         bindings.RawBuffer
+	query          *Query
+	jsonRoot       string
+	totalName      string
+	// nsNames are names of namespaces of query in order of nsid: main, merged, joined to main, joined to merged
+	nsNames []string
+	resPtr  int
+	ptr     int
+	current []byte
+	// buf is JSON of current item with embedded joined items
+	buf     []byte
+	err     error
+	explain []byte
+}
+
+func (it *JSONIterator) setBuffer(result bindings.RawBuffer) {
+	it.ser = newSerializer(result.GetBuf())
+	it.result = result
+	it.rawQueryParams = it.ser.readRawQueryParams()
 }
 
 // Next moves iterator pointer to the next element.
 // Returns bool, that indicates the availability of the next elements.
 func (it *JSONIterator) Next() bool {
+	if it.ptr >= it.rawQueryParams.qcount || it.err != nil {
+		return false
+	}
+	if it.resPtr >= it.rawQueryParams.count {
+		if it.fetchResults(); it.err != nil {
+			return false
+		}
+	}
+	item := it.ser.readRawtItemParams()
+	it.current = item.data
+	if (it.rawQueryParams.flags & bindings.ResultsWithJoined) != 0 {
+		it.current = it.readJoined(&item)
+	}
+	it.resPtr++
 	it.ptr++
-	return it.ptr < len(it.jsonOffsets)
+	return true
+}
+
+// readJoined reads joined items of item, and returns JSON of item with joined items, embedded as "joined_<ns>" fields
+func (it *JSONIterator) readJoined(item *rawResultItemParams) []byte {
+	subNSRes := int(it.ser.GetVarUInt())
+	nsIndexOffset := it.query.joinedNsIndexOffset(item.nsid)
+	json, embedded := item.data, false
+
+	for nsIndex := 0; nsIndex < subNSRes; nsIndex++ {
+		siRes := int(it.ser.GetVarUInt())
+		if siRes == 0 {
+			continue
+		}
+		if !embedded {
+			// Copy item without closing brace of object, and append joined items as fields
+			json = append(it.buf[:0], item.data[:len(item.data)-1]...)
+			if len(json) > 1 {
+				json = append(json, ',')
+			}
+			embedded = true
+		} else {
+			json = append(json, ',')
+		}
+		json = append(json, "\"joined_"...)
+		if nsIndex+nsIndexOffset < len(it.nsNames) {
+			json = append(json, it.nsNames[nsIndex+nsIndexOffset]...)
+		} else {
+			json = strconv.AppendInt(json, int64(nsIndex), 10)
+		}
+		json = append(json, "\":["...)
+		for i := 0; i < siRes; i++ {
+			if i != 0 {
+				json = append(json, ',')
+			}
+			json = append(json, it.ser.readRawtItemParams().data...)
+		}
+		json = append(json, ']')
+	}
+	if embedded {
+		json = append(json, '}')
+		it.buf = json
+	}
+	return json
+}
+
+func (it *JSONIterator) fetchResults() {
+	if fetchMore, ok := it.result.(bindings.FetchMore); ok {
+		fetchCount := defaultFetchCount
+		if it.query != nil {
+			fetchCount = it.query.fetchCount
+		}
+
+		if it.err = fetchMore.Fetch(it.ctx, it.ptr, fetchCount, true); it.err != nil {
+			return
+		}
+		it.resPtr = 0
+		it.setBuffer(it.result)
+	} else {
+		panic(fmt.Errorf("unexpected behavior: have the partial query but binding not support that"))
+	}
 }
 
 // FetchAll returns bytes slice it's JSON array with results
 func (it *JSONIterator) FetchAll() (json []byte, err error) {
 	defer it.Close()
-	return it.json, it.err
+	buf := &bytes.Buffer{}
+	if err = it.writeTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-// JSON returns JSON bytes with current document
+// writeTo writes JSON object with results array (and total count, if it's requested) to w
+func (it *JSONIterator) writeTo(w io.Writer) (err error) {
+	if it.err != nil {
+		return it.err
+	}
+	head := "{\""
+	if len(it.totalName) != 0 && it.rawQueryParams.totalcount != 0 {
+		head += it.totalName + "\":" + strconv.Itoa(it.rawQueryParams.totalcount) + ",\""
+	}
+	if _, err = io.WriteString(w, head+it.jsonRoot+"\":["); err != nil {
+		return err
+	}
+	for i := 0; it.Next(); i++ {
+		if i != 0 {
+			if _, err = io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err = w.Write(it.current); err != nil {
+			return err
+		}
+	}
+	if it.err != nil {
+		return it.err
+	}
+	_, err = io.WriteString(w, "]}")
+	return err
+}
+
+// JSON returns JSON bytes with current document. Bytes are valid until the next call of Next or Close
 func (it *JSONIterator) JSON() (json []byte) {
-	if it.ptr < 0 {
+	if it.ptr == 0 {
 		panic(errIteratorNotReady)
 	}
-	o := it.jsonOffsets[it.ptr]
-	l := 0
-	if it.ptr+1 < len(it.jsonOffsets) {
-		l = it.jsonOffsets[it.ptr+1] - 1
-	} else {
-		l = len(it.json) - 2
-	}
-	return it.json[o:l]
+	return it.current
 }
 
 // GetExplainResults returns JSON bytes with explain results
@@ -453,7 +649,12 @@ func (it *JSONIterator) GetExplainResults() (*ExplainResults, error) {
 
 // Count returns count if query results
 func (it *JSONIterator) Count() int {
-	return len(it.jsonOffsets)
+	return it.rawQueryParams.qcount
+}
+
+// TotalCount returns total count of objects (ignoring conditions of limit and offset)
+func (it *JSONIterator) TotalCount() int {
+	return it.rawQueryParams.totalcount
 }
 
 // Error returns query error if it's present.
@@ -461,8 +662,12 @@ func (it *JSONIterator) Error() error {
 	return it.err
 }
 
-// Close closes the iterator.
+// Close closes the iterator and freed CGO resources
 func (it *JSONIterator) Close() {
+	if it.result != nil {
+		it.result.Free()
+		it.result = nil
+	}
 	if it.query != nil {
 		it.query.close()
 		it.query = nil
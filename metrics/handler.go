@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/restream/reindexer"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns http.Handler, which exports metrics of db in Prometheus text format:
+// client side metrics, collected by interceptor, connection pool status and
+// server side #perfstats and #memstats rows
+func (c *Collector) Handler(db *reindexer.Reindexer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		bw := bufio.NewWriter(w)
+		c.WriteTo(bw, db)
+		bw.Flush()
+	})
+}
+
+// WriteTo writes metrics of db in Prometheus text format to w. db can be nil, then only client side metrics are written
+func (c *Collector) WriteTo(w io.Writer, db *reindexer.Reindexer) {
+	e := &encoder{w: w}
+	c.writeClient(e)
+	if db != nil {
+		writeStatus(e, db)
+		writeObjCache(e, db)
+		writeServer(e, db)
+	}
+}
+
+func (c *Collector) writeClient(e *encoder) {
+	ops := c.snapshot()
+
+	e.header("reindexer_client_requests_total", "counter", "Total number of binding calls")
+	for _, op := range ops {
+		e.sample("reindexer_client_requests_total", float64(op.requests), "method", op.method, "namespace", op.namespace)
+	}
+
+	e.header("reindexer_client_errors_total", "counter", "Total number of failed binding calls")
+	for _, op := range ops {
+		e.sample("reindexer_client_errors_total", float64(op.errors), "method", op.method, "namespace", op.namespace)
+	}
+
+	e.header("reindexer_client_request_duration_seconds", "histogram", "Latency of binding calls")
+	for _, op := range ops {
+		for i, le := range c.buckets {
+			e.sample("reindexer_client_request_duration_seconds_bucket", float64(op.buckets[i]),
+				"method", op.method, "namespace", op.namespace, "le", formatFloat(le))
+		}
+		e.sample("reindexer_client_request_duration_seconds_bucket", float64(op.requests),
+			"method", op.method, "namespace", op.namespace, "le", "+Inf")
+		e.sample("reindexer_client_request_duration_seconds_sum", op.sum, "method", op.method, "namespace", op.namespace)
+		e.sample("reindexer_client_request_duration_seconds_count", float64(op.requests), "method", op.method, "namespace", op.namespace)
+	}
+}
+
+func writeStatus(e *encoder, db *reindexer.Reindexer) {
+	status := db.Status()
+
+	up := 1.0
+	if status.Err != nil {
+		up = 0
+	}
+	e.header("reindexer_up", "gauge", "Whether db is initialized without errors")
+	e.sample("reindexer_up", up)
+
+	e.header("reindexer_cproto_conn_pool_size", "gauge", "Size of cproto connections pool")
+	e.sample("reindexer_cproto_conn_pool_size", float64(status.CProto.ConnPoolSize))
+	e.header("reindexer_cproto_conn_pool_usage", "gauge", "Number of cproto connections in use")
+	e.sample("reindexer_cproto_conn_pool_usage", float64(status.CProto.ConnPoolUsage))
+	e.header("reindexer_cproto_conn_queue_size", "gauge", "Size of cproto requests queue")
+	e.sample("reindexer_cproto_conn_queue_size", float64(status.CProto.ConnQueueSize))
+	e.header("reindexer_cproto_conn_queue_usage", "gauge", "Number of cproto requests in queue")
+	e.sample("reindexer_cproto_conn_queue_usage", float64(status.CProto.ConnQueueUsage))
+	e.header("reindexer_cproto_host_state", "gauge", "State of connections to cproto host: 0 - connecting, 1 - connected, 2 - disconnected")
+	for _, h := range status.CProto.Hosts {
+		e.sample("reindexer_cproto_host_state", float64(h.State), "host", h.Host, "master", strconv.FormatBool(h.Master))
+	}
+	e.header("reindexer_cproto_host_reconnect_failures", "gauge", "Number of failed in a row reconnect attempts to cproto host")
+	for _, h := range status.CProto.Hosts {
+		e.sample("reindexer_cproto_host_reconnect_failures", float64(h.Failures), "host", h.Host, "master", strconv.FormatBool(h.Master))
+	}
+	e.header("reindexer_builtin_cgo_limit", "gauge", "Limit of concurrent cgo calls of builtin binding")
+	e.sample("reindexer_builtin_cgo_limit", float64(status.Builtin.CGOLimit))
+	e.header("reindexer_builtin_cgo_usage", "gauge", "Number of concurrent cgo calls of builtin binding")
+	e.sample("reindexer_builtin_cgo_usage", float64(status.Builtin.CGOUsage))
+}
+
+func writeObjCache(e *encoder, db *reindexer.Reindexer) {
+	stats := db.CacheStats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.header("reindexer_client_objcache_size", "gauge", "Number of items in object cache")
+	for _, name := range names {
+		e.sample("reindexer_client_objcache_size", float64(stats[name].Size), "namespace", name)
+	}
+	e.header("reindexer_client_objcache_hits_total", "counter", "Total number of items, found in object cache")
+	for _, name := range names {
+		e.sample("reindexer_client_objcache_hits_total", float64(stats[name].Hits), "namespace", name)
+	}
+	e.header("reindexer_client_objcache_misses_total", "counter", "Total number of items, not found in object cache")
+	for _, name := range names {
+		e.sample("reindexer_client_objcache_misses_total", float64(stats[name].Misses), "namespace", name)
+	}
+	e.header("reindexer_client_objcache_evictions_total", "counter", "Total number of items, evicted from full object cache")
+	for _, name := range names {
+		e.sample("reindexer_client_objcache_evictions_total", float64(stats[name].Evictions), "namespace", name)
+	}
+}
+
+func writeServer(e *encoder, db *reindexer.Reindexer) {
+	perfStats, perfErr := db.GetNamespacesPerfStat()
+	memStats, memErr := db.GetNamespacesMemStat()
+
+	up := func(err error) float64 {
+		if err != nil {
+			return 0
+		}
+		return 1
+	}
+	e.header("reindexer_server_stats_up", "gauge", "Whether server side stats were read successfully")
+	e.sample("reindexer_server_stats_up", up(perfErr), "stats", "perfstats")
+	e.sample("reindexer_server_stats_up", up(memErr), "stats", "memstats")
+
+	type perfMetric struct {
+		name, typ, help string
+		value           func(st *reindexer.PerfStat) float64
+	}
+	perfMetrics := []perfMetric{
+		{"reindexer_perfstats_queries_total", "counter", "Total number of queries",
+			func(st *reindexer.PerfStat) float64 { return float64(st.TotalQueriesCount) }},
+		{"reindexer_perfstats_avg_latency_seconds", "gauge", "Average latency of queries",
+			func(st *reindexer.PerfStat) float64 { return float64(st.TotalAvgLatencyUs) / 1e6 }},
+		{"reindexer_perfstats_avg_lock_time_seconds", "gauge", "Average lock time of queries",
+			func(st *reindexer.PerfStat) float64 { return float64(st.TotalAvgLockTimeUs) / 1e6 }},
+		{"reindexer_perfstats_last_sec_qps", "gauge", "Queries per second during last second",
+			func(st *reindexer.PerfStat) float64 { return float64(st.LastSecQPS) }},
+		{"reindexer_perfstats_last_sec_avg_latency_seconds", "gauge", "Average latency of queries during last second",
+			func(st *reindexer.PerfStat) float64 { return float64(st.LastSecAvgLatencyUs) / 1e6 }},
+		{"reindexer_perfstats_last_sec_avg_lock_time_seconds", "gauge", "Average lock time of queries during last second",
+			func(st *reindexer.PerfStat) float64 { return float64(st.LastSecAvgLockTimeUs) / 1e6 }},
+	}
+	for _, m := range perfMetrics {
+		e.header(m.name, m.typ, m.help)
+		for _, ns := range perfStats {
+			e.sample(m.name, m.value(&ns.Selects), "namespace", ns.Name, "type", "selects")
+			e.sample(m.name, m.value(&ns.Updates), "namespace", ns.Name, "type", "updates")
+		}
+	}
+
+	type memMetric struct {
+		name, help string
+		value      func(st *reindexer.NamespaceMemStat) float64
+	}
+	memMetrics := []memMetric{
+		{"reindexer_memstats_items_count", "Number of items in namespace",
+			func(st *reindexer.NamespaceMemStat) float64 { return float64(st.ItemsCount) }},
+		{"reindexer_memstats_empty_items_count", "Number of empty items in namespace",
+			func(st *reindexer.NamespaceMemStat) float64 { return float64(st.EmptyItemsCount) }},
+		{"reindexer_memstats_data_size_bytes", "Size of namespace data",
+			func(st *reindexer.NamespaceMemStat) float64 { return float64(st.Total.DataSize) }},
+		{"reindexer_memstats_indexes_size_bytes", "Size of namespace indexes",
+			func(st *reindexer.NamespaceMemStat) float64 { return float64(st.Total.IndexesSize) }},
+		{"reindexer_memstats_cache_size_bytes", "Size of namespace caches",
+			func(st *reindexer.NamespaceMemStat) float64 { return float64(st.Total.CacheSize) }},
+		{"reindexer_memstats_storage_ok", "Whether namespace storage is ok",
+			func(st *reindexer.NamespaceMemStat) float64 {
+				if st.StorageOK {
+					return 1
+				}
+				return 0
+			}},
+	}
+	for _, m := range memMetrics {
+		e.header(m.name, "gauge", m.help)
+		for _, ns := range memStats {
+			e.sample(m.name, m.value(ns), "namespace", ns.Name)
+		}
+	}
+}
+
+// encoder writes metrics in Prometheus text exposition format
+type encoder struct {
+	w io.Writer
+}
+
+func (e *encoder) header(name, typ, help string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes metric value with labels, passed as name, value pairs
+func (e *encoder) sample(name string, value float64, labels ...string) {
+	io.WriteString(e.w, name)
+	if len(labels) != 0 {
+		io.WriteString(e.w, "{")
+		for i := 0; i < len(labels); i += 2 {
+			if i != 0 {
+				io.WriteString(e.w, ",")
+			}
+			fmt.Fprintf(e.w, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		io.WriteString(e.w, "}")
+	}
+	io.WriteString(e.w, " "+formatFloat(value)+"\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
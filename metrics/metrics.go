@@ -0,0 +1,109 @@
+// Package metrics exports reindexer client and server metrics in Prometheus text format.
+//
+// Client side counters and latency histograms are collected by binding interceptor:
+//
+//	collector := metrics.NewCollector()
+//	db := reindexer.NewReindex("cproto://127.0.0.1:6534/testdb", reindexer.WithInterceptors(collector.Interceptor()))
+//	http.Handle("/metrics", collector.Handler(db))
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+// DefaultBuckets are upper bounds of latency histogram buckets in seconds
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type opKey struct {
+	method    string
+	namespace string
+}
+
+type opStats struct {
+	requests uint64
+	errors   uint64
+	buckets  []uint64
+	sum      float64
+}
+
+// Collector collects client side metrics of binding calls
+type Collector struct {
+	lock    sync.Mutex
+	buckets []float64
+	ops     map[opKey]*opStats
+}
+
+// NewCollector creates new collector. If buckets are not set, DefaultBuckets are used
+func NewCollector(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Collector{buckets: buckets, ops: make(map[opKey]*opStats)}
+}
+
+// Interceptor returns binding interceptor, which counts calls, errors and latency per method and namespace.
+// It should be passed to reindexer.NewReindex with reindexer.WithInterceptors
+func (c *Collector) Interceptor() bindings.Interceptor {
+	return func(ctx context.Context, call *bindings.Call, invoker bindings.Invoker) (bindings.RawBuffer, error) {
+		start := time.Now()
+		res, err := invoker(ctx)
+		c.observe(call.Method, call.Namespace, time.Since(start), err)
+		return res, err
+	}
+}
+
+func (c *Collector) observe(method, namespace string, duration time.Duration, err error) {
+	seconds := duration.Seconds()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := opKey{method: method, namespace: namespace}
+	st, ok := c.ops[key]
+	if !ok {
+		st = &opStats{buckets: make([]uint64, len(c.buckets))}
+		c.ops[key] = st
+	}
+	st.requests++
+	if err != nil {
+		st.errors++
+	}
+	st.sum += seconds
+	for i, le := range c.buckets {
+		if seconds <= le {
+			st.buckets[i]++
+		}
+	}
+}
+
+type opSnapshot struct {
+	opKey
+	opStats
+}
+
+// snapshot returns copy of collected stats, sorted by method and namespace
+func (c *Collector) snapshot() []opSnapshot {
+	c.lock.Lock()
+	ops := make([]opSnapshot, 0, len(c.ops))
+	for key, st := range c.ops {
+		snap := opSnapshot{opKey: key, opStats: *st}
+		snap.buckets = append([]uint64(nil), st.buckets...)
+		ops = append(ops, snap)
+	}
+	c.lock.Unlock()
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].method != ops[j].method {
+			return ops[i].method < ops[j].method
+		}
+		return ops[i].namespace < ops[j].namespace
+	})
+	return ops
+}
@@ -0,0 +1,76 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/memory"
+	"github.com/restream/reindexer/metrics"
+)
+
+type testItem struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+}
+
+func TestHandler(t *testing.T) {
+	collector := metrics.NewCollector()
+	db := reindexer.NewReindex("memory://", reindexer.WithInterceptors(collector.Interceptor()))
+	defer db.Close()
+
+	if err := db.OpenNamespace("items", reindexer.DefaultNamespaceOptions(), testItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := db.Upsert("items", &testItem{ID: i, Name: "item"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Query("items").Exec().FetchAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecSQL("SELECT * FROM items").FetchAll(); err == nil {
+		t.Fatal("Expected error of SQL query")
+	}
+
+	srv := httptest.NewServer(collector.Handler(db))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("Unexpected content type %s", ct)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(body), `reindexer_client_requests_total{method="ModifyItem",namespace="items"} `) ||
+		!strings.Contains(string(body), `reindexer_client_requests_total{method="SelectQuery",namespace="items"} `) {
+		t.Fatalf("Metrics do not contain items namespace calls:\n%s", string(body))
+	}
+	for _, line := range []string{
+		"# TYPE reindexer_client_requests_total counter",
+		`reindexer_client_requests_total{method="OpenNamespace",namespace="items"} 1`,
+		`reindexer_client_requests_total{method="Select",namespace=""} 1`,
+		`reindexer_client_errors_total{method="Select",namespace=""} 1`,
+		"# TYPE reindexer_client_request_duration_seconds histogram",
+		`reindexer_client_request_duration_seconds_bucket{method="Select",namespace="",le="+Inf"} 1`,
+		`reindexer_client_request_duration_seconds_count{method="Select",namespace=""} 1`,
+		"reindexer_up 1",
+		"reindexer_cproto_conn_pool_usage 0",
+		`reindexer_client_objcache_size{namespace="items"} 0`,
+		`reindexer_server_stats_up{stats="memstats"} 1`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("Metrics do not contain line '%s':\n%s", line, string(body))
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package migrate runs versioned data migrations of namespaces exactly once.
+//
+// Migrations are registered by application with increasing versions per namespace, usually in init functions:
+//
+//	migrate.Register("items", 1, func(db *reindexer.Reindexer) error {
+//		_, err := db.Query("items").Where("year", reindexer.EMPTY, nil).Set("year", 2000).Update()
+//		return err
+//	})
+//
+// and are executed at startup, after namespaces are opened:
+//
+//	if err := migrate.Run(db); err != nil {
+//		panic(err)
+//	}
+//
+// Applied version of namespace is stored in meta of namespace by key "migrate.version". While migrations are executed,
+// namespace is locked by item in namespace "migrate_locks", so several instances of application do not execute
+// the same migration concurrently. Lock is acquired by insert of item with namespace as primary key, so only one instance
+// can acquire it, and it's prolonged by its owner until migrations are finished.
+//
+// Namespace "migrate_locks" (LockNamespace) is created in database by Run and Status with default options, so storage
+// is enabled for it. It's visible to application as other namespaces, e.g. in ListNamespaces and dumps, and must not be
+// modified or dropped by application, while migrations can run.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/restream/reindexer"
+)
+
+// VersionMetaKey is meta key of namespace, where applied version is stored
+const VersionMetaKey = "migrate.version"
+
+// LockNamespace is namespace, where locks of namespaces are stored
+const LockNamespace = "migrate_locks"
+
+// ErrLocked is returned, if namespace is locked by another instance for longer than LockWait
+var ErrLocked = errors.New("migrate: namespace is locked by another migration")
+
+// ErrLockLost is returned, if lock was not prolonged in time, and expired lock could be taken by another instance
+var ErrLockLost = errors.New("migrate: lock of namespace is lost")
+
+// Lock is lock of namespace, which is held while migrations are executed. Expires is time of expiration in unix nanoseconds
+type Lock struct {
+	Namespace string `reindex:"namespace,hash,pk"`
+	Owner     string `reindex:"owner"`
+	Expires   int64  `reindex:"expires,tree"`
+}
+
+// Func is migration function. It should modify namespace with db, and return error on failure
+type Func func(db *reindexer.Reindexer) error
+
+type migration struct {
+	version int
+	fn      Func
+}
+
+// Registry is set of migrations of namespaces
+type Registry struct {
+	// LockTTL is time, after which lock of crashed instance is expired. Lock is prolonged by its owner each LockTTL/3
+	LockTTL time.Duration
+	// LockWait is max time to wait for lock, held by another instance
+	LockWait time.Duration
+	// LockRetry is delay between attempts to acquire lock, held by another instance
+	LockRetry time.Duration
+
+	lock       sync.Mutex
+	owner      string
+	migrations map[string][]migration
+}
+
+// Status is migration status of namespace
+type Status struct {
+	Namespace string
+	// Version is applied version of namespace
+	Version int
+	// Latest is the latest registered version of namespace. It's 0, if status is read without registry
+	Latest int
+	// Pending is registered versions, which are not applied yet
+	Pending []int
+	// LockOwner is owner of lock, and LockExpires is time of its expiration. LockOwner is empty, if namespace is not locked
+	LockOwner   string
+	LockExpires time.Time
+}
+
+// DefaultRegistry is registry, which is used by package level functions
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates empty registry with default lock settings
+func NewRegistry() *Registry {
+	host, _ := os.Hostname()
+	return &Registry{
+		LockTTL:    5 * time.Minute,
+		LockWait:   10 * time.Minute,
+		LockRetry:  100 * time.Millisecond,
+		owner:      fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano()),
+		migrations: make(map[string][]migration),
+	}
+}
+
+// Register adds migration of namespace to DefaultRegistry
+func Register(namespace string, version int, fn Func) {
+	DefaultRegistry.Register(namespace, version, fn)
+}
+
+// Run executes pending migrations of DefaultRegistry
+func Run(db *reindexer.Reindexer) error {
+	return DefaultRegistry.Run(db)
+}
+
+// Register adds migration of namespace with version. Versions must be positive and unique per namespace.
+// Migrations are executed in order of versions, not in order of registration
+func (r *Registry) Register(namespace string, version int, fn Func) {
+	if version <= 0 {
+		panic(fmt.Errorf("migrate: invalid version %d of namespace '%s'", version, namespace))
+	}
+	if fn == nil {
+		panic(fmt.Errorf("migrate: nil migration %d of namespace '%s'", version, namespace))
+	}
+	namespace = strings.ToLower(namespace)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	migrations := r.migrations[namespace]
+	for _, m := range migrations {
+		if m.version == version {
+			panic(fmt.Errorf("migrate: migration %d of namespace '%s' is already registered", version, namespace))
+		}
+	}
+	migrations = append(migrations, migration{version: version, fn: fn})
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	r.migrations[namespace] = migrations
+}
+
+// Run executes pending migrations of all registered namespaces. Namespaces must be opened before Run.
+// Version is stored after each successful migration, so on error migrations before failed one are not executed again
+func (r *Registry) Run(db *reindexer.Reindexer) error {
+	return r.RunCtx(context.Background(), db)
+}
+
+// RunCtx executes pending migrations of all registered namespaces
+// The ctx can be used to cancel waiting of lock
+func (r *Registry) RunCtx(ctx context.Context, db *reindexer.Reindexer) error {
+	if err := openLocks(db); err != nil {
+		return err
+	}
+	for _, namespace := range r.namespaces() {
+		if err := r.runNamespace(ctx, db, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status returns migration status of all registered namespaces
+func (r *Registry) Status(db *reindexer.Reindexer) ([]Status, error) {
+	namespaces := r.namespaces()
+	statuses := make([]Status, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		st, err := ReadStatus(db, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range r.pending(namespace, st.Version) {
+			st.Pending = append(st.Pending, m.version)
+		}
+		st.Latest = r.latest(namespace)
+		statuses = append(statuses, *st)
+	}
+	return statuses, nil
+}
+
+// ReadStatus reads applied version of namespace from its meta, and lock of namespace
+func ReadStatus(db *reindexer.Reindexer, namespace string) (*Status, error) {
+	namespace = strings.ToLower(namespace)
+	version, err := readVersion(db, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err = openLocks(db); err != nil {
+		return nil, err
+	}
+	lock, err := readLock(db, namespace)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Namespace: namespace, Version: version}
+	if lock != nil {
+		st.LockOwner, st.LockExpires = lock.Owner, time.Unix(0, lock.Expires)
+	}
+	return st, nil
+}
+
+// WriteStatus writes statuses of namespaces to w as human-readable table
+func WriteStatus(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tVERSION\tLATEST\tPENDING\tLOCK")
+	for _, st := range statuses {
+		latest, pending, lock := "-", "-", "-"
+		if st.Latest != 0 {
+			latest = strconv.Itoa(st.Latest)
+			pending = strings.Trim(fmt.Sprint(st.Pending), "[]")
+			if pending == "" {
+				pending = "none"
+			}
+		}
+		if st.LockOwner != "" {
+			lock = fmt.Sprintf("%s until %s", st.LockOwner, st.LockExpires.Format(time.RFC3339))
+			if time.Now().After(st.LockExpires) {
+				lock += " (expired)"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", st.Namespace, st.Version, latest, pending, lock)
+	}
+	return tw.Flush()
+}
+
+func (r *Registry) namespaces() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	namespaces := make([]string, 0, len(r.migrations))
+	for namespace := range r.migrations {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+func (r *Registry) pending(namespace string, version int) []migration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var pending []migration
+	for _, m := range r.migrations[namespace] {
+		if m.version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+func (r *Registry) latest(namespace string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	migrations := r.migrations[namespace]
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+func (r *Registry) runNamespace(ctx context.Context, db *reindexer.Reindexer, namespace string) error {
+	version, err := readVersion(db, namespace)
+	if err != nil {
+		return err
+	}
+	if len(r.pending(namespace, version)) == 0 {
+		return nil
+	}
+
+	if err = r.acquireLock(ctx, db, namespace); err != nil {
+		return err
+	}
+	defer r.releaseLock(db, namespace)
+	lost := r.prolongLock(db, namespace)
+	defer close(lost.done)
+
+	// Migrations could be executed by another instance, while lock was waited
+	if version, err = readVersion(db, namespace); err != nil {
+		return err
+	}
+	for _, m := range r.pending(namespace, version) {
+		if err = m.fn(db); err != nil {
+			return fmt.Errorf("migrate: migration %d of namespace '%s' failed: %v", m.version, namespace, err)
+		}
+		// Version is not stored, if migration could be executed concurrently by another instance
+		if lost.isLost() {
+			return ErrLockLost
+		}
+		if err = db.PutMeta(namespace, VersionMetaKey, []byte(strconv.Itoa(m.version))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acquireLock inserts lock of namespace. Lock of another instance is removed, if it's expired
+func (r *Registry) acquireLock(ctx context.Context, db *reindexer.Reindexer, namespace string) error {
+	deadline := time.Now().Add(r.LockWait)
+	for {
+		cnt, err := db.Insert(LockNamespace, &Lock{Namespace: namespace, Owner: r.owner, Expires: r.lockExpires()})
+		if err != nil || cnt != 0 {
+			return err
+		}
+		lock, err := readLock(db, namespace)
+		if err != nil {
+			return err
+		}
+		if lock != nil && time.Now().After(time.Unix(0, lock.Expires)) {
+			// Lock is removed only if it's not changed since read, so lock of another instance, which removed it first, is kept
+			cnt, err = db.Query(LockNamespace).WhereString("namespace", reindexer.EQ, namespace).
+				WhereString("owner", reindexer.EQ, lock.Owner).WhereInt64("expires", reindexer.EQ, lock.Expires).Delete()
+			if err != nil {
+				return err
+			}
+			if cnt != 0 {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		if err = sleep(ctx, r.LockRetry); err != nil {
+			return err
+		}
+	}
+}
+
+// lockState is state of lock, which is prolonged in background
+type lockState struct {
+	lost int32
+	done chan struct{}
+}
+
+func (s *lockState) isLost() bool {
+	return atomic.LoadInt32(&s.lost) != 0
+}
+
+// prolongLock prolongs lock of namespace each LockTTL/3, until done of returned state is closed.
+// Lock is lost, if it was not prolonged before expiration, or if it's owned by another instance
+func (r *Registry) prolongLock(db *reindexer.Reindexer, namespace string) *lockState {
+	state := &lockState{done: make(chan struct{})}
+	go func() {
+		expires := time.Now().Add(r.LockTTL)
+		ticker := time.NewTicker(r.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-state.done:
+				return
+			case <-ticker.C:
+			}
+			newExpires := r.lockExpires()
+			cnt, err := db.Query(LockNamespace).WhereString("namespace", reindexer.EQ, namespace).
+				WhereString("owner", reindexer.EQ, r.owner).Set("expires", newExpires).Update()
+			if (err == nil && cnt == 0) || time.Now().After(expires) {
+				atomic.StoreInt32(&state.lost, 1)
+				return
+			}
+			if err == nil {
+				expires = time.Unix(0, newExpires)
+			}
+		}
+	}()
+	return state
+}
+
+// releaseLock removes lock of namespace, if it was not taken by another instance after expiration
+func (r *Registry) releaseLock(db *reindexer.Reindexer, namespace string) {
+	db.Query(LockNamespace).WhereString("namespace", reindexer.EQ, namespace).WhereString("owner", reindexer.EQ, r.owner).Delete()
+}
+
+func (r *Registry) lockExpires() int64 {
+	return time.Now().Add(r.LockTTL).UnixNano()
+}
+
+func openLocks(db *reindexer.Reindexer) error {
+	return db.OpenNamespace(LockNamespace, reindexer.DefaultNamespaceOptions(), Lock{})
+}
+
+func readVersion(db *reindexer.Reindexer, namespace string) (int, error) {
+	data, err := db.GetMeta(namespace, VersionMetaKey)
+	if err != nil || len(data) == 0 {
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("migrate: invalid version '%s' of namespace '%s'", string(data), namespace)
+	}
+	return version, nil
+}
+
+// readLock returns lock of namespace, or nil, if namespace is not locked
+func readLock(db *reindexer.Reindexer, namespace string) (*Lock, error) {
+	lock := &Lock{}
+	found, err := db.Query(LockNamespace).WhereString("namespace", reindexer.EQ, namespace).GetInto(lock)
+	if err != nil || !found {
+		return nil, err
+	}
+	return lock, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package migrate_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+	_ "github.com/restream/reindexer/bindings/memory"
+	"github.com/restream/reindexer/migrate"
+)
+
+type testItem struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+}
+
+func newTestDB(t *testing.T) *reindexer.Reindexer {
+	db := reindexer.NewReindex("memory://")
+	if err := db.OpenNamespace("items", reindexer.DefaultNamespaceOptions(), testItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.OpenNamespace(migrate.LockNamespace, reindexer.DefaultNamespaceOptions(), migrate.Lock{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestRegistry() *migrate.Registry {
+	r := migrate.NewRegistry()
+	r.LockRetry = 10 * time.Millisecond
+	return r
+}
+
+func TestRun(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	var applied []int
+	r := newTestRegistry()
+	r.Register("items", 2, func(db *reindexer.Reindexer) error {
+		applied = append(applied, 2)
+		return db.Upsert("items", &testItem{ID: 2, Name: "second"})
+	})
+	r.Register("items", 1, func(db *reindexer.Reindexer) error {
+		applied = append(applied, 1)
+		return db.Upsert("items", &testItem{ID: 1, Name: "first"})
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := r.Run(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(applied) != "[1 2]" {
+		t.Fatalf("Expected migrations [1 2] executed once, but got %v", applied)
+	}
+
+	errFailed := errors.New("failed")
+	r.Register("items", 3, func(db *reindexer.Reindexer) error { return errFailed })
+	if err := r.Run(db); err == nil || !strings.Contains(err.Error(), "migration 3 of namespace 'items' failed") {
+		t.Fatalf("Expected error of migration, but got %v", err)
+	}
+
+	statuses, err := r.Status(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Version != 2 || statuses[0].Latest != 3 ||
+		fmt.Sprint(statuses[0].Pending) != "[3]" || statuses[0].LockOwner != "" {
+		t.Fatalf("Unexpected status: %+v", statuses)
+	}
+
+	var out bytes.Buffer
+	migrate.WriteStatus(&out, statuses)
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || strings.Join(strings.Fields(lines[1]), " ") != "items 2 3 3 -" {
+		t.Fatalf("Unexpected status output:\n%s", out.String())
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	var executed int32
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		// Each registry is one instance of application
+		r := newTestRegistry()
+		r.Register("items", 1, func(db *reindexer.Reindexer) error {
+			atomic.AddInt32(&executed, 1)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = r.Run(db)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if executed != 1 {
+		t.Fatalf("Expected, that migration is executed once, but it's executed %d times", executed)
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	r := newTestRegistry()
+	r.LockWait = 50 * time.Millisecond
+	r.Register("items", 1, func(db *reindexer.Reindexer) error { return nil })
+
+	lock := &migrate.Lock{Namespace: "items", Owner: "other", Expires: time.Now().Add(time.Hour).UnixNano()}
+	if err := db.Upsert(migrate.LockNamespace, lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(db); err != migrate.ErrLocked {
+		t.Fatalf("Expected locked error, but got %v", err)
+	}
+	if st, err := migrate.ReadStatus(db, "items"); err != nil || st.LockOwner != "other" || st.Version != 0 {
+		t.Fatalf("Unexpected status: %+v, %v", st, err)
+	}
+
+	// Lock of crashed instance is expired
+	lock.Expires = time.Now().Add(-time.Second).UnixNano()
+	if err := db.Upsert(migrate.LockNamespace, lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(db); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := migrate.ReadStatus(db, "items"); err != nil || st.LockOwner != "" || st.Version != 1 {
+		t.Fatalf("Unexpected status: %+v, %v", st, err)
+	}
+}
+
+func TestLockProlong(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	// Migration is longer than LockTTL, so lock must be prolonged
+	started := make(chan struct{})
+	r := newTestRegistry()
+	r.LockTTL = 60 * time.Millisecond
+	r.Register("items", 1, func(db *reindexer.Reindexer) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Run(db) }()
+
+	<-started
+	other := newTestRegistry()
+	other.LockWait = 150 * time.Millisecond
+	other.Register("items", 1, func(db *reindexer.Reindexer) error {
+		t.Errorf("Migration must not be executed by another instance")
+		return nil
+	})
+	if err := other.Run(db); err != migrate.ErrLocked {
+		t.Fatalf("Expected locked error, but got %v", err)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if st, err := migrate.ReadStatus(db, "items"); err != nil || st.LockOwner != "" || st.Version != 1 {
+		t.Fatalf("Unexpected status: %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,190 @@
+package reindexer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+// Actions of migration steps
+const (
+	MigrationAddIndex    = "add"
+	MigrationUpdateIndex = "update"
+	MigrationDropIndex   = "drop"
+)
+
+// MigrationStep is one change of namespace indexes
+type MigrationStep struct {
+	// Action is one of MigrationAddIndex, MigrationUpdateIndex or MigrationDropIndex
+	Action string
+	// Index is new definition of index for add and update, or current definition for drop
+	Index IndexDef
+	// Description is human-readable description of step
+	Description string
+}
+
+// MigrationPlan is list of steps, which migrate indexes of namespace in database to indexes of struct
+type MigrationPlan struct {
+	Namespace string
+	Steps     []MigrationStep
+}
+
+// Empty returns true, if indexes of namespace are already up to date
+func (plan *MigrationPlan) Empty() bool {
+	return len(plan.Steps) == 0
+}
+
+// String returns human-readable description of plan, one step per line
+func (plan *MigrationPlan) String() string {
+	if plan.Empty() {
+		return fmt.Sprintf("namespace '%s' is up to date", plan.Namespace)
+	}
+	descs := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		descs = append(descs, step.Description)
+	}
+	return strings.Join(descs, "\n")
+}
+
+// PlanMigration compares indexes of struct s with indexes of namespace in database, and returns steps to migrate namespace.
+// Indexes, which are not present in struct, are dropped. Data is not modified by migration. If namespace does not exist, all indexes are added
+func (db *Reindexer) PlanMigration(namespace string, s interface{}) (*MigrationPlan, error) {
+	return db.PlanMigrationCtx(context.Background(), namespace, s)
+}
+
+// PlanMigrationCtx compares indexes of struct s with indexes of namespace in database
+// The ctx can be used to cancel or limit by deadline the request
+func (db *Reindexer) PlanMigrationCtx(ctx context.Context, namespace string, s interface{}) (*MigrationPlan, error) {
+	t := reflect.TypeOf(s)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	namespace = strings.ToLower(namespace)
+	joined := make(map[string][]int)
+	indexes, err := db.parseIndex(namespace, t, &joined)
+	if err != nil {
+		return nil, err
+	}
+	return db.planMigration(ctx, namespace, indexes)
+}
+
+func (db *Reindexer) planMigration(ctx context.Context, namespace string, indexes []bindings.IndexDef) (*MigrationPlan, error) {
+	plan := &MigrationPlan{Namespace: namespace}
+
+	var current []IndexDescription
+	desc, err := db.Query(NamespacesNamespaceName).Where("name", EQ, namespace).ExecCtx(ctx).FetchOne()
+	if err == nil {
+		current = desc.(*NamespaceDescription).Indexes
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(indexes))
+	for _, def := range indexes {
+		wanted[strings.ToLower(def.Name)] = true
+	}
+	for _, cur := range current {
+		if !wanted[strings.ToLower(cur.Name)] {
+			plan.Steps = append(plan.Steps, MigrationStep{
+				Action:      MigrationDropIndex,
+				Index:       cur.IndexDef,
+				Description: fmt.Sprintf("drop index '%s'", cur.Name),
+			})
+		}
+	}
+
+	var adds []MigrationStep
+	for _, def := range indexes {
+		var found *IndexDescription
+		for i := range current {
+			if strings.ToLower(current[i].Name) == strings.ToLower(def.Name) {
+				found = &current[i]
+				break
+			}
+		}
+
+		if found == nil {
+			adds = append(adds, MigrationStep{
+				Action:      MigrationAddIndex,
+				Index:       IndexDef(def),
+				Description: fmt.Sprintf("add index '%s' (%s, %s) on %v", def.Name, normIndexType(def.IndexType), def.FieldType, def.JSONPaths),
+			})
+		} else if diff := diffIndexDefs(bindings.IndexDef(found.IndexDef), def); len(diff) != 0 {
+			// Config of index is managed by UpdateIndex, and is not set by struct tags, so keep it
+			def.Config = found.Config
+			plan.Steps = append(plan.Steps, MigrationStep{
+				Action:      MigrationUpdateIndex,
+				Index:       IndexDef(def),
+				Description: fmt.Sprintf("update index '%s': %s", def.Name, strings.Join(diff, ", ")),
+			})
+		}
+	}
+	// Drops are done before adds, so new index can take json path of dropped one
+	plan.Steps = append(plan.Steps, adds...)
+
+	return plan, nil
+}
+
+// ApplyMigration executes steps of plan. Execution stops on the first failed step, and steps before it remain applied
+func (db *Reindexer) ApplyMigration(plan *MigrationPlan) error {
+	return db.ApplyMigrationCtx(context.Background(), plan)
+}
+
+// ApplyMigrationCtx executes steps of plan
+// The ctx can be used to cancel or limit by deadline the migration
+func (db *Reindexer) ApplyMigrationCtx(ctx context.Context, plan *MigrationPlan) (err error) {
+	for _, step := range plan.Steps {
+		switch step.Action {
+		case MigrationAddIndex:
+			err = db.binding.AddIndex(ctx, plan.Namespace, bindings.IndexDef(step.Index))
+		case MigrationUpdateIndex:
+			err = db.binding.UpdateIndex(ctx, plan.Namespace, bindings.IndexDef(step.Index))
+		case MigrationDropIndex:
+			err = db.binding.DropIndex(ctx, plan.Namespace, step.Index.Name)
+		default:
+			err = fmt.Errorf("rq: Unknown migration action '%s'", step.Action)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffIndexDefs returns human-readable differences of index definitions. Config of index is not compared
+func diffIndexDefs(cur, def bindings.IndexDef) (diff []string) {
+	cmp := func(name string, from, to interface{}) {
+		if !reflect.DeepEqual(from, to) {
+			diff = append(diff, fmt.Sprintf("%s %v -> %v", name, from, to))
+		}
+	}
+	cmp("json_paths", cur.JSONPaths, def.JSONPaths)
+	cmp("index_type", normIndexType(cur.IndexType), normIndexType(def.IndexType))
+	cmp("field_type", cur.FieldType, def.FieldType)
+	cmp("is_pk", cur.IsPK, def.IsPK)
+	cmp("is_array", cur.IsArray, def.IsArray)
+	cmp("is_dense", cur.IsDense, def.IsDense)
+	cmp("is_sparse", cur.IsSparse, def.IsSparse)
+	cmp("collate_mode", normCollateMode(cur.CollateMode), normCollateMode(def.CollateMode))
+	cmp("sort_order_letters", cur.SortOrder, def.SortOrder)
+	return diff
+}
+
+// normIndexType returns index type with default value, which is set by reindexer for empty type
+func normIndexType(t string) string {
+	if t == "" {
+		return "hash"
+	}
+	return t
+}
+
+// normCollateMode returns collate mode with default value, which is set by reindexer for empty mode
+func normCollateMode(mode string) string {
+	if mode == "" {
+		return "none"
+	}
+	return mode
+}
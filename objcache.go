@@ -0,0 +1,112 @@
+package reindexer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultObjCacheSize is default limit of count of items in object cache of namespace
+const DefaultObjCacheSize = 256000
+
+// ObjCacheStats is statistics of object cache of namespace
+type ObjCacheStats struct {
+	// Count of items in cache
+	Size int `json:"size"`
+	// Limit of count of items in cache. 0 means unlimited
+	MaxSize int `json:"max_size"`
+	// Count of items, which were found in cache
+	Hits uint64 `json:"hits"`
+	// Count of items, which were not found in cache, and were decoded
+	Misses uint64 `json:"misses"`
+	// Count of items, which were evicted from cache to fit MaxSize
+	Evictions uint64 `json:"evictions"`
+}
+
+type cacheItem struct {
+	id   int
+	item interface{}
+	// version of item, for cachins
+	version int
+}
+
+// objCache is LRU cache of decoded items of namespace
+type objCache struct {
+	lock sync.Mutex
+	// items are elements of lru list by item id
+	items map[int]*list.Element
+	// lru is list of cacheItem, recently used items are at front
+	lru   *list.List
+	stats ObjCacheStats
+}
+
+func newObjCache(maxSize int) *objCache {
+	return &objCache{
+		items: make(map[int]*list.Element, 100),
+		lru:   list.New(),
+		stats: ObjCacheStats{MaxSize: maxSize},
+	}
+}
+
+// get returns cached item with id and version, or nil
+func (c *objCache) get(id int, version int) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.items[id]; ok {
+		if citem := el.Value.(*cacheItem); citem.version == version {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return citem.item
+		}
+		c.removeElement(el)
+	}
+	c.stats.Misses++
+	return nil
+}
+
+// put adds item to cache, and evicts least recently used items, if cache is full
+func (c *objCache) put(id int, version int, item interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.items[id]; ok {
+		citem := el.Value.(*cacheItem)
+		citem.item, citem.version = item, version
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[id] = c.lru.PushFront(&cacheItem{id: id, item: item, version: version})
+	for c.stats.MaxSize > 0 && c.lru.Len() > c.stats.MaxSize {
+		c.removeElement(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops item with id from cache
+func (c *objCache) remove(id int) {
+	c.lock.Lock()
+	if el, ok := c.items[id]; ok {
+		c.removeElement(el)
+	}
+	c.lock.Unlock()
+}
+
+// reset drops all items from cache. Statistics counters are kept
+func (c *objCache) reset() {
+	c.lock.Lock()
+	c.items = make(map[int]*list.Element, 100)
+	c.lru.Init()
+	c.lock.Unlock()
+}
+
+func (c *objCache) removeElement(el *list.Element) {
+	delete(c.items, el.Value.(*cacheItem).id)
+	c.lru.Remove(el)
+}
+
+// getStats returns statistics of cache
+func (c *objCache) getStats() ObjCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
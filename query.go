@@ -1,11 +1,15 @@
 package reindexer
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/restream/reindexer/bindings"
@@ -14,20 +18,28 @@ import (
 
 // Constants for query serialization
 const (
-	queryCondition      = bindings.QueryCondition
-	queryDistinct       = bindings.QueryDistinct
-	querySortIndex      = bindings.QuerySortIndex
-	queryJoinOn         = bindings.QueryJoinOn
-	queryLimit          = bindings.QueryLimit
-	queryOffset         = bindings.QueryOffset
-	queryReqTotal       = bindings.QueryReqTotal
-	queryDebugLevel     = bindings.QueryDebugLevel
-	queryAggregation    = bindings.QueryAggregation
-	querySelectFilter   = bindings.QuerySelectFilter
-	queryExplain        = bindings.QueryExplain
-	QuerySelectFunction = bindings.QuerySelectFunction
-	QueryEqualPosition  = bindings.QueryEqualPosition
-	queryEnd            = bindings.QueryEnd
+	queryCondition         = bindings.QueryCondition
+	queryDistinct          = bindings.QueryDistinct
+	querySortIndex         = bindings.QuerySortIndex
+	queryJoinOn            = bindings.QueryJoinOn
+	queryLimit             = bindings.QueryLimit
+	queryOffset            = bindings.QueryOffset
+	queryReqTotal          = bindings.QueryReqTotal
+	queryDebugLevel        = bindings.QueryDebugLevel
+	queryAggregation       = bindings.QueryAggregation
+	queryAggregationFields = bindings.QueryAggregationFields
+	queryAggregationSort   = bindings.QueryAggregationSort
+	queryAggregationLimit  = bindings.QueryAggregationLimit
+	queryAggregationOffset = bindings.QueryAggregationOffset
+	querySelectFilter      = bindings.QuerySelectFilter
+	queryExplain           = bindings.QueryExplain
+	QuerySelectFunction    = bindings.QuerySelectFunction
+	QueryEqualPosition     = bindings.QueryEqualPosition
+	queryUpdateField       = bindings.QueryUpdateField
+	queryDropField         = bindings.QueryDropField
+	queryOpenBracket       = bindings.QueryOpenBracket
+	queryCloseBracket      = bindings.QueryCloseBracket
+	queryEnd               = bindings.QueryEnd
 )
 
 // Constants for calc total
@@ -81,6 +93,7 @@ type Query struct {
 	root          *Query
 	joinQueries   []*Query
 	mergedQueries []*Query
+	aggregations  []*aggregation
 	joinToFields  []string
 	joinHandlers  []JoinHandler
 	context       interface{}
@@ -92,11 +105,11 @@ type Query struct {
 	iterator      Iterator
 	jsonIterator  JSONIterator
 	items         []interface{}
-	json          []byte
-	jsonOffsets   []int
 	totalName     string
 	executed      bool
 	fetchCount    int
+	openBrackets  int
+	timeout       time.Duration
 }
 
 var queryPool sync.Pool
@@ -120,12 +133,15 @@ func newQuery(db *Reindexer, namespace string) *Query {
 		q.joinQueries = q.joinQueries[:0]
 		q.joinHandlers = q.joinHandlers[:0]
 		q.mergedQueries = q.mergedQueries[:0]
+		q.aggregations = q.aggregations[:0]
 		q.ptVersions = q.ptVersions[:0]
 		q.ser = cjson.NewSerializer(q.ser.Bytes()[:0])
 		q.closed = false
 		q.totalName = ""
 		q.executed = false
 		q.nsArray = q.nsArray[:0]
+		q.openBrackets = 0
+		q.timeout = 0
 	}
 
 	q.Namespace = namespace
@@ -320,10 +336,86 @@ func (q *Query) WhereDouble(index string, condition int, keys ...float64) *Query
 // Aggregate - Return aggregation of field
 func (q *Query) Aggregate(index string, aggType int) *Query {
 
-	q.ser.PutVarCUInt(queryAggregation).PutVString(index).PutVarCUInt(aggType)
+	q.aggregations = append(q.aggregations, &aggregation{fields: []string{index}, aggType: aggType, limit: -1})
 	return q
 }
 
+// aggregation is kept in query until execution, because options of facet can be set after other aggregations are added
+type aggregation struct {
+	fields  []string
+	aggType int
+	sort    []aggregationSort
+	limit   int
+	offset  int
+}
+
+type aggregationSort struct {
+	field string
+	desc  bool
+}
+
+// AggregateFacetRequest - Facet aggregation of query, which can be sorted and paginated
+type AggregateFacetRequest struct {
+	agg *aggregation
+}
+
+// AggregateFacet - Return facet of values of one or several fields. Count of items is calculated for each distinct combination of fields values
+func (q *Query) AggregateFacet(fields ...string) *AggregateFacetRequest {
+	if len(fields) == 0 {
+		panic(errors.New("AggregateFacet call without fields"))
+	}
+	agg := &aggregation{fields: append([]string(nil), fields...), aggType: AggFacet, limit: -1}
+	q.aggregations = append(q.aggregations, agg)
+	return &AggregateFacetRequest{agg: agg}
+}
+
+// Sort - Apply sort order to facets. Field must be one of facet fields, or "count" to sort by count of items
+// Can be called several times, facets are sorted by first sort, then by second, etc.
+func (r *AggregateFacetRequest) Sort(field string, desc bool) *AggregateFacetRequest {
+	r.agg.sort = append(r.agg.sort, aggregationSort{field: field, desc: desc})
+	return r
+}
+
+// Limit - Set limit (count) of returned facets
+func (r *AggregateFacetRequest) Limit(limit int) *AggregateFacetRequest {
+	r.agg.limit = limit
+	return r
+}
+
+// Offset - Set start offset of returned facets
+func (r *AggregateFacetRequest) Offset(offset int) *AggregateFacetRequest {
+	r.agg.offset = offset
+	return r
+}
+
+// putAggregations writes aggregations of query to its serializer. It's called on query execution
+func (q *Query) putAggregations() {
+	for _, agg := range q.aggregations {
+		q.ser.PutVarCUInt(queryAggregation).PutVString(agg.fields[0]).PutVarCUInt(agg.aggType)
+		if len(agg.fields) > 1 {
+			q.ser.PutVarCUInt(queryAggregationFields).PutVarCUInt(len(agg.fields))
+			for _, field := range agg.fields {
+				q.ser.PutVString(field)
+			}
+		}
+		for _, sort := range agg.sort {
+			q.ser.PutVarCUInt(queryAggregationSort).PutVString(sort.field)
+			if sort.desc {
+				q.ser.PutVarUInt(1)
+			} else {
+				q.ser.PutVarUInt(0)
+			}
+		}
+		if agg.limit >= 0 {
+			q.ser.PutVarCUInt(queryAggregationLimit).PutVarCUInt(agg.limit)
+		}
+		if agg.offset > 0 {
+			q.ser.PutVarCUInt(queryAggregationOffset).PutVarCUInt(agg.offset)
+		}
+	}
+	q.aggregations = q.aggregations[:0]
+}
+
 // Sort - Apply sort order to returned from query items
 // If values argument specified, then items equal to values, if found will be placed in the top positions
 // For composite indexes values must be []interface{}, with value of each subindex
@@ -357,6 +449,26 @@ func (q *Query) Not() *Query {
 	return q
 }
 
+// OpenBracket - Open bracket for where conditions. Conditions until CloseBracket will be grouped,
+// and group will be added to query with AND, OR or NOT, the same way as single condition
+func (q *Query) OpenBracket() *Query {
+	q.ser.PutVarCUInt(queryOpenBracket).PutVarCUInt(q.nextOp)
+	q.nextOp = opAND
+	q.openBrackets++
+	return q
+}
+
+// CloseBracket - Close bracket, opened by OpenBracket
+func (q *Query) CloseBracket() *Query {
+	if q.openBrackets == 0 {
+		panic(errors.New("CloseBracket call without opened bracket"))
+	}
+	q.ser.PutVarCUInt(queryCloseBracket)
+	q.nextOp = opAND
+	q.openBrackets--
+	return q
+}
+
 // Distinct - Return only items with uniq value of field
 func (q *Query) Distinct(distinctIndex string) *Query {
 	q.ser.PutVarCUInt(queryDistinct)
@@ -414,6 +526,24 @@ func (q *Query) Explain() *Query {
 	return q
 }
 
+// Timeout - Set timeout of each request to server, made by query: select and fetching of results, delete or update.
+// It overrides default timeout, set by WithTimeouts option. It's supported by cproto binding
+func (q *Query) Timeout(timeout time.Duration) *Query {
+	if q.root != nil {
+		q = q.root
+	}
+	q.timeout = timeout
+	return q
+}
+
+// withTimeout returns ctx with timeout of query, if it is set
+func (q *Query) withTimeout(ctx context.Context) context.Context {
+	if q.timeout > 0 {
+		return bindings.ContextWithTimeout(ctx, q.timeout)
+	}
+	return ctx
+}
+
 // SetContext set interface, which will be passed to Joined interface
 func (q *Query) SetContext(ctx interface{}) *Query {
 	q.context = ctx
@@ -426,6 +556,12 @@ func (q *Query) SetContext(ctx interface{}) *Query {
 
 // Exec will execute query, and return slice of items
 func (q *Query) Exec() *Iterator {
+	return q.ExecCtx(context.Background())
+}
+
+// ExecCtx will execute query, and return slice of items
+// The ctx can be used to cancel or limit by deadline the query and fetching of its results
+func (q *Query) ExecCtx(ctx context.Context) *Iterator {
 	if q.root != nil {
 		q = q.root
 	}
@@ -437,11 +573,17 @@ func (q *Query) Exec() *Iterator {
 	}
 	q.executed = true
 
-	return q.db.execQuery(q)
+	return q.db.execQuery(q.withTimeout(ctx), q)
 }
 
 // ExecAsJson will execute query, and return iterator
 func (q *Query) ExecToJson(jsonRoots ...string) *JSONIterator {
+	return q.ExecToJsonCtx(context.Background(), jsonRoots...)
+}
+
+// ExecToJsonCtx will execute query, and return iterator
+// The ctx can be used to cancel or limit by deadline the query
+func (q *Query) ExecToJsonCtx(ctx context.Context, jsonRoots ...string) *JSONIterator {
 	if q.root != nil {
 		q = q.root
 	}
@@ -458,7 +600,26 @@ func (q *Query) ExecToJson(jsonRoots ...string) *JSONIterator {
 		jsonRoot = jsonRoots[0]
 	}
 
-	return q.db.execJSONQuery(q, jsonRoot)
+	return q.db.execJSONQuery(q.withTimeout(ctx), q, jsonRoot)
+}
+
+// ExecToJSONWriter will execute query, and write JSON object with results array to w.
+// Results are fetched by pages and written to w one by one, so whole response is never kept in memory.
+// If root is empty, namespace name is used as name of results array
+func (q *Query) ExecToJSONWriter(w io.Writer, root string) error {
+	return q.ExecToJSONWriterCtx(context.Background(), w, root)
+}
+
+// ExecToJSONWriterCtx will execute query, and write JSON object with results array to w
+// The ctx can be used to cancel or limit by deadline the query and fetching of its results
+func (q *Query) ExecToJSONWriterCtx(ctx context.Context, w io.Writer, root string) error {
+	it := q.ExecToJsonCtx(ctx, root)
+	defer it.Close()
+	bw := bufio.NewWriter(w)
+	if err := it.writeTo(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
 }
 
 func (q *Query) close() {
@@ -492,6 +653,12 @@ func (q *Query) close() {
 // Delete will execute query, and delete items, matches query
 // On sucess return number of deleted elements
 func (q *Query) Delete() (int, error) {
+	return q.DeleteCtx(context.Background())
+}
+
+// DeleteCtx will execute query, and delete items, matches query
+// The ctx can be used to cancel or limit by deadline the request
+func (q *Query) DeleteCtx(ctx context.Context) (int, error) {
 	if q.root != nil || len(q.joinQueries) != 0 {
 		return 0, errors.New("Delete does not support joined queries")
 	}
@@ -500,7 +667,69 @@ func (q *Query) Delete() (int, error) {
 	}
 
 	defer q.close()
-	return q.db.deleteQuery(q)
+	return q.db.deleteQuery(q.withTimeout(ctx), q)
+}
+
+// Set - Set value of field within Update statement. If values is slice, then field will be set to array of values
+func (q *Query) Set(field string, values interface{}) *Query {
+	return q.updateField(field, bindings.UpdateFieldSet, values)
+}
+
+// ArrayAppend - Append values to array field within Update statement. values can be single value or slice
+func (q *Query) ArrayAppend(field string, values interface{}) *Query {
+	return q.updateField(field, bindings.UpdateFieldArrayAppend, values)
+}
+
+// ArrayRemove - Remove all occurrences of values from array field within Update statement. values can be single value or slice
+func (q *Query) ArrayRemove(field string, values interface{}) *Query {
+	return q.updateField(field, bindings.UpdateFieldArrayRemove, values)
+}
+
+// Drop - Remove field from items within Update statement
+func (q *Query) Drop(field string) *Query {
+	q.ser.PutVarCUInt(queryDropField).PutVString(field)
+	return q
+}
+
+// updateField serializes field update as: field name, update mode, is array flag, count of values and values
+func (q *Query) updateField(field string, mode int, values interface{}) *Query {
+	t := reflect.TypeOf(values)
+	v := reflect.ValueOf(values)
+
+	q.ser.PutVarCUInt(queryUpdateField).PutVString(field).PutVarCUInt(mode)
+
+	if values == nil {
+		q.ser.PutVarUInt(0).PutVarUInt(0)
+	} else if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		q.ser.PutVarUInt(1).PutVarCUInt(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			q.putValue(v.Index(i))
+		}
+	} else {
+		q.ser.PutVarUInt(0).PutVarCUInt(1)
+		q.putValue(v)
+	}
+	return q
+}
+
+// Update will execute query, and update fields of items, matches query, with values from Set, ArrayAppend, ArrayRemove and Drop
+// On sucess return number of updated elements
+func (q *Query) Update() (int, error) {
+	return q.UpdateCtx(context.Background())
+}
+
+// UpdateCtx will execute query, and update items, matches query
+// The ctx can be used to cancel or limit by deadline the request
+func (q *Query) UpdateCtx(ctx context.Context) (int, error) {
+	if q.root != nil || len(q.joinQueries) != 0 {
+		return 0, errors.New("Update does not support joined queries")
+	}
+	if q.closed {
+		panic(errors.New("Update call on already closed query. You shoud create new Query"))
+	}
+
+	defer q.close()
+	return q.db.updateQuery(q.withTimeout(ctx), q)
 }
 
 // MustExec will execute query, and return iterator, panic on error
@@ -522,6 +751,21 @@ func (q *Query) Get() (item interface{}, found bool) {
 	return nil, false
 }
 
+// GetInto will execute query, and decode 1st item into dst, which must be pointer to item of namespace type.
+// It returns false, if item is not found
+func (q *Query) GetInto(dst interface{}) (found bool, err error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false, ErrMustBePointer
+	}
+	it := q.Limit(1).Exec()
+	defer it.Close()
+	if it.nextInto(v.Elem()) {
+		return true, nil
+	}
+	return false, it.err
+}
+
 // Get will execute query, and return 1 st item, panic on error
 func (q *Query) GetJson() (json []byte, found bool) {
 	it := q.Limit(1).ExecToJson()
@@ -533,7 +777,20 @@ func (q *Query) GetJson() (json []byte, found bool) {
 		return nil, false
 	}
 
-	return it.JSON(), true
+	return append([]byte(nil), it.JSON()...), true
+}
+
+// joinedNsIndexOffset returns index of first namespace, joined to namespace with parentNsID, in nsArray of query
+func (q *Query) joinedNsIndexOffset(parentNsID int) int {
+	if q == nil {
+		return 1
+	}
+
+	offset := 1 + len(q.mergedQueries)
+	for m := 0; m < parentNsID; m++ {
+		offset += len(q.mergedQueries[m].joinQueries)
+	}
+	return offset
 }
 
 // Join joins 2 queries
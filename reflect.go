@@ -8,6 +8,7 @@ import (
 	"unsafe"
 
 	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
 )
 
 const (
@@ -87,7 +88,9 @@ func parse(indexDefs *[]bindings.IndexDef, st reflect.Type, subArray bool, reind
 		idxSettings := splitOptions(idxOpts)
 
 		opts := parseOpts(&idxSettings)
-		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || subArray {
+		// Values with custom marshaler are stored as single values, even if they are slices or structs
+		marshaled := cjson.HasMarshaler(t)
+		if ((t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && !marshaled) || subArray {
 			opts.isArray = true
 		}
 
@@ -104,11 +107,11 @@ func parse(indexDefs *[]bindings.IndexDef, st reflect.Type, subArray bool, reind
 			if err := indexDefAppend(indexDefs, indexDef, opts.isAppenable); err != nil {
 				return err
 			}
-		} else if t.Kind() == reflect.Struct {
+		} else if t.Kind() == reflect.Struct && !marshaled {
 			if err := parse(indexDefs, t, subArray, reindexPath, jsonPath, joined); err != nil {
 				return err
 			}
-		} else if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) &&
+		} else if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && !marshaled && !cjson.HasMarshaler(t.Elem()) &&
 			(t.Elem().Kind() == reflect.Struct || (t.Elem().Kind() == reflect.Ptr && t.Elem().Elem().Kind() == reflect.Struct)) {
 			// Check if field nested slice of struct
 			if parseByKeyWord(&idxSettings, "joined") && len(idxName) > 0 {
@@ -261,7 +264,20 @@ func parseByKeyWord(idxSettingsBuf *[]string, keyWord string) bool {
 
 func getFieldType(t reflect.Type) (string, error) {
 
-	switch t.Kind() {
+	k := t.Kind()
+	if cjson.HasMarshaler(t) {
+		// Values with custom marshaler are indexed by kind of marshaled value
+		var err error
+		if k, err = cjson.MarshaledKind(t); err != nil {
+			return "", err
+		}
+		switch k {
+		case reflect.Array, reflect.Slice, reflect.Ptr, reflect.Struct, reflect.Map, reflect.Interface, reflect.Invalid:
+			return "", errInvalidReflection
+		}
+	}
+
+	switch k {
 	case reflect.Bool:
 		return "bool", nil
 	case reflect.Int8, reflect.Int32, reflect.Int16,
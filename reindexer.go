@@ -1,6 +1,8 @@
 package reindexer
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
@@ -73,20 +75,19 @@ type Reindexer struct {
 	debugLevels   map[string]int
 	nsHashCounter int
 	status        error
+	subs          subscriptions
+	// jsonItems is set, if binding accepts items only in JSON format
+	jsonItems bool
 }
 
 // Index definition struct
 type IndexDef bindings.IndexDef
 
-type cacheItem struct {
-	item interface{}
-	// version of item, for cachins
-	version int
-}
+// dynamicItemType is type of items of namespaces, which are opened without Go struct
+var dynamicItemType = reflect.TypeOf(map[string]interface{}{})
 
 type reindexerNamespace struct {
-	cacheItems    map[int]cacheItem
-	cacheLock     sync.RWMutex
+	cacheItems    *objCache
 	joined        map[string][]int
 	indexes       []bindings.IndexDef
 	rtype         reflect.Type
@@ -96,6 +97,15 @@ type reindexerNamespace struct {
 	cjsonState    cjson.State
 	nsHash        int
 	opened        bool
+	jsonItems     bool
+}
+
+// userItem returns decoded item as it's returned to user: pointer to struct, or map for dynamic namespace
+func (ns *reindexerNamespace) userItem(item interface{}) interface{} {
+	if m, ok := item.(*map[string]interface{}); ok && ns.rtype == dynamicItemType {
+		return *m
+	}
+	return item
 }
 
 // Interface for append joined items
@@ -138,20 +148,28 @@ var (
 	ErrEmptyFieldName      = errors.New("rq: empty field name in filter")
 	ErrCondType            = errors.New("rq: cond type not found")
 	ErrOpInvalid           = errors.New("rq: op is invalid")
+	ErrAggType             = errors.New("rq: aggregation type not found")
+	ErrAggInvalid          = errors.New("rq: only facet aggregation can have several fields, sort, limit and offset")
+	ErrEmptyAggFields      = errors.New("rq: empty fields of aggregation")
 	ErrNoPK                = errors.New("rq: No pk field in struct")
 	ErrWrongType           = errors.New("rq: Wrong type of item")
 	ErrMustBePointer       = errors.New("rq: Argument must be a pointer to element, not element")
 	ErrNotFound            = errors.New("rq: Not found")
 	ErrDeepCopyType        = errors.New("rq: DeepCopy() returns wrong type")
+	ErrTxDone              = errors.New("rq: Transaction is already committed or rolled back")
 )
 
 type AggregationResult struct {
-	Field  string  `json:"field"`
-	Type   string  `json:"type"`
-	Value  float64 `json:"value"`
+	Field string `json:"field"`
+	// Fields of aggregation. Facet aggregation can have several fields
+	Fields []string `json:"fields"`
+	Type   string   `json:"type"`
+	Value  float64  `json:"value"`
 	Facets []struct {
 		Value string `json:"value"`
-		Count int    `json:"count"`
+		// Values of each of Fields
+		Values []string `json:"values"`
+		Count  int      `json:"count"`
 	} `json:"facets"`
 }
 
@@ -174,18 +192,35 @@ func NewReindex(dsn string, options ...interface{}) *Reindexer {
 	}
 
 	binding = binding.Clone()
+
+	var interceptors []bindings.Interceptor
+	bindingOptions := make([]interface{}, 0, len(options))
+	for _, option := range options {
+		if opt, ok := option.(bindings.OptionInterceptors); ok {
+			interceptors = append(interceptors, opt.Interceptors...)
+		} else {
+			bindingOptions = append(bindingOptions, option)
+		}
+	}
+	if len(interceptors) != 0 {
+		binding = bindings.NewInterceptedBinding(binding, interceptors...)
+	}
+
 	rx := &Reindexer{
 		ns:      make(map[string]*reindexerNamespace, 100),
 		binding: binding,
 	}
 
-	if err = binding.Init(u, options...); err != nil {
+	if err = binding.Init(u, bindingOptions...); err != nil {
 		rx.status = err
 	}
 
 	if changing, ok := binding.(bindings.RawBindingChanging); ok {
 		changing.OnChangeCallback(rx.resetCaches)
 	}
+	if j, ok := binding.(bindings.RawBindingJSON); ok {
+		rx.jsonItems = j.JSONOnly()
+	}
 
 	rx.registerNamespace(NamespacesNamespaceName, &NamespaceOptions{}, NamespaceDescription{})
 	rx.registerNamespace(PerfstatsNamespaceName, &NamespaceOptions{}, NamespacePerfStat{})
@@ -196,6 +231,40 @@ func NewReindex(dsn string, options ...interface{}) *Reindexer {
 	return rx
 }
 
+// WithInterceptors returns option for NewReindex, which wraps binding with interceptors.
+// Interceptors are called in order, the first one is the outermost
+func WithInterceptors(interceptors ...bindings.Interceptor) bindings.OptionInterceptors {
+	return bindings.OptionInterceptors{Interceptors: interceptors}
+}
+
+// WithHosts returns option for NewReindex, which adds hosts of cproto servers to hosts from DSN.
+// The first available host is used as master, others are used for failover and read routing
+func WithHosts(hosts ...string) bindings.OptionHosts {
+	return bindings.OptionHosts{Hosts: hosts}
+}
+
+// WithReadFromReplicas returns option for NewReindex, which routes read requests of cproto binding to replicas
+func WithReadFromReplicas() bindings.OptionReadFromReplicas {
+	return bindings.OptionReadFromReplicas{}
+}
+
+// WithReconnectBackoff returns option for NewReindex, which sets exponential backoff of reconnect attempts of cproto binding
+func WithReconnectBackoff(minDelay, maxDelay time.Duration, factor, jitter float64) bindings.OptionReconnectBackoff {
+	return bindings.OptionReconnectBackoff{MinDelay: minDelay, MaxDelay: maxDelay, Factor: factor, Jitter: jitter}
+}
+
+// WithTimeouts returns option for NewReindex, which sets default timeouts of read and write requests of cproto binding.
+// The timeout of query can be overridden by Query.Timeout
+func WithTimeouts(read, write time.Duration) bindings.OptionTimeouts {
+	return bindings.OptionTimeouts{Read: read, Write: write}
+}
+
+// WithTLSConfig returns option for NewReindex, which enables TLS for cproto connections.
+// Client certificates can be set in config.Certificates
+func WithTLSConfig(config *tls.Config) bindings.OptionTLSConfig {
+	return bindings.OptionTLSConfig{Config: config}
+}
+
 // Status will return current db status
 func (db *Reindexer) Status() bindings.Status {
 	status := db.binding.Status()
@@ -216,10 +285,17 @@ func (db *Reindexer) SetLogger(log Logger) {
 
 // Ping checks connection with reindexer
 func (db *Reindexer) Ping() error {
-	return db.binding.Ping()
+	return db.PingCtx(context.Background())
+}
+
+// PingCtx checks connection with reindexer
+// The ctx can be used to cancel or limit by deadline waiting for reply
+func (db *Reindexer) PingCtx(ctx context.Context) error {
+	return db.binding.Ping(ctx)
 }
 
 func (db *Reindexer) Close() {
+	db.closeSubscriptions()
 	if err := db.binding.Finalize(); err != nil {
 		panic(err)
 	}
@@ -235,6 +311,10 @@ type NamespaceOptions struct {
 	dropOnFileFormatError bool
 	// Cached mode options
 	cachedMode uint8
+	// Migrate indexes of existing ns to indexes of struct
+	autoMigrate bool
+	// Limit of count of items in object cache
+	objCacheSize int
 }
 
 func (opts *NamespaceOptions) NoStorage() *NamespaceOptions {
@@ -247,6 +327,13 @@ func (opts *NamespaceOptions) DropOnIndexesConflict() *NamespaceOptions {
 	return opts
 }
 
+// AutoMigrate makes OpenNamespace to migrate indexes of existing namespace to indexes of struct, instead of failing on conflict.
+// Changed indexes are updated, and indexes, which are not present in struct, are dropped. See PlanMigration
+func (opts *NamespaceOptions) AutoMigrate() *NamespaceOptions {
+	opts.autoMigrate = true
+	return opts
+}
+
 func (opts *NamespaceOptions) DropOnFileFormatError() *NamespaceOptions {
 	opts.dropOnFileFormatError = true
 	return opts
@@ -267,14 +354,27 @@ func (opts *NamespaceOptions) CacheAggressive() *NamespaceOptions {
 	return opts
 }
 
+// ObjCacheSize sets limit of count of items in object cache of namespace. Least recently used items are evicted from full cache.
+// 0 means unlimited cache
+func (opts *NamespaceOptions) ObjCacheSize(count int) *NamespaceOptions {
+	opts.objCacheSize = count
+	return opts
+}
+
 // DefaultNamespaceOptions return defailt namespace options
 func DefaultNamespaceOptions() *NamespaceOptions {
-	return &NamespaceOptions{enableStorage: true, cachedMode: bindings.CacheModeOn}
+	return &NamespaceOptions{enableStorage: true, cachedMode: bindings.CacheModeOn, objCacheSize: DefaultObjCacheSize}
 }
 
 // OpenNamespace Open or create new namespace and indexes based on passed struct.
 // IndexDef fields of struct are marked by `reindex:` tag
 func (db *Reindexer) OpenNamespace(namespace string, opts *NamespaceOptions, s interface{}) (err error) {
+	return db.OpenNamespaceCtx(context.Background(), namespace, opts, s)
+}
+
+// OpenNamespaceCtx Open or create new namespace and indexes based on passed struct.
+// The ctx can be used to cancel or limit by deadline namespace opening
+func (db *Reindexer) OpenNamespaceCtx(ctx context.Context, namespace string, opts *NamespaceOptions, s interface{}) (err error) {
 
 	namespace = strings.ToLower(namespace)
 	if err = db.registerNamespace(namespace, opts, s); err != nil {
@@ -286,24 +386,61 @@ func (db *Reindexer) OpenNamespace(namespace string, opts *NamespaceOptions, s i
 		return err
 	}
 
+	return db.openNamespace(ctx, ns, opts)
+}
+
+// OpenDynamicNamespace Open or create new namespace with passed indexes and without Go struct.
+// Items of namespace are map[string]interface{}: maps are upserted to namespace, and iterators return maps
+func (db *Reindexer) OpenDynamicNamespace(namespace string, opts *NamespaceOptions, indexes []IndexDef) (err error) {
+	return db.OpenDynamicNamespaceCtx(context.Background(), namespace, opts, indexes)
+}
+
+// OpenDynamicNamespaceCtx Open or create new namespace with passed indexes and without Go struct.
+// The ctx can be used to cancel or limit by deadline namespace opening
+func (db *Reindexer) OpenDynamicNamespaceCtx(ctx context.Context, namespace string, opts *NamespaceOptions, indexes []IndexDef) (err error) {
+
+	namespace = strings.ToLower(namespace)
+	if err = db.registerDynamicNamespace(namespace, opts, indexes); err != nil {
+		return err
+	}
+
+	ns, err := db.getNS(namespace)
+	if err != nil {
+		return err
+	}
+
+	return db.openNamespace(ctx, ns, opts)
+}
+
+// openNamespace opens registered namespace in binding, and adds its indexes
+func (db *Reindexer) openNamespace(ctx context.Context, ns *reindexerNamespace, opts *NamespaceOptions) (err error) {
+	namespace := ns.name
+
 	for retry := 0; retry < 2; retry++ {
-		if err = db.binding.OpenNamespace(namespace, opts.enableStorage, opts.dropOnFileFormatError, opts.cachedMode); err != nil {
+		if err = db.binding.OpenNamespace(ctx, namespace, opts.enableStorage, opts.dropOnFileFormatError, opts.cachedMode); err != nil {
 			break
 		}
 
-		for _, indexDef := range ns.indexes {
-			if err = db.binding.AddIndex(namespace, indexDef); err != nil {
-				break
+		if opts.autoMigrate {
+			var plan *MigrationPlan
+			if plan, err = db.planMigration(ctx, namespace, ns.indexes); err == nil {
+				err = db.ApplyMigrationCtx(ctx, plan)
+			}
+		} else {
+			for _, indexDef := range ns.indexes {
+				if err = db.binding.AddIndex(ctx, namespace, indexDef); err != nil {
+					break
+				}
 			}
 		}
 
 		if err != nil {
 			rerr, ok := err.(bindings.Error)
 			if ok && rerr.Code() == bindings.ErrConflict && opts.dropOnIndexesConflict {
-				db.binding.DropNamespace(namespace)
+				db.binding.DropNamespace(ctx, namespace)
 				continue
 			}
-			db.binding.CloseNamespace(namespace)
+			db.binding.CloseNamespace(ctx, namespace)
 			break
 		}
 
@@ -343,17 +480,8 @@ func (db *Reindexer) registerNamespace(namespace string, opts *NamespaceOptions,
 		}
 	}
 
-	ns := &reindexerNamespace{
-		cacheItems:    make(map[int]cacheItem, 100),
-		rtype:         t,
-		name:          namespace,
-		joined:        make(map[string][]int),
-		opts:          *opts,
-		cjsonState:    cjson.NewState(),
-		deepCopyIface: haveDeepCopy,
-		nsHash:        db.nsHashCounter,
-		opened:        false,
-	}
+	ns := db.newNamespace(namespace, opts, t)
+	ns.deepCopyIface = haveDeepCopy
 
 	validator := cjson.Validator{}
 
@@ -369,6 +497,42 @@ func (db *Reindexer) registerNamespace(namespace string, opts *NamespaceOptions,
 	return nil
 }
 
+// registerDynamicNamespace Register namespace with passed indexes and map items. There are no data and indexes changes will be performed
+func (db *Reindexer) registerDynamicNamespace(namespace string, opts *NamespaceOptions, indexes []IndexDef) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	ns, ok := db.ns[namespace]
+	if ok && ns.rtype != dynamicItemType {
+		return errNsExists
+	}
+	if !ok {
+		ns = db.newNamespace(namespace, opts, dynamicItemType)
+		db.nsHashCounter++
+		db.ns[namespace] = ns
+	}
+
+	ns.indexes = make([]bindings.IndexDef, 0, len(indexes))
+	for _, indexDef := range indexes {
+		ns.indexes = append(ns.indexes, bindings.IndexDef(indexDef))
+	}
+	return nil
+}
+
+func (db *Reindexer) newNamespace(namespace string, opts *NamespaceOptions, t reflect.Type) *reindexerNamespace {
+	return &reindexerNamespace{
+		cacheItems: newObjCache(opts.objCacheSize),
+		rtype:      t,
+		name:       namespace,
+		joined:     make(map[string][]int),
+		opts:       *opts,
+		cjsonState: cjson.NewState(),
+		nsHash:     db.nsHashCounter,
+		opened:     false,
+		jsonItems:  db.jsonItems,
+	}
+}
+
 // DropNamespace - drop whole namespace from DB
 func (db *Reindexer) DropNamespace(namespace string) error {
 	namespace = strings.ToLower(namespace)
@@ -376,7 +540,7 @@ func (db *Reindexer) DropNamespace(namespace string) error {
 	delete(db.ns, namespace)
 	db.lock.Unlock()
 
-	return db.binding.DropNamespace(namespace)
+	return db.binding.DropNamespace(context.Background(), namespace)
 }
 
 // CloseNamespace - close namespace, but keep storage
@@ -386,13 +550,19 @@ func (db *Reindexer) CloseNamespace(namespace string) error {
 	delete(db.ns, namespace)
 	db.lock.Unlock()
 
-	return db.binding.CloseNamespace(namespace)
+	return db.binding.CloseNamespace(context.Background(), namespace)
 }
 
 // Upsert (Insert or Update) item to index
 // Item must be the same type as item passed to OpenNamespace, or []byte with json
 func (db *Reindexer) Upsert(namespace string, item interface{}, precepts ...string) error {
-	_, err := db.modifyItem(namespace, nil, item, nil, modeUpsert, precepts...)
+	return db.UpsertCtx(context.Background(), namespace, item, precepts...)
+}
+
+// UpsertCtx (Insert or Update) item to index
+// The ctx can be used to cancel or limit by deadline the request
+func (db *Reindexer) UpsertCtx(ctx context.Context, namespace string, item interface{}, precepts ...string) error {
+	_, err := db.modifyItem(ctx, namespace, nil, item, nil, modeUpsert, precepts...)
 	return err
 }
 
@@ -400,20 +570,38 @@ func (db *Reindexer) Upsert(namespace string, item interface{}, precepts ...stri
 // Item must be the same type as item passed to OpenNamespace, or []byte with json data
 // Return 0, if no item was inserted, 1 if item was inserted
 func (db *Reindexer) Insert(namespace string, item interface{}, precepts ...string) (int, error) {
-	return db.modifyItem(namespace, nil, item, nil, modeInsert, precepts...)
+	return db.InsertCtx(context.Background(), namespace, item, precepts...)
+}
+
+// InsertCtx item to namespace.
+// The ctx can be used to cancel or limit by deadline the request
+func (db *Reindexer) InsertCtx(ctx context.Context, namespace string, item interface{}, precepts ...string) (int, error) {
+	return db.modifyItem(ctx, namespace, nil, item, nil, modeInsert, precepts...)
 }
 
 // Update item to namespace.
 // Item must be the same type as item passed to OpenNamespace, or []byte with json data
 // Return 0, if no item was updated, 1 if item was updated
 func (db *Reindexer) Update(namespace string, item interface{}, precepts ...string) (int, error) {
-	return db.modifyItem(namespace, nil, item, nil, modeUpdate, precepts...)
+	return db.UpdateCtx(context.Background(), namespace, item, precepts...)
+}
+
+// UpdateCtx item to namespace.
+// The ctx can be used to cancel or limit by deadline the request
+func (db *Reindexer) UpdateCtx(ctx context.Context, namespace string, item interface{}, precepts ...string) (int, error) {
+	return db.modifyItem(ctx, namespace, nil, item, nil, modeUpdate, precepts...)
 }
 
 // Delete - remove item  from namespace
 // Item must be the same type as item passed to OpenNamespace, or []byte with json data
 func (db *Reindexer) Delete(namespace string, item interface{}, precepts ...string) error {
-	_, err := db.modifyItem(namespace, nil, item, nil, modeDelete, precepts...)
+	return db.DeleteCtx(context.Background(), namespace, item, precepts...)
+}
+
+// DeleteCtx - remove item  from namespace
+// The ctx can be used to cancel or limit by deadline the request
+func (db *Reindexer) DeleteCtx(ctx context.Context, namespace string, item interface{}, precepts ...string) error {
+	_, err := db.modifyItem(ctx, namespace, nil, item, nil, modeDelete, precepts...)
 	return err
 }
 
@@ -431,7 +619,7 @@ func (db *Reindexer) ConfigureIndex(namespace, index string, config interface{})
 	for _, iDef := range nsDef.Indexes {
 		if strings.ToLower(iDef.Name) == index {
 			iDef.Config = config
-			return db.binding.UpdateIndex(namespace, bindings.IndexDef(iDef.IndexDef))
+			return db.binding.UpdateIndex(context.Background(), namespace, bindings.IndexDef(iDef.IndexDef))
 		}
 	}
 	return fmt.Errorf("rq: Index '%s' not found in namespace %s", index, namespace)
@@ -440,7 +628,7 @@ func (db *Reindexer) ConfigureIndex(namespace, index string, config interface{})
 // AddIndex - add index.
 func (db *Reindexer) AddIndex(namespace string, indexDef ...IndexDef) error {
 	for _, index := range indexDef {
-		if err := db.binding.AddIndex(namespace, bindings.IndexDef(index)); err != nil {
+		if err := db.binding.AddIndex(context.Background(), namespace, bindings.IndexDef(index)); err != nil {
 			return err
 		}
 	}
@@ -449,12 +637,12 @@ func (db *Reindexer) AddIndex(namespace string, indexDef ...IndexDef) error {
 
 // UpdateIndex - update index.
 func (db *Reindexer) UpdateIndex(namespace string, indexDef IndexDef) error {
-	return db.binding.UpdateIndex(namespace, bindings.IndexDef(indexDef))
+	return db.binding.UpdateIndex(context.Background(), namespace, bindings.IndexDef(indexDef))
 }
 
 // DropIndex - drop index.
 func (db *Reindexer) DropIndex(namespace, index string) error {
-	return db.binding.DropIndex(namespace, index)
+	return db.binding.DropIndex(context.Background(), namespace, index)
 }
 
 func loglevelToString(logLevel int) string {
@@ -509,6 +697,12 @@ func (db *Reindexer) Query(namespace string) *Query {
 // ExecSQL make query to database. Query is SQL statement
 // Return Iterator
 func (db *Reindexer) ExecSQL(query string) *Iterator {
+	return db.ExecSQLCtx(context.Background(), query)
+}
+
+// ExecSQLCtx make query to database. Query is SQL statement
+// The ctx can be used to cancel or limit by deadline the query
+func (db *Reindexer) ExecSQLCtx(ctx context.Context, query string) *Iterator {
 	// TODO: do not parse query string twice in go and cpp
 	namespace := ""
 	querySlice := strings.Fields(strings.ToLower(query))
@@ -520,10 +714,16 @@ func (db *Reindexer) ExecSQL(query string) *Iterator {
 		}
 	}
 
-	return db.execSQL(namespace, query)
+	return db.execSQL(ctx, namespace, query)
 }
 
 func (db *Reindexer) ExecSQLToJSON(query string) *JSONIterator {
+	return db.ExecSQLToJSONCtx(context.Background(), query)
+}
+
+// ExecSQLToJSONCtx make query to database. Query is SQL statement
+// The ctx can be used to cancel or limit by deadline the query
+func (db *Reindexer) ExecSQLToJSONCtx(ctx context.Context, query string) *JSONIterator {
 	// TODO: do not parse query string twice in go and cpp
 	namespace := ""
 	querySlice := strings.Fields(strings.ToLower(query))
@@ -535,17 +735,43 @@ func (db *Reindexer) ExecSQLToJSON(query string) *JSONIterator {
 		}
 	}
 
-	return db.execSQLAsJSON(namespace, query)
+	return db.execSQLAsJSON(ctx, namespace, query, sqlJoinedNamespaces(querySlice))
+}
+
+// sqlJoinedNamespaces returns names of namespaces, joined in SQL query: 'JOIN ns' or 'JOIN (SELECT ... FROM ns ...)'
+func sqlJoinedNamespaces(querySlice []string) (namespaces []string) {
+	for i := 0; i+1 < len(querySlice); i++ {
+		if querySlice[i] != "join" {
+			continue
+		}
+		name := querySlice[i+1]
+		if strings.HasPrefix(name, "(") {
+			for j := i + 1; j+1 < len(querySlice); j++ {
+				if strings.TrimLeft(querySlice[j], "(") == "from" {
+					name = querySlice[j+1]
+					break
+				}
+			}
+		}
+		namespaces = append(namespaces, strings.Trim(name, "()"))
+	}
+	return namespaces
 }
 
 // BeginTx - start update transaction
 func (db *Reindexer) BeginTx(namespace string) (*Tx, error) {
-	return newTx(db, namespace)
+	return newTx(context.Background(), db, namespace)
+}
+
+// BeginTxCtx - start update transaction
+// The ctx will be used by all requests of the transaction
+func (db *Reindexer) BeginTxCtx(ctx context.Context, namespace string) (*Tx, error) {
+	return newTx(ctx, db, namespace)
 }
 
 // MustBeginTx - start update transaction, panic on error
 func (db *Reindexer) MustBeginTx(namespace string) *Tx {
-	tx, err := newTx(db, namespace)
+	tx, err := newTx(context.Background(), db, namespace)
 	if err != nil {
 		panic(err)
 	}
@@ -554,10 +780,10 @@ func (db *Reindexer) MustBeginTx(namespace string) *Tx {
 
 // TODO make func as void
 // setUpdatedAt - set updated at time for namespace
-func (db *Reindexer) setUpdatedAt(ns *reindexerNamespace, updatedAt time.Time) error {
+func (db *Reindexer) setUpdatedAt(ctx context.Context, ns *reindexerNamespace, updatedAt time.Time) error {
 	str := strconv.FormatInt(updatedAt.UnixNano(), 10)
 
-	db.PutMeta(ns.name, "updated", []byte(str))
+	db.PutMetaCtx(ctx, ns.name, "updated", []byte(str))
 
 	return nil
 }
@@ -599,9 +825,33 @@ func (db *Reindexer) QueryFrom(d dsl.DSL) (*Query, error) {
 		q.Sort(d.Sort.Field, d.Sort.Desc, d.Sort.Values...)
 	}
 
-	for _, filter := range d.Filters {
+	if err := applyFilters(q, d.Filters); err != nil {
+		return nil, err
+	}
+
+	if err := applyAggregations(q, d.Aggregations); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func applyFilters(q *Query, filters []dsl.Filter) error {
+	for _, filter := range filters {
+		if len(filter.Filters) != 0 {
+			if err := applyFilterOp(q, filter.Op); err != nil {
+				return err
+			}
+			q.OpenBracket()
+			if err := applyFilters(q, filter.Filters); err != nil {
+				return err
+			}
+			q.CloseBracket()
+			continue
+		}
+
 		if filter.Field == "" {
-			return nil, ErrEmptyFieldName
+			return ErrEmptyFieldName
 		}
 		if filter.Value == nil {
 			continue
@@ -609,20 +859,72 @@ func (db *Reindexer) QueryFrom(d dsl.DSL) (*Query, error) {
 
 		cond, err := GetCondType(filter.Cond)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		switch strings.ToUpper(filter.Op) {
-		case "":
-			q.Where(filter.Field, cond, filter.Value)
-		case "NOT":
-			q.Not().Where(filter.Field, cond, filter.Value)
-		default:
-			return nil, ErrOpInvalid
+		if err := applyFilterOp(q, filter.Op); err != nil {
+			return err
 		}
+		q.Where(filter.Field, cond, filter.Value)
 	}
+	return nil
+}
 
-	return q, nil
+func applyAggregations(q *Query, aggs []dsl.Aggregation) error {
+	for _, agg := range aggs {
+		if len(agg.Fields) == 0 {
+			return ErrEmptyAggFields
+		}
+		aggType, err := GetAggType(agg.Type)
+		if err != nil {
+			return err
+		}
+		if aggType != AggFacet {
+			if len(agg.Fields) != 1 || len(agg.Sort) != 0 || agg.Limit != 0 || agg.Offset != 0 {
+				return ErrAggInvalid
+			}
+			q.Aggregate(agg.Fields[0], aggType)
+			continue
+		}
+
+		facet := q.AggregateFacet(agg.Fields...)
+		for _, s := range agg.Sort {
+			facet.Sort(s.Field, s.Desc)
+		}
+		if agg.Limit > 0 {
+			facet.Limit(agg.Limit)
+		}
+		if agg.Offset > 0 {
+			facet.Offset(agg.Offset)
+		}
+	}
+	return nil
+}
+
+func applyFilterOp(q *Query, op string) error {
+	switch strings.ToUpper(op) {
+	case "", "AND":
+	case "OR":
+		q.Or()
+	case "NOT":
+		q.Not()
+	default:
+		return ErrOpInvalid
+	}
+	return nil
+}
+
+// CacheStats returns statistics of object caches of namespaces by namespace name
+func (db *Reindexer) CacheStats() map[string]ObjCacheStats {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	stats := make(map[string]ObjCacheStats, len(db.ns))
+	for name, ns := range db.ns {
+		if !strings.HasPrefix(name, "#") {
+			stats[name] = ns.cacheItems.getStats()
+		}
+	}
+	return stats
 }
 
 // GetStats Get local thread reindexer usage stats
@@ -14,6 +14,8 @@ type rawResultItemParams struct {
 	proc    int
 	cptr    uintptr
 	data    []byte
+	// isJSON is set, if data is item in JSON format, e.g. results of http binding
+	isJSON bool
 }
 
 type rawResultsExtraParam struct {
@@ -62,7 +64,10 @@ func (s *resultSerializer) readRawtItemParams() (v rawResultItemParams) {
 	case bindings.ResultsPure:
 	case bindings.ResultsPtrs:
 		v.cptr = uintptr(s.GetUInt64())
-	case bindings.ResultsJson, bindings.ResultsCJson:
+	case bindings.ResultsJson:
+		v.data = s.GetBytes()
+		v.isJSON = true
+	case bindings.ResultsCJson:
 		v.data = s.GetBytes()
 	}
 	return v
@@ -0,0 +1,169 @@
+package reindexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/restream/reindexer/bindings"
+)
+
+// Operations of ChangeEvent
+const (
+	ChangeInsert = bindings.ModeInsert
+	ChangeUpdate = bindings.ModeUpdate
+	ChangeUpsert = bindings.ModeUpsert
+	ChangeDelete = bindings.ModeDelete
+)
+
+const defaultSubscriptionBufferSize = 1024
+
+var errUpdatesNotSupported = errors.New("rq: Binding does not support updates subscription")
+
+// ChangeEvent is modification of item in namespace
+type ChangeEvent struct {
+	// Op is one of ChangeInsert, ChangeUpdate, ChangeUpsert or ChangeDelete
+	Op        int
+	Namespace string
+	// Item is item decoded to namespace's type. It is nil if SubscribeOptions.RawJSON is set,
+	// or namespace is not opened by this client
+	Item interface{}
+	// JSON is JSON representation of item
+	JSON []byte
+	// Dropped is count of events, which were dropped before this event, because of channel overflow
+	Dropped int
+}
+
+// SubscribeOptions is options for Subscribe
+type SubscribeOptions struct {
+	// BufferSize is capacity of events channel. Events, which are not fit to channel are dropped
+	BufferSize int
+	// RawJSON disables decoding of items. Only ChangeEvent.JSON will be set
+	RawJSON bool
+}
+
+type subscription struct {
+	namespace string
+	opts      SubscribeOptions
+	ch        chan ChangeEvent
+	dropped   int
+}
+
+type subscriptions struct {
+	lock sync.Mutex
+	subs []*subscription
+}
+
+// Subscribe returns channel with modifications of items in namespace, including items deleted and updated by queries.
+// Subscription is active until Unsubscribe or Close. It's supported by builtin, builtinserver, memory and cproto bindings
+func (db *Reindexer) Subscribe(namespace string, opts SubscribeOptions) (<-chan ChangeEvent, error) {
+	return db.SubscribeCtx(context.Background(), namespace, opts)
+}
+
+// SubscribeCtx returns channel with modifications of items in namespace.
+// The ctx is used only for subscription request
+func (db *Reindexer) SubscribeCtx(ctx context.Context, namespace string, opts SubscribeOptions) (<-chan ChangeEvent, error) {
+	if namespace == "" {
+		return nil, ErrEmptyNamespace
+	}
+	updates, ok := db.binding.(bindings.RawBindingUpdates)
+	if !ok {
+		return nil, errUpdatesNotSupported
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultSubscriptionBufferSize
+	}
+
+	sub := &subscription{
+		namespace: strings.ToLower(namespace),
+		opts:      opts,
+		ch:        make(chan ChangeEvent, opts.BufferSize),
+	}
+
+	db.subs.lock.Lock()
+	defer db.subs.lock.Unlock()
+	if len(db.subs.subs) == 0 {
+		if err := updates.SubscribeUpdates(ctx, db.onUpdate); err != nil {
+			return nil, err
+		}
+	}
+	db.subs.subs = append(db.subs.subs, sub)
+	return sub.ch, nil
+}
+
+// Unsubscribe stops subscription, and closes its channel
+func (db *Reindexer) Unsubscribe(ch <-chan ChangeEvent) error {
+	db.subs.lock.Lock()
+	defer db.subs.lock.Unlock()
+	for i, sub := range db.subs.subs {
+		if sub.ch == ch {
+			close(sub.ch)
+			db.subs.subs = append(db.subs.subs[:i], db.subs.subs[i+1:]...)
+			if len(db.subs.subs) == 0 {
+				return db.binding.(bindings.RawBindingUpdates).UnsubscribeUpdates(context.Background())
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// closeSubscriptions closes channels of all subscriptions
+func (db *Reindexer) closeSubscriptions() {
+	db.subs.lock.Lock()
+	defer db.subs.lock.Unlock()
+	for _, sub := range db.subs.subs {
+		close(sub.ch)
+	}
+	db.subs.subs = nil
+}
+
+// onUpdate is called by binding, and must not block
+func (db *Reindexer) onUpdate(namespace string, mode int, data []byte) {
+	nsName := strings.ToLower(namespace)
+	data = append([]byte(nil), data...)
+
+	db.subs.lock.Lock()
+	defer db.subs.lock.Unlock()
+
+	var item interface{}
+	decoded := false
+
+	for _, sub := range db.subs.subs {
+		if sub.namespace != nsName {
+			continue
+		}
+		ev := ChangeEvent{Op: mode, Namespace: namespace, JSON: data, Dropped: sub.dropped}
+		if !sub.opts.RawJSON {
+			if !decoded {
+				item = db.decodeUpdate(nsName, data)
+				decoded = true
+			}
+			ev.Item = item
+		}
+		select {
+		case sub.ch <- ev:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+func (db *Reindexer) decodeUpdate(namespace string, data []byte) interface{} {
+	db.lock.RLock()
+	ns, ok := db.ns[namespace]
+	db.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	item := reflect.New(ns.rtype).Interface()
+	if err := json.Unmarshal(data, item); err != nil {
+		logger.Printf(WARNING, "rq: Can't decode updated item of namespace '%s': %v", namespace, err)
+		return nil
+	}
+	return ns.userItem(item)
+}
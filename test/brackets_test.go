@@ -0,0 +1,137 @@
+package reindexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/restream/reindexer"
+	"github.com/restream/reindexer/dsl"
+)
+
+const testBracketsNs = "test_items_brackets"
+
+func init() {
+	tnamespaces[testBracketsNs] = TestItemSimple{}
+}
+
+func fillTestItemsForBrackets() []*TestItemSimple {
+	tx := newTestTx(DB, testBracketsNs)
+	items := make([]*TestItemSimple, 0, 50)
+	for i := 0; i < 50; i++ {
+		item := &TestItemSimple{
+			ID:    i,
+			Year:  2000 + i%7,
+			Name:  fmt.Sprintf("name%d", i%5),
+			Phone: fmt.Sprintf("phone%d", i%3),
+		}
+		if err := tx.Upsert(item); err != nil {
+			panic(err)
+		}
+		items = append(items, item)
+	}
+	tx.MustCommit(nil)
+	return items
+}
+
+func checkBracketsQuery(t *testing.T, name string, q *reindexer.Query, items []*TestItemSimple, match func(it *TestItemSimple) bool, sorted bool) {
+	res, err := q.Exec().FetchAll()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	expected := []int{}
+	for _, it := range items {
+		if match(it) {
+			expected = append(expected, it.ID)
+		}
+	}
+	got := []int{}
+	for _, it := range res {
+		got = append(got, it.(*TestItemSimple).ID)
+	}
+	if !sorted {
+		sort.Ints(got)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("%s: expected ids %v, got %v", name, expected, got)
+	}
+}
+
+func TestBrackets(t *testing.T) {
+	items := fillTestItemsForBrackets()
+
+	checkBracketsQuery(t, "AND bracket",
+		DB.Query(testBracketsNs).Where("year", reindexer.GT, 2002).
+			OpenBracket().Where("name", reindexer.EQ, "name1").Or().Where("name", reindexer.EQ, "name2").CloseBracket(),
+		items, func(it *TestItemSimple) bool {
+			return it.Year > 2002 && (it.Name == "name1" || it.Name == "name2")
+		}, false)
+
+	checkBracketsQuery(t, "OR bracket with non indexed field",
+		DB.Query(testBracketsNs).Where("id", reindexer.LT, 5).Or().
+			OpenBracket().Where("year", reindexer.EQ, 2003).Where("Phone", reindexer.EQ, "phone1").CloseBracket(),
+		items, func(it *TestItemSimple) bool {
+			return it.ID < 5 || (it.Year == 2003 && it.Phone == "phone1")
+		}, false)
+
+	checkBracketsQuery(t, "NOT bracket",
+		DB.Query(testBracketsNs).Where("id", reindexer.GE, 10).Not().
+			OpenBracket().Where("year", reindexer.EQ, 2004).Or().Where("name", reindexer.EQ, "name0").CloseBracket(),
+		items, func(it *TestItemSimple) bool {
+			return it.ID >= 10 && !(it.Year == 2004 || it.Name == "name0")
+		}, false)
+
+	checkBracketsQuery(t, "nested brackets",
+		DB.Query(testBracketsNs).
+			OpenBracket().Where("id", reindexer.GT, 3).
+			OpenBracket().Where("name", reindexer.EQ, "name3").Or().Where("Phone", reindexer.EQ, "phone2").CloseBracket().
+			CloseBracket().
+			Or().Where("id", reindexer.EQ, 0),
+		items, func(it *TestItemSimple) bool {
+			return (it.ID > 3 && (it.Name == "name3" || it.Phone == "phone2")) || it.ID == 0
+		}, false)
+
+	// Bracket result must not depend on sort order of main query
+	byYearDesc := func(items []*TestItemSimple) []*TestItemSimple {
+		sorted := append([]*TestItemSimple{}, items...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].Year != sorted[j].Year {
+				return sorted[i].Year > sorted[j].Year
+			}
+			return sorted[i].ID < sorted[j].ID
+		})
+		return sorted
+	}
+	checkBracketsQuery(t, "bracket with sort",
+		DB.Query(testBracketsNs).Sort("year", true).Sort("id", false).Where("year", reindexer.LE, 2005).
+			OpenBracket().Where("id", reindexer.LT, 20).Or().Where("name", reindexer.EQ, "name4").CloseBracket(),
+		byYearDesc(items), func(it *TestItemSimple) bool {
+			return it.Year <= 2005 && (it.ID < 20 || it.Name == "name4")
+		}, true)
+
+	var d dsl.DSL
+	if err := json.Unmarshal([]byte(`{
+		"namespace": "`+testBracketsNs+`",
+		"filters": [
+			{"op": "AND", "field": "year", "cond": "GE", "value": 2003},
+			{"op": "AND", "filters": [
+				{"op": "AND", "field": "name", "cond": "EQ", "value": "name1"},
+				{"op": "OR", "filters": [
+					{"op": "AND", "field": "Phone", "cond": "EQ", "value": "phone0"},
+					{"op": "AND", "field": "id", "cond": "LT", "value": 25}
+				]}
+			]}
+		]
+	}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	q, err := DB.QueryFrom(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBracketsQuery(t, "DSL nested filters", q, items, func(it *TestItemSimple) bool {
+		return it.Year >= 2003 && (it.Name == "name1" || (it.Phone == "phone0" && it.ID < 25))
+	}, false)
+}
@@ -0,0 +1,225 @@
+// Code generated by rxgen. DO NOT EDIT.
+
+package reindexer
+
+import (
+	"github.com/restream/reindexer/cjson"
+)
+
+// EncodeCJSON writes fields of TestItemCodec to ser
+func (v *TestItemCodec) EncodeCJSON(ser *cjson.Serializer) {
+	ser.PutFieldInt("id", int64(v.ID))
+	if v.TestCodecEmbed == nil {
+		ser.PutFieldNull("TestCodecEmbed")
+	}
+	if v.TestCodecEmbed != nil {
+		ser.PutFieldInt("genre", v.TestCodecEmbed.Genre)
+	}
+	ser.PutFieldString("name", v.Name)
+	ser.PutFieldDouble("rate", v.Rate)
+	if v.Active {
+		ser.PutFieldBool("active", v.Active)
+	}
+	if v.Counts == nil {
+		ser.PutFieldNull("counts")
+	} else {
+		ser.PutFieldArray("counts", len(v.Counts), cjson.TAG_VARINT)
+		for _, e := range v.Counts {
+			ser.PutVarInt(int64(e))
+		}
+	}
+	ser.PutFieldArray("prices", len(v.Prices), cjson.TAG_DOUBLE)
+	for _, e := range v.Prices {
+		ser.PutDouble(e)
+	}
+	if v.Data == nil {
+		ser.PutFieldNull("data")
+	} else {
+		ser.PutFieldBytes("data", v.Data)
+	}
+	if v.PStr == nil {
+		ser.PutFieldNull("pstr")
+	} else {
+		ser.PutFieldString("pstr", *v.PStr)
+	}
+	ser.PutFieldObject("nested")
+	v.Nested.EncodeCJSON(ser)
+	ser.PutFieldEnd()
+	if v.PNested == nil {
+		ser.PutFieldNull("pnested")
+	} else {
+		ser.PutFieldObject("pnested")
+		v.PNested.EncodeCJSON(ser)
+		ser.PutFieldEnd()
+	}
+	if v.Children == nil {
+		ser.PutFieldNull("children")
+	} else {
+		ser.PutFieldArray("children", len(v.Children), cjson.TAG_OBJECT)
+		for _, e := range v.Children {
+			if e == nil {
+				ser.PutFieldNull("")
+				continue
+			}
+			ser.PutFieldObject("")
+			e.EncodeCJSON(ser)
+			ser.PutFieldEnd()
+		}
+	}
+	ser.PutFieldValue("attrs", v.Attrs, false)
+	ser.PutFieldValue("extra", v.Extra, false)
+	ser.PutFieldValue("time", v.Time, false)
+	ser.PutFieldValue("status", v.Status, false)
+}
+
+// DecodeCJSON reads fields of TestItemCodec from r
+func (v *TestItemCodec) DecodeCJSON(r *cjson.Reader) {
+	for r.Next() {
+		switch r.Name() {
+		case "id":
+			v.ID = int(r.Int())
+		case "genre":
+			if v.TestCodecEmbed == nil {
+				v.TestCodecEmbed = new(TestCodecEmbed)
+			}
+			v.TestCodecEmbed.Genre = r.Int()
+		case "name":
+			v.Name = r.String()
+		case "rate":
+			v.Rate = r.Float()
+		case "active":
+			v.Active = r.Bool()
+		case "counts":
+			a := r.Array()
+			v.Counts = make([]int32, a.Len())
+			for i := range v.Counts {
+				if a.Next() {
+					v.Counts[i] = int32(r.Int())
+				}
+			}
+		case "prices":
+			a := r.Array()
+			for i := 0; i < a.Len(); i++ {
+				if a.Next() {
+					v.Prices[i] = r.Float()
+				}
+			}
+		case "data":
+			v.Data = r.Bytes()
+		case "pstr":
+			if v.PStr == nil {
+				v.PStr = new(string)
+			}
+			*v.PStr = r.String()
+		case "nested":
+			if r.Object() {
+				v.Nested.DecodeCJSON(r)
+			}
+		case "pnested":
+			if r.Object() {
+				if v.PNested == nil {
+					v.PNested = new(TestCodecNested)
+				}
+				v.PNested.DecodeCJSON(r)
+			}
+		case "children":
+			a := r.Array()
+			v.Children = make([]*TestCodecNested, a.Len())
+			for i := range v.Children {
+				if a.Next() && r.Object() {
+					v.Children[i] = new(TestCodecNested)
+					v.Children[i].DecodeCJSON(r)
+				}
+			}
+		case "attrs":
+			r.Value(&v.Attrs)
+		case "extra":
+			r.Value(&v.Extra)
+		case "time":
+			r.Value(&v.Time)
+		case "status":
+			r.Value(&v.Status)
+		default:
+			r.Skip()
+		}
+	}
+}
+
+// DeepCopy returns deep copy of TestItemCodec
+func (v *TestItemCodec) DeepCopy() interface{} {
+	c := *v
+	if v.TestCodecEmbed != nil {
+		e := *v.TestCodecEmbed
+		c.TestCodecEmbed = &e
+	}
+	if v.Counts != nil {
+		c.Counts = append(v.Counts[:0:0], v.Counts...)
+	}
+	if v.Data != nil {
+		c.Data = append(v.Data[:0:0], v.Data...)
+	}
+	if v.PStr != nil {
+		e := *v.PStr
+		c.PStr = &e
+	}
+	c.Nested = *v.Nested.DeepCopy().(*TestCodecNested)
+	if v.PNested != nil {
+		c.PNested = v.PNested.DeepCopy().(*TestCodecNested)
+	}
+	if v.Children != nil {
+		c.Children = make([]*TestCodecNested, len(v.Children))
+		for i, e := range v.Children {
+			if e != nil {
+				c.Children[i] = e.DeepCopy().(*TestCodecNested)
+			}
+		}
+	}
+	cjson.DeepCopyValue(&c.Attrs, &v.Attrs)
+	cjson.DeepCopyValue(&c.Extra, &v.Extra)
+	cjson.DeepCopyValue(&c.Time, &v.Time)
+	cjson.DeepCopyValue(&c.Status, &v.Status)
+	return &c
+}
+
+// EncodeCJSON writes fields of TestCodecNested to ser
+func (v *TestCodecNested) EncodeCJSON(ser *cjson.Serializer) {
+	ser.PutFieldString("name", v.Name)
+	ser.PutFieldInt("age", int64(v.Age))
+	if len(v.Tags) != 0 {
+		ser.PutFieldArray("tags", len(v.Tags), cjson.TAG_STRING)
+		for _, e := range v.Tags {
+			ser.PutVString(e)
+		}
+	}
+}
+
+// DecodeCJSON reads fields of TestCodecNested from r
+func (v *TestCodecNested) DecodeCJSON(r *cjson.Reader) {
+	for r.Next() {
+		switch r.Name() {
+		case "name":
+			v.Name = r.String()
+		case "age":
+			v.Age = int(r.Int())
+		case "tags":
+			a := r.Array()
+			v.Tags = make([]string, a.Len())
+			for i := range v.Tags {
+				if a.Next() {
+					v.Tags[i] = r.String()
+				}
+			}
+		default:
+			r.Skip()
+		}
+	}
+}
+
+// DeepCopy returns deep copy of TestCodecNested
+func (v *TestCodecNested) DeepCopy() interface{} {
+	c := *v
+	if v.Tags != nil {
+		c.Tags = append(v.Tags[:0:0], v.Tags...)
+	}
+	return &c
+}
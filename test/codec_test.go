@@ -0,0 +1,126 @@
+package reindexer
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//go:generate go run ../cmd/rxgen -type TestItemCodec -output codec_gen_test.go
+
+func init() {
+	tnamespaces["test_items_codec"] = TestItemCodec{}
+	tnamespaces["test_items_codec_reflect"] = TestItemCodecReflect{}
+}
+
+type TestCodecEmbed struct {
+	Genre int64 `reindex:"genre,tree" json:"genre"`
+}
+
+type TestCodecNested struct {
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type TestItemCodec struct {
+	ID int `reindex:"id,,pk" json:"id"`
+	*TestCodecEmbed
+	Name     string             `reindex:"name" json:"name"`
+	Rate     float64            `json:"rate"`
+	Active   bool               `json:"active,omitempty"`
+	Counts   []int32            `reindex:"counts" json:"counts"`
+	Prices   [3]float64         `json:"prices"`
+	Data     []byte             `json:"data"`
+	PStr     *string            `json:"pstr"`
+	Nested   TestCodecNested    `json:"nested"`
+	PNested  *TestCodecNested   `json:"pnested"`
+	Children []*TestCodecNested `json:"children"`
+	Attrs    map[string]int     `json:"attrs"`
+	Extra    interface{}        `json:"extra"`
+	Time     time.Time          `json:"time"`
+	Status   TestStatus         `json:"status"`
+}
+
+// TestItemCodecReflect has the same fields, as TestItemCodec, but it's encoded by reflection
+type TestItemCodecReflect TestItemCodec
+
+func newTestItemCodec(i int) *TestItemCodec {
+	str := "pstr" + strconv.Itoa(i)
+	item := &TestItemCodec{
+		ID:       i,
+		Name:     "item" + strconv.Itoa(i),
+		Rate:     float64(i) / 4,
+		Active:   i%2 == 0,
+		Counts:   []int32{int32(i), int32(i * 2)},
+		Prices:   [3]float64{1.5, float64(i), 3},
+		Data:     []byte{byte(i), 1, 2},
+		Nested:   TestCodecNested{Name: "nested", Age: i, Tags: []string{"a", "b"}},
+		Children: []*TestCodecNested{{Name: "child", Age: 1}, nil, {Name: "child2"}},
+		Attrs:    map[string]int{"x": i},
+		Extra:    map[string]interface{}{"key": "value"},
+		Time:     time.Unix(1234567890+int64(i), 0).UTC(),
+		Status:   TestStatus(i % 3),
+	}
+	if i%3 != 0 {
+		item.TestCodecEmbed = &TestCodecEmbed{Genre: int64(i % 5)}
+		item.PStr = &str
+		item.PNested = &TestCodecNested{Name: "pnested", Age: i}
+	}
+	return item
+}
+
+func TestGeneratedCodec(t *testing.T) {
+	items := make([]*TestItemCodec, 0, 10)
+	for i := 0; i < 10; i++ {
+		item := newTestItemCodec(i)
+		if err := DB.Upsert("test_items_codec", item); err != nil {
+			panic(err)
+		}
+		if err := DB.Upsert("test_items_codec_reflect", (*TestItemCodecReflect)(item)); err != nil {
+			panic(err)
+		}
+		items = append(items, item)
+	}
+
+	got, err := DB.Query("test_items_codec").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(got) != len(items) {
+		t.Fatalf("Expected %d items, but got %d", len(items), len(got))
+	}
+	for i, item := range got {
+		if !reflect.DeepEqual(item, items[i]) {
+			t.Fatalf("Item is not decoded by generated codec:\n%+v\nexpected:\n%+v", item, items[i])
+		}
+	}
+
+	// Items, encoded by generated codec and by reflection, must be the same
+	jsonCodec, err := DB.Query("test_items_codec").Sort("id", false).ExecToJson().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	jsonReflect, err := DB.Query("test_items_codec_reflect").Sort("id", false).ExecToJson().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	jsonCodec = bytes.Replace(jsonCodec, []byte("test_items_codec"), []byte("test_items_codec_reflect"), 1)
+	if !bytes.Equal(jsonCodec, jsonReflect) {
+		t.Fatalf("Items, encoded by generated codec:\n%s\nand by reflection:\n%s\nare not equal", string(jsonCodec), string(jsonReflect))
+	}
+
+	item := items[1]
+	copied := item.DeepCopy().(*TestItemCodec)
+	if !reflect.DeepEqual(copied, item) {
+		t.Fatalf("Item is not copied:\n%+v\nexpected:\n%+v", copied, item)
+	}
+	copied.Counts[0], copied.Nested.Tags[0], copied.PNested.Name, copied.Children[0].Age, copied.Genre = 100, "x", "x", 100, 100
+	*copied.PStr = "x"
+	copied.Attrs["x"], copied.Extra.(map[string]interface{})["key"] = 100, "x"
+	if !reflect.DeepEqual(items[1], newTestItemCodec(1)) {
+		t.Fatalf("Copy of item is not deep")
+	}
+}
@@ -0,0 +1,61 @@
+package reindexer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+)
+
+func init() {
+	tnamespaces["test_items_context"] = TestItemSimple{}
+}
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	item := &TestItemSimple{ID: 1, Year: 2001, Name: "item1"}
+	if err := DB.UpsertCtx(ctx, "test_items_context", item); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled on upsert, but got: %v", err)
+	}
+
+	if err := DB.Upsert("test_items_context", item); err != nil {
+		panic(err)
+	}
+
+	it := DB.Query("test_items_context").ExecCtx(ctx)
+	if it.Error() != context.Canceled {
+		t.Fatalf("Expected context.Canceled on select, but got: %v", it.Error())
+	}
+	it.Close()
+
+	if err := DB.PingCtx(ctx); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled on ping, but got: %v", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	item := &TestItemSimple{ID: 2, Year: 2002, Name: "item2"}
+	if err := DB.UpsertCtx(ctx, "test_items_context", item); err != nil {
+		panic(err)
+	}
+
+	items, err := DB.Query("test_items_context").WhereInt("id", reindexer.EQ, 2).ExecCtx(ctx).FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("Expected 1 item, but got %d", len(items))
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, err := DB.Query("test_items_context").DeleteCtx(expired); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded on delete, but got: %v", err)
+	}
+}
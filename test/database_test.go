@@ -0,0 +1,59 @@
+package reindexer
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+func TestBuiltinCreateDropDatabase(t *testing.T) {
+	if udsn, err := url.Parse(*dsn); err != nil || udsn.Scheme != "builtin" {
+		t.Skip("Databases in storage directory are created only by builtin binding")
+	}
+
+	dir, err := ioutil.TempDir("", "reindex_databases_test")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := reindexer.NewReindex("builtin://" + filepath.Join(dir, "main"))
+	defer db.Close()
+
+	if err := db.CreateDatabase("second"); err != nil {
+		t.Fatalf("Can't create database: %v", err)
+	}
+	second := reindexer.NewReindex("builtin://" + filepath.Join(dir, "second"))
+	if err := second.OpenNamespace("test_items_second_db", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	if err := second.Upsert("test_items_second_db", &TestItemSimple{ID: 1, Name: "second"}); err != nil {
+		panic(err)
+	}
+	second.Close()
+	// Creation of existing database is no-op
+	if err := db.CreateDatabase("second"); err != nil {
+		t.Fatalf("Can't create existing database: %v", err)
+	}
+
+	if err := db.CreateDatabase("invalid/name"); err == nil {
+		t.Fatalf("Expected error on creation of database with invalid name")
+	}
+	if err := db.DropDatabase("main"); err == nil {
+		t.Fatalf("Expected error on drop of database, which is used by binding")
+	}
+	if err := db.DropDatabase("missing"); err == nil {
+		t.Fatalf("Expected error on drop of missing database")
+	}
+
+	if err := db.DropDatabase("second"); err != nil {
+		t.Fatalf("Can't drop database: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "second")); !os.IsNotExist(err) {
+		t.Fatalf("Storage of dropped database exists")
+	}
+}
@@ -0,0 +1,90 @@
+package reindexer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+func TestDumpRestore(t *testing.T) {
+	// Dump is restored to the second database, so for builtin binding it's created in another directory
+	src := DB
+	dstDSN := "memory://"
+	if udsn, err := url.Parse(*dsn); err == nil && udsn.Scheme == "builtin" {
+		dir, err := ioutil.TempDir("", "reindex_dump_test")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(dir)
+		dstDSN = "builtin://" + dir
+	}
+	dst := reindexer.NewReindex(dstDSN)
+	defer dst.Close()
+
+	if err := src.OpenNamespace("test_items_dump", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := src.Upsert("test_items_dump", &TestItemSimple{ID: i, Year: 2000 + i, Name: "dump", Phone: "<123>"}); err != nil {
+			panic(err)
+		}
+	}
+	if err := src.PutMeta("test_items_dump", "schema", []byte{0, 1, 2}); err != nil {
+		panic(err)
+	}
+
+	var dump bytes.Buffer
+	if err := src.DumpNamespace("test_items_dump", &dump); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.RestoreNamespace(bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := dst.DescribeNamespace("test_items_dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(desc.Indexes) != 3 {
+		t.Fatalf("Expected 3 restored indexes, but got %+v", desc.Indexes)
+	}
+	if meta, err := dst.GetMeta("test_items_dump", "schema"); err != nil || !bytes.Equal(meta, []byte{0, 1, 2}) {
+		t.Fatalf("Unexpected restored meta: %v, %v", meta, err)
+	}
+
+	if err := dst.OpenNamespace("test_items_dump", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		t.Fatal(err)
+	}
+	items, err := dst.Query("test_items_dump").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 10 {
+		t.Fatalf("Expected 10 restored items, but got %d", len(items))
+	}
+	for i, item := range items {
+		if *item.(*TestItemSimple) != (TestItemSimple{ID: i, Year: 2000 + i, Name: "dump", Phone: "<123>"}) {
+			t.Fatalf("Unexpected restored item: %+v", item)
+		}
+	}
+
+	// Restore to namespace with items is idempotent
+	if err := dst.RestoreNamespace(bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if cnt := dst.Query("test_items_dump").Exec().Count(); cnt != 10 {
+		t.Fatalf("Expected 10 items after second restore, but got %d", cnt)
+	}
+
+	if err := dst.RestoreNamespace(bytes.NewReader([]byte(`{"type":"item","item":{"id":1}}`))); err == nil {
+		t.Fatalf("Expected error on dump without namespace record")
+	}
+	if err := dst.RestoreNamespace(bytes.NewReader([]byte(`{"type":"namespace","version":1}`))); err == nil || !strings.Contains(err.Error(), "namespace definition") {
+		t.Fatalf("Expected error on namespace record without definition, but got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package reindexer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+func TestDynamicNamespace(t *testing.T) {
+	indexes := []reindexer.IndexDef{
+		{Name: "id", JSONPaths: []string{"id"}, IndexType: "hash", FieldType: "int64", IsPK: true},
+		{Name: "name", JSONPaths: []string{"name"}, IndexType: "hash", FieldType: "string"},
+		{Name: "year", JSONPaths: []string{"year"}, IndexType: "tree", FieldType: "int64"},
+	}
+	if err := DB.OpenDynamicNamespace("test_items_dynamic", reindexer.DefaultNamespaceOptions(), indexes); err != nil {
+		panic(err)
+	}
+
+	if err := DB.Upsert("test_items_dynamic", map[string]interface{}{
+		"id":     1,
+		"name":   "first",
+		"year":   2000,
+		"tags":   []interface{}{"a", "b"},
+		"nested": map[string]interface{}{"rate": 1.5},
+	}); err != nil {
+		panic(err)
+	}
+	tx := DB.MustBeginTx("test_items_dynamic")
+	for i := 2; i < 5; i++ {
+		if err := tx.Upsert(map[string]interface{}{"id": i, "name": fmt.Sprintf("item%d", i), "year": 2000 + i}); err != nil {
+			panic(err)
+		}
+	}
+	tx.MustCommit(nil)
+	if err := DB.Delete("test_items_dynamic", map[string]interface{}{"id": 4}); err != nil {
+		panic(err)
+	}
+
+	items, err := DB.Query("test_items_dynamic").WhereInt("year", reindexer.GE, 2000).Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items, but got %d", len(items))
+	}
+	first, ok := items[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map item, but got %T", items[0])
+	}
+	if fmt.Sprintf("%v %v %v %v", first["id"], first["name"], first["year"], first["tags"]) != "1 first 2000 [a b]" {
+		t.Fatalf("Unexpected item: %v", first)
+	}
+	if nested, ok := first["nested"].(map[string]interface{}); !ok || nested["rate"] != 1.5 {
+		t.Fatalf("Unexpected nested object of item: %v", first)
+	}
+	if name := items[2].(map[string]interface{})["name"]; name != "item3" {
+		t.Fatalf("Unexpected name of item: %v", name)
+	}
+
+	// Items of dynamic namespace are maps only
+	func() {
+		defer func() {
+			if ret := recover(); ret != reindexer.ErrWrongType {
+				t.Fatalf("Expected wrong type panic, but got %v", ret)
+			}
+		}()
+		DB.Upsert("test_items_dynamic", &TestItemSimple{ID: 5})
+	}()
+}
@@ -0,0 +1,99 @@
+package reindexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+	"github.com/restream/reindexer/bindings"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	errInjected := errors.New("injected error")
+
+	trace := func(ctx context.Context, call *bindings.Call, invoker bindings.Invoker) (bindings.RawBuffer, error) {
+		calls = append(calls, call.Method+" "+call.Namespace)
+		return invoker(ctx)
+	}
+	faults := func(ctx context.Context, call *bindings.Call, invoker bindings.Invoker) (bindings.RawBuffer, error) {
+		if call.Method == bindings.MethodDeleteQuery {
+			return nil, errInjected
+		}
+		return invoker(ctx)
+	}
+
+	// Interceptors do not depend on binding, so use memory binding to not interfere with the main test DB
+	db := reindexer.NewReindex("memory://", reindexer.WithInterceptors(trace, faults))
+	defer db.Close()
+
+	if err := db.OpenNamespace("test_items_interceptor", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	if err := db.Upsert("test_items_interceptor", &TestItemSimple{ID: 1, Year: 2018, Name: "interceptor"}); err != nil {
+		panic(err)
+	}
+	calls = calls[:0]
+
+	if _, found := db.Query("test_items_interceptor").WhereInt("id", reindexer.EQ, 1).Get(); !found {
+		t.Fatalf("Item is not found")
+	}
+	if err := db.Upsert("test_items_interceptor", &TestItemSimple{ID: 2, Year: 2018, Name: "interceptor"}); err != nil {
+		panic(err)
+	}
+	if _, err := db.Query("test_items_interceptor").WhereInt("id", reindexer.EQ, 2).Delete(); err != errInjected {
+		t.Fatalf("Expected injected error, but got %v", err)
+	}
+
+	expected := []string{
+		"SelectQuery test_items_interceptor",
+		"ModifyItem test_items_interceptor",
+		"DeleteQuery test_items_interceptor",
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls %v, but got %v", expected, calls)
+	}
+	for i := range calls {
+		if calls[i] != expected[i] {
+			t.Fatalf("Expected calls %v, but got %v", expected, calls)
+		}
+	}
+
+	// Delete query was not executed by binding
+	if _, found := db.Query("test_items_interceptor").WhereInt("id", reindexer.EQ, 2).Get(); !found {
+		t.Fatalf("Item is deleted, though delete query was rejected by interceptor")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	var timeouts []time.Duration
+	interceptor := func(ctx context.Context, call *bindings.Call, invoker bindings.Invoker) (bindings.RawBuffer, error) {
+		if call.Method == bindings.MethodSelectQuery || call.Method == bindings.MethodDeleteQuery {
+			timeout, _ := bindings.TimeoutFromContext(ctx)
+			timeouts = append(timeouts, timeout)
+		}
+		return invoker(ctx)
+	}
+
+	db := reindexer.NewReindex("memory://", reindexer.WithInterceptors(interceptor))
+	defer db.Close()
+
+	if err := db.OpenNamespace("test_items_timeout", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	if _, err := db.Query("test_items_timeout").Timeout(time.Second).Exec().FetchAll(); err != nil {
+		panic(err)
+	}
+	if _, err := db.Query("test_items_timeout").Exec().FetchAll(); err != nil {
+		panic(err)
+	}
+	if _, err := db.Query("test_items_timeout").Timeout(2 * time.Second).Delete(); err != nil {
+		panic(err)
+	}
+
+	if len(timeouts) != 3 || timeouts[0] != time.Second || timeouts[1] != 0 || timeouts[2] != 2*time.Second {
+		t.Fatalf("Unexpected timeouts of requests: %v", timeouts)
+	}
+}
@@ -1,8 +1,11 @@
 package reindexer
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -12,6 +15,8 @@ import (
 
 func init() {
 	tnamespaces["test_items_iter"] = TestItem{}
+	tnamespaces["test_items_iter_into"] = TestItem{}
+	tnamespaces["test_items_iter_json"] = TestItem{}
 }
 
 func TestQueryIter(t *testing.T) {
@@ -58,6 +63,108 @@ func TestQueryIter(t *testing.T) {
 	}
 }
 
+func TestQueryFetchInto(t *testing.T) {
+	total := 10
+	for i := 0; i < total; i++ {
+		if err := DB.Upsert("test_items_iter_into", newTestItem(2000+i, 5)); err != nil {
+			panic(err)
+		}
+	}
+	items, err := DB.Query("test_items_iter_into").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+
+	var ptrs []*TestItem
+	if err = DB.Query("test_items_iter_into").Sort("id", false).Exec().FetchAllInto(&ptrs); err != nil {
+		t.Fatalf("FetchAllInto returns error: %v", err)
+	}
+	var values []TestItem
+	if err = DB.Query("test_items_iter_into").Sort("id", false).Exec().FetchAllInto(&values); err != nil {
+		t.Fatalf("FetchAllInto returns error: %v", err)
+	}
+	if len(ptrs) != total || len(values) != total {
+		t.Fatalf("Unexpected result count: %d and %d (want %d)", len(ptrs), len(values), total)
+	}
+	for i, item := range items {
+		if !reflect.DeepEqual(ptrs[i], item) || !reflect.DeepEqual(&values[i], item) {
+			t.Fatalf("Item is not decoded:\n%+v\n%+v\nexpected:\n%+v", ptrs[i], values[i], item)
+		}
+	}
+
+	var item TestItem
+	found, err := DB.Query("test_items_iter_into").WhereInt("id", reindexer.EQ, items[5].(*TestItem).ID).GetInto(&item)
+	if err != nil || !found || !reflect.DeepEqual(&item, items[5]) {
+		t.Fatalf("GetInto returns %v, %v, item %+v", found, err, item)
+	}
+	if found, err = DB.Query("test_items_iter_into").WhereInt("id", reindexer.EQ, -1).GetInto(&item); err != nil || found {
+		t.Fatalf("GetInto returns %v, %v for not existing item", found, err)
+	}
+
+	var wrong []TestItemObjCache
+	if err = DB.Query("test_items_iter_into").Exec().FetchAllInto(&wrong); err != reindexer.ErrWrongType {
+		t.Fatalf("FetchAllInto returns %v for slice of wrong type", err)
+	}
+	if err = DB.Query("test_items_iter_into").Exec().FetchAllInto(ptrs); err != reindexer.ErrMustBePointer {
+		t.Fatalf("FetchAllInto returns %v for not pointer", err)
+	}
+}
+
+func TestQueryJSONIter(t *testing.T) {
+	total := 10
+	for i := 0; i < total; i++ {
+		if err := DB.Upsert("test_items_iter_json", newTestItem(3000+i, 5)); err != nil {
+			panic(err)
+		}
+	}
+
+	// Results are fetched by pages of 3 items
+	it := DB.Query("test_items_iter_json").Sort("id", false).ReqTotal().FetchCount(3).ExecToJson()
+	ids := make([]int, 0, total)
+	for it.Next() {
+		item := TestItem{}
+		if err := json.Unmarshal(it.JSON(), &item); err != nil {
+			t.Fatalf("Can't unmarshal item %s: %v", string(it.JSON()), err)
+		}
+		ids = append(ids, item.ID)
+	}
+	if it.Error() != nil {
+		t.Fatalf("Iter has error: %v", it.Error())
+	}
+	it.Close()
+	if len(ids) != total {
+		t.Fatalf("Unexpected result count: %d (want %d)", len(ids), total)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Fatalf("Items are not sorted: %v", ids)
+		}
+	}
+
+	data, err := DB.Query("test_items_iter_json").Sort("id", false).ReqTotal("total_count").FetchCount(3).ExecToJson("items").FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	res := struct {
+		Total int        `json:"total_count"`
+		Items []TestItem `json:"items"`
+	}{}
+	if err = json.Unmarshal(data, &res); err != nil {
+		t.Fatalf("Can't unmarshal results %s: %v", string(data), err)
+	}
+	if res.Total != total || len(res.Items) != total || res.Items[total-1].ID != ids[total-1] {
+		t.Fatalf("Unexpected results: total %d, %d items", res.Total, len(res.Items))
+	}
+
+	buf := &bytes.Buffer{}
+	if err = DB.Query("test_items_iter_json").Sort("id", false).ReqTotal("total_count").FetchCount(3).ExecToJSONWriter(buf, "items"); err != nil {
+		t.Fatalf("ExecToJSONWriter returns error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("ExecToJSONWriter output:\n%s\nis not equal to FetchAll output:\n%s", buf.String(), string(data))
+	}
+}
+
 func TestRaceConditions(t *testing.T) {
 	FillTestJoinItems(7000, 2000)
 	done := make(chan bool)
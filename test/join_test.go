@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -15,6 +16,14 @@ import (
 func init() {
 	tnamespaces["test_items_for_join"] = TestItem{}
 	tnamespaces["test_join_items"] = TestJoinItem{}
+	tnamespaces["test_items_json_join"] = TestJSONJoinItem{}
+	tnamespaces["test_items_json_joined"] = TestJoinItem{}
+}
+
+type TestJSONJoinItem struct {
+	ID     int             `reindex:"id,,pk" json:"id"`
+	Amount int             `reindex:"amount" json:"amount"`
+	Prices []*TestJoinItem `reindex:"prices,,joined" json:"-"`
 }
 
 type TestJoinItem struct {
@@ -251,3 +260,61 @@ func TestJoinQueryResultsOnIterator(t *testing.T) {
 		}
 	}
 }
+
+func TestJoinJSON(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		if err := DB.Upsert("test_items_json_joined", &TestJoinItem{ID: i, Name: "price_" + strconv.Itoa(i), Amount: i % 3}); err != nil {
+			panic(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := DB.Upsert("test_items_json_join", &TestJSONJoinItem{ID: i, Amount: i}); err != nil {
+			panic(err)
+		}
+	}
+
+	type joinedItem struct {
+		ID     int            `json:"id"`
+		Amount int            `json:"amount"`
+		Prices []TestJoinItem `json:"joined_test_items_json_joined"`
+	}
+	check := func(data []byte) {
+		res := map[string][]joinedItem{}
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatalf("Can't unmarshal joined results %s: %v", string(data), err)
+		}
+		items := res["test_items_json_join"]
+		if len(items) != 3 {
+			t.Fatalf("Expected 3 items, but got %s", string(data))
+		}
+		for i, item := range items {
+			ids := []int{}
+			for _, price := range item.Prices {
+				ids = append(ids, price.ID)
+			}
+			sort.Ints(ids)
+			expected := []int{i, i + 3}
+			if i+3 >= 5 {
+				expected = expected[:1]
+			}
+			if item.ID != i || item.Amount != i || !reflect.DeepEqual(ids, expected) {
+				t.Fatalf("Unexpected joined item %+v in %s", item, string(data))
+			}
+		}
+	}
+
+	q := DB.Query("test_items_json_join").Sort("id", false)
+	q.LeftJoin(DB.Query("test_items_json_joined"), "prices").On("amount", reindexer.EQ, "amount")
+	data, err := q.ExecToJson().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	check(data)
+
+	data, err = DB.ExecSQLToJSON("SELECT * FROM test_items_json_join LEFT JOIN test_items_json_joined " +
+		"ON test_items_json_join.amount = test_items_json_joined.amount ORDER BY id").FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	check(data)
+}
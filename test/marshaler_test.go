@@ -0,0 +1,155 @@
+package reindexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+// TestUUID is encoded as string by encoding.TextMarshaler
+type TestUUID [16]byte
+
+func (u TestUUID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(u[:])), nil
+}
+
+func (u TestUUID) String() string {
+	return hex.EncodeToString(u[:])
+}
+
+func (u *TestUUID) UnmarshalText(data []byte) error {
+	b, err := hex.DecodeString(string(data))
+	if err != nil || len(b) != len(u) {
+		return fmt.Errorf("invalid uuid '%s'", string(data))
+	}
+	copy(u[:], b)
+	return nil
+}
+
+// TestDecimal is encoded as JSON string by json.Marshaler
+type TestDecimal struct {
+	Units int64
+	Cents int64
+}
+
+func (d TestDecimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%d.%02d", d.Units, d.Cents))
+}
+
+func (d *TestDecimal) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	_, err := fmt.Sscanf(str, "%d.%d", &d.Units, &d.Cents)
+	return err
+}
+
+// TestStatus is encoded as string by cjson.Marshaler, though it's integer in go
+type TestStatus int
+
+var testStatusNames = []string{"draft", "active", "archived"}
+
+func (s TestStatus) MarshalCJSON() (interface{}, error) {
+	return testStatusNames[s], nil
+}
+
+func (s *TestStatus) UnmarshalCJSON(v interface{}) error {
+	for i, name := range testStatusNames {
+		if name == v {
+			*s = TestStatus(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid status %v", v)
+}
+
+type TestItemMarshaler struct {
+	ID       TestUUID              `reindex:"id,,pk" json:"id"`
+	Price    TestDecimal           `reindex:"price" json:"price"`
+	Status   TestStatus            `reindex:"status" json:"status"`
+	IP       net.IP                `reindex:"ip" json:"ip"`
+	IPs      []net.IP              `reindex:"ips" json:"ips"`
+	PStatus  *TestStatus           `json:"pstatus,omitempty"`
+	Statuses map[string]TestStatus `json:"statuses"`
+	Prices   []TestDecimal         `json:"prices"`
+}
+
+func TestCustomMarshalers(t *testing.T) {
+	ns := "test_items_marshaler"
+	if err := DB.OpenNamespace(ns, reindexer.DefaultNamespaceOptions(), TestItemMarshaler{}); err != nil {
+		panic(err)
+	}
+	desc, err := DB.DescribeNamespace(ns)
+	if err != nil {
+		panic(err)
+	}
+	types := map[string]string{}
+	for _, idx := range desc.Indexes {
+		types[idx.Name] = fmt.Sprintf("%s %v", idx.FieldType, idx.IsArray)
+	}
+	if !reflect.DeepEqual(types, map[string]string{"id": "string false", "price": "string false", "status": "string false",
+		"ip": "string false", "ips": "string true"}) {
+		t.Fatalf("Unexpected indexes: %v", types)
+	}
+
+	items := make([]*TestItemMarshaler, 0, 10)
+	for i := 0; i < 10; i++ {
+		status := TestStatus(i % 3)
+		item := &TestItemMarshaler{
+			Price:    TestDecimal{Units: int64(i), Cents: 50},
+			Status:   status,
+			IP:       net.IPv4(10, 0, 0, byte(i)),
+			IPs:      []net.IP{net.ParseIP("::1"), net.IPv4(192, 168, 0, byte(i))},
+			Statuses: map[string]TestStatus{"prev": TestStatus((i + 1) % 3)},
+			Prices:   []TestDecimal{{Units: 1, Cents: int64(i)}},
+		}
+		item.ID[15] = byte(i)
+		if i%2 == 0 {
+			item.PStatus = &status
+		}
+		if err := DB.Upsert(ns, item); err != nil {
+			panic(err)
+		}
+		items = append(items, item)
+	}
+
+	got, err := DB.Query(ns).Where("status", reindexer.EQ, "active").Sort("price", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 active items, but got %d", len(got))
+	}
+	for i, it := range got {
+		expected := items[1+i*3]
+		if !reflect.DeepEqual(it, expected) {
+			t.Fatalf("Item is not decoded:\n%+v\nexpected:\n%+v", it, expected)
+		}
+	}
+
+	item, found := DB.Query(ns).Where("id", reindexer.EQ, items[4].ID.String()).Get()
+	if !found || !reflect.DeepEqual(item, items[4]) {
+		t.Fatalf("Item is not found by uuid: %+v", item)
+	}
+	if _, found = DB.Query(ns).Where("ips", reindexer.EQ, "192.168.0.7").Get(); !found {
+		t.Fatalf("Item is not found by ip")
+	}
+
+	data, err := DB.Query(ns).Where("id", reindexer.EQ, items[2].ID.String()).ExecToJson().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, expected := range []string{`"id":"00000000000000000000000000000002"`, `"price":"2.50"`, `"status":"archived"`,
+		`"ip":"10.0.0.2"`, `"ips":["::1","192.168.0.2"]`, `"pstatus":"archived"`, `"statuses":{"prev":"draft"}`, `"prices":["1.02"]`} {
+		if !strings.Contains(string(data), expected) {
+			t.Fatalf("Expected %s in JSON of item: %s", expected, string(data))
+		}
+	}
+}
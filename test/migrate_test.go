@@ -0,0 +1,62 @@
+package reindexer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+	"github.com/restream/reindexer/migrate"
+)
+
+func TestMigrateConcurrently(t *testing.T) {
+	DB.DropNamespace("test_items_migrate")
+	if err := DB.OpenNamespace("test_items_migrate", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	if err := DB.Upsert("test_items_migrate", &TestItemSimple{ID: 1, Name: "migrate"}); err != nil {
+		panic(err)
+	}
+
+	var executed int32
+	var wg sync.WaitGroup
+	registries := []*migrate.Registry{migrate.NewRegistry(), migrate.NewRegistry()}
+	errs := make([]error, len(registries))
+	for i, r := range registries {
+		// Each registry is one instance of application
+		r.LockRetry = 10 * time.Millisecond
+		r.Register("test_items_migrate", 1, func(db *reindexer.Reindexer) error {
+			atomic.AddInt32(&executed, 1)
+			time.Sleep(100 * time.Millisecond)
+			_, err := db.Query("test_items_migrate").Set("year", 2018).Update()
+			return err
+		})
+		wg.Add(1)
+		go func(i int, r *migrate.Registry) {
+			defer wg.Done()
+			errs[i] = r.Run(DB)
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if executed != 1 {
+		t.Fatalf("Expected, that migration is executed once, but it's executed %d times", executed)
+	}
+
+	statuses, err := registries[1].Status(DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Version != 1 || len(statuses[0].Pending) != 0 || statuses[0].LockOwner != "" {
+		t.Fatalf("Unexpected migration status: %+v", statuses)
+	}
+	if item, found := DB.Query("test_items_migrate").WhereInt("id", reindexer.EQ, 1).Get(); !found || item.(*TestItemSimple).Year != 2018 {
+		t.Fatalf("Migration was not applied: %+v", item)
+	}
+}
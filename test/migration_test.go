@@ -0,0 +1,73 @@
+package reindexer
+
+import (
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+type TestItemMigrationV1 struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+	Year int    `reindex:"year,tree"`
+	Old  string `reindex:"old"`
+}
+
+type TestItemMigrationV2 struct {
+	ID    int    `reindex:"id,,pk"`
+	Name  string `reindex:"name,tree"`
+	Year  int    `reindex:"year,tree"`
+	Genre string `reindex:"genre"`
+}
+
+func TestMigration(t *testing.T) {
+	// Migration changes indexes of namespace, so use memory binding to not interfere with the main test DB
+	db := reindexer.NewReindex("memory://")
+	defer db.Close()
+
+	if err := db.OpenNamespace("test_items_migration", reindexer.DefaultNamespaceOptions(), TestItemMigrationV1{}); err != nil {
+		panic(err)
+	}
+	if err := db.Upsert("test_items_migration", &TestItemMigrationV1{ID: 1, Name: "migration", Year: 2018, Old: "old"}); err != nil {
+		panic(err)
+	}
+
+	plan, err := db.PlanMigration("test_items_migration", TestItemMigrationV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"drop index 'old'",
+		"update index 'name': index_type hash -> tree",
+		"add index 'genre' (hash, string) on [Genre]",
+	}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("Unexpected migration plan:\n%s", plan)
+	}
+	for i, step := range plan.Steps {
+		if step.Description != expected[i] {
+			t.Fatalf("Unexpected migration plan:\n%s\nexpected:\n%v", plan, expected)
+		}
+	}
+
+	if err = db.ApplyMigration(plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err = db.PlanMigration("test_items_migration", TestItemMigrationV2{}); err != nil || !plan.Empty() {
+		t.Fatalf("Expected empty plan after migration, but got:\n%v, %v", plan, err)
+	}
+	if _, found := db.Query("test_items_migration").WhereInt("id", reindexer.EQ, 1).Get(); !found {
+		t.Fatalf("Item is lost after migration")
+	}
+
+	// Namespace is registered with V1 struct, so AutoMigrate reverts indexes to V1
+	if err = db.OpenNamespace("test_items_migration", reindexer.DefaultNamespaceOptions().AutoMigrate(), TestItemMigrationV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err = db.PlanMigration("test_items_migration", TestItemMigrationV1{}); err != nil || !plan.Empty() {
+		t.Fatalf("Expected empty plan after auto migration, but got:\n%v, %v", plan, err)
+	}
+	if _, found := db.Query("test_items_migration").WhereString("old", reindexer.EQ, "old").Get(); !found {
+		t.Fatalf("Item is lost after auto migration")
+	}
+}
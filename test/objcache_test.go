@@ -0,0 +1,70 @@
+package reindexer
+
+import (
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+type TestItemObjCache struct {
+	ID   int    `reindex:"id,,pk"`
+	Name string `reindex:"name"`
+}
+
+func (item *TestItemObjCache) DeepCopy() interface{} {
+	copyItem := *item
+	return &copyItem
+}
+
+func TestObjCache(t *testing.T) {
+	ns := "test_items_objcache"
+	if err := DB.OpenNamespace(ns, reindexer.DefaultNamespaceOptions().ObjCacheSize(5), TestItemObjCache{}); err != nil {
+		panic(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := DB.Upsert(ns, &TestItemObjCache{ID: i, Name: "item"}); err != nil {
+			panic(err)
+		}
+	}
+
+	checkStats := func(expected reindexer.ObjCacheStats) {
+		if stats := DB.CacheStats()[ns]; stats != expected {
+			t.Fatalf("Unexpected object cache stats %+v, expected %+v", stats, expected)
+		}
+	}
+
+	// All items are decoded, and only 5 recently used items are kept in cache
+	items, err := DB.Query(ns).Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 10 {
+		t.Fatalf("Expected 10 items, but got %d", len(items))
+	}
+	checkStats(reindexer.ObjCacheStats{Size: 5, MaxSize: 5, Misses: 10, Evictions: 5})
+
+	if _, err = DB.Query(ns).Where("id", reindexer.GE, 5).Exec().FetchAll(); err != nil {
+		panic(err)
+	}
+	checkStats(reindexer.ObjCacheStats{Size: 5, MaxSize: 5, Hits: 5, Misses: 10, Evictions: 5})
+
+	// Updated item is dropped from cache
+	if err = DB.Upsert(ns, &TestItemObjCache{ID: 9, Name: "updated"}); err != nil {
+		panic(err)
+	}
+	item, found := DB.Query(ns).WhereInt("id", reindexer.EQ, 9).Get()
+	if !found || item.(*TestItemObjCache).Name != "updated" {
+		t.Fatalf("Updated item is not found: %+v", item)
+	}
+	checkStats(reindexer.ObjCacheStats{Size: 5, MaxSize: 5, Hits: 5, Misses: 11, Evictions: 5})
+
+	// Cached items are copied into slice
+	var values []TestItemObjCache
+	if err = DB.Query(ns).Where("id", reindexer.GE, 5).Sort("id", false).Exec().FetchAllInto(&values); err != nil {
+		panic(err)
+	}
+	if len(values) != 5 || values[0].ID != 5 || values[4].Name != "updated" {
+		t.Fatalf("Unexpected items %+v", values)
+	}
+	checkStats(reindexer.ObjCacheStats{Size: 5, MaxSize: 5, Hits: 10, Misses: 11, Evictions: 5})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"sort"
 	"strconv"
 	"testing"
 
@@ -509,6 +510,114 @@ func CheckAggregateQueries() {
 		panic(fmt.Errorf("%d != %f", ageMax, aggregations[5].Value))
 	}
 
+	CheckAggregateFacetQueries()
+}
+
+func CheckAggregateFacetQueries() {
+	type facetKey struct {
+		age, year int
+	}
+	type facetEntry struct {
+		facetKey
+		count int
+	}
+
+	res, err := DB.Query("test_items").Where("genre", reindexer.LT, 5).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	counts := make(map[facetKey]int)
+	for _, it := range res {
+		testItem := it.(*TestItem)
+		counts[facetKey{testItem.Age, testItem.Year}]++
+	}
+
+	expected := make([]facetEntry, 0, len(counts))
+	for k, c := range counts {
+		expected = append(expected, facetEntry{k, c})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].count != expected[j].count {
+			return expected[i].count > expected[j].count
+		}
+		if expected[i].age != expected[j].age {
+			return expected[i].age < expected[j].age
+		}
+		return expected[i].year > expected[j].year
+	})
+
+	offset, limit := 2, 10
+	if offset > len(expected) {
+		offset = len(expected)
+	}
+	expected = expected[offset:]
+	if limit < len(expected) {
+		expected = expected[:limit]
+	}
+
+	q := DB.Query("test_items").Where("genre", reindexer.LT, 5)
+	q.AggregateFacet("age", "year").Sort("count", true).Sort("age", false).Sort("year", true).Offset(2).Limit(10)
+	it := q.Exec()
+	if err := it.Error(); err != nil {
+		panic(err)
+	}
+	defer it.Close()
+
+	aggregations := it.AggResults()
+	if len(aggregations) != 1 {
+		panic(fmt.Errorf("expected 1 aggregation, got %d", len(aggregations)))
+	}
+	facetRes := aggregations[0]
+	if len(facetRes.Fields) != 2 || facetRes.Fields[0] != "age" || facetRes.Fields[1] != "year" {
+		panic(fmt.Errorf("unexpected facet fields %v", facetRes.Fields))
+	}
+	if len(facetRes.Facets) != len(expected) {
+		panic(fmt.Errorf("expected %d facets, got %d", len(expected), len(facetRes.Facets)))
+	}
+	for i, facet := range facetRes.Facets {
+		exp := expected[i]
+		if len(facet.Values) != 2 || facet.Values[0] != strconv.Itoa(exp.age) || facet.Values[1] != strconv.Itoa(exp.year) ||
+			facet.Count != exp.count {
+			panic(fmt.Errorf("facet #%d: expected %v, got %v:%d", i, exp, facet.Values, facet.Count))
+		}
+	}
+
+	// Options of facet are applied to it, even if they are set after another aggregation is added
+	minAge, years := -1, make(map[int]struct{})
+	for k := range counts {
+		if minAge < 0 || k.age < minAge {
+			minAge = k.age
+		}
+		years[k.year] = struct{}{}
+	}
+	q = DB.Query("test_items").Where("genre", reindexer.LT, 5)
+	ageFacet := q.AggregateFacet("age")
+	q.AggregateFacet("year")
+	ageFacet.Sort("age", false).Limit(1)
+	twoFacetsIt := q.Exec()
+	if err := twoFacetsIt.Error(); err != nil {
+		panic(err)
+	}
+	defer twoFacetsIt.Close()
+
+	aggregations = twoFacetsIt.AggResults()
+	if len(aggregations) != 2 {
+		panic(fmt.Errorf("expected 2 aggregations, got %d", len(aggregations)))
+	}
+	if len(aggregations[0].Facets) != 1 || aggregations[0].Facets[0].Values[0] != strconv.Itoa(minAge) {
+		panic(fmt.Errorf("expected 1 facet with age %d, got %v", minAge, aggregations[0].Facets))
+	}
+	if len(aggregations[1].Facets) != len(years) {
+		panic(fmt.Errorf("expected %d facets of year, got %d", len(years), len(aggregations[1].Facets)))
+	}
+
+	q = DB.Query("test_items")
+	q.AggregateFacet("age", "year").Sort("genre", false)
+	it = q.Exec()
+	defer it.Close()
+	if it.Error() == nil {
+		panic(fmt.Errorf("facet sort by field, which is not in facet, must fail"))
+	}
 }
 
 func CheckTestItemsJsonQueries() {
@@ -11,6 +11,7 @@ import (
 	"github.com/restream/reindexer"
 	_ "github.com/restream/reindexer/bindings/builtin"
 	_ "github.com/restream/reindexer/bindings/cproto"
+	_ "github.com/restream/reindexer/bindings/memory"
 	// _ "github.com/restream/reindexer/pprof"
 )
 
@@ -0,0 +1,73 @@
+package reindexer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/restream/reindexer"
+)
+
+func init() {
+	tnamespaces["test_items_subscribe"] = TestItemSimple{}
+}
+
+func waitChangeEvent(t *testing.T, ch <-chan reindexer.ChangeEvent) reindexer.ChangeEvent {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change event was not received")
+	}
+	return reindexer.ChangeEvent{}
+}
+
+func TestSubscribe(t *testing.T) {
+	if udsn, err := url.Parse(*dsn); err != nil || (udsn.Scheme != "builtin" && udsn.Scheme != "memory" && udsn.Scheme != "cproto" && udsn.Scheme != "cprotos") {
+		t.Skip("Updates are delivered only by builtin, memory and cproto bindings")
+	}
+
+	ch, err := DB.Subscribe("test_items_subscribe", reindexer.SubscribeOptions{})
+	if err != nil {
+		panic(err)
+	}
+	defer DB.Unsubscribe(ch)
+
+	if err := DB.Upsert("test_items_subscribe", &TestItemSimple{ID: 1, Year: 2018, Name: "subscribe"}); err != nil {
+		panic(err)
+	}
+	ev := waitChangeEvent(t, ch)
+	if ev.Op != reindexer.ChangeUpsert || ev.Namespace != "test_items_subscribe" {
+		t.Fatalf("Unexpected change event: %+v", ev)
+	}
+	if item, ok := ev.Item.(*TestItemSimple); !ok || item.ID != 1 || item.Name != "subscribe" {
+		t.Fatalf("Unexpected item in change event: %+v", ev.Item)
+	}
+
+	if err := DB.Delete("test_items_subscribe", &TestItemSimple{ID: 1}); err != nil {
+		panic(err)
+	}
+	if ev = waitChangeEvent(t, ch); ev.Op != reindexer.ChangeDelete {
+		t.Fatalf("Expected delete change event, but got: %+v", ev)
+	}
+
+	// Items deleted by query are reported too
+	if err := DB.Upsert("test_items_subscribe", &TestItemSimple{ID: 2, Year: 2018, Name: "subscribe"}); err != nil {
+		panic(err)
+	}
+	waitChangeEvent(t, ch)
+	if _, err := DB.Query("test_items_subscribe").WhereInt("id", reindexer.EQ, 2).Delete(); err != nil {
+		panic(err)
+	}
+	ev = waitChangeEvent(t, ch)
+	if item, ok := ev.Item.(*TestItemSimple); ev.Op != reindexer.ChangeDelete || !ok || item.ID != 2 || item.Name != "subscribe" {
+		t.Fatalf("Unexpected delete by query change event: %+v", ev)
+	}
+
+	if err := DB.Unsubscribe(ch); err != nil {
+		panic(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatalf("Expected closed channel after unsubscribe")
+	}
+}
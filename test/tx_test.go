@@ -0,0 +1,192 @@
+package reindexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/restream/reindexer"
+	"github.com/restream/reindexer/bindings"
+)
+
+func init() {
+	tnamespaces["test_items_tx"] = TestItemSimple{}
+}
+
+func txItemsCount(t *testing.T) int {
+	items, err := DB.Query("test_items_tx").Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	return len(items)
+}
+
+func TestTxRollback(t *testing.T) {
+	tx := DB.MustBeginTx("test_items_tx")
+	for i := 0; i < 10; i++ {
+		if err := tx.Upsert(&TestItemSimple{ID: i, Year: 2000 + i, Name: "rollback"}); err != nil {
+			panic(err)
+		}
+	}
+
+	if cnt := txItemsCount(t); cnt != 0 {
+		t.Fatalf("Expected no items before commit, but got %d", cnt)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		panic(err)
+	}
+
+	if cnt := txItemsCount(t); cnt != 0 {
+		t.Fatalf("Expected no items after rollback, but got %d", cnt)
+	}
+
+	if err := tx.Upsert(&TestItemSimple{ID: 100}); err != reindexer.ErrTxDone {
+		t.Fatalf("Expected ErrTxDone on upsert after rollback, but got: %v", err)
+	}
+	if err := tx.Commit(nil); err != reindexer.ErrTxDone {
+		t.Fatalf("Expected ErrTxDone on commit after rollback, but got: %v", err)
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	tx := DB.MustBeginTx("test_items_tx")
+	item := TestItemSimple{Name: "commit"}
+	for i := 0; i < 10; i++ {
+		// Item is packed on call, so it can be reused
+		item.ID = i
+		item.Year = 2000 + i
+		if err := tx.Upsert(&item); err != nil {
+			panic(err)
+		}
+	}
+	if err := tx.Delete(&TestItemSimple{ID: 9}); err != nil {
+		panic(err)
+	}
+
+	count, err := tx.CommitWithCount(nil)
+	if err != nil {
+		panic(err)
+	}
+	if count != 11 {
+		t.Fatalf("Expected 11 changed items, but got %d", count)
+	}
+
+	items, err := DB.Query("test_items_tx").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 9 {
+		t.Fatalf("Expected 9 items after commit, but got %d", len(items))
+	}
+	for i, item := range items {
+		if item.(*TestItemSimple).ID != i || item.(*TestItemSimple).Year != 2000+i {
+			t.Fatalf("Unexpected item %d after commit: %+v", i, item)
+		}
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Expected rollback after commit to be no-op, but got: %v", err)
+	}
+}
+
+// newTxTestDB opens namespace in memory database, which calls of ModifyItem are passed through intercept
+func newTxTestDB(intercept func(call *bindings.Call) error) *reindexer.Reindexer {
+	interceptor := func(ctx context.Context, call *bindings.Call, invoker bindings.Invoker) (bindings.RawBuffer, error) {
+		if call.Method == bindings.MethodModifyItem {
+			if err := intercept(call); err != nil {
+				return nil, err
+			}
+		}
+		return invoker(ctx)
+	}
+	db := reindexer.NewReindex("memory://", reindexer.WithInterceptors(interceptor))
+	if err := db.OpenNamespace("test_items_tx", reindexer.DefaultNamespaceOptions(), TestItemSimple{}); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func TestTxCommitStateInvalidated(t *testing.T) {
+	// Items are rejected as packed with outdated state, so they are repacked on commit
+	rejected := make(map[string]bool)
+	db := newTxTestDB(func(call *bindings.Call) error {
+		if !rejected[string(call.Item)] {
+			rejected[string(call.Item)] = true
+			return bindings.NewError("state invalidated", bindings.ErrStateInvalidated)
+		}
+		return nil
+	})
+	defer db.Close()
+
+	tx := db.MustBeginTx("test_items_tx")
+	item := TestItemSimple{Name: "invalidated"}
+	for i := 0; i < 10; i++ {
+		item.ID = i
+		item.Year = 2000 + i
+		if err := tx.Upsert(&item); err != nil {
+			panic(err)
+		}
+	}
+	tx.MustCommit(nil)
+
+	items, err := db.Query("test_items_tx").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 10 {
+		t.Fatalf("Expected 10 items after commit, but got %d", len(items))
+	}
+	for i, item := range items {
+		if item.(*TestItemSimple).ID != i || item.(*TestItemSimple).Year != 2000+i {
+			t.Fatalf("Unexpected item %d after commit: %+v", i, item)
+		}
+	}
+}
+
+func TestTxCommitFailure(t *testing.T) {
+	errInjected := errors.New("injected error")
+	calls, failOn := 0, -1
+	db := newTxTestDB(func(call *bindings.Call) error {
+		if calls++; calls == failOn {
+			return errInjected
+		}
+		return nil
+	})
+	defer db.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := db.Upsert("test_items_tx", &TestItemSimple{ID: i, Year: 2000 + i, Name: "before"}); err != nil {
+			panic(err)
+		}
+	}
+
+	tx := db.MustBeginTx("test_items_tx")
+	for i := 3; i < 8; i++ {
+		if err := tx.Upsert(&TestItemSimple{ID: i, Year: 3000 + i, Name: "tx"}); err != nil {
+			panic(err)
+		}
+	}
+	if err := tx.Delete(&TestItemSimple{ID: 0}); err != nil {
+		panic(err)
+	}
+
+	// Fail on deletion, after all upserts are added to transaction
+	calls, failOn = 0, 6
+	if err := tx.Commit(nil); err != errInjected {
+		t.Fatalf("Expected injected error on commit, but got: %v", err)
+	}
+
+	items, err := db.Query("test_items_tx").Sort("id", false).Exec().FetchAll()
+	if err != nil {
+		panic(err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("Expected 5 items after failed commit, but got %d", len(items))
+	}
+	for i, item := range items {
+		if item.(*TestItemSimple).ID != i || item.(*TestItemSimple).Year != 2000+i || item.(*TestItemSimple).Name != "before" {
+			t.Fatalf("Unexpected item %d after failed commit: %+v", i, item)
+		}
+	}
+}
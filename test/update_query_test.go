@@ -0,0 +1,111 @@
+package reindexer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/restream/reindexer"
+)
+
+type TestItemUpdateQuery struct {
+	ID      int      `reindex:"id,,pk"`
+	Year    int      `reindex:"year,tree"`
+	Name    string   `reindex:"name"`
+	Tags    []string `reindex:"tags"`
+	Comment string   `json:"comment,omitempty"`
+	Info    struct {
+		Rate int `json:"rate"`
+	} `json:"info"`
+}
+
+const testUpdateQueryNs = "test_items_update_query"
+
+func init() {
+	tnamespaces[testUpdateQueryNs] = TestItemUpdateQuery{}
+}
+
+func fillTestItemsForUpdateQuery() {
+	tx := newTestTx(DB, testUpdateQueryNs)
+	for i := 0; i < 20; i++ {
+		item := &TestItemUpdateQuery{
+			ID:      i,
+			Year:    2000 + i,
+			Name:    fmt.Sprintf("name%d", i),
+			Tags:    []string{"tag1", "tag2"},
+			Comment: "comment",
+		}
+		item.Info.Rate = i
+		if err := tx.Upsert(item); err != nil {
+			panic(err)
+		}
+	}
+	tx.MustCommit(nil)
+}
+
+func getUpdateQueryItems(t *testing.T) map[int]*TestItemUpdateQuery {
+	items, err := DB.Query(testUpdateQueryNs).Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[int]*TestItemUpdateQuery, len(items))
+	for _, it := range items {
+		item := it.(*TestItemUpdateQuery)
+		res[item.ID] = item
+	}
+	return res
+}
+
+func TestUpdateQuery(t *testing.T) {
+	fillTestItemsForUpdateQuery()
+
+	cnt, err := DB.Query(testUpdateQueryNs).Where("year", reindexer.GE, 2010).
+		Set("name", "updated").Set("info.rate", 100).ArrayAppend("tags", []string{"tag3", "tag4"}).Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 10 {
+		t.Fatalf("Expected 10 updated items, but got %d", cnt)
+	}
+
+	cnt, err = DB.Query(testUpdateQueryNs).Where("id", reindexer.LT, 5).
+		ArrayRemove("tags", "tag1").Drop("comment").Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 5 {
+		t.Fatalf("Expected 5 updated items, but got %d", cnt)
+	}
+
+	items := getUpdateQueryItems(t)
+	if len(items) != 20 {
+		t.Fatalf("Expected 20 items, but got %d", len(items))
+	}
+	for id, item := range items {
+		name, rate, tags, comment := fmt.Sprintf("name%d", id), id, "[tag1 tag2]", "comment"
+		if id >= 10 {
+			name, rate, tags = "updated", 100, "[tag1 tag2 tag3 tag4]"
+		}
+		if id < 5 {
+			tags, comment = "[tag2]", ""
+		}
+		if item.Name != name || item.Info.Rate != rate || fmt.Sprint(item.Tags) != tags || item.Comment != comment {
+			t.Fatalf("Unexpected item after update: %+v", *item)
+		}
+	}
+
+	// Indexes must be updated too
+	res, err := DB.Query(testUpdateQueryNs).Where("name", reindexer.EQ, "updated").Where("tags", reindexer.EQ, "tag4").Exec().FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 10 {
+		t.Fatalf("Expected 10 items found by updated indexes, but got %d", len(res))
+	}
+
+	if _, err = DB.Query(testUpdateQueryNs).Where("id", reindexer.EQ, 1).Set("id", 1000).Update(); err == nil {
+		t.Fatalf("Expected error on update of primary key")
+	}
+	if _, found := DB.Query(testUpdateQueryNs).Where("id", reindexer.EQ, 1).Get(); !found {
+		t.Fatalf("Item must not be changed by failed update")
+	}
+}
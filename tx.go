@@ -1,25 +1,51 @@
 package reindexer
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"reflect"
 	"time"
+
+	"github.com/restream/reindexer/bindings"
+	"github.com/restream/reindexer/cjson"
 )
 
-// Tx Is pseudo transaction object. Rollback is not implemented yet, and data will always updated
+// Tx Is transaction object. Items are packed and buffered on client side, and sent to reindexer only on Commit.
+// Until Commit changes are not visible to other clients. Rollback discards all buffered changes.
+// Commit sends buffered changes to transaction of reindexer, which applies all of them atomically:
+// either all changes are applied, or none of them. Transactions are supported by builtin, builtinserver and cproto bindings
 type Tx struct {
+	ctx       context.Context
 	txNs      *reindexerNamespace
 	namespace string
 	db        *Reindexer
 	started   bool
+	finished  bool
+	items     []txItem
+}
+
+// txItem is buffered modification of item. JSON of item is kept to repack it, if namespace state was changed,
+// because caller can modify item after it was added to transaction. If item was added as object, it's decoded
+// from JSON to new object of namespace type before repacking, so types of its fields are kept
+type txItem struct {
+	json       []byte
+	data       []byte
+	format     int
+	stateToken int
+	mode       int
+	isObject   bool
 }
 
-func newTx(db *Reindexer, namespace string) (*Tx, error) {
-	tx := &Tx{db: db, namespace: namespace}
+func newTx(ctx context.Context, db *Reindexer, namespace string) (*Tx, error) {
+	tx := &Tx{ctx: ctx, db: db, namespace: namespace}
 
 	return tx, nil
 }
 
 func (tx *Tx) startTx() error {
+	if tx.finished {
+		return ErrTxDone
+	}
 	if tx.started {
 		return nil
 	}
@@ -29,71 +55,158 @@ func (tx *Tx) startTx() error {
 	return err
 }
 
-// Insert (only) item to index
-func (tx *Tx) Insert(s interface{}) (int, error) {
+// addItem packs item and appends it to transaction buffer. Item is packed immediately,
+// so caller can reuse or modify it after call
+func (tx *Tx) addItem(item interface{}, itemJSON []byte, mode int) error {
 	if err := tx.startTx(); err != nil {
+		return err
+	}
+
+	ser := cjson.NewPoolSerializer()
+	defer ser.Close()
+
+	format, stateToken, err := packItem(tx.txNs, item, itemJSON, ser)
+	if err != nil {
+		return err
+	}
+
+	data := append([]byte(nil), ser.Bytes()...)
+	isObject := itemJSON == nil && tx.txNs.rtype != dynamicItemType
+	if format == bindings.FormatJson {
+		itemJSON = data
+	} else if itemJSON, err = json.Marshal(item); err != nil {
+		return err
+	}
+
+	tx.items = append(tx.items, txItem{
+		json:       itemJSON,
+		data:       data,
+		format:     format,
+		stateToken: stateToken,
+		mode:       mode,
+		isObject:   isObject,
+	})
+	return nil
+}
+
+// Insert (only) item to index. Returns 1 if item was added to transaction.
+// Actual count of inserted items will be known after CommitWithCount
+func (tx *Tx) Insert(s interface{}) (int, error) {
+	if err := tx.addItem(s, nil, modeInsert); err != nil {
 		return 0, err
 	}
-	return tx.db.modifyItem(tx.txNs.name, tx.txNs, s, nil, modeInsert)
+	return 1, nil
 }
 
+// Update (only) item in index. Returns 1 if item was added to transaction.
+// Actual count of updated items will be known after CommitWithCount
 func (tx *Tx) Update(s interface{}) (int, error) {
-	if err := tx.startTx(); err != nil {
+	if err := tx.addItem(s, nil, modeUpdate); err != nil {
 		return 0, err
 	}
-	return tx.db.modifyItem(tx.txNs.name, tx.txNs, s, nil, modeUpdate)
+	return 1, nil
 }
 
 // Upsert (Insert or Update) item to index
 func (tx *Tx) Upsert(s interface{}) error {
-	if err := tx.startTx(); err != nil {
-		return err
-	}
-	_, err := tx.db.modifyItem(tx.txNs.name, tx.txNs, s, nil, modeUpsert)
-	return err
+	return tx.addItem(s, nil, modeUpsert)
 }
 
 // UpsertJSON (Insert or Update) item to index
 func (tx *Tx) UpsertJSON(json []byte) error {
-	if err := tx.startTx(); err != nil {
-		return err
-	}
-	_, err := tx.db.modifyItem(tx.txNs.name, tx.txNs, nil, json, modeUpsert)
-	return err
+	return tx.addItem(nil, json, modeUpsert)
 }
 
 // Delete - remove item by id from namespace
 func (tx *Tx) Delete(s interface{}) error {
-	if err := tx.startTx(); err != nil {
-		return err
-	}
-	_, err := tx.db.modifyItem(tx.txNs.name, tx.txNs, s, nil, modeDelete)
-	return err
+	return tx.addItem(s, nil, modeDelete)
 }
 
 // DeleteJSON - remove item by id from namespace
 func (tx *Tx) DeleteJSON(json []byte) error {
-	if err := tx.startTx(); err != nil {
-		return err
-	}
-	_, err := tx.db.modifyItem(tx.txNs.name, tx.txNs, json, nil, modeDelete)
-	return err
+	return tx.addItem(nil, json, modeDelete)
 }
 
 // Commit apply changes
 func (tx *Tx) Commit(updatedAt *time.Time) error {
+	_, err := tx.CommitWithCount(updatedAt)
+	return err
+}
+
+// CommitWithCount apply changes, and return count of changed items.
+// Changes are applied atomically: if some change fails, none of changes is applied, and error is returned
+func (tx *Tx) CommitWithCount(updatedAt *time.Time) (count int, err error) {
+	if tx.finished {
+		return 0, ErrTxDone
+	}
+	tx.finished = true
 	if !tx.started {
-		return nil
+		return 0, nil
 	}
-	if err := tx.commitInternal(); err != nil {
-		return err
+	items := tx.items
+	tx.items = nil
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	binding, ok := tx.db.binding.(bindings.RawBindingTx)
+	if !ok {
+		return 0, bindings.NewError("Transactions are not supported by binding", bindings.ErrLogic)
+	}
+	txID, err := binding.BeginTx(tx.ctx, tx.namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, it := range items {
+		if err = tx.sendItem(txID, it); err != nil {
+			// Commit can fail because of canceled ctx, so transaction is discarded without it
+			binding.RollbackTx(context.Background(), txID)
+			return 0, err
+		}
+	}
+
+	out, err := binding.CommitTx(tx.ctx, txID)
+	if err != nil {
+		return 0, err
+	}
+	rdSer := newSerializer(out.GetBuf())
+	rawQueryParams := rdSer.readRawQueryParams(func(nsid int) {
+		tx.txNs.cjsonState.ReadPayloadType(&rdSer.Serializer)
+	})
+	for i := 0; i < rawQueryParams.count; i++ {
+		tx.txNs.cacheItems.remove(rdSer.readRawtItemParams().id)
+	}
+	out.Free()
+	count = rawQueryParams.count
+
+	if err = tx.commitInternal(); err != nil {
+		return count, err
 	}
 	if updatedAt == nil {
 		now := time.Now().UTC()
 		updatedAt = &now
 	}
-	tx.db.setUpdatedAt(tx.txNs, *updatedAt)
+	tx.db.setUpdatedAt(tx.ctx, tx.txNs, *updatedAt)
+
+	return count, nil
+}
 
+// sendItem adds item to transaction of reindexer. If namespace state was changed since item was packed,
+// item is repacked with actual state
+func (tx *Tx) sendItem(txID int, it txItem) error {
+	out, err := tx.db.binding.ModifyItem(tx.ctx, tx.txNs.nsHash, tx.namespace, it.format, it.data, it.mode, nil, it.stateToken, txID)
+	if isStateInvalidated(err) {
+		tx.db.Query(tx.namespace).Limit(0).ExecCtx(tx.ctx).Close()
+		if it, err = tx.repackItem(it); err != nil {
+			return err
+		}
+		out, err = tx.db.binding.ModifyItem(tx.ctx, tx.txNs.nsHash, tx.namespace, it.format, it.data, it.mode, nil, it.stateToken, txID)
+	}
+	if err != nil {
+		return err
+	}
+	out.Free()
 	return nil
 }
 
@@ -106,11 +219,37 @@ func (tx *Tx) MustCommit(updatedAt *time.Time) {
 
 // Commit apply changes
 func (tx *Tx) commitInternal() error {
-	return tx.db.binding.Commit(tx.namespace)
+	return tx.db.binding.Commit(tx.ctx, tx.namespace)
 }
 
-// Rollback update
+// repackItem packs transaction item with actual state of namespace
+func (tx *Tx) repackItem(it txItem) (txItem, error) {
+	var item interface{}
+	itemJSON := it.json
+	if it.isObject {
+		item = reflect.New(tx.txNs.rtype).Interface()
+		if err := json.Unmarshal(it.json, item); err != nil {
+			return it, err
+		}
+		itemJSON = nil
+	}
+
+	ser := cjson.NewPoolSerializer()
+	defer ser.Close()
+	var err error
+	if it.format, it.stateToken, err = packItem(tx.txNs, item, itemJSON, ser); err != nil {
+		return it, err
+	}
+	it.data = append([]byte(nil), ser.Bytes()...)
+	return it, nil
+}
+
+// Rollback discards all buffered changes. Rollback after Commit is no-op
 func (tx *Tx) Rollback() error {
-	fmt.Printf("Sorry tx.rollback is not implemented properly yet.")
-	return tx.commitInternal()
+	if tx.finished {
+		return nil
+	}
+	tx.finished = true
+	tx.items = nil
+	return nil
 }